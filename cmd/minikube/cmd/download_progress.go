@@ -0,0 +1,151 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/cheggaaa/pb.v1"
+	"k8s.io/minikube/pkg/minikube/bootstrapper/kubeadm"
+	"k8s.io/minikube/pkg/minikube/machine"
+)
+
+// downloadProgressRefresh limits how often the progress line is redrawn.
+const downloadProgressRefresh = 200 * time.Millisecond
+
+// downloadProgressPrinter renders the progress of concurrent binary
+// downloads on a single line that's redrawn as they progress. It's also the
+// writer for status messages about the downloads, which are printed above
+// the progress line so the two don't garble each other.
+type downloadProgressPrinter struct {
+	w io.Writer
+
+	mu       sync.Mutex
+	binaries []string
+	progress map[string]kubeadm.DownloadProgress
+	drawn    time.Time
+	width    int
+}
+
+func newDownloadProgressPrinter(w io.Writer) *downloadProgressPrinter {
+	return &downloadProgressPrinter{w: w, progress: map[string]kubeadm.DownloadProgress{}}
+}
+
+// Report records the progress of a download, and redraws the progress line.
+func (p *downloadProgressPrinter) Report(dp kubeadm.DownloadProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.progress[dp.Binary]; !ok {
+		p.binaries = append(p.binaries, dp.Binary)
+	}
+	p.progress[dp.Binary] = dp
+	if !dp.Finished && time.Since(p.drawn) < downloadProgressRefresh {
+		return
+	}
+	p.draw()
+
+	for _, dp := range p.progress {
+		if !dp.Finished {
+			return
+		}
+	}
+	// All of the downloads are done, so the line is left as it is and the
+	// next download starts a new one.
+	fmt.Fprintln(p.w)
+	p.binaries = nil
+	p.progress = map[string]kubeadm.DownloadProgress{}
+	p.width = 0
+}
+
+// Write prints a status message above the progress line.
+func (p *downloadProgressPrinter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.width > 0 {
+		fmt.Fprintf(p.w, "\r%s\r", strings.Repeat(" ", p.width))
+	}
+	n, err := p.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	if p.width > 0 {
+		p.width = 0
+		p.draw()
+	}
+	return n, nil
+}
+
+// ReportImage prints a status message as each cached image is loaded.
+// Images that fail to load are warned about once they've all finished.
+func (p *downloadProgressPrinter) ReportImage(ip machine.ImageLoadProgress) {
+	if !ip.Finished || ip.Err != nil {
+		return
+	}
+	size := ""
+	if ip.Size >= 0 {
+		size = fmt.Sprintf(" (%s)", pb.Format(ip.Size).To(pb.U_BYTES))
+	}
+	took := ""
+	if ip.Duration > 0 {
+		took = " in " + ip.Duration.Round(10*time.Millisecond).String()
+	}
+	if len(ip.Bundled) > 0 {
+		fmt.Fprintf(p, "Loaded %d cached images from bundle %s%s%s\n", len(ip.Bundled), ip.Image, size, took)
+		return
+	}
+	repaired := ""
+	if ip.Repaired {
+		repaired = ", repaired after it loaded corrupted"
+	}
+	fmt.Fprintf(p, "Loaded cached image %s%s%s%s\n", ip.Image, size, took, repaired)
+}
+
+func (p *downloadProgressPrinter) draw() {
+	line := p.line()
+	// Pad over the rest of a longer previous line.
+	padding := ""
+	if len(line) < p.width {
+		padding = strings.Repeat(" ", p.width-len(line))
+	}
+	fmt.Fprintf(p.w, "\r%s%s", line, padding)
+	p.width = len(line)
+	p.drawn = time.Now()
+}
+
+// line returns the progress line, e.g.
+// "Downloading kubelet v1.8.0: 12.50 MB / 130.00 MB (9%), kubeadm v1.8.0: done".
+func (p *downloadProgressPrinter) line() string {
+	var parts []string
+	for _, b := range p.binaries {
+		dp := p.progress[b]
+		status := pb.Format(dp.Done).To(pb.U_BYTES).String()
+		switch {
+		case dp.Finished:
+			status = "done"
+		case dp.Total > 0:
+			status = fmt.Sprintf("%s / %s (%d%%)", status, pb.Format(dp.Total).To(pb.U_BYTES), dp.Done*100/dp.Total)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s: %s", dp.Binary, dp.Version, status))
+	}
+	return "Downloading " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper/kubeadm"
+	"k8s.io/minikube/pkg/minikube/machine"
+)
+
+func TestDownloadProgressPrinter(t *testing.T) {
+	var b bytes.Buffer
+	p := newDownloadProgressPrinter(&b)
+
+	p.Report(kubeadm.DownloadProgress{Binary: "kubelet", Version: "v1.8.0", Done: 0, Total: 2048})
+	p.Report(kubeadm.DownloadProgress{Binary: "kubeadm", Version: "v1.8.0", Done: 0, Total: -1})
+	fmt.Fprintln(p, "Download of kubeadm failed, retrying")
+	p.Report(kubeadm.DownloadProgress{Binary: "kubeadm", Version: "v1.8.0", Done: 1024, Total: 1024, Finished: true})
+	p.Report(kubeadm.DownloadProgress{Binary: "kubelet", Version: "v1.8.0", Done: 2048, Total: 2048, Finished: true})
+
+	out := b.String()
+	if !strings.Contains(out, "\rDownload of kubeadm failed, retrying\n\rDownloading") {
+		t.Errorf("Expected the status message to replace the progress line, which is redrawn below it, got %q", out)
+	}
+	lines := strings.Split(out, "\r")
+	expected := "Downloading kubelet v1.8.0: done, kubeadm v1.8.0: done\n"
+	if last := strings.TrimRight(lines[len(lines)-1], " \n") + "\n"; last != expected {
+		t.Errorf("Expected final progress line %q, got %q", expected, last)
+	}
+	if !strings.HasSuffix(out, "\n") || strings.Count(out, "\n") != 2 {
+		t.Errorf("Expected the progress line to end once every download finished, got %q", out)
+	}
+}
+
+func TestDownloadProgressPrinterImages(t *testing.T) {
+	var b bytes.Buffer
+	p := newDownloadProgressPrinter(&b)
+
+	p.ReportImage(machine.ImageLoadProgress{Image: "gcr.io/google_containers/pause-amd64:3.0", Size: 2048})
+	p.ReportImage(machine.ImageLoadProgress{Image: "gcr.io/google_containers/pause-amd64:3.0", Size: 2048, Finished: true})
+	p.ReportImage(machine.ImageLoadProgress{Image: "gcr.io/google_containers/etcd-amd64:3.0.17", Size: -1, Finished: true, Err: fmt.Errorf("invalid tar header")})
+	p.ReportImage(machine.ImageLoadProgress{Image: "v1.10.0-0123456789ab.tar", Size: 4096, Finished: true, Duration: 1234 * time.Millisecond, Bundled: []string{"a", "b"}})
+
+	expected := "Loaded cached image gcr.io/google_containers/pause-amd64:3.0 (2.00 KB)\n" +
+		"Loaded 2 cached images from bundle v1.10.0-0123456789ab.tar (4.00 KB) in 1.23s\n"
+	if out := b.String(); out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestDownloadProgressLine(t *testing.T) {
+	p := newDownloadProgressPrinter(&bytes.Buffer{})
+	p.binaries = []string{"kubelet", "kubeadm"}
+	p.progress["kubelet"] = kubeadm.DownloadProgress{Binary: "kubelet", Version: "v1.8.0", Done: 512, Total: 2048}
+	p.progress["kubeadm"] = kubeadm.DownloadProgress{Binary: "kubeadm", Version: "v1.8.0", Done: 100, Total: -1}
+
+	expected := "Downloading kubelet v1.8.0: 512 B / 2.00 KB (25%), kubeadm v1.8.0: 100 B"
+	if line := p.line(); line != expected {
+		t.Errorf("Expected %q, got %q", expected, line)
+	}
+}
@@ -17,20 +17,32 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	cmdcfg "k8s.io/minikube/cmd/minikube/cmd/config"
 	cmdUtil "k8s.io/minikube/cmd/util"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/bootstrapper/kubeadm"
 	"k8s.io/minikube/pkg/minikube/machine"
 )
 
 var (
-	follow bool
+	follow       bool
+	bundle       string
+	length       int
+	since        time.Duration
+	component    string
+	previousBoot bool
 )
 
 // logsCmd represents the logs command
@@ -50,16 +62,85 @@ var logsCmd = &cobra.Command{
 			glog.Exitf("Error getting cluster bootstrapper: %s", err)
 		}
 
-		s, err := clusterBootstrapper.GetClusterLogs(follow)
+		if bundle != "" {
+			writeLogsBundle(clusterBootstrapper, bundle)
+			return
+		}
+
+		// Interrupting minikube logs -f stops following the logs, killing
+		// the command on the node rather than leaving it running there.
+		ctx, cancel := context.WithCancel(context.Background())
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			select {
+			case <-sigs:
+				signal.Stop(sigs)
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		s, err := getClusterLogs(ctx, clusterBootstrapper, bootstrapper.LogOptions{
+			Follow:       follow,
+			Tail:         length,
+			Since:        since,
+			Component:    component,
+			PreviousBoot: previousBoot,
+			Writer:       os.Stdout,
+		})
+		signal.Stop(sigs)
+		cancel()
+		if follow && errors.Cause(err) == context.Canceled {
+			return
+		}
 		if err != nil {
 			log.Println("Error getting machine logs:", err)
 			cmdUtil.MaybeReportErrorAndExit(err)
 		}
+		if problems := bootstrapper.AnalyzeLogs(s); len(problems) > 0 {
+			fmt.Fprintln(os.Stdout, "Known problems were found in the logs:")
+			for _, p := range problems {
+				fmt.Fprintf(os.Stdout, "  * %s\n    %s\n    Log: %s\n", p.Name, p.Explanation, p.Line)
+			}
+			fmt.Fprintln(os.Stdout)
+		}
 		fmt.Fprintln(os.Stdout, s)
 	},
 }
 
+// getClusterLogs gets the cluster's logs with b. Cancelling ctx stops the
+// kubeadm bootstrapper following them.
+func getClusterLogs(ctx context.Context, b bootstrapper.Bootstrapper, opts bootstrapper.LogOptions) (string, error) {
+	kb, ok := b.(*kubeadm.KubeadmBootstrapper)
+	if !ok {
+		return b.GetClusterLogs(opts)
+	}
+	return kb.GetClusterLogsContext(ctx, opts)
+}
+
+func writeLogsBundle(b bootstrapper.Bootstrapper, path string) {
+	kb, ok := b.(*kubeadm.KubeadmBootstrapper)
+	if !ok {
+		glog.Exitf("--bundle is only supported by the %s bootstrapper", bootstrapper.BootstrapperTypeKubeadm)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		glog.Exitf("Error creating bundle file: %s", err)
+	}
+	defer f.Close()
+	if err := kb.ExportSupportBundle(f); err != nil {
+		log.Println("Error writing logs bundle:", err)
+		cmdUtil.MaybeReportErrorAndExit(err)
+	}
+	fmt.Printf("Wrote logs bundle to %s\n", path)
+}
+
 func init() {
 	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Show only the most recent journal entries, and continuously print new entries as they are appended to the journal.")
+	logsCmd.Flags().IntVarP(&length, "length", "n", 0, "Number of lines back to go within each log source. 0 means all lines.")
+	logsCmd.Flags().DurationVar(&since, "since", 0, "Only show entries newer than this duration ago, e.g. 10m. 0 means all entries.")
+	logsCmd.Flags().StringVar(&component, "component", "", "Show the logs of a single component instead of the kubelet, e.g. kube-apiserver, proxy or dns. (kubeadm only)")
+	logsCmd.Flags().BoolVar(&previousBoot, "previous-boot", false, "Show the logs from the VM's previous boot. Requires journald to persist logs. (kubeadm only)")
+	logsCmd.Flags().StringVar(&bundle, "bundle", "", "Write a gzipped tarball of the logs, configuration and status needed for a bug report to this file. Secrets are redacted.")
 	RootCmd.AddCommand(logsCmd)
 }
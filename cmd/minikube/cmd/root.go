@@ -176,10 +176,14 @@ func GetClusterBootstrapper(api libmachine.API, bootstrapperName string) (bootst
 			return nil, errors.Wrap(err, "getting localkube bootstrapper")
 		}
 	case bootstrapper.BootstrapperTypeKubeadm:
-		b, err = kubeadm.NewKubeadmBootstrapper(api)
+		kb, err := kubeadm.NewKubeadmBootstrapper(api)
 		if err != nil {
 			return nil, errors.Wrap(err, "getting kubeadm bootstrapper")
 		}
+		p := newDownloadProgressPrinter(os.Stdout)
+		kb.SetDownloadProgress(p, p.Report)
+		kb.SetImageLoadProgress(p.ReportImage)
+		b = kb
 	default:
 		return nil, fmt.Errorf("Unknown bootstrapper: %s", bootstrapperName)
 	}
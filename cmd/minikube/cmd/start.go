@@ -17,24 +17,29 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/blang/semver"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/ghodss/yaml"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	cmdcfg "k8s.io/minikube/cmd/minikube/cmd/config"
 	cmdUtil "k8s.io/minikube/cmd/util"
 	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/bootstrapper/kubeadm"
 	"k8s.io/minikube/pkg/minikube/cluster"
 	cfg "k8s.io/minikube/pkg/minikube/config"
 	"k8s.io/minikube/pkg/minikube/constants"
@@ -62,11 +67,45 @@ const (
 	keepContext           = "keep-context"
 	createMount           = "mount"
 	featureGates          = "feature-gates"
+	kubeletFeatureGates   = "kubelet-feature-gates"
 	apiServerName         = "apiserver-name"
 	dnsDomain             = "dns-domain"
 	mountString           = "mount-string"
 	disableDriverMounts   = "disable-driver-mounts"
 	cacheImages           = "cache-images"
+	requireCachedImages   = "require-cached-images"
+	noCacheImages         = "no-cache-images"
+	forceReload           = "force-reload"
+	cgroupDriver          = "cgroup-driver"
+	imageRepository       = "image-repository"
+	pauseImage            = "pause-image"
+	imageCacheDir         = "image-cache-dir"
+	bundleCachedImages    = "bundle-cached-images"
+	bootstrapToken        = "bootstrap-token"
+	bootstrapTokenTTL     = "bootstrap-token-ttl"
+	controlPlaneTimeout   = "control-plane-timeout"
+	signingDuration       = "cluster-signing-duration"
+	nodePortRange         = "service-node-port-range"
+	kubeletConfig         = "kubelet-config"
+	cni                   = "cni"
+	podCIDR               = "pod-cidr"
+	releaseMirror         = "kubernetes-release-mirror"
+	skipAddons            = "skip-addons"
+	downloadProxy         = "download-proxy"
+	downloadTimeout       = "download-timeout"
+	downloadStallBytes    = "download-stall-bytes"
+	downloadStallPeriod   = "download-stall-period"
+	offline               = "offline"
+	forceVerify           = "force-verify"
+	cacheKubectl          = "cache-kubectl"
+	installKubectl        = "install-kubectl"
+	restartRuntime        = "restart-container-runtime"
+	binaryDownload        = "binary-download"
+	serviceCIDR           = "service-cidr"
+	nodeIP                = "node-ip"
+	containerLogMaxSize   = "container-log-max-size"
+	containerLogMaxFiles  = "container-log-max-files"
+	journaldMaxUse        = "journald-max-use"
 )
 
 var (
@@ -75,6 +114,13 @@ var (
 	dockerOpt        []string
 	insecureRegistry []string
 	extraOptions     util.ExtraOptionSlice
+	manifests        []string
+	staticPods       []string
+	hostAliases      []string
+	registryCreds    []string
+	binaryOverrides  []string
+	sysctls          []string
+	requests         []string
 )
 
 // startCmd represents the start command
@@ -91,8 +137,41 @@ func runStart(cmd *cobra.Command, args []string) {
 	k8sVersion := viper.GetString(kubernetesVersion)
 	clusterBootstrapper := viper.GetString(cmdcfg.Bootstrapper)
 
+	cacheDir, err := getImageCacheDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error with the image cache directory: %s\n", err)
+		os.Exit(1)
+	}
 	if shouldCacheImages {
-		go machine.CacheImagesForBootstrapper(k8sVersion, clusterBootstrapper)
+		dir := cacheDir
+		if dir == "" {
+			dir = constants.ImageCacheDir
+		}
+		go func() {
+			if err := machine.CacheImagesForBootstrapper(viper.GetString(imageRepository), k8sVersion, clusterBootstrapper, dir); err != nil {
+				glog.Warningf("Error caching images: %v", err)
+				return
+			}
+			if clusterBootstrapper != bootstrapper.BootstrapperTypeKubeadm {
+				return
+			}
+			k8s := bootstrapper.KubernetesConfig{
+				KubernetesVersion: k8sVersion,
+				ImageRepository:   viper.GetString(imageRepository),
+				PauseImage:        viper.GetString(pauseImage),
+			}
+			if k8s.PauseImage != "" {
+				if err := machine.CacheImages([]string{k8s.PauseImage}, dir); err != nil {
+					glog.Warningf("Error caching the pause image: %s", err)
+				}
+			}
+			if viper.GetBool(bundleCachedImages) {
+				images := k8s.GetKubeadmCachedImages()
+				if _, err := machine.BundleCachedImages(images, dir, k8sVersion); err != nil {
+					glog.Warningf("Error bundling cached images: %s", err)
+				}
+			}
+		}()
 	}
 	api, err := machine.NewAPIClient()
 	if err != nil {
@@ -160,6 +239,11 @@ func runStart(cmd *cobra.Command, args []string) {
 		glog.Errorln("Error getting VM IP address: ", err)
 		cmdUtil.MaybeReportErrorAndExit(err)
 	}
+	// On hosts with several network interfaces, the driver may not pick the
+	// one the cluster should use.
+	if viper.GetString(nodeIP) != "" {
+		ip = viper.GetString(nodeIP)
+	}
 
 	selectedKubernetesVersion := viper.GetString(kubernetesVersion)
 
@@ -187,16 +271,98 @@ func runStart(cmd *cobra.Command, args []string) {
 	}
 
 	kubernetesConfig := bootstrapper.KubernetesConfig{
-		KubernetesVersion:      selectedKubernetesVersion,
-		NodeIP:                 ip,
-		NodeName:               cfg.GetMachineName(),
-		APIServerName:          viper.GetString(apiServerName),
-		DNSDomain:              viper.GetString(dnsDomain),
-		FeatureGates:           viper.GetString(featureGates),
-		ContainerRuntime:       viper.GetString(containerRuntime),
-		NetworkPlugin:          viper.GetString(networkPlugin),
-		ExtraOptions:           extraOptions,
-		ShouldLoadCachedImages: shouldCacheImages,
+		KubernetesVersion:       selectedKubernetesVersion,
+		NodeIP:                  ip,
+		NodeName:                cfg.GetMachineName(),
+		APIServerName:           viper.GetString(apiServerName),
+		DNSDomain:               viper.GetString(dnsDomain),
+		FeatureGates:            viper.GetString(featureGates),
+		ContainerRuntime:        viper.GetString(containerRuntime),
+		NetworkPlugin:           viper.GetString(networkPlugin),
+		ExtraOptions:            extraOptions,
+		ReleaseMirror:           viper.GetString(releaseMirror),
+		DownloadProxy:           viper.GetString(downloadProxy),
+		DownloadTimeout:         viper.GetDuration(downloadTimeout),
+		DownloadStallBytes:      viper.GetInt64(downloadStallBytes),
+		DownloadStallPeriod:     viper.GetDuration(downloadStallPeriod),
+		Offline:                 viper.GetBool(offline),
+		ForceVerify:             viper.GetBool(forceVerify),
+		BinaryDownload:          viper.GetString(binaryDownload),
+		ServiceCIDR:             viper.GetString(serviceCIDR),
+		CacheKubectl:            viper.GetBool(cacheKubectl),
+		InstallKubectl:          viper.GetBool(installKubectl),
+		RestartContainerRuntime: viper.GetBool(restartRuntime),
+		Manifests:               manifests,
+		StaticPodManifests:      staticPods,
+		SkipAddons:              viper.GetBool(skipAddons),
+		BootstrapToken:          viper.GetString(bootstrapToken),
+		BootstrapTokenTTL:       viper.GetDuration(bootstrapTokenTTL),
+		ControlPlaneTimeout:     viper.GetDuration(controlPlaneTimeout),
+		ClusterSigningDuration:  viper.GetDuration(signingDuration),
+		ServiceNodePortRange:    viper.GetString(nodePortRange),
+		ShouldLoadCachedImages:  shouldCacheImages,
+		RequireCachedImages:     viper.GetBool(requireCachedImages),
+		NoCacheImages:           viper.GetBool(noCacheImages),
+		ForceReload:             viper.GetBool(forceReload),
+		CgroupDriver:            viper.GetString(cgroupDriver),
+		RegistryMirrors:         registryMirror,
+		ImageRepository:         viper.GetString(imageRepository),
+		PauseImage:              viper.GetString(pauseImage),
+		ImageCacheDir:           cacheDir,
+		BundleCachedImages:      viper.GetBool(bundleCachedImages),
+		ContainerLogMaxSize:     viper.GetString(containerLogMaxSize),
+		ContainerLogMaxFiles:    viper.GetInt(containerLogMaxFiles),
+		JournaldMaxUse:          viper.GetString(journaldMaxUse),
+	}
+
+	aliases, err := parseHostAliases(hostAliases)
+	if err != nil {
+		glog.Exitf("Error parsing host aliases: %s", err)
+	}
+	kubernetesConfig.HostAliases = aliases
+
+	creds, err := parseRegistryCredentials(registryCreds)
+	if err != nil {
+		glog.Exitf("Error parsing registry credentials: %s", err)
+	}
+	kubernetesConfig.RegistryCredentials = creds
+
+	overrides, err := parseBinaryOverrides(binaryOverrides)
+	if err != nil {
+		glog.Exitf("Error parsing binary overrides: %s", err)
+	}
+	kubernetesConfig.BinaryOverrides = overrides
+
+	parsedSysctls, err := parseSysctls(sysctls)
+	if err != nil {
+		glog.Exitf("Error parsing sysctls: %s", err)
+	}
+	kubernetesConfig.Sysctls = parsedSysctls
+
+	controlPlaneRequests, err := parseControlPlaneRequests(requests)
+	if err != nil {
+		glog.Exitf("Error parsing control plane requests: %s", err)
+	}
+	kubernetesConfig.ControlPlaneRequests = controlPlaneRequests
+
+	gates, err := parseKubeletFeatureGates(viper.GetString(kubeletFeatureGates))
+	if err != nil {
+		glog.Exitf("Error parsing kubelet feature gates: %s", err)
+	}
+	kubernetesConfig.KubeletFeatureGates = gates
+
+	kubeletConfiguration, err := readKubeletConfiguration(viper.GetString(kubeletConfig))
+	if err != nil {
+		glog.Exitf("Error reading kubelet configuration: %s", err)
+	}
+	kubernetesConfig.KubeletConfiguration = kubeletConfiguration
+
+	// A CNI plugin needs kubelet to use CNI and a pod CIDR to allocate from.
+	if viper.GetString(cni) != "" {
+		if kubernetesConfig.NetworkPlugin == "" {
+			kubernetesConfig.NetworkPlugin = "cni"
+		}
+		kubernetesConfig.PodCIDR = viper.GetString(podCIDR)
 	}
 
 	k8sBootstrapper, err := GetClusterBootstrapper(api, clusterBootstrapper)
@@ -214,8 +380,25 @@ func runStart(cmd *cobra.Command, args []string) {
 		glog.Errorln("Error saving profile cluster configuration: ", err)
 	}
 
+	// Interrupting the start while the cluster is updated cancels the
+	// downloads in progress, which clean up their partial files. A second
+	// interrupt kills minikube as usual.
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			signal.Stop(sigs)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 	fmt.Println("Moving files into cluster...")
-	if err := k8sBootstrapper.UpdateCluster(kubernetesConfig); err != nil {
+	err = updateCluster(ctx, k8sBootstrapper, kubernetesConfig)
+	signal.Stop(sigs)
+	cancel()
+	if err != nil {
 		glog.Errorln("Error updating cluster: ", err)
 		cmdUtil.MaybeReportErrorAndExit(err)
 	}
@@ -257,7 +440,7 @@ func runStart(cmd *cobra.Command, args []string) {
 	fmt.Println("Starting cluster components...")
 
 	if !exists {
-		if err := k8sBootstrapper.StartCluster(kubernetesConfig); err != nil {
+		if err := startCluster(k8sBootstrapper, kubernetesConfig); err != nil {
 			glog.Errorln("Error starting cluster: ", err)
 			cmdUtil.MaybeReportErrorAndExit(err)
 		}
@@ -268,6 +451,14 @@ func runStart(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if plugin := viper.GetString(cni); plugin != "" {
+		fmt.Printf("Configuring %s networking...\n", plugin)
+		if err := k8sBootstrapper.ConfigureCNI(plugin, kubernetesConfig); err != nil {
+			glog.Errorln("Error configuring CNI: ", err)
+			cmdUtil.MaybeReportErrorAndExit(err)
+		}
+	}
+
 	// start 9p server mount
 	if viper.GetBool(createMount) {
 		fmt.Printf("Setting up hostmount on %s...\n", viper.GetString(mountString))
@@ -302,6 +493,10 @@ func runStart(cmd *cobra.Command, args []string) {
 		fmt.Println("Kubectl is now configured to use the cluster.")
 	}
 
+	if kubernetesConfig.CacheKubectl && clusterBootstrapper == bootstrapper.BootstrapperTypeKubeadm {
+		fmt.Printf("kubectl %s is cached at %s\n", kubernetesConfig.KubernetesVersion, kubeadm.CachedKubectlPath(kubernetesConfig.KubernetesVersion))
+	}
+
 	if config.VMDriver == "none" {
 		fmt.Println(`===================
 WARNING: IT IS RECOMMENDED NOT TO RUN THE NONE DRIVER ON PERSONAL WORKSTATIONS
@@ -362,12 +557,53 @@ func init() {
 	startCmd.Flags().String(apiServerName, constants.APIServerName, "The apiserver name which is used in the generated certificate for localkube/kubernetes.  This can be used if you want to make the apiserver available from outside the machine")
 	startCmd.Flags().String(dnsDomain, constants.ClusterDNSDomain, "The cluster dns domain name used in the kubernetes cluster")
 	startCmd.Flags().StringSliceVar(&insecureRegistry, "insecure-registry", []string{pkgutil.DefaultInsecureRegistry}, "Insecure Docker registries to pass to the Docker daemon")
-	startCmd.Flags().StringSliceVar(&registryMirror, "registry-mirror", nil, "Registry mirrors to pass to the Docker daemon")
+	startCmd.Flags().StringSliceVar(&registryMirror, "registry-mirror", nil, "Registry mirrors to pass to the Docker daemon, or containerd")
 	startCmd.Flags().String(kubernetesVersion, constants.DefaultKubernetesVersion, "The kubernetes version that the minikube VM will use (ex: v1.2.3) \n OR a URI which contains a localkube binary (ex: https://storage.googleapis.com/minikube/k8sReleases/v1.3.0/localkube-linux-amd64)")
 	startCmd.Flags().String(containerRuntime, "", "The container runtime to be used")
 	startCmd.Flags().String(networkPlugin, "", "The name of the network plugin")
 	startCmd.Flags().String(featureGates, "", "A set of key=value pairs that describe feature gates for alpha/experimental features.")
+	startCmd.Flags().String(kubeletFeatureGates, "", "A set of key=value pairs that describe feature gates for kubelet only, e.g. DevicePlugins=true. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(bootstrapToken, "", "A fixed token for nodes to join the cluster with (format: [a-z0-9]{6}.[a-z0-9]{16}). Defaults to a generated token. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&binaryOverrides, "binary-override", nil, "A locally built Kubernetes binary to use on the node instead of the released one, e.g. kubelet=_output/bin/kubelet. Can be repeated. (format: binary=path) (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&sysctls, "sysctl", nil, "A sysctl to set on the node before kubelet starts, e.g. fs.inotify.max_user_watches=524288. Can be repeated. (format: key=value) (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&requests, "control-plane-request", nil, "A resource to request for a control plane component's static pod, e.g. apiserver.cpu=250m or etcd.memory=128Mi, so that workloads can't starve it on small machines. Can be repeated. Valid components are: apiserver, controller-manager, scheduler, etcd. (format: component.resource=quantity) (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&registryCreds, "registry-creds", nil, "Credentials for pulling images from a private registry. Can be repeated. (format: registry=username:password) (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Duration(bootstrapTokenTTL, bootstrapper.DefaultBootstrapTokenTTL, "How long the bootstrap token is valid for. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Duration(signingDuration, 0, "How long the certificates the controller manager signs are valid for, e.g. 8760h in long-lived clusters, as a Go duration. Defaults to kubeadm's default. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(nodePortRange, "", "The range of ports NodePort services may use, e.g. 30000-32767. Defaults to kubeadm's default. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(kubeletConfig, "", "A YAML file with a KubeletConfiguration to configure kubelet with, for Kubernetes v1.13 and later. Flags set on kubelet, including with --extra-config, take precedence over it. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Duration(controlPlaneTimeout, 0, "How long kubeadm init waits for the control plane to come up, e.g. longer on slow disks. Before kubernetes v1.13 it bounds the whole of kubeadm init. Defaults to kubeadm's default. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(cni, "", "The CNI plugin to install for pod networking, one of flannel, calico or weave, or the path to a manifest. Defaults to none. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(podCIDR, constants.DefaultPodCIDR, "The CIDR pod IPs are allocated from when a CNI plugin is installed, or an IPv4 and an IPv6 CIDR, comma separated, for dual-stack. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(nodeIP, "", "The IP the node registers and the apiserver advertises, for hosts with several network interfaces. Defaults to the IP reported by the driver. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(serviceCIDR, "", "The CIDR service IPs are allocated from, or an IPv4 and an IPv6 CIDR, comma separated, for dual-stack. The IPv4 CIDR must contain "+pkgutil.DefaultServiceClusterIP+" and "+pkgutil.DefaultDNSIP+". Defaults to "+pkgutil.DefaultServiceCIDR+". (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(containerLogMaxSize, bootstrapper.DefaultContainerLogMaxSize, "The size a container's log grows to before kubelet rotates it, e.g. 50Mi. Only the logs of remote container runtimes, e.g. containerd, are rotated by kubelet. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Int(containerLogMaxFiles, bootstrapper.DefaultContainerLogMaxFiles, "How many of a container's logs kubelet keeps, including the one being written. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(journaldMaxUse, "", "The most disk space journald's logs, including kubelet's, may use on the node, e.g. 500M. Defaults to journald's default. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(releaseMirror, "", "A mirror of "+constants.DefaultKubernetesReleaseMirror+" to download the kubernetes binaries from, with the same path layout. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&manifests, "manifest", nil, "A manifest file or directory to apply with kubectl once the cluster is up. Can be repeated, manifests are applied in order. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&staticPods, "static-pod", nil, "A pod manifest file for kubelet to run as a static pod, before the control plane is ready. Can be repeated. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(downloadProxy, "", "The proxy to download the kubernetes binaries through, in place of HTTP_PROXY and HTTPS_PROXY. Hosts in NO_PROXY are still reached directly. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Duration(downloadTimeout, bootstrapper.DefaultDownloadTimeout, "How long downloading each kubernetes binary may take, including retries. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Int64(downloadStallBytes, bootstrapper.DefaultDownloadStallBytes, "A download receiving fewer bytes than this in --download-stall-period is stalled, and retried. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Duration(downloadStallPeriod, bootstrapper.DefaultDownloadStallPeriod, "The period in which a download must receive --download-stall-bytes not to be stalled. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().StringArrayVar(&hostAliases, "host-alias", nil, "An entry to add to the node's /etc/hosts. Can be repeated. (format: ip=hostname[,hostname...]) (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(offline, false, "If true, never download the kubernetes binaries, and fail if they aren't cached. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(forceVerify, false, "If true, verify cached kubernetes binaries against their published checksums, rather than the checksums recorded when they were cached. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(restartRuntime, false, "If true, restart the container runtime when restarting an existing cluster, and wait for it to become active. Supports docker, containerd and cri-o. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(installKubectl, true, "If true, install kubectl on the node, which is used to set up the cluster, apply addons and manifests, and read pod logs. If false, the node must have kubectl already. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(cacheKubectl, false, "If true, also cache the kubectl for this host matching the kubernetes version. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(binaryDownload, bootstrapper.BinaryDownloadAuto, "Where to download the kubernetes binaries: on the node, on the host, or auto to download them on the node unless they're cached on the host, falling back to the host. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(skipAddons, false, "If true, don't install any addons, for a bare control plane. (only supported with kubeadm bootstrapper)")
 	startCmd.Flags().Bool(cacheImages, true, "If true, cache docker images for the current bootstrapper and load them into the machine.")
+	startCmd.Flags().Bool(requireCachedImages, false, "If true, fail to start when cached images can't be loaded into the machine, rather than pulling them, e.g. on air-gapped machines. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(imageRepository, "", "The registry to pull the control plane images from, e.g. a mirror, instead of kubeadm's default. Cached images are pulled from it too. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(pauseImage, "", "The pause image each pod's sandbox runs, e.g. one preloaded on an offline machine. Defaults to the one kubeadm uses for the kubernetes version, from the image repository. It's cached and loaded like the other images. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(bundleCachedImages, false, "If true, bundle the cached images into a single archive, which is loaded into a new machine in one go, rather than one by one. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(imageCacheDir, "", "The host directory to cache images in, e.g. on a larger disk, instead of ~/.minikube/cache/images. It's created if needed, and remembered by the profile for later starts. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().String(cgroupDriver, "", "The cgroup driver of kubelet and containerd, cgroupfs or systemd. Defaults to cgroupfs. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(forceReload, false, "If true, load cached images into the machine even if it already has them, e.g. to replace corrupted ones. (only supported with kubeadm bootstrapper)")
+	startCmd.Flags().Bool(noCacheImages, false, "If true, don't load cached images into the machine for this start, so that they're pulled from the registry instead. The cache itself is kept.")
 	startCmd.Flags().Var(&extraOptions, "extra-config",
 		`A set of key=value pairs that describe configuration that may be passed to different components.
 		The key should be '.' separated, and the first part before the dot is the component to apply the configuration to.
@@ -376,6 +612,135 @@ func init() {
 	RootCmd.AddCommand(startCmd)
 }
 
+// parseHostAliases parses --host-alias values of the form
+// ip=hostname[,hostname...].
+func parseHostAliases(values []string) ([]bootstrapper.HostAlias, error) {
+	var aliases []bootstrapper.HostAlias
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid host alias %q, expected ip=hostname[,hostname...]", v)
+		}
+		aliases = append(aliases, bootstrapper.HostAlias{IP: parts[0], Hostnames: strings.Split(parts[1], ",")})
+	}
+	return aliases, nil
+}
+
+// parseBinaryOverrides parses --binary-override values of the form
+// binary=path, making the paths absolute so that they don't depend on the
+// working directory when the profile is started again.
+func parseBinaryOverrides(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	overrides := map[string]string{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid binary override %q, expected binary=path", v)
+		}
+		path, err := filepath.Abs(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("resolving binary override %s: %s", parts[1], err)
+		}
+		overrides[parts[0]] = path
+	}
+	return overrides, nil
+}
+
+// parseSysctls parses --sysctl values of the form key=value.
+func parseSysctls(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	sysctls := map[string]string{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid sysctl %q, expected key=value", v)
+		}
+		sysctls[parts[0]] = parts[1]
+	}
+	return sysctls, nil
+}
+
+// parseControlPlaneRequests parses --control-plane-request values of the
+// form component.resource=quantity, where resource is cpu or memory.
+func parseControlPlaneRequests(values []string) (map[string]bootstrapper.ResourceRequests, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	requests := map[string]bootstrapper.ResourceRequests{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		keys := strings.SplitN(parts[0], ".", 2)
+		if len(parts) != 2 || parts[1] == "" || len(keys) != 2 {
+			return nil, fmt.Errorf("invalid control plane request %q, expected component.resource=quantity", v)
+		}
+		r := requests[keys[0]]
+		switch keys[1] {
+		case "cpu":
+			r.CPU = parts[1]
+		case "memory":
+			r.Memory = parts[1]
+		default:
+			return nil, fmt.Errorf("invalid control plane request %q, resource must be cpu or memory", v)
+		}
+		requests[keys[0]] = r
+	}
+	return requests, nil
+}
+
+// parseKubeletFeatureGates parses --kubelet-feature-gates, a comma-separated
+// list of name=value pairs.
+func parseKubeletFeatureGates(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	gates := map[string]string{}
+	for _, g := range strings.Split(value, ",") {
+		parts := strings.SplitN(g, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid kubelet feature gate %q, expected name=value", g)
+		}
+		gates[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return gates, nil
+}
+
+// readKubeletConfiguration reads the KubeletConfiguration in the YAML file
+// at path, from --kubelet-config.
+func readKubeletConfiguration(path string) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// parseRegistryCredentials parses --registry-creds values of the form
+// registry=username:password. Errors don't include the values, which hold
+// passwords.
+func parseRegistryCredentials(values []string) ([]bootstrapper.RegistryCredential, error) {
+	var creds []bootstrapper.RegistryCredential
+	for i, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || !strings.Contains(parts[1], ":") {
+			return nil, fmt.Errorf("invalid registry credentials #%d, expected registry=username:password", i+1)
+		}
+		userPass := strings.SplitN(parts[1], ":", 2)
+		creds = append(creds, bootstrapper.RegistryCredential{Server: parts[0], Username: userPass[0], Password: userPass[1]})
+	}
+	return creds, nil
+}
+
 // saveConfig saves profile cluster configuration in
 // $MINIKUBE_HOME/profiles/<profilename>/config.json
 func saveConfig(clusterConfig cluster.Config) error {
@@ -426,6 +791,45 @@ func saveConfigToFile(data []byte, file string) error {
 	return nil
 }
 
+// getImageCacheDir returns the image cache directory given with
+// --image-cache-dir, else the one the profile was last started with, so
+// that restarts use the same cache, else "" for the default. It's expanded
+// and checked to be writable before any image is cached in it.
+func getImageCacheDir() (string, error) {
+	dir := viper.GetString(imageCacheDir)
+	if dir == "" {
+		cc, err := loadConfigFromFile(viper.GetString(cfg.MachineProfile))
+		if err != nil {
+			return "", nil
+		}
+		dir = cc.KubernetesConfig.ImageCacheDir
+	}
+	if dir == "" {
+		return "", nil
+	}
+	return machine.ValidateImageCacheDir(dir)
+}
+
+// updateCluster updates the cluster with b. Cancelling ctx aborts the
+// kubeadm bootstrapper's downloads.
+func updateCluster(ctx context.Context, b bootstrapper.Bootstrapper, k8s bootstrapper.KubernetesConfig) error {
+	kb, ok := b.(*kubeadm.KubeadmBootstrapper)
+	if !ok {
+		return b.UpdateCluster(k8s)
+	}
+	return kb.UpdateClusterContext(ctx, k8s)
+}
+
+// startCluster starts the cluster with b. With -v 1 or more, kubeadm init's
+// output is streamed as it runs, so that a slow start's progress is visible.
+func startCluster(b bootstrapper.Bootstrapper, k8s bootstrapper.KubernetesConfig) error {
+	kb, ok := b.(*kubeadm.KubeadmBootstrapper)
+	if !ok || !bool(glog.V(1)) {
+		return b.StartCluster(k8s)
+	}
+	return kb.StartClusterWithOptions(context.Background(), k8s, kubeadm.StartOptions{Output: os.Stdout})
+}
+
 func loadConfigFromFile(profile string) (cluster.Config, error) {
 	var cc cluster.Config
 
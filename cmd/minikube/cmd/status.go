@@ -19,6 +19,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/state"
@@ -27,6 +28,8 @@ import (
 	"github.com/spf13/viper"
 	cmdcfg "k8s.io/minikube/cmd/minikube/cmd/config"
 	cmdUtil "k8s.io/minikube/cmd/util"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/bootstrapper/kubeadm"
 	"k8s.io/minikube/pkg/minikube/cluster"
 	"k8s.io/minikube/pkg/minikube/config"
 	"k8s.io/minikube/pkg/minikube/constants"
@@ -40,6 +43,12 @@ type Status struct {
 	MinikubeStatus   string
 	ClusterStatus    string
 	KubeconfigStatus string
+	// Binaries is set when the cluster runs custom Kubernetes binaries
+	// instead of released ones, naming them.
+	Binaries string
+	// Certs is set when the cluster's certificates have expired or will
+	// soon, naming them.
+	Certs string
 }
 
 // statusCmd represents the status command
@@ -63,6 +72,7 @@ var statusCmd = &cobra.Command{
 
 		cs := state.None.String()
 		ks := state.None.String()
+		certs := ""
 		if ms == state.Running.String() {
 			clusterBootstrapper, err := GetClusterBootstrapper(api, viper.GetString(cmdcfg.Bootstrapper))
 			if err != nil {
@@ -74,6 +84,7 @@ var statusCmd = &cobra.Command{
 				glog.Errorln("Error cluster status:", err)
 				cmdUtil.MaybeReportErrorAndExit(err)
 			}
+			certs = certExpiryStatus(clusterBootstrapper, viper.GetString(config.MachineProfile))
 			ip, err := cluster.GetHostDriverIP(api)
 			if err != nil {
 				glog.Errorln("Error host driver ip status:", err)
@@ -92,7 +103,7 @@ var statusCmd = &cobra.Command{
 			}
 		}
 
-		status := Status{ms, cs, ks}
+		status := Status{ms, cs, ks, customBinariesStatus(viper.GetString(config.MachineProfile)), certs}
 
 		tmpl, err := template.New("status").Parse(statusFormat)
 		if err != nil {
@@ -107,6 +118,47 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+// customBinariesStatus describes the custom Kubernetes binaries in profile's
+// config, or returns "" if it uses released binaries.
+func customBinariesStatus(profile string) string {
+	cc, err := loadConfigFromFile(profile)
+	if err != nil {
+		glog.Infof("Error loading profile config: %s", err)
+		return ""
+	}
+	custom := cc.KubernetesConfig.CustomBinaries()
+	if len(custom) == 0 {
+		return ""
+	}
+	return "custom binaries (" + strings.Join(custom, ", ") + ")"
+}
+
+// certExpiryStatus describes the cluster's certificates which have expired
+// or will soon, or returns "" if there are none, or they can't be checked.
+func certExpiryStatus(b bootstrapper.Bootstrapper, profile string) string {
+	kb, ok := b.(*kubeadm.KubeadmBootstrapper)
+	if !ok {
+		return ""
+	}
+	cc, err := loadConfigFromFile(profile)
+	if err != nil {
+		glog.Infof("Error loading profile config: %s", err)
+		return ""
+	}
+	certs, err := kb.CheckCertExpiry(cc.KubernetesConfig)
+	if err != nil {
+		glog.Infof("Error checking certificate expiry: %s", err)
+		return ""
+	}
+	var expiring []string
+	for _, c := range certs {
+		if c.ExpiringSoon {
+			expiring = append(expiring, fmt.Sprintf("%s expires %s", c.Name, c.Expires.Format("2006-01-02")))
+		}
+	}
+	return strings.Join(expiring, ", ")
+}
+
 func init() {
 	statusCmd.Flags().StringVar(&statusFormat, "format", constants.DefaultStatusFormat,
 		`Go template format string for the status output.  The format for Go templates can be found here: https://golang.org/pkg/text/template/
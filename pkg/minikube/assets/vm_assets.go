@@ -18,6 +18,7 @@ package assets
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -32,6 +33,9 @@ type CopyableFile interface {
 	GetTargetDir() string
 	GetTargetName() string
 	GetPermissions() string
+	// GetOwner returns the uid:gid the file is owned by once copied, or the
+	// empty string to leave it owned by root.
+	GetOwner() string
 }
 
 type BaseAsset struct {
@@ -42,6 +46,9 @@ type BaseAsset struct {
 	TargetDir   string
 	TargetName  string
 	Permissions string
+	// Owner is the uid:gid the file is owned by once copied. Empty means
+	// root.
+	Owner string
 }
 
 func (b *BaseAsset) GetAssetName() string {
@@ -60,6 +67,15 @@ func (b *BaseAsset) GetPermissions() string {
 	return b.Permissions
 }
 
+func (b *BaseAsset) GetOwner() string {
+	return b.Owner
+}
+
+// SetOwner sets the uid and gid the file is owned by once copied.
+func (b *BaseAsset) SetOwner(uid, gid int) {
+	b.Owner = fmt.Sprintf("%d:%d", uid, gid)
+}
+
 type FileAsset struct {
 	BaseAsset
 }
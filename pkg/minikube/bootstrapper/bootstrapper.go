@@ -17,18 +17,84 @@ limitations under the License.
 package bootstrapper
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
 	"k8s.io/minikube/pkg/minikube/constants"
 	"k8s.io/minikube/pkg/util"
 )
 
+// DefaultBootstrapTokenTTL is kubeadm's default bootstrap token TTL.
+const DefaultBootstrapTokenTTL = 24 * time.Hour
+
+// Defaults for KubernetesConfig's download limits. A download that's
+// stalled is retried, and one that times out isn't.
+const (
+	DefaultDownloadTimeout     = 30 * time.Minute
+	DefaultDownloadStallBytes  = 64 * 1024
+	DefaultDownloadStallPeriod = 30 * time.Second
+)
+
+// Defaults for KubernetesConfig's container log rotation, which are
+// kubelet's own.
+const (
+	DefaultContainerLogMaxSize  = "10Mi"
+	DefaultContainerLogMaxFiles = 5
+)
+
+// Where the node's Kubernetes binaries are downloaded, for
+// KubernetesConfig.BinaryDownload.
+const (
+	// BinaryDownloadAuto downloads the binaries on the node when they aren't
+	// cached on the host, falling back to the host if that fails.
+	BinaryDownloadAuto = "auto"
+	// BinaryDownloadNode always downloads the binaries on the node.
+	BinaryDownloadNode = "node"
+	// BinaryDownloadHost downloads the binaries into the host's cache, and
+	// copies them to the node.
+	BinaryDownloadHost = "host"
+)
+
+// The cgroup drivers kubelet and the container runtime can use, for
+// KubernetesConfig.CgroupDriver.
+const (
+	CgroupDriverCgroupfs = "cgroupfs"
+	CgroupDriverSystemd  = "systemd"
+)
+
+// ContainerRuntimeContainerd is the KubernetesConfig.ContainerRuntime that
+// runs pods with containerd, through its CRI plugin.
+const ContainerRuntimeContainerd = "containerd"
+
+// The apiVersion and kind of KubernetesConfig.KubeletConfiguration.
+const (
+	KubeletConfigurationAPIVersion = "kubelet.config.k8s.io/v1beta1"
+	KubeletConfigurationKind       = "KubeletConfiguration"
+)
+
+// OverridableBinaries are the node's Kubernetes binaries which
+// KubernetesConfig.BinaryOverrides can replace.
+var OverridableBinaries = []string{"kubelet", "kubeadm", "kubectl"}
+
+// ControlPlaneComponents are the static pods whose resources
+// KubernetesConfig.ControlPlaneRequests can set.
+var ControlPlaneComponents = []string{"apiserver", "controller-manager", "scheduler", "etcd"}
+
 // Bootstrapper contains all the methods needed to bootstrap a kubernetes cluster
 type Bootstrapper interface {
 	StartCluster(KubernetesConfig) error
 	UpdateCluster(KubernetesConfig) error
 	RestartCluster(KubernetesConfig) error
-	GetClusterLogs(follow bool) (string, error)
+	GetClusterLogs(opts LogOptions) (string, error)
 	SetupCerts(cfg KubernetesConfig) error
 	GetClusterStatus() (string, error)
+	GetRunningVersion() (string, error)
+	ConfigureCNI(plugin string, k8s KubernetesConfig) error
 }
 
 // KubernetesConfig contains the parameters used to configure the VM Kubernetes.
@@ -43,7 +109,410 @@ type KubernetesConfig struct {
 	FeatureGates      string
 	ExtraOptions      util.ExtraOptionSlice
 
+	// KubeletFeatureGates are passed to kubelet's --feature-gates, and to
+	// no other component, unlike FeatureGates. The values are booleans.
+	KubeletFeatureGates map[string]string
+
+	// CertDir is the absolute path of the certificates directory on the
+	// node. Empty means util.DefaultCertPath.
+	CertDir string
+
+	// KubeProxyMetricsBindAddress is the address kube-proxy serves metrics
+	// on. Empty leaves kube-proxy's default.
+	KubeProxyMetricsBindAddress string
+	// KubeProxyConntrackMaxPerCore is the maximum number of NAT connections
+	// kube-proxy tracks per CPU core. Zero leaves kube-proxy's default.
+	KubeProxyConntrackMaxPerCore int
+	// KubeProxyMode is the proxy mode, e.g. iptables or ipvs. Empty leaves
+	// kube-proxy's default.
+	KubeProxyMode string
+
+	// HostAliases are added to the node's /etc/hosts, so the names resolve
+	// before the cluster's DNS is up.
+	HostAliases []HostAlias
+
+	// Sysctls are set on the node, and persisted across reboots, before
+	// kubelet starts, e.g. net.bridge.bridge-nf-call-iptables=1, which
+	// kubeadm's preflight checks require. localkube ignores them.
+	Sysctls map[string]string
+
+	// RegistryCredentials authenticate the node's image pulls from private
+	// registries, e.g. for addons or manifests using private images.
+	RegistryCredentials []RegistryCredential
+
+	// SkipAddons stops the bundled and custom addons being copied to the
+	// node, for a bare control plane. The kubeadm bootstrapper still has
+	// DNS, which kubeadm installs itself; localkube ignores SkipAddons, as
+	// its DNS is an addon.
+	SkipAddons bool
+
+	// Manifests are files, or directories of files, applied with kubectl
+	// once the cluster is up, in order.
+	Manifests []string
+
+	// StaticPodManifests are files, each a pod, which kubelet runs as static
+	// pods, e.g. a local registry, so that they come up before the control
+	// plane is ready. localkube ignores them.
+	StaticPodManifests []string
+
+	// ControlPlaneRequests maps control plane components, one of
+	// ControlPlaneComponents, to the resources their static pods request,
+	// so that on small machines the scheduler accounts for them instead of
+	// letting workloads starve them. Components without requests keep
+	// kubeadm's. localkube ignores them.
+	ControlPlaneRequests map[string]ResourceRequests
+
+	// ContainerLogMaxSize is the size, as a quantity, e.g. 10Mi, a
+	// container's log grows to before kubelet rotates it, and
+	// ContainerLogMaxFiles is how many of its logs are kept, so that a
+	// cluster left running doesn't fill the node's disk. Only the logs of
+	// remote runtimes, e.g. containerd, are rotated by kubelet; docker
+	// rotates its own with its log-opts. Empty and zero mean
+	// DefaultContainerLogMaxSize and DefaultContainerLogMaxFiles.
+	ContainerLogMaxSize  string
+	ContainerLogMaxFiles int
+
+	// JournaldMaxUse caps the disk space journald's logs, including
+	// kubelet's, use on the node, e.g. 500M. Empty leaves journald's
+	// default. localkube ignores it.
+	JournaldMaxUse string
+
+	// ReleaseMirror is the base URL Kubernetes release binaries are
+	// downloaded from, with the same path layout as
+	// constants.DefaultKubernetesReleaseMirror. Empty means the default.
+	ReleaseMirror string
+
+	// DownloadProxy is the URL of the proxy Kubernetes release binaries are
+	// downloaded through, in place of the HTTP_PROXY and HTTPS_PROXY
+	// environment variables. Empty means the environment's proxy.
+	DownloadProxy string
+
+	// DownloadTimeout bounds each binary's download, including retries.
+	// Zero means DefaultDownloadTimeout.
+	DownloadTimeout time.Duration
+	// A download attempt is abandoned as stalled, and retried, when fewer
+	// than DownloadStallBytes arrive in DownloadStallPeriod. Zero means
+	// DefaultDownloadStallBytes and DefaultDownloadStallPeriod.
+	DownloadStallBytes  int64
+	DownloadStallPeriod time.Duration
+
+	// Offline forbids downloads, so that only cached binaries are used.
+	Offline bool
+
+	// ForceVerify verifies cached binaries against their published
+	// checksums, rather than the checksums recorded when they were cached.
+	ForceVerify bool
+
+	// BinaryDownload is where the node's Kubernetes binaries are downloaded,
+	// one of the BinaryDownload constants. Empty means BinaryDownloadAuto.
+	BinaryDownload string
+
+	// BinaryOverrides maps the names of the node's Kubernetes binaries, one
+	// of OverridableBinaries, to binaries on the host, e.g. built from
+	// source, which are copied to the node as they are instead of
+	// downloading the release. localkube ignores them.
+	BinaryOverrides map[string]string
+
+	// CgroupDriver is the cgroup driver of kubelet and containerd. Empty
+	// means CgroupDriverCgroupfs.
+	CgroupDriver string
+
+	// RegistryMirrors are mirrors of Docker Hub which containerd pulls
+	// images from before Docker Hub itself. docker's mirrors are set by the
+	// machine's provisioning instead.
+	RegistryMirrors []string
+
+	// RestartContainerRuntime restarts the container runtime's systemd unit
+	// when restarting the cluster, to recover a runtime that didn't come
+	// back cleanly after a reboot. localkube ignores it.
+	RestartContainerRuntime bool
+
+	// CacheKubectl also caches the kubectl for the host matching
+	// KubernetesVersion, so the client version doesn't skew from the
+	// cluster's.
+	CacheKubectl bool
+
+	// InstallKubectl installs the kubectl matching KubernetesVersion on the
+	// node, which the kubeadm bootstrapper runs with the admin kubeconfig to
+	// set up the control plane, apply addons and manifests, and read pod
+	// logs. Without it, the node must have kubectl already. localkube
+	// ignores it.
+	InstallKubectl bool
+
+	// PodCIDR is the range pod IPs are allocated from, which a CNI plugin
+	// needs. Empty leaves pod IPs to the container runtime. An IPv4 and an
+	// IPv6 range, comma separated, make the cluster dual-stack.
+	PodCIDR string
+
+	// ServiceCIDR is the range service IPs are allocated from, or an IPv4
+	// and an IPv6 range, comma separated, for a dual-stack cluster. The IPv4
+	// range must contain the apiserver's and DNS's service IPs. Empty means
+	// util.DefaultServiceCIDR.
+	ServiceCIDR string
+
+	// BootstrapToken is a fixed token for nodes to join the cluster with,
+	// in the form [a-z0-9]{6}.[a-z0-9]{16}. Empty lets kubeadm generate one.
+	BootstrapToken string
+	// BootstrapTokenTTL is how long the bootstrap token is valid for. Zero
+	// means DefaultBootstrapTokenTTL.
+	BootstrapTokenTTL time.Duration
+
+	// ControlPlaneTimeout is how long kubeadm init waits for the control
+	// plane to come up, e.g. longer on slow disks. Kubernetes before v1.13
+	// can't configure the wait, so it bounds the whole of kubeadm init
+	// instead. Zero leaves kubeadm's default.
+	ControlPlaneTimeout time.Duration
+	// ClusterSigningDuration is how long the certificates the controller
+	// manager signs are valid for, e.g. longer for long-lived clusters.
+	// Zero leaves kubeadm's default.
+	ClusterSigningDuration time.Duration
+	// ServiceNodePortRange is the range of ports NodePort services may use,
+	// e.g. 30000-32767. Empty leaves kubeadm's default.
+	ServiceNodePortRange string
+	// KubeletConfiguration is a KubeletConfiguration object, e.g.
+	// {"maxPods": 50}, which kubeadm configures kubelet with, for
+	// Kubernetes v1.13 and later. Its apiVersion and kind may be left out.
+	// The flags minikube sets on kubelet, and kubelet options in
+	// ExtraOptions, take precedence over it, as kubelet gives its flags
+	// precedence over its config file. localkube ignores it.
+	KubeletConfiguration map[string]interface{}
+
+	// BundleCachedImages bundles the cached images into a single archive,
+	// which is transferred to the node and loaded in one go when none of
+	// them are loaded yet, e.g. on a new node. Otherwise, they're loaded
+	// one by one, as transferring the whole bundle for a few would be
+	// slower.
+	BundleCachedImages bool
+
+	// ImageCacheDir is the absolute path of the host's image cache, e.g. on
+	// a larger disk, or shared between users. Empty means
+	// constants.ImageCacheDir.
+	ImageCacheDir string
+
+	// ImageRepository is the registry the control plane images are pulled
+	// from, e.g. a mirror, instead of kubeadm's default.
+	ImageRepository string
+	// PauseImage is the pause image each pod's sandbox runs, e.g. one
+	// preloaded on an offline node. Empty means the one kubeadm uses for
+	// KubernetesVersion, pulled from ImageRepository.
+	PauseImage string
+
 	ShouldLoadCachedImages bool
+	// RequireCachedImages fails the cluster's update when cached images
+	// can't be loaded, e.g. on air-gapped machines which can't pull them
+	// instead.
+	RequireCachedImages bool
+	// NoCacheImages skips loading cached images for this start, so that
+	// they're pulled from the registry instead, without clearing the cache.
+	NoCacheImages bool
+	// ForceReload loads cached images into the node even if its container
+	// runtime already has them, e.g. to replace corrupted ones.
+	ForceReload bool
+}
+
+// LogOptions are the options used to select which cluster logs are returned.
+type LogOptions struct {
+	// Follow continuously streams new entries to Writer as they are appended.
+	Follow bool
+	// Tail limits the output to the last Tail lines of each log source.
+	// Zero means no limit.
+	Tail int
+	// Since limits the output to entries newer than Since ago, measured
+	// against the node's clock. Zero means no limit.
+	Since time.Duration
+	// PreviousBoot selects the logs from the node's previous boot instead of
+	// the current one, e.g. to find out why the cluster didn't survive the
+	// host sleeping. It requires journald to persist logs across boots.
+	PreviousBoot bool
+	// Component selects the logs of a single component instead of the
+	// kubelet, e.g. kube-apiserver, proxy or dns. Empty means the kubelet.
+	Component string
+	// Writer receives the logs when following.
+	Writer io.Writer
+}
+
+// GetCertDir returns the certificates directory on the node.
+func (k KubernetesConfig) GetCertDir() string {
+	if k.CertDir == "" {
+		return util.DefaultCertPath
+	}
+	return k.CertDir
+}
+
+// GetImageCacheDir returns the host's image cache directory, defaulting to
+// constants.ImageCacheDir.
+func (k KubernetesConfig) GetImageCacheDir() string {
+	if k.ImageCacheDir == "" {
+		return constants.ImageCacheDir
+	}
+	return k.ImageCacheDir
+}
+
+// GetPauseImage returns the pause image each pod's sandbox runs, defaulting
+// to the one kubeadm uses for the Kubernetes version.
+func (k KubernetesConfig) GetPauseImage() string {
+	if k.PauseImage == "" {
+		return constants.GetPauseImage(k.ImageRepository, k.KubernetesVersion)
+	}
+	return k.PauseImage
+}
+
+// GetKubeadmCachedImages returns the images kubeadm needs, as
+// constants.GetKubeadmCachedImages lists them, but with the pause image from
+// GetPauseImage, so that the one the node's pods run is the one cached.
+func (k KubernetesConfig) GetKubeadmCachedImages() []string {
+	var images []string
+	for _, image := range constants.GetKubeadmImages(k.ImageRepository, k.KubernetesVersion) {
+		if image.Component == "pause" {
+			image.Image = k.GetPauseImage()
+		}
+		images = append(images, image.Image)
+	}
+	return images
+}
+
+// GetCgroupDriver returns the cgroup driver, defaulting to
+// CgroupDriverCgroupfs.
+func (k KubernetesConfig) GetCgroupDriver() string {
+	if k.CgroupDriver == "" {
+		return CgroupDriverCgroupfs
+	}
+	return k.CgroupDriver
+}
+
+// GetContainerLogRotation returns the size a container's log is rotated at,
+// and how many of its logs are kept, defaulting to
+// DefaultContainerLogMaxSize and DefaultContainerLogMaxFiles.
+func (k KubernetesConfig) GetContainerLogRotation() (string, int) {
+	size, files := k.ContainerLogMaxSize, k.ContainerLogMaxFiles
+	if size == "" {
+		size = DefaultContainerLogMaxSize
+	}
+	if files == 0 {
+		files = DefaultContainerLogMaxFiles
+	}
+	return size, files
+}
+
+// GetDNSDomain returns the cluster's DNS domain, defaulting to
+// constants.ClusterDNSDomain.
+func (k KubernetesConfig) GetDNSDomain() string {
+	if k.DNSDomain == "" {
+		return constants.ClusterDNSDomain
+	}
+	return k.DNSDomain
+}
+
+// GetServiceCIDR returns the cluster's service CIDRs, defaulting to
+// util.DefaultServiceCIDR.
+func (k KubernetesConfig) GetServiceCIDR() string {
+	if k.ServiceCIDR == "" {
+		return util.DefaultServiceCIDR
+	}
+	return k.ServiceCIDR
+}
+
+// IsDualStack returns whether the cluster has both IPv4 and IPv6 service or
+// pod CIDRs.
+func (k KubernetesConfig) IsDualStack() bool {
+	return strings.Contains(k.ServiceCIDR, ",") || strings.Contains(k.PodCIDR, ",")
+}
+
+// LoadsCachedImages returns whether cached images are loaded into the node.
+func (k KubernetesConfig) LoadsCachedImages() bool {
+	return k.ShouldLoadCachedImages && !k.NoCacheImages
+}
+
+// CustomBinaries returns the names of the node's binaries which are
+// overridden by BinaryOverrides, sorted.
+func (k KubernetesConfig) CustomBinaries() []string {
+	var names []string
+	for name := range k.BinaryOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KubeletFeatureGatesFlag returns KubeletFeatureGates as the value of
+// kubelet's --feature-gates, sorted by name so that the kubelet config only
+// changes when the gates do.
+func (k KubernetesConfig) KubeletFeatureGatesFlag() string {
+	var gates []string
+	for name, value := range k.KubeletFeatureGates {
+		gates = append(gates, name+"="+value)
+	}
+	sort.Strings(gates)
+	return strings.Join(gates, ",")
+}
+
+// HostAlias maps hostnames to an IP in the node's /etc/hosts.
+type HostAlias struct {
+	IP        string
+	Hostnames []string
+}
+
+// ResourceRequests are the CPU and memory a pod requests, as quantities,
+// e.g. 250m or 128Mi. Empty leaves the pod's request as it is.
+type ResourceRequests struct {
+	CPU    string
+	Memory string
+}
+
+// RegistryCredential is a username and password for an image registry.
+type RegistryCredential struct {
+	// Server is the registry's host, with an optional port, e.g.
+	// registry.example.com:5000. docker.io selects Docker Hub.
+	Server   string
+	Username string
+	Password string
+}
+
+// String returns the credential with its password redacted, so that it can
+// be logged.
+func (r RegistryCredential) String() string {
+	return fmt.Sprintf("%s@%s (password redacted)", r.Username, r.Server)
+}
+
+// GetBootstrapTokenTTL returns the bootstrap token's TTL, defaulting to
+// DefaultBootstrapTokenTTL.
+func (k KubernetesConfig) GetBootstrapTokenTTL() time.Duration {
+	if k.BootstrapTokenTTL == 0 {
+		return DefaultBootstrapTokenTTL
+	}
+	return k.BootstrapTokenTTL
+}
+
+// GetDownloadTimeout returns the binary download timeout, defaulting to
+// DefaultDownloadTimeout.
+func (k KubernetesConfig) GetDownloadTimeout() time.Duration {
+	if k.DownloadTimeout == 0 {
+		return DefaultDownloadTimeout
+	}
+	return k.DownloadTimeout
+}
+
+// GetDownloadStallLimit returns the fewest bytes a download must receive in
+// each period not to be stalled, defaulting to DefaultDownloadStallBytes and
+// DefaultDownloadStallPeriod.
+func (k KubernetesConfig) GetDownloadStallLimit() (int64, time.Duration) {
+	bytes, period := k.DownloadStallBytes, k.DownloadStallPeriod
+	if bytes == 0 {
+		bytes = DefaultDownloadStallBytes
+	}
+	if period == 0 {
+		period = DefaultDownloadStallPeriod
+	}
+	return bytes, period
+}
+
+// ValidateCertDir returns an error if the certificates directory isn't an
+// absolute path.
+func (k KubernetesConfig) ValidateCertDir() error {
+	if !path.IsAbs(k.GetCertDir()) {
+		return fmt.Errorf("certificates directory must be an absolute path: %s", k.GetCertDir())
+	}
+	return nil
 }
 
 const (
@@ -51,13 +520,109 @@ const (
 	BootstrapperTypeKubeadm   = "kubeadm"
 )
 
-func GetCachedImageList(version string, bootstrapper string) []string {
+func GetCachedImageList(imageRepository, version, bootstrapper string) []string {
 	switch bootstrapper {
 	case BootstrapperTypeLocalkube:
 		return constants.LocalkubeCachedImages
 	case BootstrapperTypeKubeadm:
-		return constants.GetKubeadmCachedImages(version)
+		return constants.GetKubeadmCachedImages(imageRepository, version)
 	default:
 		return []string{}
 	}
 }
+
+// UnsupportedLogOptionError is returned by GetClusterLogs when the
+// bootstrapper can't honor one of the LogOptions.
+type UnsupportedLogOptionError struct {
+	// Option is the name of the LogOptions field that isn't supported.
+	Option string
+	// Reason explains why the option isn't supported.
+	Reason string
+}
+
+func (e *UnsupportedLogOptionError) Error() string {
+	return fmt.Sprintf("log option %s is not supported: %s", e.Option, e.Reason)
+}
+
+// GetClusterLogs gets the cluster logs with the GetClusterLogs signature used
+// before LogOptions, streaming the logs to stdout when following.
+//
+// Deprecated: call Bootstrapper.GetClusterLogs with LogOptions instead.
+func GetClusterLogs(b Bootstrapper, follow bool) (string, error) {
+	return b.GetClusterLogs(LogOptions{Follow: follow, Writer: os.Stdout})
+}
+
+// LogEntry is a single line of the cluster logs.
+type LogEntry struct {
+	// Source is the unit or component the line came from, e.g. kubelet or
+	// kube-apiserver.
+	Source string
+	// Timestamp is when the line was logged. It's zero when the line's
+	// timestamp couldn't be parsed.
+	Timestamp time.Time
+	// Line is the logged line, without its timestamp.
+	Line string
+}
+
+// ClusterInfo is an overview of a running cluster. A field which couldn't be
+// determined is left empty, with the reason in Errors.
+type ClusterInfo struct {
+	// APIServer is the apiserver's endpoint, as IP:port.
+	APIServer string
+	// KubernetesVersion is the version the apiserver is running.
+	KubernetesVersion string
+	// KubeletStatus is Running or Stopped.
+	KubeletStatus string
+	// Components is the health of the apiserver and of the components it
+	// reports on, e.g. the scheduler and etcd.
+	Components []ComponentHealth
+	// Etcd is the health of etcd, checked on the node rather than through
+	// the apiserver, so that it's known even while the apiserver is down.
+	Etcd ComponentHealth
+	// DNSServiceIP is the cluster IP of the cluster's DNS service.
+	DNSServiceIP string
+	// Errors maps the names of the fields which couldn't be determined to
+	// why not.
+	Errors map[string]string
+}
+
+// ComponentHealth is whether a control plane component is healthy.
+type ComponentHealth struct {
+	Name    string
+	Healthy bool
+	// Message explains why the component isn't healthy, if it isn't.
+	Message string
+}
+
+// FindingSeverity is how likely a Finding is to stop the cluster starting.
+type FindingSeverity string
+
+const (
+	// SeverityError findings stop the cluster starting.
+	SeverityError FindingSeverity = "error"
+	// SeverityWarning findings may slow it down, or stop it starting later.
+	SeverityWarning FindingSeverity = "warning"
+)
+
+// Finding is a likely cause of a cluster failing to start, found by
+// diagnosing its node.
+type Finding struct {
+	// Check is the name of the check that found it, e.g. swap.
+	Check    string
+	Severity FindingSeverity
+	// Message describes what was found.
+	Message string
+	// Remediation explains how to fix it.
+	Remediation string
+}
+
+// CertStatus is when one of the cluster's certificates expires.
+type CertStatus struct {
+	// Name is the certificate's name, e.g. apiserver, or admin.conf for one
+	// embedded in a kubeconfig.
+	Name    string
+	Expires time.Time
+	// ExpiringSoon is set if the certificate has expired, or will within
+	// the threshold it was checked with.
+	ExpiringSoon bool
+}
@@ -47,6 +47,11 @@ func SetupCerts(cmd CommandRunner, k8s KubernetesConfig) error {
 	localPath := constants.GetMinipath()
 	glog.Infof("Setting up certificates for IP: %s\n", k8s.NodeIP)
 
+	if err := k8s.ValidateCertDir(); err != nil {
+		return err
+	}
+	certDir := k8s.GetCertDir()
+
 	if err := generateCerts(k8s); err != nil {
 		return errors.Wrap(err, "Error generating certs")
 	}
@@ -59,7 +64,7 @@ func SetupCerts(cmd CommandRunner, k8s KubernetesConfig) error {
 		if strings.HasSuffix(cert, ".key") {
 			perms = "0600"
 		}
-		certFile, err := assets.NewFileAsset(p, util.DefaultCertPath, cert, perms)
+		certFile, err := assets.NewFileAsset(p, certDir, cert, perms)
 		if err != nil {
 			return err
 		}
@@ -69,9 +74,9 @@ func SetupCerts(cmd CommandRunner, k8s KubernetesConfig) error {
 	kubeCfgSetup := &kubeconfig.KubeConfigSetup{
 		ClusterName:          k8s.NodeName,
 		ClusterServerAddress: "https://localhost:8443",
-		ClientCertificate:    path.Join(util.DefaultCertPath, "apiserver.crt"),
-		ClientKey:            path.Join(util.DefaultCertPath, "apiserver.key"),
-		CertificateAuthority: path.Join(util.DefaultCertPath, "ca.crt"),
+		ClientCertificate:    path.Join(certDir, "apiserver.crt"),
+		ClientKey:            path.Join(certDir, "apiserver.key"),
+		CertificateAuthority: path.Join(certDir, "ca.crt"),
 		KeepContext:          false,
 	}
 
@@ -143,7 +148,7 @@ func generateCerts(k8s KubernetesConfig) error {
 			keyPath:        filepath.Join(localPath, "apiserver.key"),
 			subject:        "minikube",
 			ips:            []net.IP{net.ParseIP(k8s.NodeIP), internalIP},
-			alternateNames: util.GetAlternateDNS(k8s.DNSDomain),
+			alternateNames: util.GetAlternateDNS(k8s.GetDNSDomain()),
 			caCertPath:     caCertPath,
 			caKeyPath:      caKeyPath,
 		},
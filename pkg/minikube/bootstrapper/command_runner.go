@@ -17,10 +17,19 @@ limitations under the License.
 package bootstrapper
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"time"
 
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
 	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/util"
 )
 
 // CommandRunner represents an interface to run commands.
@@ -32,13 +41,288 @@ type CommandRunner interface {
 	// output and standard error.
 	CombinedOutput(cmd string) (string, error)
 
+	// Output runs the command and returns its standard output and standard
+	// error apart, so that output which is parsed, e.g. kubectl -o json,
+	// isn't broken by warnings, and its exit code. The result is returned
+	// even if the command fails, unless it couldn't be run at all.
+	Output(cmd string) (*RunResult, error)
+
+	// RunWithOutput starts the specified command and streams its standard
+	// output and standard error to stdout and stderr as it runs, returning
+	// once the command exits.
+	RunWithOutput(cmd string, stdout, stderr io.Writer) error
+
+	// RunWithInput starts the specified command with stdin as its standard
+	// input, e.g. to stream a file into it without copying it first, and
+	// waits for it to complete.
+	RunWithInput(cmd string, stdin io.Reader) error
+
 	// Copy is a convenience method that runs a command to copy a file
 	Copy(assets.CopyableFile) error
 
 	//Remove is a convenience method that runs a command to remove a file
 	Remove(assets.CopyableFile) error
+
+	// RunContext is Run, but the command is killed, and ctx's error
+	// returned, if ctx is done before it completes.
+	RunContext(ctx context.Context, cmd string) error
+
+	// CombinedOutputContext is CombinedOutput, but the command is killed,
+	// and ctx's error returned, if ctx is done before it completes.
+	CombinedOutputContext(ctx context.Context, cmd string) (string, error)
+
+	// OutputContext is Output, but the command is killed, and ctx's error
+	// returned, if ctx is done before it completes.
+	OutputContext(ctx context.Context, cmd string) (*RunResult, error)
+
+	// RunWithOutputContext is RunWithOutput, but the command is killed, and
+	// ctx's error returned, if ctx is done before it completes, e.g. to stop
+	// following logs.
+	RunWithOutputContext(ctx context.Context, cmd string, stdout, stderr io.Writer) error
+
+	// CopyContext is Copy, but the copy is aborted, and ctx's error
+	// returned, if ctx is done before it completes. The target may be left
+	// partly written.
+	CopyContext(ctx context.Context, f assets.CopyableFile) error
+}
+
+// RunResult is the result of a command run with Output.
+type RunResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the command's exit status, or -1 if it didn't exit,
+	// e.g. because it was killed.
+	ExitCode int
+}
+
+// newRunResult returns the result of a command which wrote stdout and
+// stderr, and failed with err, if it failed. Its standard error is logged,
+// as it's otherwise dropped when the command succeeds.
+func newRunResult(cmd string, stdout, stderr *bytes.Buffer, err error) *RunResult {
+	res := &RunResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: exitCode(err)}
+	if res.Stderr != "" {
+		glog.Infof("%s: stderr: %s", cmd, res.Stderr)
+	}
+	return res
+}
+
+// exitCode returns the exit status of a command which failed with err, as
+// returned by os/exec or the ssh package, 0 if err is nil, or -1 if the
+// command didn't exit.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	err = errors.Cause(err)
+	if e, ok := err.(*exec.ExitError); ok {
+		if ws, ok := e.Sys().(interface {
+			ExitStatus() int
+		}); ok {
+			return ws.ExitStatus()
+		}
+	}
+	if e, ok := err.(interface {
+		ExitStatus() int
+	}); ok {
+		return e.ExitStatus()
+	}
+	return -1
+}
+
+// TimeoutError is returned by RunWithTimeout and CombinedOutputWithTimeout
+// when a command didn't complete in time, and was killed.
+type TimeoutError struct {
+	Command string
+	// Elapsed is how long the command ran for before it was killed.
+	Elapsed time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("command timed out after %s: %s", e.Elapsed, e.Command)
+}
+
+// RunWithTimeout runs cmd with r, killing it, and returning a *TimeoutError,
+// if it doesn't complete within timeout. Cancelling ctx kills it too, but
+// returns ctx's error.
+func RunWithTimeout(ctx context.Context, r CommandRunner, cmd string, timeout time.Duration) error {
+	_, err := withTimeout(ctx, cmd, timeout, func(ctx context.Context) (string, error) {
+		return "", r.RunContext(ctx, cmd)
+	})
+	return err
+}
+
+// CombinedOutputWithTimeout is RunWithTimeout, returning the command's
+// combined standard output and standard error.
+func CombinedOutputWithTimeout(ctx context.Context, r CommandRunner, cmd string, timeout time.Duration) (string, error) {
+	return withTimeout(ctx, cmd, timeout, func(ctx context.Context) (string, error) {
+		return r.CombinedOutputContext(ctx, cmd)
+	})
+}
+
+// OutputWithTimeout is RunWithTimeout, returning the command's result as
+// Output does.
+func OutputWithTimeout(ctx context.Context, r CommandRunner, cmd string, timeout time.Duration) (*RunResult, error) {
+	var res *RunResult
+	_, err := withTimeout(ctx, cmd, timeout, func(ctx context.Context) (string, error) {
+		var err error
+		res, err = r.OutputContext(ctx, cmd)
+		return "", err
+	})
+	return res, err
+}
+
+// withTimeout calls run with ctx bounded by timeout, turning the timeout
+// expiring into a *TimeoutError.
+func withTimeout(ctx context.Context, cmd string, timeout time.Duration, run func(context.Context) (string, error)) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	out, err := run(timeoutCtx)
+	if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		return out, &TimeoutError{Command: cmd, Elapsed: time.Since(start)}
+	}
+	return out, err
+}
+
+// RetryError is returned by RunWithRetry when a command fails on its last
+// attempt, or in a way that isn't worth retrying.
+type RetryError struct {
+	Command string
+	// Attempts is how many times the command was run.
+	Attempts int
+	// Output is the combined output of the last attempt.
+	Output string
+	// Err is why the last attempt failed.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("command failed after %d attempts: %s: %v\noutput: %s", e.Attempts, e.Command, e.Err, e.Output)
+}
+
+// maxRetryBackoff is how many times longer than the first wait RunWithRetry
+// waits between attempts at most.
+const maxRetryBackoff = 5
+
+// RunWithRetry runs cmd with r until it succeeds, up to attempts times, and
+// returns its combined output. It waits backoff after the first failure and
+// twice as long after each one after that, up to maxRetryBackoff times
+// backoff, jittered as util.RetryWithBackoff does. Each failure is passed to
+// retriable, if set, with its output, and isn't retried unless retriable
+// returns true. Commands failing transiently, e.g. kubectl while the
+// apiserver starts, or systemctl right after daemon-reload, are retried this
+// way.
+func RunWithRetry(r CommandRunner, cmd string, attempts int, backoff time.Duration, retriable func(error, string) bool) (string, error) {
+	var out bytes.Buffer
+	var lastErr error
+	attempt := 0
+	run := func() error {
+		attempt++
+		out.Reset()
+		lastErr = r.RunWithOutput(cmd, &out, &out)
+		if lastErr == nil {
+			return nil
+		}
+		if retriable != nil && !retriable(lastErr, out.String()) {
+			return lastErr
+		}
+		if attempt < attempts {
+			glog.Infof("Retrying %q after attempt %d failed: %v", cmd, attempt, lastErr)
+		}
+		return &util.RetriableError{Err: lastErr}
+	}
+	if err := util.RetryWithBackoff(attempts, run, backoff, maxRetryBackoff*backoff); err != nil {
+		return out.String(), &RetryError{Command: cmd, Attempts: attempt, Output: out.String(), Err: lastErr}
+	}
+	return out.String(), nil
+}
+
+// runnerProbeInterval is how often WaitForRunner retries.
+var runnerProbeInterval = time.Second
+
+// WaitForRunner runs a trivial command on r until it succeeds or timeout
+// passes. Right after a VM is created, sshd may not accept connections yet,
+// which would fail the first command. Commands run on the host are always
+// ready.
+func WaitForRunner(r CommandRunner, timeout time.Duration) error {
+	if _, ok := r.(*ExecRunner); ok {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := r.CombinedOutput("echo")
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Wrapf(err, "command runner not ready after %s", timeout)
+		}
+		glog.Infof("Waiting for command runner to be ready: %s", err)
+		time.Sleep(runnerProbeInterval)
+	}
+}
+
+// CopyAtomically copies f with r to a temporary file beside its target,
+// then renames it into place, so that readers of the target, e.g. kubelet or
+// kubeadm, never see it partly written, even if the copy is interrupted.
+func CopyAtomically(r CommandRunner, f assets.CopyableFile) error {
+	return CopyAtomicallyContext(context.Background(), r, f)
+}
+
+// CopyAtomicallyContext is CopyAtomically, but cancelling ctx aborts the
+// copy, leaving the target as it was.
+func CopyAtomicallyContext(ctx context.Context, r CommandRunner, f assets.CopyableFile) error {
+	target := path.Join(f.GetTargetDir(), f.GetTargetName())
+	tmp := &renamedFile{CopyableFile: f, name: "." + f.GetTargetName() + ".tmp"}
+	tmpPath := path.Join(tmp.GetTargetDir(), tmp.GetTargetName())
+	if err := r.CopyContext(ctx, tmp); err != nil {
+		if err := r.Run(Sudo("rm -f " + tmpPath)); err != nil {
+			glog.Warningf("Error removing %s: %s", tmpPath, err)
+		}
+		return errors.Wrapf(err, "copying %s", target)
+	}
+	if err := r.RunContext(ctx, Sudo(fmt.Sprintf("mv -f %s %s", tmpPath, target))); err != nil {
+		return errors.Wrapf(err, "renaming %s to %s", tmpPath, target)
+	}
+	return nil
+}
+
+// renamedFile is a CopyableFile copied under another name.
+type renamedFile struct {
+	assets.CopyableFile
+	name string
+}
+
+func (f *renamedFile) GetTargetName() string {
+	return f.name
+}
+
+// contextReader reads from r until ctx is done, when reads fail with ctx's
+// error, so that copying from it stops.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
 
 func getDeleteFileCommand(f assets.CopyableFile) string {
-	return fmt.Sprintf("sudo rm %s", filepath.Join(f.GetTargetDir(), f.GetTargetName()))
+	return Sudo("rm " + filepath.Join(f.GetTargetDir(), f.GetTargetName()))
+}
+
+// chown sets the owner of the copied file f, if it has one.
+func chown(r CommandRunner, f assets.CopyableFile) error {
+	if f.GetOwner() == "" {
+		return nil
+	}
+	cmd := Sudo(fmt.Sprintf("chown %s %s", f.GetOwner(), filepath.Join(f.GetTargetDir(), f.GetTargetName())))
+	if err := r.Run(cmd); err != nil {
+		return errors.Wrapf(err, "changing owner of %s", f.GetTargetName())
+	}
+	return nil
 }
@@ -0,0 +1,318 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+func TestChown(t *testing.T) {
+	owned := assets.NewMemoryAsset([]byte("data"), "/etc/kubernetes/addons", "addon.yaml", "0600")
+	owned.SetOwner(1000, 1000)
+
+	cases := []struct {
+		description string
+		file        assets.CopyableFile
+		cmdToOutput map[string]string
+		shouldErr   bool
+	}{
+		{
+			description: "owner set",
+			file:        owned,
+			cmdToOutput: map[string]string{"sudo chown 1000:1000 /etc/kubernetes/addons/addon.yaml": ""},
+		},
+		{
+			// chown must be run, and fails since the fake has no output
+			// set for it.
+			description: "owner set chown fails",
+			file:        owned,
+			cmdToOutput: map[string]string{},
+			shouldErr:   true,
+		},
+		{
+			description: "default owner",
+			file:        assets.NewMemoryAsset([]byte("data"), "/etc/kubernetes/addons", "addon.yaml", "0640"),
+			cmdToOutput: map[string]string{},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := NewFakeCommandRunner()
+			f.SetCommandToOutput(test.cmdToOutput)
+			err := chown(f, test.file)
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+// startingRunner is a CommandRunner that fails until it's been tried ready
+// times, like sshd on a booting VM.
+type startingRunner struct {
+	*FakeCommandRunner
+	ready    int
+	attempts int
+}
+
+func (r *startingRunner) CombinedOutput(cmd string) (string, error) {
+	r.attempts++
+	if r.attempts < r.ready {
+		return "", errors.New("connection refused")
+	}
+	return r.FakeCommandRunner.CombinedOutput(cmd)
+}
+
+func TestWaitForRunner(t *testing.T) {
+	defer func(d time.Duration) { runnerProbeInterval = d }(runnerProbeInterval)
+	runnerProbeInterval = time.Millisecond
+
+	cases := []struct {
+		description string
+		ready       int
+		timeout     time.Duration
+		shouldErr   bool
+	}{
+		{
+			description: "ready",
+			ready:       1,
+			timeout:     time.Second,
+		},
+		{
+			description: "ready after retries",
+			ready:       5,
+			timeout:     time.Second,
+		},
+		{
+			description: "timeout",
+			ready:       1000000,
+			timeout:     50 * time.Millisecond,
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{"echo": "\n"})
+			r := &startingRunner{FakeCommandRunner: f, ready: test.ready}
+			err := WaitForRunner(r, test.timeout)
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+			if !test.shouldErr && r.attempts != test.ready {
+				t.Errorf("Expected %d attempts, got %d", test.ready, r.attempts)
+			}
+		})
+	}
+}
+
+func TestWaitForRunnerExec(t *testing.T) {
+	if err := WaitForRunner(&ExecRunner{}, 0); err != nil {
+		t.Errorf("Expected the exec runner to be ready, got: %s", err)
+	}
+}
+
+// flakyRunner is a CommandRunner whose commands fail, printing output,
+// until they've been tried ready times.
+type flakyRunner struct {
+	*FakeCommandRunner
+	ready    int
+	output   string
+	attempts int
+}
+
+func (r *flakyRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	r.attempts++
+	if r.attempts < r.ready {
+		fmt.Fprint(stderr, r.output)
+		return errors.New("exit status 1")
+	}
+	return r.FakeCommandRunner.RunWithOutput(cmd, stdout, stderr)
+}
+
+func TestRunWithRetry(t *testing.T) {
+	cases := []struct {
+		description string
+		ready       int
+		output      string
+		retriable   func(error, string) bool
+		attempts    int
+		shouldErr   bool
+	}{
+		{
+			description: "succeeds",
+			ready:       1,
+			attempts:    1,
+		},
+		{
+			description: "succeeds after retries",
+			ready:       3,
+			output:      "The connection to the server was refused\n",
+			attempts:    3,
+		},
+		{
+			description: "out of attempts",
+			ready:       10,
+			output:      "The connection to the server was refused\n",
+			attempts:    5,
+			shouldErr:   true,
+		},
+		{
+			description: "not retriable",
+			ready:       3,
+			output:      "Error from server (Forbidden)\n",
+			retriable:   func(_ error, out string) bool { return !strings.Contains(out, "Forbidden") },
+			attempts:    1,
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{"kubectl get nodes": "minikube\n"})
+			r := &flakyRunner{FakeCommandRunner: f, ready: test.ready, output: test.output}
+			out, err := RunWithRetry(r, "kubectl get nodes", 5, time.Millisecond, test.retriable)
+			if r.attempts != test.attempts {
+				t.Errorf("Expected %d attempts, got %d", test.attempts, r.attempts)
+			}
+			if !test.shouldErr {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if out != "minikube\n" {
+					t.Errorf("Expected the command's output, got %q", out)
+				}
+				return
+			}
+			retryErr, ok := err.(*RetryError)
+			if !ok {
+				t.Fatalf("Expected a *RetryError, got %v", err)
+			}
+			if retryErr.Attempts != test.attempts || retryErr.Output != test.output {
+				t.Errorf("Expected %d attempts and the last output %q, got %+v", test.attempts, test.output, retryErr)
+			}
+		})
+	}
+}
+
+// recordingRunner records the files it copies and the commands it runs, in
+// order, and fails to copy when failCopy is set.
+type recordingRunner struct {
+	*FakeCommandRunner
+	ops      []string
+	failCopy bool
+}
+
+func (r *recordingRunner) Copy(f assets.CopyableFile) error {
+	r.ops = append(r.ops, "copy "+path.Join(f.GetTargetDir(), f.GetTargetName()))
+	if r.failCopy {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (r *recordingRunner) Run(cmd string) error {
+	r.ops = append(r.ops, cmd)
+	return nil
+}
+
+func (r *recordingRunner) CopyContext(ctx context.Context, f assets.CopyableFile) error {
+	if err := ctx.Err(); err != nil {
+		r.ops = append(r.ops, "cancelled copy "+path.Join(f.GetTargetDir(), f.GetTargetName()))
+		return err
+	}
+	return r.Copy(f)
+}
+
+func (r *recordingRunner) RunContext(ctx context.Context, cmd string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Run(cmd)
+}
+
+func TestCopyAtomically(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		description string
+		ctx         context.Context
+		failCopy    bool
+		expected    []string
+	}{
+		{
+			description: "copied then renamed",
+			expected: []string{
+				"copy /etc/kubernetes/.kubeadm.yaml.tmp",
+				"sudo mv -f /etc/kubernetes/.kubeadm.yaml.tmp /etc/kubernetes/kubeadm.yaml",
+			},
+		},
+		{
+			description: "interrupted copy left out of place",
+			failCopy:    true,
+			expected: []string{
+				"copy /etc/kubernetes/.kubeadm.yaml.tmp",
+				"sudo rm -f /etc/kubernetes/.kubeadm.yaml.tmp",
+			},
+		},
+		{
+			description: "cancelled copy cleaned up",
+			ctx:         cancelled,
+			expected: []string{
+				"cancelled copy /etc/kubernetes/.kubeadm.yaml.tmp",
+				"sudo rm -f /etc/kubernetes/.kubeadm.yaml.tmp",
+			},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := &recordingRunner{FakeCommandRunner: NewFakeCommandRunner(), failCopy: test.failCopy}
+			f := assets.NewMemoryAssetTarget([]byte("kind: MasterConfiguration"), "/etc/kubernetes/kubeadm.yaml", "0640")
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			err := CopyAtomicallyContext(ctx, r, f)
+			if (test.failCopy || test.ctx != nil) != (err != nil) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(r.ops, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, r.ops)
+			}
+		})
+	}
+}
@@ -17,27 +17,79 @@ limitations under the License.
 package bootstrapper
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"k8s.io/minikube/pkg/minikube/assets"
 )
 
-// ExecRunner runs commands using the os/exec package.
+// ExecRunner runs commands using the os/exec package. Commands asking for
+// root privileges with Sudo are granted them as execPrivilege allows, e.g.
+// by running them directly when minikube is already run as root.
 //
 // It implements the CommandRunner interface.
 type ExecRunner struct{}
 
+// command returns the bash command running cmd, in a process group of its
+// own so that runCommand can kill the processes it starts along with it.
+func (*ExecRunner) command(cmd string) (*exec.Cmd, error) {
+	script, err := elevate(cmd, execPrivilege())
+	if err != nil {
+		return nil, err
+	}
+	c := exec.Command("/bin/bash", "-c", script)
+	setProcessGroup(c)
+	return c, nil
+}
+
+// runCommand runs c, killing its process group if ctx is done before it
+// completes. Killing only bash would leave e.g. the children of a compound
+// command or of sudo running, and holding c's output open until they exit.
+func runCommand(ctx context.Context, c *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := killProcessGroup(c.Process); err != nil {
+				glog.Warningf("Error killing %s: %v", c.Args, err)
+			}
+		case <-done:
+		}
+	}()
+	return c.Wait()
+}
+
 // Run starts the specified command in a bash shell and waits for it to complete.
-func (*ExecRunner) Run(cmd string) error {
+func (e *ExecRunner) Run(cmd string) error {
+	return e.RunContext(context.Background(), cmd)
+}
+
+// RunContext is Run, killing the command if ctx is done before it completes.
+func (e *ExecRunner) RunContext(ctx context.Context, cmd string) error {
 	glog.Infoln("Run:", cmd)
-	c := exec.Command("/bin/bash", "-c", cmd)
-	if err := c.Run(); err != nil {
+	c, err := e.command(cmd)
+	if err != nil {
+		return err
+	}
+	if err := runCommand(ctx, c); err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "running command: %s", cmd)
+		}
 		return errors.Wrapf(err, "running command: %s", cmd)
 	}
 	return nil
@@ -45,18 +97,114 @@ func (*ExecRunner) Run(cmd string) error {
 
 // CombinedOutput runs the command  in a bash shell and returns its
 // combined standard output and standard error.
-func (*ExecRunner) CombinedOutput(cmd string) (string, error) {
+func (e *ExecRunner) CombinedOutput(cmd string) (string, error) {
+	return e.CombinedOutputContext(context.Background(), cmd)
+}
+
+// CombinedOutputContext is CombinedOutput, killing the command if ctx is
+// done before it completes.
+func (e *ExecRunner) CombinedOutputContext(ctx context.Context, cmd string) (string, error) {
 	glog.Infoln("Run with output:", cmd)
-	c := exec.Command("/bin/bash", "-c", cmd)
-	out, err := c.CombinedOutput()
+	c, err := e.command(cmd)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err = runCommand(ctx, c)
+	out := b.Bytes()
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", errors.Wrapf(ctx.Err(), "running command: %s\n output: %s", cmd, out)
+		}
 		return "", errors.Wrapf(err, "running command: %s\n output: %s", cmd, out)
 	}
 	return string(out), nil
 }
 
-// Copy copies a file and its permissions
-func (*ExecRunner) Copy(f assets.CopyableFile) error {
+// Output runs the command in a bash shell and returns its result, with its
+// standard output and standard error apart.
+func (e *ExecRunner) Output(cmd string) (*RunResult, error) {
+	return e.OutputContext(context.Background(), cmd)
+}
+
+// OutputContext is Output, killing the command if ctx is done before it
+// completes.
+func (e *ExecRunner) OutputContext(ctx context.Context, cmd string) (*RunResult, error) {
+	glog.Infoln("Run with separate output:", cmd)
+	c, err := e.command(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err = runCommand(ctx, c)
+	res := newRunResult(cmd, &stdout, &stderr, err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return res, errors.Wrapf(ctx.Err(), "running command: %s\n stderr: %s", cmd, res.Stderr)
+		}
+		return res, errors.Wrapf(err, "running command: %s\n stderr: %s", cmd, res.Stderr)
+	}
+	return res, nil
+}
+
+// RunWithOutput starts the specified command in a bash shell, streaming its
+// standard output and standard error to stdout and stderr.
+func (e *ExecRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	return e.RunWithOutputContext(context.Background(), cmd, stdout, stderr)
+}
+
+// RunWithOutputContext is RunWithOutput, killing the command if ctx is done
+// before it completes.
+func (e *ExecRunner) RunWithOutputContext(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	glog.Infoln("Run with streaming output:", cmd)
+	c, err := e.command(cmd)
+	if err != nil {
+		return err
+	}
+	c.Stdout = stdout
+	c.Stderr = stderr
+	if err := runCommand(ctx, c); err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "running command: %s", cmd)
+		}
+		return errors.Wrapf(err, "running command: %s", cmd)
+	}
+	return nil
+}
+
+// RunWithInput starts the specified command in a bash shell, with stdin as
+// its standard input.
+func (e *ExecRunner) RunWithInput(cmd string, stdin io.Reader) error {
+	glog.Infoln("Run with input:", cmd)
+	c, err := e.command(cmd)
+	if err != nil {
+		return err
+	}
+	c.Stdin = stdin
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "running command: %s\n output: %s", cmd, out)
+	}
+	return nil
+}
+
+// Copy copies a file, its permissions and owner. If SELinux is enforcing, the
+// file's SELinux context is reset to the default for its location, so that
+// e.g. binaries copied to /usr/bin may be executed.
+func (e *ExecRunner) Copy(f assets.CopyableFile) error {
+	return e.CopyContext(context.Background(), f)
+}
+
+// CopyContext is Copy, stopping the copy if ctx is done before it
+// completes.
+func (e *ExecRunner) CopyContext(ctx context.Context, f assets.CopyableFile) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrapf(err, "copying %s", f.GetTargetName())
+	}
 	if err := os.MkdirAll(f.GetTargetDir(), os.ModePerm); err != nil {
 		return errors.Wrapf(err, "error making dirs for %s", f.GetTargetDir())
 	}
@@ -73,18 +221,45 @@ func (*ExecRunner) Copy(f assets.CopyableFile) error {
 	}
 	perms, err := strconv.Atoi(f.GetPermissions())
 	if err != nil {
-		return errors.Wrapf(err, "error converting permissions %s to integer", perms)
+		return errors.Wrapf(err, "error converting permissions %s to integer", f.GetPermissions())
 	}
 	if err := os.Chmod(targetPath, os.FileMode(perms)); err != nil {
 		return errors.Wrapf(err, "error changing file permissions for %s", targetPath)
 	}
 
-	if _, err = io.Copy(target, f); err != nil {
+	if _, err = io.Copy(target, &contextReader{ctx: ctx, r: f}); err != nil {
+		target.Close()
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "copying file %s", targetPath)
+		}
 		return errors.Wrapf(err, `error copying file %s to target location:
 do you have the correct permissions?`,
 			targetPath)
 	}
-	return target.Close()
+	if err := target.Close(); err != nil {
+		return errors.Wrapf(err, "error closing file %s", targetPath)
+	}
+	if err := chown(e, f); err != nil {
+		return err
+	}
+	return restoreSELinuxContext(e, targetPath)
+}
+
+// restoreSELinuxContext resets the SELinux context of path when SELinux is
+// enforcing. It's a no-op when SELinux is permissive, disabled or absent.
+func restoreSELinuxContext(r CommandRunner, path string) error {
+	out, err := r.CombinedOutput("getenforce")
+	if err != nil {
+		// getenforce isn't installed on hosts without SELinux.
+		return nil
+	}
+	if strings.TrimSpace(out) != "Enforcing" {
+		return nil
+	}
+	if err := r.Run("restorecon " + path); err != nil {
+		return errors.Wrapf(err, "restoring SELinux context of %s", path)
+	}
+	return nil
 }
 
 // Remove removes a file
@@ -0,0 +1,189 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+func TestRestoreSELinuxContext(t *testing.T) {
+	cases := []struct {
+		description string
+		cmdToOutput map[string]string
+		shouldErr   bool
+	}{
+		{
+			description: "enforcing",
+			cmdToOutput: map[string]string{
+				"getenforce":                  "Enforcing\n",
+				"restorecon /usr/bin/kubelet": "",
+			},
+		},
+		{
+			// restorecon must be run, and fails since the fake has no
+			// output set for it.
+			description: "enforcing restorecon fails",
+			cmdToOutput: map[string]string{
+				"getenforce": "Enforcing\n",
+			},
+			shouldErr: true,
+		},
+		{
+			description: "permissive",
+			cmdToOutput: map[string]string{
+				"getenforce": "Permissive\n",
+			},
+		},
+		{
+			description: "selinux absent",
+			cmdToOutput: map[string]string{},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := NewFakeCommandRunner()
+			f.SetCommandToOutput(test.cmdToOutput)
+			err := restoreSELinuxContext(f, "/usr/bin/kubelet")
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestExecRunnerContext(t *testing.T) {
+	r := &ExecRunner{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := r.RunContext(ctx, "sleep 10")
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected sleep to be killed, took %s", elapsed)
+	}
+
+	out, err := r.CombinedOutputContext(context.Background(), "echo hello")
+	if err != nil || out != "hello\n" {
+		t.Errorf("Expected hello, got %q, %v", out, err)
+	}
+
+	dir, err := ioutil.TempDir("", "exec-runner")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := assets.NewMemoryAssetTarget([]byte("data"), filepath.Join(dir, "file"), "0644")
+	if err := r.CopyContext(cancelled, f); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected the copy to be cancelled, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "file")); !os.IsNotExist(err) {
+		t.Errorf("Expected a cancelled copy not to create the file, got %v", err)
+	}
+}
+
+// cancellingWriter cancels a context once it's written to.
+type cancellingWriter struct {
+	out    bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return w.out.Write(p)
+}
+
+func TestExecRunnerFollow(t *testing.T) {
+	r := &ExecRunner{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &cancellingWriter{cancel: cancel}
+	start := time.Now()
+	err := r.RunWithOutputContext(ctx, "while true; do echo line; sleep 0.1; done", w, w)
+	if errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected following to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected following to stop on cancel, took %s", elapsed)
+	}
+	if out := w.out.String(); !strings.HasPrefix(out, "line\n") {
+		t.Errorf("Expected the output to be streamed until cancelled, got %q", out)
+	}
+}
+
+func TestExecRunnerOutput(t *testing.T) {
+	r := &ExecRunner{}
+	res, err := r.Output("echo out; echo err >&2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := RunResult{Stdout: "out\n", Stderr: "err\n"}
+	if *res != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *res)
+	}
+
+	res, err = r.Output("echo out; echo failed >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Expected the error to include stderr, got %v", err)
+	}
+	expected = RunResult{Stdout: "out\n", Stderr: "failed\n", ExitCode: 3}
+	if res == nil || *res != expected {
+		t.Errorf("Expected %+v, got %+v", expected, res)
+	}
+}
+
+func TestExecRunnerTimeout(t *testing.T) {
+	r := &ExecRunner{}
+	// bash forks sleep for a compound command, and the child holds the
+	// output open, so it has to be killed along with bash.
+	cmd := "sleep 10; echo done"
+	start := time.Now()
+	out, err := CombinedOutputWithTimeout(context.Background(), r, cmd, 100*time.Millisecond)
+	timeoutErr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("Expected a timeout error, got %q, %v", out, err)
+	}
+	if timeoutErr.Command != cmd || time.Since(start) > 5*time.Second {
+		t.Errorf("Expected %q to be killed after 100ms, took %s: %+v", cmd, time.Since(start), timeoutErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunWithTimeout(ctx, r, cmd, time.Minute); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected a cancelled command not to time out, got %v", err)
+	}
+	if err := RunWithTimeout(context.Background(), r, "true", time.Minute); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,33 @@
+// +build windows
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package bootstrapper
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, as Windows has no process groups to kill.
+func setProcessGroup(c *exec.Cmd) {}
+
+// killProcessGroup kills p.
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
@@ -18,6 +18,7 @@ package bootstrapper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 
@@ -32,8 +33,10 @@ import (
 //
 // It implements the CommandRunner interface and is used for testing.
 type FakeCommandRunner struct {
-	cmdMap  syncmap.Map
-	fileMap syncmap.Map
+	cmdMap    syncmap.Map
+	stderrMap syncmap.Map
+	fileMap   syncmap.Map
+	inputMap  syncmap.Map
 }
 
 // NewFakeCommandRunner returns a new FakeCommandRunner
@@ -58,6 +61,54 @@ func (f *FakeCommandRunner) CombinedOutput(cmd string) (string, error) {
 	return out.(string), nil
 }
 
+// Output returns the set output for a given command text as its standard
+// output, and the set standard error, if any, as its standard error.
+func (f *FakeCommandRunner) Output(cmd string) (*RunResult, error) {
+	out, err := f.CombinedOutput(cmd)
+	if err != nil {
+		return nil, err
+	}
+	res := &RunResult{Stdout: out}
+	if stderr, ok := f.stderrMap.Load(cmd); ok {
+		res.Stderr = stderr.(string)
+	}
+	return res, nil
+}
+
+// RunWithOutput writes the set output for a given command text to stdout.
+func (f *FakeCommandRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	out, err := f.CombinedOutput(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, out)
+	return err
+}
+
+// RunWithInput reads stdin, storing it as the command's input, and returns
+// nil if output has been set for the given command text.
+func (f *FakeCommandRunner) RunWithInput(cmd string, stdin io.Reader) error {
+	if _, err := f.CombinedOutput(cmd); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, stdin); err != nil {
+		return errors.Wrapf(err, "reading input of %s", cmd)
+	}
+	f.inputMap.Store(cmd, b.String())
+	return nil
+}
+
+// GetCommandInput returns the input the given command text was last run
+// with by RunWithInput.
+func (f *FakeCommandRunner) GetCommandInput(cmd string) (string, error) {
+	in, ok := f.inputMap.Load(cmd)
+	if !ok {
+		return "", fmt.Errorf("command not run with input: %s", cmd)
+	}
+	return in.(string), nil
+}
+
 // Copy adds the filename, file contents key value pair to the stored map.
 func (f *FakeCommandRunner) Copy(file assets.CopyableFile) error {
 	var b bytes.Buffer
@@ -75,6 +126,48 @@ func (f *FakeCommandRunner) Remove(file assets.CopyableFile) error {
 	return nil
 }
 
+// RunContext is Run, returning ctx's error instead if ctx is done.
+func (f *FakeCommandRunner) RunContext(ctx context.Context, cmd string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Run(cmd)
+}
+
+// CombinedOutputContext is CombinedOutput, returning ctx's error instead if
+// ctx is done.
+func (f *FakeCommandRunner) CombinedOutputContext(ctx context.Context, cmd string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return f.CombinedOutput(cmd)
+}
+
+// OutputContext is Output, returning ctx's error instead if ctx is done.
+func (f *FakeCommandRunner) OutputContext(ctx context.Context, cmd string) (*RunResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.Output(cmd)
+}
+
+// RunWithOutputContext is RunWithOutput, returning ctx's error instead if
+// ctx is done.
+func (f *FakeCommandRunner) RunWithOutputContext(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.RunWithOutput(cmd, stdout, stderr)
+}
+
+// CopyContext is Copy, returning ctx's error instead if ctx is done.
+func (f *FakeCommandRunner) CopyContext(ctx context.Context, file assets.CopyableFile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Copy(file)
+}
+
 // SetFileToContents stores the file to contents map for the FakeCommandRunner
 func (f *FakeCommandRunner) SetFileToContents(fileToContents map[string]string) {
 	for k, v := range fileToContents {
@@ -89,6 +182,14 @@ func (f *FakeCommandRunner) SetCommandToOutput(cmdToOutput map[string]string) {
 	}
 }
 
+// SetCommandToStderr stores what commands write to standard error, which
+// only Output returns apart from their output.
+func (f *FakeCommandRunner) SetCommandToStderr(cmdToStderr map[string]string) {
+	for k, v := range cmdToStderr {
+		f.stderrMap.Store(k, v)
+	}
+}
+
 // SetFileToContents stores the file to contents map for the FakeCommandRunner
 func (f *FakeCommandRunner) GetFileToContents(filename string) (string, error) {
 	contents, ok := f.fileMap.Load(filename)
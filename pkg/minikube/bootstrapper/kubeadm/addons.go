@@ -0,0 +1,176 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// kubeadmDNSAddon is the bundled addon kubeadm replaces with its own DNS,
+// which is never installed.
+const kubeadmDNSAddon = "kube-dns"
+
+// addonFiles returns the files of the bundled addon name.
+func addonFiles(name string) ([]assets.CopyableFile, error) {
+	addon, ok := assets.Addons[name]
+	if !ok || name == kubeadmDNSAddon {
+		return nil, fmt.Errorf("unknown addon %q", name)
+	}
+	var files []assets.CopyableFile
+	for _, a := range addon.Assets {
+		files = append(files, a)
+	}
+	return files, nil
+}
+
+// targetPath returns where f is copied to on the node.
+func targetPath(f assets.CopyableFile) string {
+	return path.Join(f.GetTargetDir(), f.GetTargetName())
+}
+
+// isAddonResource returns whether f is applied by the addon manager, rather
+// than run by kubelet as a static pod, like the addon manager itself.
+func isAddonResource(f assets.CopyableFile) bool {
+	return path.Clean(f.GetTargetDir()) == constants.AddonsPath
+}
+
+// GetInstalledAddons returns the names of the bundled addons whose files are
+// all on the node, sorted. The addon manager applies the files in
+// constants.AddonsPath, and kubelet runs those in its static pods
+// directory, so these are the addons the cluster runs, or soon will.
+func (k *KubeadmBootstrapper) GetInstalledAddons() ([]string, error) {
+	present, err := k.nodeAddonFiles()
+	if err != nil {
+		return nil, err
+	}
+	var installed []string
+	for name := range assets.Addons {
+		if name == kubeadmDNSAddon {
+			continue
+		}
+		files, err := addonFiles(name)
+		if err != nil {
+			return nil, err
+		}
+		if addonInstalled(present, files) {
+			installed = append(installed, name)
+		}
+	}
+	sort.Strings(installed)
+	return installed, nil
+}
+
+// nodeAddonFiles returns the paths of the files in the node's addons and
+// static pods directories.
+func (k *KubeadmBootstrapper) nodeAddonFiles() (map[string]bool, error) {
+	cmd := bootstrapper.Sudo(fmt.Sprintf("find %s %s -maxdepth 1 -type f 2>/dev/null || true", constants.AddonsPath, staticPodsDir))
+	out, err := k.c.CombinedOutput(cmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing addon files")
+	}
+	present := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			present[line] = true
+		}
+	}
+	return present, nil
+}
+
+// addonInstalled returns whether all of files are present.
+func addonInstalled(present map[string]bool, files []assets.CopyableFile) bool {
+	for _, f := range files {
+		if !present[targetPath(f)] {
+			return false
+		}
+	}
+	return len(files) > 0
+}
+
+// EnableAddon installs the bundled addon name on the running cluster, from
+// assets.Addons, applying its resources right away rather than waiting for
+// the addon manager to. It doesn't change whether the addon is enabled in
+// minikube's config, so it's reinstalled on restart only if it is.
+func (k *KubeadmBootstrapper) EnableAddon(name string) error {
+	files, err := addonFiles(name)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(k.enableAddon(files), "enabling addon %s", name)
+}
+
+func (k *KubeadmBootstrapper) enableAddon(files []assets.CopyableFile) error {
+	for _, f := range files {
+		if err := bootstrapper.CopyAtomically(k.c, f); err != nil {
+			return errors.Wrapf(err, "copying %s", targetPath(f))
+		}
+	}
+	for _, f := range files {
+		if !isAddonResource(f) {
+			continue
+		}
+		cmd := kubectl("apply -f " + targetPath(f))
+		if out, err := k.c.CombinedOutput(cmd); err != nil {
+			return errors.Wrapf(err, "applying %s: %s", targetPath(f), out)
+		}
+	}
+	return nil
+}
+
+// DisableAddon uninstalls the bundled addon name from the running cluster,
+// deleting its resources and removing its files, so that the addon manager
+// doesn't recreate them. Like EnableAddon, it leaves minikube's config as
+// it is.
+func (k *KubeadmBootstrapper) DisableAddon(name string) error {
+	files, err := addonFiles(name)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(k.disableAddon(files), "disabling addon %s", name)
+}
+
+func (k *KubeadmBootstrapper) disableAddon(files []assets.CopyableFile) error {
+	present, err := k.nodeAddonFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		// The resources can only be deleted by their files on the node,
+		// and there's nothing to delete without them.
+		if !isAddonResource(f) || !present[targetPath(f)] {
+			continue
+		}
+		cmd := kubectl("delete --ignore-not-found -f " + targetPath(f))
+		if out, err := k.c.CombinedOutput(cmd); err != nil {
+			return errors.Wrapf(err, "deleting %s: %s", targetPath(f), out)
+		}
+	}
+	for _, f := range files {
+		if err := k.c.Run(bootstrapper.Sudo("rm -f " + targetPath(f))); err != nil {
+			return errors.Wrapf(err, "removing %s", targetPath(f))
+		}
+	}
+	return nil
+}
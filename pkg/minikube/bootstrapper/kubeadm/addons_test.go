@@ -0,0 +1,170 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+var findAddonsCmd = bootstrapper.Sudo("find /etc/kubernetes/addons /etc/kubernetes/manifests -maxdepth 1 -type f 2>/dev/null || true")
+
+// recordingRunner records the commands it runs, in order.
+type recordingRunner struct {
+	*bootstrapper.FakeCommandRunner
+	commands []string
+}
+
+func (r *recordingRunner) Run(cmd string) error {
+	r.commands = append(r.commands, cmd)
+	return r.FakeCommandRunner.Run(cmd)
+}
+
+func (r *recordingRunner) CombinedOutput(cmd string) (string, error) {
+	r.commands = append(r.commands, cmd)
+	return r.FakeCommandRunner.CombinedOutput(cmd)
+}
+
+func (r *recordingRunner) RunContext(_ context.Context, cmd string) error {
+	return r.Run(cmd)
+}
+
+func (r *recordingRunner) CombinedOutputContext(_ context.Context, cmd string) (string, error) {
+	return r.CombinedOutput(cmd)
+}
+
+func testAddonFiles() []assets.CopyableFile {
+	return []assets.CopyableFile{
+		assets.NewMemoryAssetTarget([]byte("registry rc"), "/etc/kubernetes/addons/registry-rc.yaml", "0640"),
+		assets.NewMemoryAssetTarget([]byte("registry svc"), "/etc/kubernetes/addons/registry-svc.yaml", "0640"),
+		assets.NewMemoryAssetTarget([]byte("registry pod"), "/etc/kubernetes/manifests/registry-proxy.yaml", "0640"),
+	}
+}
+
+func TestEnableAddon(t *testing.T) {
+	r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+	r.SetCommandToOutput(map[string]string{
+		"sudo mv -f /etc/kubernetes/addons/.registry-rc.yaml.tmp /etc/kubernetes/addons/registry-rc.yaml":             "",
+		"sudo mv -f /etc/kubernetes/addons/.registry-svc.yaml.tmp /etc/kubernetes/addons/registry-svc.yaml":           "",
+		"sudo mv -f /etc/kubernetes/manifests/.registry-proxy.yaml.tmp /etc/kubernetes/manifests/registry-proxy.yaml": "",
+		kubectl("apply -f /etc/kubernetes/addons/registry-rc.yaml"):                                                   "replicationcontroller \"registry\" created",
+	})
+	k := KubeadmBootstrapper{c: r}
+
+	err := k.enableAddon(testAddonFiles())
+	if err == nil || !strings.Contains(err.Error(), "applying /etc/kubernetes/addons/registry-svc.yaml") {
+		t.Fatalf("Expected an error applying the service, got %v", err)
+	}
+	for _, f := range []string{".registry-rc.yaml.tmp", ".registry-svc.yaml.tmp"} {
+		if _, ok := r.files["/etc/kubernetes/addons/"+f]; !ok {
+			t.Errorf("Expected %s to be copied, got %v", f, r.files)
+		}
+	}
+
+	r.SetCommandToOutput(map[string]string{kubectl("apply -f /etc/kubernetes/addons/registry-svc.yaml"): "service \"registry\" created"})
+	if err := k.enableAddon(testAddonFiles()); err != nil {
+		t.Errorf("Error enabling addon: %s", err)
+	}
+}
+
+func TestDisableAddon(t *testing.T) {
+	cases := []struct {
+		description string
+		present     string
+		commands    []string
+	}{
+		{
+			description: "installed",
+			present:     "/etc/kubernetes/addons/registry-rc.yaml\n/etc/kubernetes/addons/registry-svc.yaml\n/etc/kubernetes/manifests/registry-proxy.yaml\n",
+			commands: []string{
+				kubectl("delete --ignore-not-found -f /etc/kubernetes/addons/registry-rc.yaml"),
+				kubectl("delete --ignore-not-found -f /etc/kubernetes/addons/registry-svc.yaml"),
+			},
+		},
+		{
+			description: "partly installed",
+			present:     "/etc/kubernetes/addons/registry-svc.yaml\n",
+			commands: []string{
+				kubectl("delete --ignore-not-found -f /etc/kubernetes/addons/registry-svc.yaml"),
+			},
+		},
+		{
+			description: "not installed",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := &recordingRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner()}
+			r.SetCommandToOutput(map[string]string{findAddonsCmd: test.present})
+			for _, cmd := range test.commands {
+				r.SetCommandToOutput(map[string]string{cmd: ""})
+			}
+			for _, f := range testAddonFiles() {
+				r.SetCommandToOutput(map[string]string{"sudo rm -f " + targetPath(f): ""})
+			}
+			k := KubeadmBootstrapper{c: r}
+
+			if err := k.disableAddon(testAddonFiles()); err != nil {
+				t.Fatalf("Error disabling addon: %s", err)
+			}
+			var deleted []string
+			for _, cmd := range r.commands {
+				if strings.Contains(cmd, " delete ") {
+					deleted = append(deleted, cmd)
+				}
+			}
+			if !reflect.DeepEqual(deleted, test.commands) {
+				t.Errorf("Expected deletes %v, got %v", test.commands, deleted)
+			}
+			if removed := r.commands[len(r.commands)-1]; removed != "sudo rm -f /etc/kubernetes/manifests/registry-proxy.yaml" {
+				t.Errorf("Expected the addon's files to be removed last, got %q", removed)
+			}
+		})
+	}
+}
+
+func TestGetInstalledAddons(t *testing.T) {
+	r := bootstrapper.NewFakeCommandRunner()
+	r.SetCommandToOutput(map[string]string{findAddonsCmd: `/etc/kubernetes/manifests/addon-manager.yaml
+/etc/kubernetes/manifests/kube-apiserver.yaml
+/etc/kubernetes/addons/registry-rc.yaml
+/etc/kubernetes/addons/registry-svc.yaml
+/etc/kubernetes/addons/dashboard-rc.yaml
+/etc/kubernetes/addons/kube-dns-controller.yaml
+/etc/kubernetes/addons/kube-dns-cm.yaml
+/etc/kubernetes/addons/kube-dns-svc.yaml
+`})
+	k := KubeadmBootstrapper{c: r}
+
+	installed, err := k.GetInstalledAddons()
+	if err != nil {
+		t.Fatalf("Error getting installed addons: %s", err)
+	}
+	if expected := []string{"addon-manager", "registry"}; !reflect.DeepEqual(installed, expected) {
+		t.Errorf("Expected installed addons %v, got %v", expected, installed)
+	}
+
+	if err := k.EnableAddon("kube-dns"); err == nil {
+		t.Error("Expected an error enabling kube-dns, which kubeadm replaces, got nil")
+	}
+}
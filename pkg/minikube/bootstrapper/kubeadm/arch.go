@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// releaseArches maps the machine hardware names reported by uname to the
+// architectures Kubernetes releases are built for.
+var releaseArches = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm",
+	"armv8l":  "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+// releaseArch returns the Kubernetes release architecture for a machine
+// hardware name, as reported by uname -m.
+func releaseArch(machine string) (string, error) {
+	arch, ok := releaseArches[strings.TrimSpace(machine)]
+	if !ok {
+		return "", fmt.Errorf("unsupported node architecture %q: Kubernetes binaries are not released for it", strings.TrimSpace(machine))
+	}
+	return arch, nil
+}
+
+// nodeArch returns the Kubernetes release architecture of the node.
+func (k *KubeadmBootstrapper) nodeArch() (string, error) {
+	out, err := k.c.CombinedOutput("uname -m")
+	if err != nil {
+		return "", errors.Wrap(err, "detecting node architecture")
+	}
+	return releaseArch(out)
+}
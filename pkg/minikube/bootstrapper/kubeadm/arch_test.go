@@ -0,0 +1,71 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestNodeArch(t *testing.T) {
+	cases := []struct {
+		description string
+		machine     string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "x86_64",
+			machine:     "x86_64\n",
+			expected:    "amd64",
+		},
+		{
+			description: "aarch64",
+			machine:     "aarch64\n",
+			expected:    "arm64",
+		},
+		{
+			description: "armv7l",
+			machine:     "armv7l\n",
+			expected:    "arm",
+		},
+		{
+			description: "unsupported",
+			machine:     "mips\n",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{"uname -m": test.machine})
+			k := KubeadmBootstrapper{c: f}
+			arch, err := k.nodeArch()
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error, got %s", arch)
+			}
+			if arch != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, arch)
+			}
+		})
+	}
+}
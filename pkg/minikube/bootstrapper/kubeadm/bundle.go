@@ -0,0 +1,167 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+)
+
+// bundleLogLines is the number of lines of each log collected in a bundle.
+const bundleLogLines = 1000
+
+// bundleFile is a single file in a logs bundle.
+type bundleFile struct {
+	name     string
+	contents string
+}
+
+// redactions are applied to everything written to a logs bundle so that
+// bundles can be attached to public bug reports.
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)((?:token|password|secret|client-key-data)["']?\s*[:=]\s*["']?)[^\s"',]+`), "${1}<redacted>"},
+	{regexp.MustCompile(`(--token\s+)\S+`), "${1}<redacted>"},
+	{regexp.MustCompile(`(?i)(bearer\s+)\S+`), "${1}<redacted>"},
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "<redacted private key>"},
+}
+
+func redact(s string) string {
+	for _, r := range redactions {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+	return s
+}
+
+// getPodsOutput lists the pods in all namespaces. It is a variable so that
+// tests don't need a running apiserver.
+var getPodsOutput = func() (string, error) {
+	client, err := util.GetClient()
+	if err != nil {
+		return "", errors.Wrap(err, "getting k8s client")
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "listing pods")
+	}
+	b := bytes.Buffer{}
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSTATUS\tRESTARTS\tIP\tNODE")
+	for _, pod := range pods.Items {
+		restarts := int32(0)
+		for _, s := range pod.Status.ContainerStatuses {
+			restarts += s.RestartCount
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", pod.Namespace, pod.Name, pod.Status.Phase, restarts, pod.Status.PodIP, pod.Spec.NodeName)
+	}
+	w.Flush()
+	return b.String(), nil
+}
+
+// bundleFiles collects the files for a support bundle. Collection is best
+// effort: a file that can't be collected contains the error instead, so a
+// broken cluster still produces a useful bundle.
+func (k *KubeadmBootstrapper) bundleFiles() []bundleFile {
+	var files []bundleFile
+	collect := func(name string, fn func() (string, error)) {
+		out, err := fn()
+		if err != nil {
+			out = fmt.Sprintf("%s\nError collecting %s: %v\n", out, name, err)
+		}
+		files = append(files, bundleFile{name: name, contents: redact(out)})
+	}
+	run := func(cmd string) func() (string, error) {
+		return func() (string, error) { return k.c.CombinedOutput(cmd) }
+	}
+
+	collect("status.txt", k.GetClusterStatus)
+	collect("kubeadm-version.txt", run(bootstrapper.Sudo("/usr/bin/kubeadm version")))
+	collect("node.txt", run(kubectl("describe nodes")))
+	collect("kubelet.log", run(bootstrapper.Sudo(fmt.Sprintf("journalctl -n %d -u kubelet", bundleLogLines))))
+	collect("kubelet-previous-boot.log", func() (string, error) {
+		opts := bootstrapper.LogOptions{Tail: bundleLogLines, PreviousBoot: true}
+		out, err := k.c.CombinedOutput(getLogsCommand(opts, 0))
+		if err := checkPreviousBoot(opts, out, err); err != nil {
+			return err.Error() + "\n", nil
+		}
+		return out, err
+	})
+	for _, component := range logComponents() {
+		opts := bootstrapper.LogOptions{Component: component, Tail: bundleLogLines}
+		collect(component+".log", func() (string, error) { return k.getComponentLogs(context.Background(), opts) })
+	}
+	collect("kubeadm.yaml", run(bootstrapper.Sudo("cat "+constants.KubeadmConfigFile)))
+	collect("kubeadm-init.log", run(readInitLogCmd))
+	collect("kubeadm-init.log"+previousInitLogSuffix, run(readInitLogCmd+previousInitLogSuffix))
+	collect("kubelet.service", run(bootstrapper.Sudo(fmt.Sprintf("cat %s %s", constants.KubeletServiceFile, constants.KubeletSystemdConfFile))))
+	collect("containers.txt", run(bootstrapper.Sudo("crictl ps -a 2>/dev/null || docker ps -a")))
+	collect("pods.txt", getPodsOutput)
+	collect("events.txt", func() (string, error) { return k.GetClusterEvents(metav1.NamespaceAll) })
+
+	return files
+}
+
+// LogsBundle writes the diagnostics needed for a bug report to w, as
+// ExportSupportBundle does.
+func (k *KubeadmBootstrapper) LogsBundle(w io.Writer) error {
+	return k.ExportSupportBundle(w)
+}
+
+// ExportSupportBundle writes a gzipped tarball containing the diagnostics
+// needed for a bug report to w: the cluster status, kubeadm version and node
+// description, the kubelet and component logs, the kubelet logs from the
+// previous boot, the kubeadm config, init output and kubelet unit from the
+// node, the node's containers and the cluster's pods and events. Secrets
+// and tokens are redacted.
+func (k *KubeadmBootstrapper) ExportSupportBundle(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range k.bundleFiles() {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.contents)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return errors.Wrapf(err, "writing bundle header for %s", f.name)
+		}
+		if _, err := io.WriteString(tw, f.contents); err != nil {
+			return errors.Wrapf(err, "writing %s to bundle", f.name)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "closing bundle tar")
+	}
+	return gz.Close()
+}
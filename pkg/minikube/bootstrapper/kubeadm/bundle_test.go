@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+func readBundle(t *testing.T, b []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Error reading gzip: %s", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading tar: %s", err)
+		}
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Error reading %s: %s", hdr.Name, err)
+		}
+		files[hdr.Name] = string(contents)
+	}
+	return files
+}
+
+func TestExportSupportBundle(t *testing.T) {
+	getPodsOutput = func() (string, error) { return "", errors.New("connection refused") }
+
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		kubeletStatusCommand:                                                   "Running\n",
+		"sudo /usr/bin/kubeadm version":                                        "kubeadm version: v1.8.0",
+		"sudo journalctl -n 1000 -u kubelet":                                   "kubelet started",
+		"sudo journalctl -n 1000 -b -1 -u kubelet":                             "Specifying boot ID or boot offset has no effect, no persistent journal was found.",
+		listContainersCommand("kube-apiserver"):                                "abc123\n",
+		listContainersCommand("kube-controller-manager"):                       "",
+		containerLogsCommand("abc123", bootstrapper.LogOptions{Tail: 1000}, 0): "apiserver log --token abcdef.0123456789abcdef",
+		"sudo cat " + constants.KubeadmConfigFile:                              "token: abcdef.0123456789abcdef\nnodeName: minikube\n",
+		readInitLogCmd: "kubeadm join --token abcdef.0123456789abcdef 192.168.99.100:8443",
+		bootstrapper.Sudo("crictl ps -a 2>/dev/null || docker ps -a"): "CONTAINER ID",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	var b bytes.Buffer
+	if err := k.ExportSupportBundle(&b); err != nil {
+		t.Fatalf("Error writing bundle: %s", err)
+	}
+	files := readBundle(t, b.Bytes())
+
+	expected := map[string]string{
+		"kubelet.log":                 "kubelet started",
+		"kubelet-previous-boot.log":   "no previous boot found",
+		"kube-apiserver.log":          "apiserver log --token <redacted>",
+		"kube-controller-manager.log": "kube-controller-manager is not running",
+		"kubeadm.yaml":                "token: <redacted>\nnodeName: minikube",
+		"kubeadm-init.log":            "kubeadm join --token <redacted>",
+		"kubeadm-init.log.1":          "Error collecting kubeadm-init.log.1",
+		"containers.txt":              "CONTAINER ID",
+		"pods.txt":                    "connection refused",
+		"kube-scheduler.log":          "Error collecting kube-scheduler.log",
+		"status.txt":                  "Running",
+		"kubeadm-version.txt":         "kubeadm version: v1.8.0",
+		"node.txt":                    "Error collecting node.txt",
+		"dns.log":                     "Error collecting dns.log",
+		"events.txt":                  "the apiserver isn't ready",
+	}
+	for name, contents := range expected {
+		if !strings.Contains(files[name], contents) {
+			t.Errorf("Expected %s to contain %q, got %q", name, contents, files[name])
+		}
+	}
+	if strings.Contains(b.String(), "0123456789abcdef") {
+		t.Error("Expected token to be redacted from the bundle")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"password: hunter2":                       "password: <redacted>",
+		`"token":"abc"`:                           `"token":"<redacted>"`,
+		"--token=abc.def":                         "--token=<redacted>",
+		"Authorization: Bearer abc":               "Authorization: Bearer <redacted>",
+		"client-key-data: LS0tLS1CRUdJTg==":       "client-key-data: <redacted>",
+		"client-certificate-data: LS0tLS1CRUdJTg": "client-certificate-data: LS0tLS1CRUdJTg",
+	}
+	for in, expected := range cases {
+		if out := redact(in); out != expected {
+			t.Errorf("redact(%q): expected %q, got %q", in, expected, out)
+		}
+	}
+}
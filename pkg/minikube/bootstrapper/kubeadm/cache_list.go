@@ -0,0 +1,264 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/version"
+)
+
+// checksumSuffix is appended to a cached binary's path for the file
+// recording its SHA256 checksum, in sha256sum's format.
+const checksumSuffix = ".sha256"
+
+// cacheableBinaries are the Kubernetes binaries kept in the cache.
+var cacheableBinaries = map[string]bool{"kubelet": true, "kubeadm": true, "kubectl": true}
+
+// CachedBinary is a Kubernetes binary in the cache.
+type CachedBinary struct {
+	Binary  string
+	Version string
+	// OS and Arch are the platform the binary is built for.
+	OS   string
+	Arch string
+	Path string
+	Size int64
+	// Verified is whether the binary matches the checksum recorded when it
+	// was downloaded. Binaries without a recorded checksum, e.g. cached by
+	// hand, aren't verified.
+	Verified bool
+}
+
+// ListCachedBinaries returns the Kubernetes binaries in the cache, by
+// version, then platform. Files which aren't cached binaries are ignored.
+func ListCachedBinaries() ([]CachedBinary, error) {
+	return listCachedBinaries(constants.MakeMiniPath("cache"))
+}
+
+func listCachedBinaries(cacheDir string) ([]CachedBinary, error) {
+	entries, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", cacheDir)
+	}
+
+	var names []string
+	for _, v := range entries {
+		if v.IsDir() && isKubernetesVersion(v.Name()) {
+			names = append(names, v.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return semver.MustParse(strings.TrimPrefix(names[i], version.VersionPrefix)).LT(
+			semver.MustParse(strings.TrimPrefix(names[j], version.VersionPrefix)))
+	})
+
+	var binaries []CachedBinary
+	for _, v := range names {
+		versionDir := filepath.Join(cacheDir, v)
+		found, err := listPlatformBinaries(versionDir, v, constants.NodeOS, constants.NodeArch)
+		if err != nil {
+			return nil, err
+		}
+		binaries = append(binaries, found...)
+
+		platforms, err := ioutil.ReadDir(versionDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", versionDir)
+		}
+		for _, p := range platforms {
+			parts := strings.SplitN(p.Name(), "-", 2)
+			if !p.IsDir() || len(parts) != 2 {
+				continue
+			}
+			found, err := listPlatformBinaries(filepath.Join(versionDir, p.Name()), v, parts[0], parts[1])
+			if err != nil {
+				return nil, err
+			}
+			binaries = append(binaries, found...)
+		}
+	}
+	return binaries, nil
+}
+
+// listPlatformBinaries returns the binaries for goos and goarch in dir.
+func listPlatformBinaries(dir, v, goos, goarch string) ([]CachedBinary, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", dir)
+	}
+	var binaries []CachedBinary
+	for _, f := range files {
+		name := f.Name()
+		if goos == "windows" {
+			if !strings.HasSuffix(name, ".exe") {
+				continue
+			}
+			name = strings.TrimSuffix(name, ".exe")
+		}
+		if f.IsDir() || !cacheableBinaries[name] {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		binaries = append(binaries, CachedBinary{
+			Binary:   name,
+			Version:  v,
+			OS:       goos,
+			Arch:     goarch,
+			Path:     path,
+			Size:     f.Size(),
+			Verified: verifyChecksumFile(path) == nil,
+		})
+	}
+	return binaries, nil
+}
+
+// isKubernetesVersion returns whether name is a Kubernetes version, as used
+// for the cache's version directories.
+func isKubernetesVersion(name string) bool {
+	if !strings.HasPrefix(name, version.VersionPrefix) {
+		return false
+	}
+	_, err := semver.Parse(strings.TrimPrefix(name, version.VersionPrefix))
+	return err == nil
+}
+
+// writeChecksumFile records the SHA256 checksum of the file at path, so
+// that it can be verified later.
+func writeChecksumFile(path string) error {
+	sum, err := fileChecksum(path, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+checksumSuffix, []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))), 0644)
+}
+
+// verifyChecksumFile returns an error unless the file at path matches its
+// recorded checksum.
+func verifyChecksumFile(path string) error {
+	recorded, err := ioutil.ReadFile(path + checksumSuffix)
+	if err != nil {
+		return errors.Wrap(err, "reading checksum")
+	}
+	fields := strings.Fields(string(recorded))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file %s", path+checksumSuffix)
+	}
+	actual, err := fileChecksum(path, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	if actual != fields[0] {
+		return fmt.Errorf("checksum mismatch for %s: recorded %s, got %s", path, fields[0], actual)
+	}
+	return nil
+}
+
+// verifyCached returns whether the cached binary at path is intact. It's
+// verified against its recorded checksum, so that cached binaries can be
+// used offline. Only a binary cached before checksums were recorded needs
+// the published checksum, which is then recorded, and if it can't be
+// downloaded the binary is used unverified. k8s.ForceVerify always checks
+// the published checksum, and warns if it differs from the recorded one.
+func (d *downloader) verifyCached(ctx context.Context, path, sha256URL, sha1URL string, k8s bootstrapper.KubernetesConfig) (bool, error) {
+	_, err := os.Stat(path + checksumSuffix)
+	recorded := err == nil
+	if recorded && !k8s.ForceVerify {
+		if err := verifyChecksumFile(path); err != nil {
+			glog.Warningf("Verifying %s: %s", path, err)
+			return false, nil
+		}
+		return true, nil
+	}
+	if !recorded && !os.IsNotExist(err) {
+		return false, errors.Wrap(err, "checking for recorded checksum")
+	}
+
+	if k8s.Offline {
+		if k8s.ForceVerify {
+			return false, errors.New("verification was forced, but downloads are disabled in offline mode")
+		}
+		glog.Warningf("No checksum is recorded for %s, and downloads are disabled, so it's used unverified", path)
+		return true, nil
+	}
+	published, hash, err := d.publishedChecksum(ctx, sha256URL, sha1URL, filepath.Base(path))
+	if err != nil {
+		if k8s.ForceVerify {
+			return false, errors.Wrap(err, "downloading checksum")
+		}
+		glog.Warningf("No checksum is recorded for %s, and downloading it failed, so it's used unverified: %s", path, err)
+		return true, nil
+	}
+
+	if recorded && hash == crypto.SHA256 {
+		b, err := ioutil.ReadFile(path + checksumSuffix)
+		if err != nil {
+			return false, errors.Wrap(err, "reading checksum")
+		}
+		if fields := strings.Fields(string(b)); len(fields) > 0 && fields[0] != published {
+			d.printf("WARNING: The published checksum of %s, %s, differs from the one recorded when it was cached, %s. The release may have been modified since.\n", sha256URL, published, fields[0])
+		}
+	}
+	actual, err := fileChecksum(path, hash)
+	if err != nil {
+		return false, err
+	}
+	if actual != published {
+		glog.Warningf("Checksum mismatch for %s: published %s, got %s", path, published, actual)
+		return false, nil
+	}
+	if err := writeChecksumFile(path); err != nil {
+		glog.Warningf("Error recording the checksum of %s: %s", path, err)
+	}
+	return true, nil
+}
+
+// publishedChecksum downloads the published checksum of the release binary
+// filename, from sha256URL, or from sha1URL for old releases that don't
+// publish SHA256 checksums. It returns the checksum and its hash.
+func (d *downloader) publishedChecksum(ctx context.Context, sha256URL, sha1URL, filename string) (string, crypto.Hash, error) {
+	checksumURL, hash := sha256URL, crypto.SHA256
+	hasSha256, err := d.exists(ctx, sha256URL)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "checking for sha256 checksum")
+	}
+	if !hasSha256 {
+		checksumURL, hash = sha1URL, crypto.SHA1
+	}
+	client := &http.Client{Transport: &contextTransport{ctx: ctx, rt: newDownloadTransport(d.proxy)}}
+	sum, err := expectedChecksum(client, checksumURL, filename)
+	if err != nil {
+		return "", 0, err
+	}
+	return sum, hash, nil
+}
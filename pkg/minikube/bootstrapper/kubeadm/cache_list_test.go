@@ -0,0 +1,235 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+func TestListCachedBinaries(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	files := map[string]string{
+		"v1.10.0/kubelet":                       "kubelet 1.10",
+		"v1.10.0/darwin-amd64/kubectl":          "darwin kubectl",
+		"v1.10.0/windows-amd64/kubectl.exe":     "windows kubectl",
+		"v1.8.0/kubelet":                        "kubelet 1.8",
+		"v1.8.0/kubeadm":                        "corrupt kubeadm",
+		"v1.8.0/kubectl":                        "kubectl cached by hand",
+		"v1.8.0/kubelet.lock":                   "123",
+		"v1.8.0/kubeadm.partial":                "kube",
+		"v1.8.0/README":                         "foreign",
+		"v1.8.0/linux-arm64/kube-apiserver":     "foreign",
+		"localkube/localkube-v1.8.0":            "localkube",
+		"images/gcr.io/google_containers/pause": "image",
+		"notes.txt":                             "foreign",
+	}
+	for name, contents := range files {
+		p := filepath.Join(cacheDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatalf("Error making dir: %s", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", p, err)
+		}
+	}
+	for _, name := range []string{"v1.10.0/kubelet", "v1.10.0/darwin-amd64/kubectl", "v1.10.0/windows-amd64/kubectl.exe", "v1.8.0/kubelet", "v1.8.0/kubeadm"} {
+		if err := writeChecksumFile(filepath.Join(cacheDir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("Error recording checksum of %s: %s", name, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(cacheDir, "v1.8.0", "kubeadm"), []byte("truncated"), 0644); err != nil {
+		t.Fatalf("Error corrupting kubeadm: %s", err)
+	}
+
+	binaries, err := listCachedBinaries(cacheDir)
+	if err != nil {
+		t.Fatalf("Error listing cached binaries: %s", err)
+	}
+	path := func(name string) string {
+		return filepath.Join(cacheDir, filepath.FromSlash(name))
+	}
+	expected := []CachedBinary{
+		{Binary: "kubeadm", Version: "v1.8.0", OS: "linux", Arch: "amd64", Path: path("v1.8.0/kubeadm"), Size: 9},
+		{Binary: "kubectl", Version: "v1.8.0", OS: "linux", Arch: "amd64", Path: path("v1.8.0/kubectl"), Size: 22},
+		{Binary: "kubelet", Version: "v1.8.0", OS: "linux", Arch: "amd64", Path: path("v1.8.0/kubelet"), Size: 11, Verified: true},
+		{Binary: "kubelet", Version: "v1.10.0", OS: "linux", Arch: "amd64", Path: path("v1.10.0/kubelet"), Size: 12, Verified: true},
+		{Binary: "kubectl", Version: "v1.10.0", OS: "darwin", Arch: "amd64", Path: path("v1.10.0/darwin-amd64/kubectl"), Size: 14, Verified: true},
+		{Binary: "kubectl", Version: "v1.10.0", OS: "windows", Arch: "amd64", Path: path("v1.10.0/windows-amd64/kubectl.exe"), Size: 15, Verified: true},
+	}
+	if !reflect.DeepEqual(binaries, expected) {
+		t.Errorf("Expected cached binaries:\n%+v\ngot:\n%+v", expected, binaries)
+	}
+}
+
+func TestListCachedBinariesEmpty(t *testing.T) {
+	binaries, err := listCachedBinaries(filepath.Join(os.TempDir(), "nonexistent-minikube-cache"))
+	if err != nil || binaries != nil {
+		t.Errorf("Expected no cached binaries and no error, got %v, %v", binaries, err)
+	}
+}
+
+func TestMaybeDownloadAndCacheChecksumSidecar(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	const contents = "kubelet binary"
+	published := fmt.Sprintf("%x", sha256.Sum256([]byte(contents)))
+	cases := []struct {
+		description string
+		cached      string
+		// sidecar is the recorded checksum, or empty for none.
+		sidecar string
+		// published is the checksum on the mirror, or empty if the mirror
+		// is unreachable.
+		published    string
+		forceVerify  bool
+		shouldErr    bool
+		expectedHits int32
+		expectedOut  string
+	}{
+		{
+			description: "offline with sidecar",
+			cached:      contents,
+			sidecar:     published,
+		},
+		{
+			description: "offline with sidecar not matching",
+			cached:      "corrupt kubelet",
+			sidecar:     published,
+			shouldErr:   true,
+			expectedOut: "doesn't match its checksum",
+		},
+		{
+			description:  "missing sidecar",
+			cached:       contents,
+			published:    published,
+			expectedHits: 2,
+		},
+		{
+			description: "missing sidecar offline",
+			cached:      contents,
+		},
+		{
+			description:  "forced",
+			cached:       contents,
+			sidecar:      published,
+			published:    published,
+			forceVerify:  true,
+			expectedHits: 2,
+		},
+		{
+			description:  "forced with changed checksum",
+			cached:       contents,
+			sidecar:      strings.Repeat("0", 64),
+			published:    published,
+			forceVerify:  true,
+			expectedHits: 2,
+			expectedOut:  "WARNING: The published checksum",
+		},
+		{
+			description: "forced unreachable",
+			cached:      contents,
+			sidecar:     published,
+			forceVerify: true,
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "minikube")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+			defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+			os.Setenv(constants.MinikubeHome, tempDir)
+
+			var hits int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				if test.published == "" || !strings.HasSuffix(r.URL.Path, ".sha256") {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprintln(w, test.published)
+			}))
+			defer server.Close()
+
+			path := cachedBinaryPath("kubelet", "v1.8.0", constants.NodeOS, constants.NodeArch)
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatalf("Error making cache dir: %s", err)
+			}
+			if err := ioutil.WriteFile(path, []byte(test.cached), 0644); err != nil {
+				t.Fatalf("Error caching kubelet: %s", err)
+			}
+			if test.sidecar != "" {
+				if err := ioutil.WriteFile(path+checksumSuffix, []byte(test.sidecar+"  kubelet\n"), 0644); err != nil {
+					t.Fatalf("Error recording checksum: %s", err)
+				}
+			}
+
+			var out bytes.Buffer
+			d := &downloader{out: &out}
+			k8s := bootstrapper.KubernetesConfig{
+				KubernetesVersion: "v1.8.0",
+				ReleaseMirror:     server.URL,
+				Offline:           test.published == "" && !test.forceVerify,
+				ForceVerify:       test.forceVerify,
+			}
+			_, err = d.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, k8s)
+			if test.shouldErr && err == nil {
+				t.Fatal("Expected an error, got nil")
+			}
+			if !test.shouldErr && err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if n := atomic.LoadInt32(&hits); n != test.expectedHits && !test.shouldErr {
+				t.Errorf("Expected %d requests to the mirror, got %d", test.expectedHits, n)
+			}
+			if !strings.Contains(out.String(), test.expectedOut) {
+				t.Errorf("Expected output to contain %q, got %q", test.expectedOut, out.String())
+			}
+			if test.published != "" && !test.shouldErr {
+				if err := verifyChecksumFile(path); err != nil {
+					t.Errorf("Expected the published checksum to be recorded: %s", err)
+				}
+			}
+		})
+	}
+}
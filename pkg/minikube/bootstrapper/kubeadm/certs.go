@@ -0,0 +1,142 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/version"
+)
+
+// certExpiryThreshold is how soon a certificate must expire for
+// CheckCertExpiry to flag it.
+var certExpiryThreshold = 30 * 24 * time.Hour
+
+// checkExpirationTimeLayout is how kubeadm certs check-expiration formats
+// expiry times, e.g. Dec 30, 2020 23:36 UTC.
+const checkExpirationTimeLayout = "Jan 02, 2006 15:04 MST"
+
+// opensslTimeLayout is how openssl x509 -enddate formats expiry times, e.g.
+// Dec 30 23:36:00 2020 GMT.
+const opensslTimeLayout = "Jan _2 15:04:05 2006 MST"
+
+// supportsCheckExpiration returns whether kubeadm can report when the
+// cluster's certificates expire, which it can from Kubernetes v1.15.
+func supportsCheckExpiration(kubernetesVersion string) bool {
+	v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix))
+	if err != nil {
+		return false
+	}
+	return v.GTE(semver.MustParse("1.15.0"))
+}
+
+// checkExpirationCommand returns the kubeadm command reporting when the
+// certificates in certDir expire. It graduated from alpha in Kubernetes
+// v1.20.
+func checkExpirationCommand(kubernetesVersion, certDir string) string {
+	certs := "alpha certs"
+	if v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix)); err == nil && v.GTE(semver.MustParse("1.20.0")) {
+		certs = "certs"
+	}
+	return bootstrapper.Sudo(fmt.Sprintf("/usr/bin/kubeadm %s check-expiration --cert-dir %s", certs, certDir))
+}
+
+// CheckCertExpiry returns when each of the certificates of the cluster
+// configured by k8s expires, flagging those which have expired or will
+// within certExpiryThreshold. kubeadm reports them where it can; for older
+// versions, the certificates in k8s's certificates directory are read
+// instead.
+func (k *KubeadmBootstrapper) CheckCertExpiry(k8s bootstrapper.KubernetesConfig) ([]bootstrapper.CertStatus, error) {
+	var certs []bootstrapper.CertStatus
+	if supportsCheckExpiration(k8s.KubernetesVersion) {
+		cmd := checkExpirationCommand(k8s.KubernetesVersion, k8s.GetCertDir())
+		out, err := k.c.CombinedOutput(cmd)
+		if err != nil {
+			return nil, errors.Wrap(err, "checking certificate expiry")
+		}
+		certs = parseCheckExpiration(out)
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("no certificates in the output of %s: %s", cmd, out)
+		}
+	} else {
+		var err error
+		if certs, err = k.readCertExpiry(k8s.GetCertDir()); err != nil {
+			return nil, err
+		}
+	}
+
+	soon := time.Now().Add(certExpiryThreshold)
+	for i := range certs {
+		certs[i].ExpiringSoon = certs[i].Expires.Before(soon)
+	}
+	return certs, nil
+}
+
+// parseCheckExpiration parses the tables of certificates, and of
+// certificate authorities for Kubernetes v1.17 and later, which kubeadm
+// certs check-expiration prints, e.g.
+//
+//	CERTIFICATE   EXPIRES                  RESIDUAL TIME   CERTIFICATE AUTHORITY   EXTERNALLY MANAGED
+//	admin.conf    Dec 30, 2020 23:36 UTC   364d                                    no
+//	apiserver     Dec 30, 2020 23:36 UTC   364d            ca                      no
+//
+// Headers, and any other lines without an expiry time, are skipped.
+func parseCheckExpiration(out string) []bootstrapper.CertStatus {
+	var certs []bootstrapper.CertStatus
+	for _, line := range splitLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		expires, err := time.Parse(checkExpirationTimeLayout, strings.Join(fields[1:6], " "))
+		if err != nil {
+			continue
+		}
+		certs = append(certs, bootstrapper.CertStatus{Name: fields[0], Expires: expires})
+	}
+	return certs
+}
+
+// readCertExpiry returns when each of the certificates in certDir which
+// DiagnoseStart checks expires, read with openssl. Missing certificates are
+// skipped.
+func (k *KubeadmBootstrapper) readCertExpiry(certDir string) ([]bootstrapper.CertStatus, error) {
+	var certs []bootstrapper.CertStatus
+	for _, cert := range diagnosedCerts {
+		out, err := k.c.CombinedOutput(bootstrapper.Sudo("openssl x509 -noout -enddate -in " + path.Join(certDir, cert)))
+		if err != nil {
+			glog.Infof("Skipping %s: %s", cert, err)
+			continue
+		}
+		expires, err := time.Parse(opensslTimeLayout, strings.TrimPrefix(strings.TrimSpace(out), "notAfter="))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing expiry of %s", cert)
+		}
+		certs = append(certs, bootstrapper.CertStatus{Name: strings.TrimSuffix(cert, ".crt"), Expires: expires})
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in %s", certDir)
+	}
+	return certs, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const checkExpirationOutput = `[check-expiration] Reading configuration from the cluster...
+
+CERTIFICATE                EXPIRES                  RESIDUAL TIME   CERTIFICATE AUTHORITY   EXTERNALLY MANAGED
+admin.conf                 Dec 30, 2020 23:36 UTC   364d                                    no
+apiserver                  Jan 02, 2020 08:05 UTC   <invalid>       ca                      no
+
+CERTIFICATE AUTHORITY   EXPIRES                  RESIDUAL TIME   EXTERNALLY MANAGED
+ca                      Dec 28, 2029 23:36 UTC   9y              no
+`
+
+func TestParseCheckExpiration(t *testing.T) {
+	expected := []bootstrapper.CertStatus{
+		{Name: "admin.conf", Expires: time.Date(2020, time.December, 30, 23, 36, 0, 0, time.UTC)},
+		{Name: "apiserver", Expires: time.Date(2020, time.January, 2, 8, 5, 0, 0, time.UTC)},
+		{Name: "ca", Expires: time.Date(2029, time.December, 28, 23, 36, 0, 0, time.UTC)},
+	}
+	certs := parseCheckExpiration(checkExpirationOutput)
+	if len(certs) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, certs)
+	}
+	for i, c := range certs {
+		if c.Name != expected[i].Name || !c.Expires.Equal(expected[i].Expires) {
+			t.Errorf("Expected %+v, got %+v", expected[i], c)
+		}
+	}
+}
+
+func TestCheckExpirationCommand(t *testing.T) {
+	cases := map[string]string{
+		"v1.15.0": "sudo /usr/bin/kubeadm alpha certs check-expiration --cert-dir /certs",
+		"v1.20.0": "sudo /usr/bin/kubeadm certs check-expiration --cert-dir /certs",
+	}
+	for v, expected := range cases {
+		if cmd := checkExpirationCommand(v, "/certs"); cmd != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, v, cmd)
+		}
+	}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour).UTC()
+	later := time.Now().Add(365 * 24 * time.Hour).UTC()
+	cases := []struct {
+		description string
+		version     string
+		outputs     map[string]string
+		expected    map[string]bool
+		shouldErr   bool
+	}{
+		{
+			description: "kubeadm",
+			version:     "v1.16.0",
+			outputs: map[string]string{
+				"sudo /usr/bin/kubeadm alpha certs check-expiration --cert-dir /certs": "CERTIFICATE   EXPIRES   RESIDUAL TIME   EXTERNALLY MANAGED\n" +
+					"admin.conf   " + later.Format(checkExpirationTimeLayout) + "   364d   no\n" +
+					"apiserver   " + soon.Format(checkExpirationTimeLayout) + "   23h   no\n",
+			},
+			expected: map[string]bool{"admin.conf": false, "apiserver": true},
+		},
+		{
+			description: "kubeadm without certificates",
+			version:     "v1.16.0",
+			outputs: map[string]string{
+				"sudo /usr/bin/kubeadm alpha certs check-expiration --cert-dir /certs": "error\n",
+			},
+			shouldErr: true,
+		},
+		{
+			description: "openssl",
+			version:     "v1.10.0",
+			outputs: map[string]string{
+				"sudo openssl x509 -noout -enddate -in /certs/ca.crt":        "notAfter=" + later.Format(opensslTimeLayout) + "\n",
+				"sudo openssl x509 -noout -enddate -in /certs/apiserver.crt": "notAfter=" + soon.Format(opensslTimeLayout) + "\n",
+			},
+			expected: map[string]bool{"ca": false, "apiserver": true},
+		},
+		{
+			description: "openssl without certificates",
+			version:     "v1.10.0",
+			outputs:     map[string]string{},
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(test.outputs)
+			k := &KubeadmBootstrapper{c: r}
+			certs, err := k.CheckCertExpiry(bootstrapper.KubernetesConfig{KubernetesVersion: test.version, CertDir: "/certs"})
+			if (err != nil) != test.shouldErr {
+				t.Fatalf("Expected error %t, got %v", test.shouldErr, err)
+			}
+			if test.shouldErr {
+				return
+			}
+			found := map[string]bool{}
+			for _, c := range certs {
+				found[c.Name] = c.ExpiringSoon
+			}
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("Expected %v, got %+v", test.expected, certs)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
+)
+
+var (
+	apiServerEndpointCommand = kubectl("config view -o jsonpath='{.clusters[0].cluster.server}'")
+	apiServerHealthCommand   = fmt.Sprintf("curl -sSfk --max-time 5 https://localhost:%d/healthz", util.APIServerPort)
+	componentStatusCommand   = kubectl("get componentstatuses -o json")
+	dnsServiceIPCommand      = kubectl("-n kube-system get service kube-dns -o jsonpath='{.spec.clusterIP}'")
+)
+
+// etcdClientPort is the port etcd serves its clients, the apiserver, on.
+const etcdClientPort = 2379
+
+// GetClusterInfo returns an overview of the cluster configured by k8s,
+// gathered from the node. Fields which can't be determined, e.g. because the
+// apiserver is down, are left empty and noted in the ClusterInfo's Errors.
+// An error is only returned if commands can't be run on the node at all.
+func (k *KubeadmBootstrapper) GetClusterInfo(k8s bootstrapper.KubernetesConfig) (*bootstrapper.ClusterInfo, error) {
+	kubelet, err := k.GetClusterStatus()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting cluster info")
+	}
+	info := &bootstrapper.ClusterInfo{KubeletStatus: kubelet, Errors: map[string]string{}}
+	note := func(field string, err error) {
+		info.Errors[field] = err.Error()
+	}
+
+	if info.APIServer, err = k.getAPIServerEndpoint(); err != nil {
+		note("APIServer", err)
+	}
+	if info.KubernetesVersion, err = k.GetRunningVersion(); err != nil {
+		note("KubernetesVersion", err)
+	}
+	if info.Components, err = k.getComponentHealth(); err != nil {
+		note("Components", err)
+	}
+	info.Etcd = k.getEtcdHealth(k8s)
+	if info.DNSServiceIP, err = k.getDNSServiceIP(); err != nil {
+		note("DNSServiceIP", err)
+	}
+	return info, nil
+}
+
+// getAPIServerEndpoint returns the apiserver's IP:port, from the admin
+// kubeconfig kubeadm wrote.
+func (k *KubeadmBootstrapper) getAPIServerEndpoint() (string, error) {
+	res, err := k.c.Output(apiServerEndpointCommand)
+	if err != nil {
+		return "", errors.Wrap(err, "reading apiserver endpoint")
+	}
+	u, err := url.Parse(strings.TrimSpace(res.Stdout))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid apiserver URL %q", strings.TrimSpace(res.Stdout))
+	}
+	return u.Host, nil
+}
+
+// getComponentHealth returns the health of the apiserver, followed by that
+// of the components in its componentstatuses. Only the apiserver's is
+// returned if it isn't healthy.
+func (k *KubeadmBootstrapper) getComponentHealth() ([]bootstrapper.ComponentHealth, error) {
+	apiserver := bootstrapper.ComponentHealth{Name: "apiserver", Healthy: true}
+	if out, err := k.c.CombinedOutput(apiServerHealthCommand); err != nil {
+		apiserver.Healthy = false
+		apiserver.Message = strings.TrimSpace(fmt.Sprintf("%v: %s", err, out))
+		return []bootstrapper.ComponentHealth{apiserver}, nil
+	}
+
+	// Only stdout is parsed, as kubectl warns on stderr that
+	// componentstatuses are deprecated from Kubernetes v1.19.
+	res, err := k.c.Output(componentStatusCommand)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting component statuses")
+	}
+	var statuses struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Message string `json:"message"`
+				Error   string `json:"error"`
+			} `json:"conditions"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(res.Stdout), &statuses); err != nil {
+		return nil, errors.Wrap(err, "parsing component statuses")
+	}
+
+	health := []bootstrapper.ComponentHealth{apiserver}
+	for _, item := range statuses.Items {
+		h := bootstrapper.ComponentHealth{Name: item.Metadata.Name}
+		for _, c := range item.Conditions {
+			if c.Type != "Healthy" {
+				continue
+			}
+			h.Healthy = c.Status == "True"
+			if !h.Healthy {
+				h.Message = c.Error
+				if h.Message == "" {
+					h.Message = c.Message
+				}
+			}
+		}
+		health = append(health, h)
+	}
+	return health, nil
+}
+
+// etcdHealthCommand returns the command that gets etcd's health from its
+// /health endpoint, the check etcdctl endpoint health makes, without needing
+// the apiserver. From Kubernetes v1.10 kubeadm serves etcd over TLS, which
+// is checked with the healthcheck client certificate kubeadm generates in
+// the cluster's certificates directory.
+func etcdHealthCommand(k8s bootstrapper.KubernetesConfig) string {
+	v, err := semver.Make(strings.TrimPrefix(k8s.KubernetesVersion, version.VersionPrefix))
+	if err == nil && v.LT(semver.MustParse("1.10.0")) {
+		return fmt.Sprintf("curl -sSf --max-time 5 http://127.0.0.1:%d/health", etcdClientPort)
+	}
+	certs := path.Join(k8s.GetCertDir(), "etcd")
+	return bootstrapper.Sudo(fmt.Sprintf("curl -sSf --max-time 5 --cacert %s --cert %s --key %s https://127.0.0.1:%d/health",
+		path.Join(certs, "ca.crt"), path.Join(certs, "healthcheck-client.crt"), path.Join(certs, "healthcheck-client.key"), etcdClientPort))
+}
+
+// getEtcdHealth returns etcd's health, checked directly rather than through
+// the apiserver, so that an apiserver crashlooping because etcd won't start
+// can be told from one failing by itself.
+func (k *KubeadmBootstrapper) getEtcdHealth(k8s bootstrapper.KubernetesConfig) bootstrapper.ComponentHealth {
+	etcd := bootstrapper.ComponentHealth{Name: "etcd"}
+	res, err := k.c.Output(etcdHealthCommand(k8s))
+	if err != nil {
+		etcd.Message = strings.TrimSpace(err.Error())
+		return etcd
+	}
+	out := res.Stdout
+	var health struct {
+		Health string `json:"health"`
+	}
+	if err := json.Unmarshal([]byte(out), &health); err != nil {
+		etcd.Message = fmt.Sprintf("parsing etcd health %q: %v", strings.TrimSpace(out), err)
+		return etcd
+	}
+	etcd.Healthy = health.Health == "true"
+	if !etcd.Healthy {
+		etcd.Message = "etcd reports it's unhealthy: " + strings.TrimSpace(out)
+	}
+	return etcd
+}
+
+// getDNSServiceIP returns the cluster IP of the cluster's DNS service.
+func (k *KubeadmBootstrapper) getDNSServiceIP() (string, error) {
+	res, err := k.c.Output(dnsServiceIPCommand)
+	if err != nil {
+		return "", errors.Wrap(err, "getting DNS service")
+	}
+	ip := strings.TrimSpace(res.Stdout)
+	if ip == "" {
+		return "", errors.New("DNS service has no cluster IP")
+	}
+	return ip, nil
+}
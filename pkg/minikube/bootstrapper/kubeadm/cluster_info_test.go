@@ -0,0 +1,213 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+var kubeletStatusCommand = bootstrapper.Sudo(`systemctl is-active kubelet &>/dev/null && echo "Running" || echo "Stopped"`)
+
+const componentStatuses = `{
+  "items": [
+    {"metadata": {"name": "controller-manager"}, "conditions": [{"type": "Healthy", "status": "True", "message": "ok"}]},
+    {"metadata": {"name": "etcd-0"}, "conditions": [{"type": "Healthy", "status": "False", "error": "connection refused"}]}
+  ]
+}`
+
+func TestGetClusterInfo(t *testing.T) {
+	cases := []struct {
+		description string
+		outputs     map[string]string
+		stderrs     map[string]string
+		expected    bootstrapper.ClusterInfo
+		errorFields []string
+		shouldErr   bool
+	}{
+		{
+			description: "running",
+			outputs: map[string]string{
+				kubeletStatusCommand:     "Running\n",
+				apiServerEndpointCommand: "https://192.168.99.100:8443",
+				"curl -sSfk --max-time 5 https://localhost:8443/version": `{"gitVersion": "v1.8.0"}`,
+				apiServerHealthCommand: "ok",
+				componentStatusCommand: componentStatuses,
+				dnsServiceIPCommand:    "10.0.0.10",
+				"curl -sSf --max-time 5 http://127.0.0.1:2379/health": `{"health": "true"}`,
+			},
+			expected: bootstrapper.ClusterInfo{
+				APIServer:         "192.168.99.100:8443",
+				KubernetesVersion: "v1.8.0",
+				KubeletStatus:     "Running",
+				Components: []bootstrapper.ComponentHealth{
+					{Name: "apiserver", Healthy: true},
+					{Name: "controller-manager", Healthy: true},
+					{Name: "etcd-0"},
+				},
+				Etcd:         bootstrapper.ComponentHealth{Name: "etcd", Healthy: true},
+				DNSServiceIP: "10.0.0.10",
+			},
+		},
+		{
+			// kubectl warns on stderr that componentstatuses are
+			// deprecated, which mustn't break parsing their JSON.
+			description: "componentstatuses deprecated",
+			outputs: map[string]string{
+				kubeletStatusCommand:     "Running\n",
+				apiServerEndpointCommand: "https://192.168.99.100:8443",
+				"curl -sSfk --max-time 5 https://localhost:8443/version": `{"gitVersion": "v1.19.0"}`,
+				apiServerHealthCommand: "ok",
+				componentStatusCommand: componentStatuses,
+				dnsServiceIPCommand:    "10.0.0.10",
+				"curl -sSf --max-time 5 http://127.0.0.1:2379/health": `{"health": "true"}`,
+			},
+			stderrs: map[string]string{
+				componentStatusCommand: "Warning: v1 ComponentStatus is deprecated in v1.19+\n",
+			},
+			expected: bootstrapper.ClusterInfo{
+				APIServer:         "192.168.99.100:8443",
+				KubernetesVersion: "v1.19.0",
+				KubeletStatus:     "Running",
+				Components: []bootstrapper.ComponentHealth{
+					{Name: "apiserver", Healthy: true},
+					{Name: "controller-manager", Healthy: true},
+					{Name: "etcd-0"},
+				},
+				Etcd:         bootstrapper.ComponentHealth{Name: "etcd", Healthy: true},
+				DNSServiceIP: "10.0.0.10",
+			},
+		},
+		{
+			description: "apiserver down",
+			outputs: map[string]string{
+				kubeletStatusCommand:     "Running\n",
+				apiServerEndpointCommand: "https://192.168.99.100:8443",
+			},
+			expected: bootstrapper.ClusterInfo{
+				APIServer:     "192.168.99.100:8443",
+				KubeletStatus: "Running",
+				Components: []bootstrapper.ComponentHealth{
+					{Name: "apiserver"},
+				},
+				Etcd: bootstrapper.ComponentHealth{Name: "etcd"},
+			},
+			errorFields: []string{"DNSServiceIP", "KubernetesVersion"},
+		},
+		{
+			description: "etcd unhealthy",
+			outputs: map[string]string{
+				kubeletStatusCommand:                                  "Running\n",
+				apiServerEndpointCommand:                              "https://192.168.99.100:8443",
+				"curl -sSf --max-time 5 http://127.0.0.1:2379/health": `{"health": "false"}`,
+			},
+			expected: bootstrapper.ClusterInfo{
+				APIServer:     "192.168.99.100:8443",
+				KubeletStatus: "Running",
+				Components: []bootstrapper.ComponentHealth{
+					{Name: "apiserver"},
+				},
+				Etcd: bootstrapper.ComponentHealth{Name: "etcd"},
+			},
+			errorFields: []string{"DNSServiceIP", "KubernetesVersion"},
+		},
+		{
+			description: "node unreachable",
+			outputs:     map[string]string{},
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(test.outputs)
+			f.SetCommandToStderr(test.stderrs)
+			k := KubeadmBootstrapper{c: f}
+
+			info, err := k.GetClusterInfo(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0"})
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error, got %+v", info)
+			}
+			if test.shouldErr {
+				return
+			}
+
+			errs := info.Errors
+			info.Errors = nil
+			// Unhealthy components must say why, in messages that vary.
+			for i, c := range info.Components {
+				if !c.Healthy && c.Message == "" {
+					t.Errorf("Expected a message for unhealthy %s", c.Name)
+				}
+				info.Components[i].Message = ""
+			}
+			if !info.Etcd.Healthy && info.Etcd.Message == "" {
+				t.Error("Expected a message for unhealthy etcd")
+			}
+			info.Etcd.Message = ""
+			if !reflect.DeepEqual(*info, test.expected) {
+				t.Errorf("Expected %+v, got %+v", test.expected, *info)
+			}
+			if len(errs) != len(test.errorFields) {
+				t.Errorf("Expected errors for %v, got %v", test.errorFields, errs)
+			}
+			for _, field := range test.errorFields {
+				if errs[field] == "" {
+					t.Errorf("Expected an error for %s, got %v", field, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestEtcdHealthCommand(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    string
+	}{
+		{
+			description: "plain http",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.9.4"},
+			expected:    "curl -sSf --max-time 5 http://127.0.0.1:2379/health",
+		},
+		{
+			description: "tls",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0"},
+			expected:    "sudo curl -sSf --max-time 5 --cacert /var/lib/localkube/certs/etcd/ca.crt --cert /var/lib/localkube/certs/etcd/healthcheck-client.crt --key /var/lib/localkube/certs/etcd/healthcheck-client.key https://127.0.0.1:2379/health",
+		},
+		{
+			description: "tls with cert dir",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.13.2", CertDir: "/etc/kubernetes/pki"},
+			expected:    "sudo curl -sSf --max-time 5 --cacert /etc/kubernetes/pki/etcd/ca.crt --cert /etc/kubernetes/pki/etcd/healthcheck-client.crt --key /etc/kubernetes/pki/etcd/healthcheck-client.key https://127.0.0.1:2379/health",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if cmd := etcdHealthCommand(test.k8s); cmd != test.expected {
+				t.Errorf("Expected etcd health command:\n%s\ngot:\n%s", test.expected, cmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/service"
+	"k8s.io/minikube/pkg/util"
+)
+
+// cniManifestDir is where user supplied CNI manifests are copied to on the
+// node.
+const cniManifestDir = "/var/lib/minikube"
+
+// cniNodeReadyTimeout is how long the node has to become ready once a user
+// supplied CNI plugin is installed.
+const cniNodeReadyTimeout = 2 * time.Minute
+
+// cniPlugin is a CNI plugin that can be installed by name.
+type cniPlugin struct {
+	// manifest returns the URL of the plugin's manifest for k8s.
+	manifest func(k8s bootstrapper.KubernetesConfig) string
+	// podCIDR is the pod CIDR hardcoded in the manifest, which is replaced
+	// with the cluster's. Empty means the manifest already uses the
+	// cluster's.
+	podCIDR string
+	// label selects the plugin's pods.
+	label map[string]string
+}
+
+var cniPlugins = map[string]cniPlugin{
+	"flannel": {
+		manifest: func(bootstrapper.KubernetesConfig) string {
+			return "https://raw.githubusercontent.com/coreos/flannel/v0.9.1/Documentation/kube-flannel.yml"
+		},
+		podCIDR: "10.244.0.0/16",
+		label:   map[string]string{"app": "flannel"},
+	},
+	"calico": {
+		manifest: func(bootstrapper.KubernetesConfig) string {
+			return "https://docs.projectcalico.org/v2.6/getting-started/kubernetes/installation/hosted/kubeadm/1.6/calico.yaml"
+		},
+		podCIDR: "192.168.0.0/16",
+		label:   map[string]string{"k8s-app": "calico-node"},
+	},
+	"weave": {
+		manifest: func(k8s bootstrapper.KubernetesConfig) string {
+			v := url.Values{}
+			v.Set("k8s-version", k8s.KubernetesVersion)
+			v.Set("env.IPALLOC_RANGE", cniPodCIDR(k8s))
+			return "https://cloud.weave.works/k8s/net?" + v.Encode()
+		},
+		label: map[string]string{"name": "weave-net"},
+	},
+}
+
+// cniPluginNames returns the names of the CNI plugins that can be installed
+// by name.
+func cniPluginNames() []string {
+	var names []string
+	for name := range cniPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigureCNI installs the CNI plugin, which is either one of the plugins
+// in cniPlugins or the path to a manifest on the host, and waits for it to
+// be ready. The cluster must have been started with k8s.PodCIDR set.
+func (k *KubeadmBootstrapper) ConfigureCNI(plugin string, k8s bootstrapper.KubernetesConfig) error {
+	if k8s.PodCIDR == "" {
+		return errors.New("installing a CNI plugin requires a pod CIDR")
+	}
+
+	cmd, err := k.cniManifestCommand(plugin, k8s)
+	if err != nil {
+		return errors.Wrap(err, "getting CNI manifest")
+	}
+	if out, err := k.c.CombinedOutput(cmd); err != nil {
+		return errors.Wrapf(err, "applying CNI manifest: %s", out)
+	}
+
+	if p, ok := cniPlugins[plugin]; ok {
+		if err := waitForCNIPods(p.label); err != nil {
+			return errors.Wrapf(err, "waiting for %s pods", plugin)
+		}
+		return nil
+	}
+	// There's no telling which pods a user supplied manifest runs, but the
+	// node isn't ready until its network is.
+	if err := k.WaitForNodeReady(k8s, cniNodeReadyTimeout); err != nil {
+		return errors.Wrap(err, "waiting for node to be ready")
+	}
+	return nil
+}
+
+// cniPodCIDR returns the pod CIDR for the bundled plugins, which are IPv4
+// only. The IPv4 range always comes first in dual-stack pod CIDRs.
+func cniPodCIDR(k8s bootstrapper.KubernetesConfig) string {
+	return strings.Split(k8s.PodCIDR, ",")[0]
+}
+
+// cniManifestCommand returns the command that applies the manifest for
+// plugin. A user supplied manifest is copied to the node first.
+func (k *KubeadmBootstrapper) cniManifestCommand(plugin string, k8s bootstrapper.KubernetesConfig) (string, error) {
+	if p, ok := cniPlugins[plugin]; ok {
+		cmd := fmt.Sprintf("curl -sSL '%s'", p.manifest(k8s))
+		podCIDR := cniPodCIDR(k8s)
+		if p.podCIDR != "" && p.podCIDR != podCIDR {
+			cmd += fmt.Sprintf(" | sed 's#%s#%s#g'", p.podCIDR, podCIDR)
+		}
+		return bootstrapper.Sudo(cmd + " | " + kubectlCmd + " apply -f -"), nil
+	}
+
+	if _, err := os.Stat(plugin); err != nil {
+		return "", fmt.Errorf("unknown CNI plugin %q, expected one of %s or the path to a manifest", plugin, strings.Join(cniPluginNames(), ", "))
+	}
+	f, err := assets.NewFileAsset(plugin, cniManifestDir, "cni.yaml", "0640")
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s", plugin)
+	}
+	if err := k.c.Copy(f); err != nil {
+		return "", errors.Wrapf(err, "copying %s", plugin)
+	}
+	return kubectl("apply -f " + path.Join(cniManifestDir, "cni.yaml")), nil
+}
+
+func waitForCNIPods(label map[string]string) error {
+	client, err := service.K8s.GetClientset()
+	if err != nil {
+		return errors.Wrap(err, "getting clientset")
+	}
+	return util.WaitForPodsWithLabelRunning(client, "kube-system", labels.SelectorFromSet(labels.Set(label)))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestCNIManifestCommand(t *testing.T) {
+	f, err := ioutil.TempFile("", "cni")
+	if err != nil {
+		t.Fatalf("Error creating temp file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("custom manifest"); err != nil {
+		t.Fatalf("Error writing temp file: %s", err)
+	}
+	f.Close()
+
+	cases := []struct {
+		description string
+		plugin      string
+		podCIDR     string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "flannel",
+			plugin:      "flannel",
+			podCIDR:     "10.244.0.0/16",
+			expected:    bootstrapper.Sudo("curl -sSL 'https://raw.githubusercontent.com/coreos/flannel/v0.9.1/Documentation/kube-flannel.yml' | " + kubectlCmd + " apply -f -"),
+		},
+		{
+			description: "flannel with another pod CIDR",
+			plugin:      "flannel",
+			podCIDR:     "172.16.0.0/16",
+			expected:    bootstrapper.Sudo("curl -sSL 'https://raw.githubusercontent.com/coreos/flannel/v0.9.1/Documentation/kube-flannel.yml' | sed 's#10.244.0.0/16#172.16.0.0/16#g' | " + kubectlCmd + " apply -f -"),
+		},
+		{
+			description: "flannel with dual-stack pod CIDR",
+			plugin:      "flannel",
+			podCIDR:     "172.16.0.0/16,fd00:10:244::/56",
+			expected:    bootstrapper.Sudo("curl -sSL 'https://raw.githubusercontent.com/coreos/flannel/v0.9.1/Documentation/kube-flannel.yml' | sed 's#10.244.0.0/16#172.16.0.0/16#g' | " + kubectlCmd + " apply -f -"),
+		},
+		{
+			description: "calico",
+			plugin:      "calico",
+			podCIDR:     "10.244.0.0/16",
+			expected:    bootstrapper.Sudo("curl -sSL 'https://docs.projectcalico.org/v2.6/getting-started/kubernetes/installation/hosted/kubeadm/1.6/calico.yaml' | sed 's#192.168.0.0/16#10.244.0.0/16#g' | " + kubectlCmd + " apply -f -"),
+		},
+		{
+			description: "weave",
+			plugin:      "weave",
+			podCIDR:     "10.244.0.0/16",
+			expected:    bootstrapper.Sudo("curl -sSL 'https://cloud.weave.works/k8s/net?env.IPALLOC_RANGE=10.244.0.0%2F16&k8s-version=v1.8.0' | " + kubectlCmd + " apply -f -"),
+		},
+		{
+			description: "manifest path",
+			plugin:      f.Name(),
+			podCIDR:     "10.244.0.0/16",
+			expected:    kubectl("apply -f /var/lib/minikube/cni.yaml"),
+		},
+		{
+			description: "unknown plugin",
+			plugin:      "cilium",
+			podCIDR:     "10.244.0.0/16",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			runner := bootstrapper.NewFakeCommandRunner()
+			k := KubeadmBootstrapper{c: runner}
+			cmd, err := k.cniManifestCommand(test.plugin, bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", PodCIDR: test.podCIDR})
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error getting CNI manifest command: %s", err)
+			}
+			if cmd != test.expected {
+				t.Errorf("Expected command %q, got %q", test.expected, cmd)
+			}
+			if test.plugin == f.Name() {
+				contents, err := runner.GetFileToContents(f.Name())
+				if err != nil {
+					t.Fatalf("Expected the manifest to be copied to the node: %s", err)
+				}
+				if contents != "custom manifest" {
+					t.Errorf("Expected copied manifest %q, got %q", "custom manifest", contents)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigureCNIRequiresPodCIDR(t *testing.T) {
+	k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+	if err := k.ConfigureCNI("flannel", bootstrapper.KubernetesConfig{}); err == nil {
+		t.Error("Expected error configuring CNI without a pod CIDR, got nil")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// adminKubeconfig is the kubeconfig kubeadm writes on the node for the
+// cluster admin.
+const adminKubeconfig = "/etc/kubernetes/admin.conf"
+
+const kubectlCmd = "/usr/bin/kubectl --kubeconfig=" + adminKubeconfig
+
+// kubectl returns the command running kubectl with args against the
+// cluster, as root, as only root may read the admin kubeconfig.
+func kubectl(args string) string {
+	return bootstrapper.Sudo(kubectlCmd + " " + args)
+}
+
+// podComponent is a component that runs as regular pods in kube-system,
+// rather than as a static control plane pod.
+type podComponent struct {
+	// label selects the component's pods.
+	label string
+	// containers are the names of the component's containers, used to find
+	// them in the container runtime when the apiserver is down.
+	containers []string
+}
+
+// podComponents are keyed by the name used to select their logs.
+var podComponents = map[string]podComponent{
+	"proxy": {label: "k8s-app=kube-proxy", containers: []string{"kube-proxy"}},
+	// kube-dns and CoreDNS both use the kube-dns label.
+	"dns": {label: "k8s-app=kube-dns", containers: []string{"kubedns", "dnsmasq", "sidecar", "coredns"}},
+}
+
+// logComponents returns the names of all of the components whose logs can be
+// selected.
+func logComponents() []string {
+	names := append([]string{}, controlPlaneComponents...)
+	for name := range podComponents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getComponentLogs returns the logs of the component selected by opts.
+// Components that aren't running produce a message saying so rather than
+// an error.
+func (k *KubeadmBootstrapper) getComponentLogs(ctx context.Context, opts bootstrapper.LogOptions) (string, error) {
+	if opts.Follow {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "Follow", Reason: "component logs can't be followed"}
+	}
+	if opts.PreviousBoot {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "PreviousBoot", Reason: "components have no logs from the previous boot"}
+	}
+	since, err := k.getSinceTimestamp(ctx, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "getting logs command")
+	}
+
+	var containers []string
+	if c, ok := podComponents[opts.Component]; ok {
+		logs, err := k.getPodLogs(ctx, c, opts, since)
+		if err == nil {
+			return orNotRunning(opts.Component, logs), nil
+		}
+		glog.Infof("Getting %s logs from the apiserver failed, falling back to the container runtime: %s", opts.Component, err)
+		containers = c.containers
+	} else {
+		for _, name := range controlPlaneComponents {
+			if name == opts.Component {
+				containers = []string{name}
+			}
+		}
+	}
+	if containers == nil {
+		return "", errors.Errorf("unknown component %q, expected one of: %s", opts.Component, strings.Join(logComponents(), ", "))
+	}
+
+	logs, err := k.getContainerLogs(ctx, containers, opts, since)
+	if err != nil {
+		return "", err
+	}
+	return orNotRunning(opts.Component, logs), nil
+}
+
+func orNotRunning(component, logs string) string {
+	if logs == "" {
+		return fmt.Sprintf("%s is not running\n", component)
+	}
+	return logs
+}
+
+// listPodsCommand lists the pods matching label, one per line, followed by
+// the names of their containers.
+func listPodsCommand(label string) string {
+	return kubectl(fmt.Sprintf(`-n kube-system get pods -l %s -o jsonpath='{range .items[*]}{.metadata.name}{" "}{.spec.containers[*].name}{"\n"}{end}'`, label))
+}
+
+// podLogsCommand returns the logs of a container in a pod, limited by
+// opts.Tail and the since unix timestamp.
+func podLogsCommand(namespace, pod, container string, opts bootstrapper.LogOptions, since int64) string {
+	flags := []string{"-c " + container}
+	if opts.Follow {
+		flags = append(flags, "-f")
+	}
+	if opts.Tail > 0 {
+		flags = append(flags, fmt.Sprintf("--tail=%d", opts.Tail))
+	}
+	if since > 0 {
+		flags = append(flags, "--since-time="+time.Unix(since, 0).UTC().Format(time.RFC3339))
+	}
+	return kubectl(fmt.Sprintf("-n %s logs %s %s", namespace, pod, strings.Join(flags, " ")))
+}
+
+// getPodLogs returns the logs of each container of each of the component's
+// pods, with a header per container. It returns an error if the pods can't
+// be listed, e.g. because the apiserver is down.
+func (k *KubeadmBootstrapper) getPodLogs(ctx context.Context, c podComponent, opts bootstrapper.LogOptions, since int64) (string, error) {
+	out, err := k.c.CombinedOutputContext(ctx, listPodsCommand(c.label))
+	if err != nil {
+		return "", errors.Wrap(err, "listing pods")
+	}
+	var b bytes.Buffer
+	for _, line := range splitLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pod := fields[0]
+		for _, container := range fields[1:] {
+			fmt.Fprintf(&b, "==> %s/%s <==\n", pod, container)
+			logs, err := k.c.CombinedOutputContext(ctx, podLogsCommand("kube-system", pod, container, opts, since))
+			if err != nil {
+				fmt.Fprintf(&b, "Error getting logs: %v\n", err)
+				continue
+			}
+			fmt.Fprint(&b, logs)
+		}
+	}
+	return b.String(), nil
+}
+
+// getContainerLogs returns the logs of the most recent container with each
+// of the given names, with a header per container.
+func (k *KubeadmBootstrapper) getContainerLogs(ctx context.Context, names []string, opts bootstrapper.LogOptions, since int64) (string, error) {
+	var b bytes.Buffer
+	for _, name := range names {
+		ids, err := k.getContainerIDs(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		logs, err := k.c.CombinedOutputContext(ctx, containerLogsCommand(ids[0], opts, since))
+		if err != nil {
+			return "", errors.Wrapf(err, "getting %s logs", name)
+		}
+		fmt.Fprintf(&b, "==> %s <==\n", name)
+		fmt.Fprint(&b, logs)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestComponentLogs(t *testing.T) {
+	cases := []struct {
+		description string
+		component   string
+		tail        int
+		outputs     map[string]string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "proxy pods",
+			component:   "proxy",
+			outputs: map[string]string{
+				listPodsCommand("k8s-app=kube-proxy"): "kube-proxy-abcde kube-proxy\nkube-proxy-fghij kube-proxy\n",
+				podLogsCommand("kube-system", "kube-proxy-abcde", "kube-proxy", bootstrapper.LogOptions{}, 0): "old proxy logs\n",
+				podLogsCommand("kube-system", "kube-proxy-fghij", "kube-proxy", bootstrapper.LogOptions{}, 0): "new proxy logs\n",
+			},
+			expected: "==> kube-proxy-abcde/kube-proxy <==\nold proxy logs\n==> kube-proxy-fghij/kube-proxy <==\nnew proxy logs\n",
+		},
+		{
+			description: "dns pod with several containers",
+			component:   "dns",
+			outputs: map[string]string{
+				listPodsCommand("k8s-app=kube-dns"): "kube-dns-12345 kubedns dnsmasq\n",
+				podLogsCommand("kube-system", "kube-dns-12345", "kubedns", bootstrapper.LogOptions{}, 0): "kubedns logs\n",
+				podLogsCommand("kube-system", "kube-dns-12345", "dnsmasq", bootstrapper.LogOptions{}, 0): "dnsmasq logs\n",
+			},
+			expected: "==> kube-dns-12345/kubedns <==\nkubedns logs\n==> kube-dns-12345/dnsmasq <==\ndnsmasq logs\n",
+		},
+		{
+			description: "dns not running",
+			component:   "dns",
+			outputs: map[string]string{
+				listPodsCommand("k8s-app=kube-dns"): "",
+			},
+			expected: "dns is not running\n",
+		},
+		{
+			description: "proxy falls back to the container runtime",
+			tail:        10,
+			component:   "proxy",
+			outputs: map[string]string{
+				listContainersCommand("kube-proxy"):                                  "abc123\n",
+				containerLogsCommand("abc123", bootstrapper.LogOptions{Tail: 10}, 0): "proxy logs from runtime\n",
+			},
+			expected: "==> kube-proxy <==\nproxy logs from runtime\n",
+		},
+		{
+			description: "control plane component",
+			tail:        10,
+			component:   "etcd",
+			outputs: map[string]string{
+				listContainersCommand("etcd"):                                       "etcd2\netcd1\n",
+				containerLogsCommand("etcd2", bootstrapper.LogOptions{Tail: 10}, 0): "etcd logs\n",
+			},
+			expected: "==> etcd <==\netcd logs\n",
+		},
+		{
+			description: "control plane component not running",
+			component:   "kube-scheduler",
+			outputs: map[string]string{
+				listContainersCommand("kube-scheduler"): "",
+			},
+			expected: "kube-scheduler is not running\n",
+		},
+		{
+			description: "unknown component",
+			component:   "kube-foo",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(test.outputs)
+			k := KubeadmBootstrapper{c: f}
+
+			logs, err := k.GetClusterLogs(bootstrapper.LogOptions{Component: test.component, Tail: test.tail})
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error but got none: %s", logs)
+			}
+			if logs != test.expected {
+				t.Errorf("Expected logs %q, got %q", test.expected, logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const (
+	// containerdConfigFile is containerd's config, which minikube owns
+	// when containerd is the container runtime.
+	containerdConfigFile = "/etc/containerd/config.toml"
+	// containerdSocket is where containerd serves kubelet's CRI requests.
+	containerdSocket = "/run/containerd/containerd.sock"
+	// dockerHubRegistry is the registry the mirrors are mirrors of, which
+	// is tried after them.
+	dockerHubRegistry = "https://registry-1.docker.io"
+)
+
+// The sandbox image must be the pause image cached for the Kubernetes
+// version, and the cgroup driver must match kubelet's. Strings are quoted
+// with %q, whose escapes are a subset of TOML's.
+var containerdConfigTmpl = template.Must(template.New("containerdConfigTmpl").Parse(`# Written by minikube.
+root = "/var/lib/containerd"
+state = "/run/containerd"
+oom_score = 0
+
+[grpc]
+  address = {{printf "%q" .Socket}}
+
+[plugins]
+  [plugins.cri]
+    sandbox_image = {{printf "%q" .SandboxImage}}
+    systemd_cgroup = {{.SystemdCgroup}}
+    [plugins.cri.containerd]
+      snapshotter = "overlayfs"
+    [plugins.cri.cni]
+      bin_dir = "/opt/cni/bin"
+      conf_dir = "/etc/cni/net.d"
+{{if .RegistryMirrors}}    [plugins.cri.registry]
+      [plugins.cri.registry.mirrors]
+        [plugins.cri.registry.mirrors."docker.io"]
+          endpoint = [{{range $i, $m := .RegistryMirrors}}{{if $i}}, {{end}}{{printf "%q" $m}}{{end}}]
+{{end}}`))
+
+// containerdConfig returns containerd's config.toml for k8s.
+func containerdConfig(k8s bootstrapper.KubernetesConfig) (string, error) {
+	opts := struct {
+		Socket          string
+		SandboxImage    string
+		SystemdCgroup   bool
+		RegistryMirrors []string
+	}{
+		Socket:        containerdSocket,
+		SandboxImage:  k8s.GetPauseImage(),
+		SystemdCgroup: k8s.GetCgroupDriver() == bootstrapper.CgroupDriverSystemd,
+	}
+	if len(k8s.RegistryMirrors) > 0 {
+		opts.RegistryMirrors = append(append([]string{}, k8s.RegistryMirrors...), dockerHubRegistry)
+	}
+
+	var b bytes.Buffer
+	if err := containerdConfigTmpl.Execute(&b, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// updateContainerdConfig writes containerd's config for k8s, and restarts
+// containerd if it changed, so that it's configured before kubelet starts.
+// It does nothing unless containerd is the container runtime.
+func (k *KubeadmBootstrapper) updateContainerdConfig(k8s bootstrapper.KubernetesConfig) error {
+	if k8s.ContainerRuntime != bootstrapper.ContainerRuntimeContainerd {
+		return nil
+	}
+	conf, err := containerdConfig(k8s)
+	if err != nil {
+		return errors.Wrap(err, "generating containerd config")
+	}
+	if current, err := k.c.CombinedOutput(bootstrapper.Sudo("cat " + containerdConfigFile)); err == nil && current == conf {
+		glog.Infof("%s is up to date", containerdConfigFile)
+		return nil
+	}
+
+	if err := bootstrapper.CopyAtomically(k.c, assets.NewMemoryAssetTarget([]byte(conf), containerdConfigFile, "0644")); err != nil {
+		return errors.Wrapf(err, "copying %s", containerdConfigFile)
+	}
+	return k.restartContainerRuntime(k8s.ContainerRuntime)
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestContainerdConfig(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    string
+	}{
+		{
+			description: "default",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0"},
+			expected: `# Written by minikube.
+root = "/var/lib/containerd"
+state = "/run/containerd"
+oom_score = 0
+
+[grpc]
+  address = "/run/containerd/containerd.sock"
+
+[plugins]
+  [plugins.cri]
+    sandbox_image = "k8s.gcr.io/pause-amd64:3.1"
+    systemd_cgroup = false
+    [plugins.cri.containerd]
+      snapshotter = "overlayfs"
+    [plugins.cri.cni]
+      bin_dir = "/opt/cni/bin"
+      conf_dir = "/etc/cni/net.d"
+`,
+		},
+		{
+			description: "mirrors, systemd and image repository",
+			k8s: bootstrapper.KubernetesConfig{
+				KubernetesVersion: "v1.12.0",
+				ImageRepository:   "registry.example.com/k8s",
+				CgroupDriver:      bootstrapper.CgroupDriverSystemd,
+				RegistryMirrors:   []string{"https://mirror.example.com", "http://10.0.0.5:5000"},
+			},
+			expected: `# Written by minikube.
+root = "/var/lib/containerd"
+state = "/run/containerd"
+oom_score = 0
+
+[grpc]
+  address = "/run/containerd/containerd.sock"
+
+[plugins]
+  [plugins.cri]
+    sandbox_image = "registry.example.com/k8s/pause:3.1"
+    systemd_cgroup = true
+    [plugins.cri.containerd]
+      snapshotter = "overlayfs"
+    [plugins.cri.cni]
+      bin_dir = "/opt/cni/bin"
+      conf_dir = "/etc/cni/net.d"
+    [plugins.cri.registry]
+      [plugins.cri.registry.mirrors]
+        [plugins.cri.registry.mirrors."docker.io"]
+          endpoint = ["https://mirror.example.com", "http://10.0.0.5:5000", "https://registry-1.docker.io"]
+`,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			conf, err := containerdConfig(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating containerd config: %s", err)
+			}
+			if conf != test.expected {
+				t.Errorf("Expected containerd config:\n%s\ngot:\n%s", test.expected, conf)
+			}
+		})
+	}
+}
+
+func TestUpdateContainerdConfig(t *testing.T) {
+	defer func(attempts int, interval time.Duration) {
+		runtimeActiveAttempts, runtimeActiveInterval = attempts, interval
+	}(runtimeActiveAttempts, runtimeActiveInterval)
+	runtimeActiveAttempts, runtimeActiveInterval = 1, 0
+
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ContainerRuntime: bootstrapper.ContainerRuntimeContainerd}
+	conf, err := containerdConfig(k8s)
+	if err != nil {
+		t.Fatalf("Error generating containerd config: %s", err)
+	}
+	restart := map[string]string{
+		"sudo mv -f /etc/containerd/.config.toml.tmp " + containerdConfigFile: "",
+		"sudo systemctl restart containerd":                                   "",
+		"sudo systemctl is-active containerd":                                 "active\n",
+	}
+
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		current     string
+		commands    map[string]string
+		written     bool
+	}{
+		{
+			description: "changed",
+			k8s:         k8s,
+			current:     "# Written by containerd.\n",
+			commands:    restart,
+			written:     true,
+		},
+		{
+			description: "unchanged",
+			k8s:         k8s,
+			current:     conf,
+		},
+		{
+			description: "docker",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0"},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+			r.SetCommandToOutput(map[string]string{"sudo cat " + containerdConfigFile: test.current})
+			r.SetCommandToOutput(test.commands)
+			k := KubeadmBootstrapper{c: r}
+
+			if err := k.updateContainerdConfig(test.k8s); err != nil {
+				t.Fatalf("Error updating containerd config: %s", err)
+			}
+			written, ok := r.files["/etc/containerd/.config.toml.tmp"]
+			if ok != test.written {
+				t.Fatalf("Expected containerd config to be written: %t, got %t", test.written, ok)
+			}
+			if ok && written != conf {
+				t.Errorf("Expected containerd config:\n%s\ngot:\n%s", conf, written)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeletSystemdConfContainerd(t *testing.T) {
+	k8s := bootstrapper.KubernetesConfig{ContainerRuntime: bootstrapper.ContainerRuntimeContainerd, CgroupDriver: bootstrapper.CgroupDriverSystemd}
+	cfg, err := generateKubeletSystemdConf(k8s)
+	if err != nil {
+		t.Fatalf("Error generating kubelet systemd conf: %s", err)
+	}
+	for _, expected := range []string{
+		"Environment=\"KUBELET_CGROUP_ARGS=--cgroup-driver=systemd\"\n",
+		"Environment=\"KUBELET_RUNTIME_ARGS=--container-runtime=remote --container-runtime-endpoint=unix:///run/containerd/containerd.sock\"\n",
+		"$KUBELET_RUNTIME_ARGS",
+	} {
+		if !strings.Contains(cfg, expected) {
+			t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", expected, cfg)
+		}
+	}
+
+	cfg, err = generateKubeletSystemdConf(bootstrapper.KubernetesConfig{})
+	if err != nil {
+		t.Fatalf("Error generating kubelet systemd conf: %s", err)
+	}
+	if !strings.Contains(cfg, "--cgroup-driver=cgroupfs\"") || strings.Contains(cfg, "KUBELET_RUNTIME_ARGS=") {
+		t.Errorf("Expected kubelet systemd conf to use docker with cgroupfs, got:\n%s", cfg)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/state"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const (
+	swapsCommand    = "cat /proc/swaps"
+	memTotalCommand = "grep MemTotal /proc/meminfo"
+)
+
+// minNodeMemoryMB is the least memory kubeadm's preflight checks allow the
+// node to have.
+const minNodeMemoryMB = 1700
+
+// diagnosedCerts are the certificates in the cluster's certificates
+// directory checked for expiry.
+var diagnosedCerts = []string{"ca.crt", "apiserver.crt", "proxy-client-ca.crt", "proxy-client.crt"}
+
+// DiagnoseStart checks the node of the cluster configured by k8s for the
+// common causes of it failing to start, e.g. kubelet being stopped, swap
+// being on, or certificates having expired, and returns what was found,
+// with how to fix each. Checks which can't be made are skipped. An error is only returned if commands can't be run on the node
+// at all.
+func (k *KubeadmBootstrapper) DiagnoseStart(k8s bootstrapper.KubernetesConfig) ([]bootstrapper.Finding, error) {
+	kubelet, err := k.GetClusterStatus()
+	if err != nil {
+		return nil, errors.Wrap(err, "diagnosing start")
+	}
+
+	var findings []bootstrapper.Finding
+	for _, check := range []func(bootstrapper.KubernetesConfig) []bootstrapper.Finding{
+		k.diagnoseRuntime,
+		func(bootstrapper.KubernetesConfig) []bootstrapper.Finding { return diagnoseKubelet(kubelet) },
+		k.diagnoseAPIServer,
+		k.diagnoseSwap,
+		k.diagnoseMemory,
+		k.diagnoseImages,
+		k.diagnoseCerts,
+	} {
+		findings = append(findings, check(k8s)...)
+	}
+	return findings, nil
+}
+
+// diagnoseRuntime checks that the container runtime's systemd unit is
+// active.
+func (k *KubeadmBootstrapper) diagnoseRuntime(k8s bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	unit, ok := runtimeUnits[k8s.ContainerRuntime]
+	if !ok {
+		return nil
+	}
+	out, err := bootstrapper.CombinedOutputWithTimeout(context.Background(), k.c, bootstrapper.Sudo("systemctl is-active "+unit), probeTimeout)
+	if state := strings.TrimSpace(out); err == nil && state == "active" {
+		return nil
+	}
+	return []bootstrapper.Finding{{
+		Check:       "runtime",
+		Severity:    bootstrapper.SeverityError,
+		Message:     fmt.Sprintf("The container runtime, %s, isn't running: %s", unit, strings.TrimSpace(out)),
+		Remediation: fmt.Sprintf("Check why with 'minikube ssh sudo journalctl -u %s', or restart it with minikube start --restart-container-runtime.", unit),
+	}}
+}
+
+// diagnoseKubelet checks kubelet's status, as GetClusterStatus reports it.
+func diagnoseKubelet(status string) []bootstrapper.Finding {
+	if status == state.Running.String() {
+		return nil
+	}
+	return []bootstrapper.Finding{{
+		Check:       "kubelet",
+		Severity:    bootstrapper.SeverityError,
+		Message:     "kubelet isn't running.",
+		Remediation: "Check why with 'minikube logs', or 'minikube ssh sudo systemctl status kubelet'.",
+	}}
+}
+
+// diagnoseAPIServer checks the apiserver's health endpoint.
+func (k *KubeadmBootstrapper) diagnoseAPIServer(bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	out, err := k.c.CombinedOutput(apiServerHealthCommand)
+	if err == nil {
+		return nil
+	}
+	return []bootstrapper.Finding{{
+		Check:       "apiserver",
+		Severity:    bootstrapper.SeverityError,
+		Message:     strings.TrimSpace(fmt.Sprintf("The apiserver isn't healthy: %v: %s", err, out)),
+		Remediation: "It may still be starting. Otherwise, check the kube-apiserver logs with 'minikube logs --component kube-apiserver'.",
+	}}
+}
+
+// diagnoseSwap checks that no swap is enabled, which kubelet refuses to run
+// with.
+func (k *KubeadmBootstrapper) diagnoseSwap(bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	out, err := k.c.CombinedOutput(swapsCommand)
+	if err != nil {
+		return nil
+	}
+	// The first line is the table's header.
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	var devices []string
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			devices = append(devices, fields[0])
+		}
+	}
+	return []bootstrapper.Finding{{
+		Check:       "swap",
+		Severity:    bootstrapper.SeverityError,
+		Message:     "Swap is enabled on " + strings.Join(devices, ", ") + ", which kubelet refuses to run with.",
+		Remediation: "Disable swap on the node with 'minikube ssh sudo swapoff -a'.",
+	}}
+}
+
+// diagnoseMemory checks that the node has as much memory as kubeadm needs.
+func (k *KubeadmBootstrapper) diagnoseMemory(bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	out, err := k.c.CombinedOutput(memTotalCommand)
+	if err != nil {
+		return nil
+	}
+	// e.g. MemTotal:        2048272 kB
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return nil
+	}
+	kb, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	if mb := kb / 1024; mb < minNodeMemoryMB {
+		return []bootstrapper.Finding{{
+			Check:       "memory",
+			Severity:    bootstrapper.SeverityError,
+			Message:     fmt.Sprintf("The node has %dMB of memory, less than the %dMB kubeadm needs.", mb, minNodeMemoryMB),
+			Remediation: "Delete the cluster, and start it again with more memory, e.g. --memory 2048.",
+		}}
+	}
+	return nil
+}
+
+// diagnoseImages checks that the container runtime has the images kubeadm
+// needs. Missing ones are pulled, unless the node is offline.
+func (k *KubeadmBootstrapper) diagnoseImages(k8s bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	missing, err := imagesNotInRuntime(k.c, k8s.ContainerRuntime, k8s.GetKubeadmCachedImages())
+	if err != nil || len(missing) == 0 {
+		return nil
+	}
+	return []bootstrapper.Finding{{
+		Check:       "images",
+		Severity:    bootstrapper.SeverityWarning,
+		Message:     fmt.Sprintf("%d images kubeadm needs aren't in the container runtime, so must be pulled: %s", len(missing), strings.Join(missing, ", ")),
+		Remediation: "Check the node's network access and any HTTP proxy settings, or start with --cache-images so that they're loaded from the host.",
+	}}
+}
+
+// diagnoseCerts checks that the cluster's certificates haven't expired.
+func (k *KubeadmBootstrapper) diagnoseCerts(k8s bootstrapper.KubernetesConfig) []bootstrapper.Finding {
+	var expired []string
+	for _, cert := range diagnosedCerts {
+		// openssl exits non-zero for an expired certificate, so its output
+		// is what's checked, which is neither for a missing one.
+		out, _ := k.c.CombinedOutput(bootstrapper.Sudo("openssl x509 -noout -checkend 0 -in " + path.Join(k8s.GetCertDir(), cert)))
+		if strings.Contains(out, "Certificate will expire") {
+			expired = append(expired, cert)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	return []bootstrapper.Finding{{
+		Check:       "certs",
+		Severity:    bootstrapper.SeverityError,
+		Message:     "Certificates have expired: " + strings.Join(expired, ", "),
+		Remediation: "Run 'minikube delete' and 'minikube start' to regenerate the certificates.",
+	}}
+}
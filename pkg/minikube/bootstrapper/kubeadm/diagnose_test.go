@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// healthyNode returns the outputs of the commands DiagnoseStart runs on a
+// healthy node, with certificates in /certs.
+func healthyNode() map[string]string {
+	outputs := map[string]string{
+		kubeletStatusCommand:              "Running\n",
+		"sudo systemctl is-active docker": "active\n",
+		apiServerHealthCommand:            "ok",
+		swapsCommand:                      "Filename\tType\tSize\tUsed\tPriority\n",
+		memTotalCommand:                   "MemTotal:        2048272 kB\n",
+	}
+	for _, cert := range diagnosedCerts {
+		outputs["sudo openssl x509 -noout -checkend 0 -in /certs/"+cert] = "Certificate will not expire\n"
+	}
+	return outputs
+}
+
+func TestDiagnoseStart(t *testing.T) {
+	cases := []struct {
+		description string
+		modify      func(map[string]string)
+		missing     []string
+		expected    map[string]bootstrapper.FindingSeverity
+	}{
+		{
+			description: "healthy",
+			modify:      func(map[string]string) {},
+			expected:    map[string]bootstrapper.FindingSeverity{},
+		},
+		{
+			description: "swap on and kubelet stopped",
+			modify: func(outputs map[string]string) {
+				outputs[kubeletStatusCommand] = "Stopped\n"
+				outputs[swapsCommand] += "/dev/sda2\tpartition\t1048572\t0\t-1\n"
+				delete(outputs, apiServerHealthCommand)
+			},
+			expected: map[string]bootstrapper.FindingSeverity{
+				"kubelet":   bootstrapper.SeverityError,
+				"swap":      bootstrapper.SeverityError,
+				"apiserver": bootstrapper.SeverityError,
+			},
+		},
+		{
+			description: "expired certs, little memory and images missing",
+			modify: func(outputs map[string]string) {
+				outputs["sudo openssl x509 -noout -checkend 0 -in /certs/apiserver.crt"] = "Certificate will expire\n"
+				outputs[memTotalCommand] = "MemTotal:        1015800 kB\n"
+			},
+			missing: []string{"k8s.gcr.io/pause-amd64:3.1"},
+			expected: map[string]bootstrapper.FindingSeverity{
+				"certs":  bootstrapper.SeverityError,
+				"memory": bootstrapper.SeverityError,
+				"images": bootstrapper.SeverityWarning,
+			},
+		},
+		{
+			description: "runtime stopped",
+			modify: func(outputs map[string]string) {
+				outputs["sudo systemctl is-active docker"] = "inactive\n"
+			},
+			expected: map[string]bootstrapper.FindingSeverity{
+				"runtime": bootstrapper.SeverityError,
+			},
+		},
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string, []string) ([]string, error)) { imagesNotInRuntime = f }(imagesNotInRuntime)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			imagesNotInRuntime = func(bootstrapper.CommandRunner, string, []string) ([]string, error) { return test.missing, nil }
+			outputs := healthyNode()
+			test.modify(outputs)
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(outputs)
+			k := &KubeadmBootstrapper{c: r}
+			findings, err := k.DiagnoseStart(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", CertDir: "/certs"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			found := map[string]bootstrapper.FindingSeverity{}
+			for _, f := range findings {
+				found[f.Check] = f.Severity
+				if f.Message == "" || f.Remediation == "" {
+					t.Errorf("Expected %s finding to explain itself and how to fix it, got %+v", f.Check, f)
+				}
+			}
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("Expected findings %v, got %+v", test.expected, findings)
+			}
+		})
+	}
+}
+
+func TestDiagnoseStartDetails(t *testing.T) {
+	defer func(f func(bootstrapper.CommandRunner, string, []string) ([]string, error)) { imagesNotInRuntime = f }(imagesNotInRuntime)
+	imagesNotInRuntime = func(bootstrapper.CommandRunner, string, []string) ([]string, error) { return nil, nil }
+
+	outputs := healthyNode()
+	outputs[swapsCommand] += "/swapfile\tfile\t1048572\t0\t-1\n"
+	outputs["sudo openssl x509 -noout -checkend 0 -in /certs/ca.crt"] = "Certificate will expire\n"
+	r := bootstrapper.NewFakeCommandRunner()
+	r.SetCommandToOutput(outputs)
+	k := &KubeadmBootstrapper{c: r}
+	findings, err := k.DiagnoseStart(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", CertDir: "/certs"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, f := range findings {
+		switch f.Check {
+		case "swap":
+			if !strings.Contains(f.Message, "/swapfile") {
+				t.Errorf("Expected the swap finding to name /swapfile, got %q", f.Message)
+			}
+		case "certs":
+			if !strings.Contains(f.Message, "ca.crt") || strings.Contains(f.Message, "apiserver.crt") {
+				t.Errorf("Expected the certs finding to name only ca.crt, got %q", f.Message)
+			}
+		}
+	}
+}
+
+func TestDiagnoseStartUnreachable(t *testing.T) {
+	k := &KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+	if _, err := k.DiagnoseStart(bootstrapper.KubernetesConfig{}); err == nil {
+		t.Error("Expected an error diagnosing a node commands can't be run on, got nil")
+	}
+}
@@ -0,0 +1,768 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"crypto"
+	// Registers the checksum hashes used by kubernetes releases.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	download "github.com/jimmidyson/go-download"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// DownloadProgress is the progress of a download of a Kubernetes binary.
+type DownloadProgress struct {
+	Binary  string
+	Version string
+	// Done is the number of bytes downloaded so far.
+	Done int64
+	// Total is the size of the binary, or -1 if it's unknown.
+	Total int64
+	// Finished is set on the last report, once the binary has been
+	// downloaded and verified.
+	Finished bool
+}
+
+// DownloadProgressFunc is called as binaries are downloaded. Binaries are
+// downloaded concurrently, so it must be safe to call from several
+// goroutines.
+type DownloadProgressFunc func(DownloadProgress)
+
+// downloader downloads Kubernetes binaries and caches them on the host.
+type downloader struct {
+	// out receives status messages, e.g. about retries. nil means
+	// os.Stdout.
+	out io.Writer
+	// progress, if set, is called as binaries are downloaded, in place of
+	// printing when each download starts and finishes.
+	progress DownloadProgressFunc
+	// proxy chooses the proxy for each request. nil means the proxy from
+	// the environment.
+	proxy proxyFunc
+	// stallBytes and stallPeriod abandon a download attempt which receives
+	// fewer than stallBytes in stallPeriod, so that it's retried. Zero
+	// disables stall detection.
+	stallBytes  int64
+	stallPeriod time.Duration
+}
+
+// withProxy returns a copy of d that downloads through proxy, a URL, unless
+// it's empty. Hosts in the NO_PROXY environment variable are still reached
+// directly.
+func (d *downloader) withProxy(proxy string) (*downloader, error) {
+	if proxy == "" {
+		return d, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing proxy %q", proxy)
+	}
+	withProxy := *d
+	withProxy.proxy = overrideProxy(u, envNoProxy())
+	return &withProxy, nil
+}
+
+func (d *downloader) printf(format string, a ...interface{}) {
+	out := d.out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, a...)
+}
+
+// cachedBinaryPath returns where version of a Kubernetes binary built for
+// goos and goarch is cached. Binaries for the default node platform are
+// cached directly in the version's directory, and other platforms', such as
+// arm64 nodes' or the host's, in a subdirectory per platform.
+func cachedBinaryPath(binary, version, goos, goarch string) string {
+	if goos == constants.NodeOS && goarch == constants.NodeArch {
+		return constants.MakeMiniPath("cache", version, binary)
+	}
+	if goos == "windows" {
+		binary += ".exe"
+	}
+	return constants.MakeMiniPath("cache", version, goos+"-"+goarch, binary)
+}
+
+// CachedKubectlPath returns where the kubectl for the host matching version
+// is cached when KubernetesConfig.CacheKubectl is set.
+func CachedKubectlPath(version string) string {
+	return cachedBinaryPath("kubectl", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// maybeDownloadAndCache downloads k8s's version of a Kubernetes binary for
+// a node of arch, from k8s's release mirror and through its download proxy,
+// unless it's cached already. It returns the path of the cached binary.
+func (d *downloader) maybeDownloadAndCache(ctx context.Context, binary, arch string, k8s bootstrapper.KubernetesConfig) (string, error) {
+	return d.maybeDownloadAndCacheForPlatform(ctx, binary, constants.NodeOS, arch, k8s)
+}
+
+// maybeDownloadAndCacheForPlatform is maybeDownloadAndCache for a binary
+// built for goos and goarch.
+func (d *downloader) maybeDownloadAndCacheForPlatform(ctx context.Context, binary, goos, goarch string, k8s bootstrapper.KubernetesConfig) (string, error) {
+	version := k8s.KubernetesVersion
+	targetFilepath := cachedBinaryPath(binary, version, goos, goarch)
+	targetDir := filepath.Dir(targetFilepath)
+	removeOrphanedPartials(targetDir)
+
+	url := constants.GetKubernetesReleaseURLForPlatform(k8s.ReleaseMirror, binary, version, goos, goarch)
+	sha256URL := constants.GetKubernetesReleaseURLSha256ForPlatform(k8s.ReleaseMirror, binary, version, goos, goarch)
+	sha1URL := constants.GetKubernetesReleaseURLSha1ForPlatform(k8s.ReleaseMirror, binary, version, goos, goarch)
+	d, err := d.withProxy(k8s.DownloadProxy)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = os.Stat(targetFilepath)
+	if err == nil {
+		valid, err := d.verifyCached(ctx, targetFilepath, sha256URL, sha1URL, k8s)
+		if err != nil {
+			return "", errors.Wrapf(err, "verifying cached %s %s", binary, version)
+		}
+		if valid {
+			// Mark the version as used, so that pruning the cache can tell
+			// when.
+			versionDir := constants.MakeMiniPath("cache", version)
+			if now := time.Now(); os.Chtimes(versionDir, now, now) != nil {
+				glog.Infof("Couldn't update the last used time of %s", versionDir)
+			}
+			return targetFilepath, nil
+		}
+		d.printf("Cached %s %s doesn't match its checksum, downloading it again\n", binary, version)
+		for _, path := range []string{targetFilepath, targetFilepath + checksumSuffix} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return "", errors.Wrapf(err, "removing %s", path)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return "", errors.Wrapf(err, "stat %s version %s at %s", binary, version, targetDir)
+	}
+
+	if err = os.MkdirAll(targetDir, 0777); err != nil {
+		return "", errors.Wrapf(err, "mkdir %s", targetDir)
+	}
+
+	notCached := &NotCachedError{Binary: binary, Version: version, URL: url, CachePath: targetFilepath}
+
+	if k8s.Offline {
+		notCached.Err = errors.New("downloads are disabled in offline mode")
+		return "", notCached
+	}
+
+	// Another minikube, e.g. starting a different profile, may be
+	// downloading the same binary.
+	unlock, err := lockFile(ctx, targetFilepath+".lock")
+	if err != nil {
+		return "", errors.Wrapf(err, "locking %s", targetFilepath)
+	}
+	defer unlock()
+	if _, err := os.Stat(targetFilepath); err == nil {
+		glog.Infof("%s %s was downloaded while waiting for the lock", binary, version)
+		return targetFilepath, nil
+	}
+
+	limited := *d
+	limited.stallBytes, limited.stallPeriod = k8s.GetDownloadStallLimit()
+	d = &limited
+
+	// Fail fast when offline, rather than after long connection timeouts.
+	if err := d.checkReachable(ctx, url); err != nil {
+		notCached.Err = err
+		return "", notCached
+	}
+
+	var report progressFunc
+	if d.progress != nil {
+		report = func(done, total int64) {
+			d.progress(DownloadProgress{Binary: binary, Version: version, Done: done, Total: total})
+		}
+	} else {
+		d.printf("Downloading %s %s\n", binary, version)
+	}
+	timeout := k8s.GetDownloadTimeout()
+	downloadCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	checksum, err := d.downloadRelease(downloadCtx, url, sha256URL, sha1URL, targetFilepath, report)
+	if err != nil {
+		if downloadCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return "", fmt.Errorf("downloading %s %s from %s timed out after %s; a closer mirror can be used with --kubernetes-release-mirror", binary, version, url, timeout)
+		}
+		return "", errors.Wrapf(err, "Error downloading %s %s from %s", binary, version, url)
+	}
+	glog.Infof("Verified %s %s with checksum %s", binary, version, checksum)
+	if err := writeChecksumFile(targetFilepath); err != nil {
+		glog.Warningf("Error recording the checksum of %s: %s", targetFilepath, err)
+	}
+	if d.progress != nil {
+		size := int64(-1)
+		if fi, err := os.Stat(targetFilepath); err == nil {
+			size = fi.Size()
+		}
+		d.progress(DownloadProgress{Binary: binary, Version: version, Done: size, Total: size, Finished: true})
+	} else {
+		d.printf("Finished Downloading %s %s\n", binary, version)
+	}
+
+	return targetFilepath, nil
+}
+
+// NotCachedError is returned when a binary that isn't cached can't be
+// downloaded, because minikube is offline or in offline mode.
+type NotCachedError struct {
+	Binary  string
+	Version string
+	// URL is where the binary would have been downloaded from.
+	URL string
+	// CachePath is where the binary was looked for.
+	CachePath string
+	// Err is why the binary can't be downloaded.
+	Err error
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf(`%s %s isn't cached at %s and can't be downloaded from %s: %v
+To cache it, run this while online:
+  mkdir -p %s && curl -fLo %s %s`, e.Binary, e.Version, e.CachePath, e.URL, e.Err, filepath.Dir(e.CachePath), e.CachePath, e.URL)
+}
+
+// reachableTimeout bounds how long checkReachable waits for a response.
+var reachableTimeout = 5 * time.Second
+
+// checkReachable returns an error if the server at url can't be reached
+// within reachableTimeout, retrying as downloads are. Any HTTP response but
+// a server error, even a 404, means it can be.
+func (d *downloader) checkReachable(ctx context.Context, url string) error {
+	err := d.retry(ctx, "Checking "+path.Base(url), func() (bool, error) {
+		attemptCtx, cancel := context.WithTimeout(ctx, reachableTimeout)
+		defer cancel()
+		resp, transient, err := d.head(attemptCtx, url)
+		if err != nil {
+			return transient, err
+		}
+		if transient {
+			return true, errors.Errorf("unexpected status %s from %s", resp.Status, url)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "server is unreachable")
+	}
+	return nil
+}
+
+// head makes a HEAD request for url, and returns whether its failure, or
+// its response, e.g. a 5xx, is transient.
+func (d *downloader) head(ctx context.Context, url string) (*http.Response, bool, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "creating request")
+	}
+	tracker := &transientErrorTracker{rt: newDownloadTransport(d.proxy)}
+	client := &http.Client{Transport: tracker}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, tracker.isTransient(), err
+	}
+	resp.Body.Close()
+	return resp, tracker.isTransient(), nil
+}
+
+// downloadRelease downloads the release binary at url to dst, verified
+// against its SHA256 checksum, or its SHA1 checksum for old releases that
+// don't publish SHA256 checksums. It returns the URL of the checksum used.
+func (d *downloader) downloadRelease(ctx context.Context, url, sha256URL, sha1URL, dst string, report progressFunc) (string, error) {
+	options := download.FileOptions{
+		Mkdirs: download.MkdirAll,
+	}
+	hasSha256, err := d.exists(ctx, sha256URL)
+	if err != nil {
+		return "", errors.Wrap(err, "checking for sha256 checksum")
+	}
+	if hasSha256 {
+		options.Checksum = sha256URL
+		options.ChecksumHash = crypto.SHA256
+	} else {
+		glog.Infof("No sha256 checksum at %s, falling back to sha1", sha256URL)
+		options.Checksum = sha1URL
+		options.ChecksumHash = crypto.SHA1
+	}
+
+	if err := d.downloadFile(ctx, url, dst, options, report); err != nil {
+		return "", err
+	}
+	return options.Checksum, nil
+}
+
+// exists returns whether there's a file at url, retrying as downloads are.
+func (d *downloader) exists(ctx context.Context, url string) (bool, error) {
+	var found bool
+	err := d.retry(ctx, "Checking "+path.Base(url), func() (bool, error) {
+		resp, transient, err := d.head(ctx, url)
+		if err != nil {
+			return transient, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			found = true
+			return false, nil
+		// GCS responds with 403 rather than 404 for missing files in some
+		// buckets.
+		case http.StatusNotFound, http.StatusForbidden:
+			return false, nil
+		default:
+			return transient, errors.Errorf("unexpected status %s from %s", resp.Status, url)
+		}
+	})
+	return found, err
+}
+
+// downloadAttempts and downloadBackoff bound the retries of downloads that
+// fail transiently. The backoff doubles after each attempt.
+var (
+	downloadAttempts = 4
+	downloadBackoff  = 2 * time.Second
+)
+
+// downloadFile downloads url to dst, aborting if ctx is cancelled. Downloads
+// that fail because of a network error or a 5xx response are retried with
+// exponential backoff; other failures, such as a checksum mismatch, aren't.
+//
+// The download is written to dst.partial, and only renamed to dst once its
+// checksum has been verified, so a failed download never leaves a file at
+// dst. An interrupted download is resumed from dst.partial, by this or a
+// later call. report, if set, is called as the download progresses.
+func (d *downloader) downloadFile(ctx context.Context, url, dst string, options download.FileOptions, report progressFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.retry(ctx, "Download of "+path.Base(url), func() (bool, error) {
+		return d.downloadFileOnce(ctx, url, dst, options, report)
+	})
+}
+
+// retry calls attempt, which returns whether its failure is transient,
+// until it succeeds, fails other than transiently, or has been called
+// downloadAttempts times, with exponential backoff. what is reported as
+// having failed when attempt is retried.
+func (d *downloader) retry(ctx context.Context, what string, attempt func() (bool, error)) error {
+	backoff := downloadBackoff
+	for n := 1; ; n++ {
+		transient, err := attempt()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "download cancelled")
+		}
+		if !transient || n == downloadAttempts {
+			return err
+		}
+
+		// Jitter keeps concurrent downloads from retrying in lockstep.
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		d.printf("%s failed, retrying in %s (attempt %d of %d): %v\n", what, wait, n+1, downloadAttempts, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "download cancelled")
+		}
+		backoff *= 2
+	}
+}
+
+// downloadFileOnce makes a single attempt at downloading url to dst, and
+// returns whether a failure was transient.
+func (d *downloader) downloadFileOnce(ctx context.Context, url, dst string, options download.FileOptions, report progressFunc) (bool, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := &transientErrorTracker{rt: newDownloadTransport(d.proxy)}
+	client := &http.Client{
+		Transport: &contextTransport{ctx: attemptCtx, rt: tracker},
+	}
+
+	if options.Mkdirs == nil || *options.Mkdirs {
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return false, errors.Wrapf(err, "mkdir %s", filepath.Dir(dst))
+		}
+	}
+
+	expected, err := expectedChecksum(client, options.Checksum, path.Base(url))
+	if err != nil {
+		return tracker.isTransient(), err
+	}
+
+	partial := dst + ".partial"
+	watch := watchStall(cancel, d.stallBytes, d.stallPeriod)
+	err = downloadPartial(client, url, partial, func(done, total int64) {
+		watch.observe(done)
+		if report != nil {
+			report(done, total)
+		}
+	})
+	watch.stop()
+	if err != nil && watch.isStalled() {
+		return true, &DownloadStalledError{URL: url, Bytes: d.stallBytes, Period: d.stallPeriod}
+	}
+	if err != nil {
+		return tracker.isTransient(), errors.Wrap(err, "download failed")
+	}
+
+	if expected != "" {
+		actual, err := fileChecksum(partial, options.ChecksumHash)
+		if err != nil {
+			return false, err
+		}
+		if actual != expected {
+			// The partial download is corrupt, so the next attempt has to
+			// start over.
+			os.Remove(partial)
+			return false, errors.Errorf("checksum validation failed: expected %s, got %s", expected, actual)
+		}
+	}
+
+	if err := os.Rename(partial, dst); err != nil {
+		return false, errors.Wrapf(err, "renaming %s to %s", partial, dst)
+	}
+	return false, nil
+}
+
+// DownloadStalledError is returned when a download receives too little for
+// too long, e.g. through a congested link to a distant mirror.
+type DownloadStalledError struct {
+	URL string
+	// Bytes is the fewest bytes the download had to receive in Period.
+	Bytes  int64
+	Period time.Duration
+}
+
+func (e *DownloadStalledError) Error() string {
+	return fmt.Sprintf("download of %s stalled, receiving fewer than %d bytes in %s; a closer mirror can be used with --kubernetes-release-mirror", e.URL, e.Bytes, e.Period)
+}
+
+// stallWatch cancels a download attempt which receives fewer than a minimum
+// number of bytes in any period.
+type stallWatch struct {
+	mu sync.Mutex
+	// started is set once the download's first progress is observed, at
+	// last bytes. received is the bytes received in the current period.
+	started  bool
+	last     int64
+	received int64
+	stalled  bool
+	done     chan struct{}
+}
+
+// watchStall starts watching for a stall, calling cancel if one happens.
+// It doesn't watch if minBytes or period are zero.
+func watchStall(cancel func(), minBytes int64, period time.Duration) *stallWatch {
+	w := &stallWatch{done: make(chan struct{})}
+	if minBytes <= 0 || period <= 0 {
+		return w
+	}
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			w.mu.Lock()
+			w.stalled = w.received < minBytes
+			w.received = 0
+			stalled := w.stalled
+			w.mu.Unlock()
+			if stalled {
+				cancel()
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// observe records that the download has reached done bytes.
+func (w *stallWatch) observe(done int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// A download that restarts from the beginning goes backwards.
+	if w.started && done > w.last {
+		w.received += done - w.last
+	}
+	w.started = true
+	w.last = done
+}
+
+func (w *stallWatch) isStalled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalled
+}
+
+func (w *stallWatch) stop() {
+	close(w.done)
+}
+
+// partialMaxAge is how long after it was last written a partial download is
+// assumed to have been abandoned, rather than waiting to be resumed.
+var partialMaxAge = 24 * time.Hour
+
+// removeOrphanedPartials removes the partial downloads in dir which haven't
+// been written to for partialMaxAge. A download in progress keeps writing to
+// its partial file, so it's never removed from under it.
+func removeOrphanedPartials(dir string) {
+	partials, err := filepath.Glob(filepath.Join(dir, "*.partial"))
+	if err != nil {
+		glog.Warningf("Error listing partial downloads in %s: %s", dir, err)
+		return
+	}
+	for _, p := range partials {
+		fi, err := os.Stat(p)
+		if err != nil || time.Since(fi.ModTime()) < partialMaxAge {
+			continue
+		}
+		glog.Infof("Removing partial download %s, last written at %s", p, fi.ModTime())
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("Error removing partial download %s: %s", p, err)
+		}
+	}
+}
+
+// downloadPartial downloads url to partial, resuming from the end of
+// partial if the server supports range requests, and starting over if it
+// doesn't. An interrupted download is left in partial.
+func downloadPartial(client *http.Client, url, partial string, report progressFunc) error {
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", partial)
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrapf(err, "seeking %s", partial)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		glog.Infof("Resuming download of %s from byte %d", url, offset)
+	case http.StatusOK:
+		if offset > 0 {
+			offset = 0
+			glog.Infof("Server doesn't support resuming %s, downloading it again", url)
+			if err := f.Truncate(0); err != nil {
+				return errors.Wrapf(err, "truncating %s", partial)
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return errors.Wrapf(err, "seeking %s", partial)
+			}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// partial is already complete, and is verified by the caller.
+		return nil
+	default:
+		return errors.Errorf("received invalid status code: %d (expected %d)", resp.StatusCode, http.StatusOK)
+	}
+
+	var body io.Reader = resp.Body
+	if report != nil {
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+		report(offset, total)
+		body = &progressReader{r: resp.Body, done: offset, total: total, report: report}
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		return errors.Wrap(err, "copying contents")
+	}
+	return f.Close()
+}
+
+// progressFunc is called with the number of bytes downloaded so far, and
+// the total, or -1 if it's unknown.
+type progressFunc func(done, total int64)
+
+// progressReader reports the progress of reading a download.
+type progressReader struct {
+	r      io.Reader
+	done   int64
+	total  int64
+	report progressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.report(p.done, p.total)
+	}
+	return n, err
+}
+
+// expectedChecksum returns the hex encoded checksum for filename, given
+// either the checksum itself or the URL of a checksum file as accepted by
+// go-download. It returns "" if checksum is empty.
+func expectedChecksum(client *http.Client, checksum, filename string) (string, error) {
+	if !strings.HasPrefix(checksum, "http://") && !strings.HasPrefix(checksum, "https://") {
+		return checksum, nil
+	}
+
+	resp, err := client.Get(checksum)
+	if err != nil {
+		return "", errors.Wrap(err, "downloading checksum file")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("downloading checksum file: received status code %d", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "reading checksum file")
+	}
+
+	// The file holds either just the checksum, or lines of the form
+	// "CHECKSUM FILENAME".
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] == filename {
+			return fields[0], nil
+		}
+	}
+	if fields := strings.Fields(lines[0]); len(fields) == 1 {
+		if _, err := hex.DecodeString(fields[0]); err == nil {
+			return fields[0], nil
+		}
+	}
+	return "", errors.Errorf("no checksum for %s in %s", filename, checksum)
+}
+
+// fileChecksum returns the hex encoded checksum of the file at path, using
+// SHA256 if hash is unset.
+func fileChecksum(path string, hash crypto.Hash) (string, error) {
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	if !hash.Available() {
+		return "", errors.Errorf("unsupported checksum hash %d", hash)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "opening %s", path)
+	}
+	defer f.Close()
+
+	h := hash.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "reading %s", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextTransport attaches ctx to every request so that cancelling ctx
+// aborts both the connection and any in-progress body read.
+type contextTransport struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.rt.RoundTrip(req.WithContext(t.ctx))
+}
+
+// transientErrorTracker records whether a request failed in a way that's
+// worth retrying: a network error, including while reading the response
+// body, or a 5xx response.
+type transientErrorTracker struct {
+	rt http.RoundTripper
+
+	mu        sync.Mutex
+	transient bool
+}
+
+func (t *transientErrorTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		t.setTransient()
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		t.setTransient()
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, t: t}
+	return resp, nil
+}
+
+func (t *transientErrorTracker) setTransient() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transient = true
+}
+
+func (t *transientErrorTracker) isTransient() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transient
+}
+
+// trackedBody records read errors, e.g. a dropped connection, as transient.
+type trackedBody struct {
+	io.ReadCloser
+	t *transientErrorTracker
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.t.setTransient()
+	}
+	return n, err
+}
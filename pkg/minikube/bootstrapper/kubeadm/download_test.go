@@ -0,0 +1,1002 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	download "github.com/jimmidyson/go-download"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// testDownloader doesn't print status messages, which would clutter the
+// test output.
+var testDownloader = &downloader{out: ioutil.Discard}
+
+func TestDownloadFileCancelled(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		fmt.Fprint(w, "partial contents")
+		w.(http.Flusher).Flush()
+		// Never finish the body, the client has to give up.
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	dst := filepath.Join(tempDir, "kubelet")
+	errCh := make(chan error)
+	go func() {
+		errCh <- testDownloader.downloadFile(ctx, server.URL, dst, download.FileOptions{Mkdirs: download.MkdirAll}, nil)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("Expected error from cancelled download, got nil")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Download did not stop after the context was cancelled")
+	}
+
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("Expected no file at %s after a cancelled download", dst)
+	}
+	b, err := ioutil.ReadFile(dst + ".partial")
+	if err != nil {
+		t.Fatalf("Expected the partial download to be kept for resuming: %s", err)
+	}
+	if string(b) != "partial contents" {
+		t.Errorf("Expected partial download to contain %q, got %q", "partial contents", string(b))
+	}
+}
+
+func TestDownloadFileAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := testDownloader.downloadFile(ctx, "http://127.0.0.1:0/kubelet", "kubelet", download.FileOptions{}, nil); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMaybeDownloadAndCacheVerifiesCached(t *testing.T) {
+	const contents = "kubelet binary"
+	cases := []struct {
+		description string
+		cached      string
+		recorded    bool
+		redownload  bool
+	}{
+		{
+			description: "intact",
+			cached:      contents,
+			recorded:    true,
+		},
+		{
+			description: "cached before checksums were recorded",
+			cached:      "kubelet bin",
+		},
+		{
+			description: "truncated",
+			cached:      "kubelet bin",
+			recorded:    true,
+			redownload:  true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "minikube")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+			defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+			os.Setenv(constants.MinikubeHome, tempDir)
+
+			path := constants.MakeMiniPath("cache", "v1.8.0", "kubelet")
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatalf("Error making cache dir: %s", err)
+			}
+			if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatalf("Error caching kubelet: %s", err)
+			}
+			if test.recorded {
+				if err := writeChecksumFile(path); err != nil {
+					t.Fatalf("Error recording checksum: %s", err)
+				}
+			}
+			if err := ioutil.WriteFile(path, []byte(test.cached), 0644); err != nil {
+				t.Fatalf("Error caching kubelet: %s", err)
+			}
+
+			// Downloading again fails at once, as ctx is cancelled.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			cached, err := testDownloader.maybeDownloadAndCache(ctx, "kubelet", constants.NodeArch, bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0"})
+			if test.redownload {
+				if err == nil {
+					t.Fatalf("Expected the corrupt kubelet to be downloaded again, got %s", cached)
+				}
+				for _, p := range []string{path, path + checksumSuffix} {
+					if _, err := os.Stat(p); !os.IsNotExist(err) {
+						t.Errorf("Expected %s to be removed", p)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if cached != path {
+				t.Errorf("Expected the cached kubelet at %s, got %s", path, cached)
+			}
+		})
+	}
+}
+
+func TestDownloadRelease(t *testing.T) {
+	const contents = "kubelet binary"
+	sha256sum := fmt.Sprintf("%x", sha256.Sum256([]byte(contents)))
+	sha1sum := fmt.Sprintf("%x", sha1.Sum([]byte(contents)))
+
+	cases := []struct {
+		description string
+		checksums   map[string]string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "sha256",
+			checksums:   map[string]string{"/kubelet.sha256": sha256sum, "/kubelet.sha1": sha1sum},
+			expected:    "/kubelet.sha256",
+		},
+		{
+			description: "sha1 fallback for old releases",
+			checksums:   map[string]string{"/kubelet.sha1": sha1sum},
+			expected:    "/kubelet.sha1",
+		},
+		{
+			description: "sha256 mismatch",
+			checksums:   map[string]string{"/kubelet.sha256": sha1sum + "000000000000000000000000", "/kubelet.sha1": sha1sum},
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "download")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/kubelet" {
+					fmt.Fprint(w, contents)
+					return
+				}
+				checksum, ok := test.checksums[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintln(w, checksum)
+			}))
+			defer server.Close()
+
+			dst := filepath.Join(tempDir, "kubelet")
+			checksum, err := testDownloader.downloadRelease(context.Background(), server.URL+"/kubelet", server.URL+"/kubelet.sha256", server.URL+"/kubelet.sha1", dst, nil)
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				if _, err := os.Stat(dst); !os.IsNotExist(err) {
+					t.Errorf("Expected no file at %s after a failed verification", dst)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error downloading release: %s", err)
+			}
+			if checksum != server.URL+test.expected {
+				t.Errorf("Expected verification with %s, got %s", server.URL+test.expected, checksum)
+			}
+			b, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatalf("Error reading download: %s", err)
+			}
+			if string(b) != contents {
+				t.Errorf("Expected download to contain %q, got %q", contents, string(b))
+			}
+		})
+	}
+}
+
+func TestDownloadProbesRetries(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	cases := []struct {
+		description      string
+		failures         int
+		failureStatus    int
+		expectedRequests int
+		// unreachable and missing are whether checkReachable and exists
+		// fail.
+		unreachable bool
+		missing     bool
+	}{
+		{
+			description:      "succeeds after transient failures",
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedRequests: 3,
+		},
+		{
+			description:      "gives up after the last attempt",
+			failures:         downloadAttempts,
+			failureStatus:    http.StatusBadGateway,
+			expectedRequests: downloadAttempts,
+			unreachable:      true,
+			missing:          true,
+		},
+		{
+			description:      "client error isn't retried",
+			failures:         1,
+			failureStatus:    http.StatusUnauthorized,
+			expectedRequests: 1,
+			missing:          true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := atomic.AddInt32(&requests, 1); int(n) <= test.failures {
+					w.WriteHeader(test.failureStatus)
+				}
+			}))
+			defer server.Close()
+			url := server.URL + "/kubelet.sha256"
+
+			err := testDownloader.checkReachable(context.Background(), url)
+			if (err != nil) != test.unreachable {
+				t.Errorf("Expected unreachable %t, got %v", test.unreachable, err)
+			}
+			if n := int(atomic.LoadInt32(&requests)); n != test.expectedRequests {
+				t.Errorf("Expected checkReachable to make %d requests, got %d", test.expectedRequests, n)
+			}
+
+			atomic.StoreInt32(&requests, 0)
+			found, err := testDownloader.exists(context.Background(), url)
+			if (err != nil || !found) != test.missing {
+				t.Errorf("Expected missing %t, got %t, %v", test.missing, found, err)
+			}
+			if n := int(atomic.LoadInt32(&requests)); n != test.expectedRequests {
+				t.Errorf("Expected exists to make %d requests, got %d", test.expectedRequests, n)
+			}
+		})
+	}
+}
+
+func TestDownloadFileRetries(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	const contents = "kubelet binary"
+	cases := []struct {
+		description      string
+		failures         int
+		failureStatus    int
+		checksum         string
+		expectedRequests int
+		shouldErr        bool
+	}{
+		{
+			description:      "succeeds after transient failures",
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedRequests: 3,
+		},
+		{
+			description:      "gives up after the last attempt",
+			failures:         downloadAttempts,
+			failureStatus:    http.StatusBadGateway,
+			expectedRequests: downloadAttempts,
+			shouldErr:        true,
+		},
+		{
+			description:      "not found isn't retried",
+			failures:         1,
+			failureStatus:    http.StatusNotFound,
+			expectedRequests: 1,
+			shouldErr:        true,
+		},
+		{
+			description:      "checksum mismatch isn't retried",
+			checksum:         fmt.Sprintf("%x", sha256.Sum256([]byte("other binary"))),
+			expectedRequests: 1,
+			shouldErr:        true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "download")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := atomic.AddInt32(&requests, 1); int(n) <= test.failures {
+					w.WriteHeader(test.failureStatus)
+					return
+				}
+				fmt.Fprint(w, contents)
+			}))
+			defer server.Close()
+
+			options := download.FileOptions{Mkdirs: download.MkdirAll}
+			if test.checksum != "" {
+				options.Checksum = test.checksum
+				options.ChecksumHash = crypto.SHA256
+			}
+			err = testDownloader.downloadFile(context.Background(), server.URL+"/kubelet", filepath.Join(tempDir, "kubelet"), options, nil)
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error, got nil")
+			}
+			if n := int(atomic.LoadInt32(&requests)); n != test.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", test.expectedRequests, n)
+			}
+		})
+	}
+}
+
+// interrupt sends the headers for the whole of contents but only the first
+// n bytes of it, then drops the connection.
+func interrupt(t *testing.T, w http.ResponseWriter, contents string, n int) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Fatalf("Error hijacking connection: %s", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(contents), contents[:n])
+	buf.Flush()
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	contents := strings.Repeat("kubelet binary ", 1000)
+	sha256sum := fmt.Sprintf("%x", sha256.Sum256([]byte(contents)))
+
+	cases := []struct {
+		description   string
+		supportsRange bool
+		partial       string
+		checksum      string
+		expectedRange string
+		shouldErr     bool
+	}{
+		{
+			description:   "interrupted download is resumed",
+			supportsRange: true,
+			checksum:      sha256sum,
+			expectedRange: "bytes=" + strconv.Itoa(len(contents)/2) + "-",
+		},
+		{
+			description: "server ignoring range is downloaded again",
+			checksum:    sha256sum,
+		},
+		{
+			description:   "partial from an earlier run is resumed",
+			supportsRange: true,
+			partial:       contents[:100],
+			checksum:      sha256sum,
+			expectedRange: "bytes=100-",
+		},
+		{
+			description:   "corrupt partial fails verification",
+			supportsRange: true,
+			partial:       strings.Repeat("x", 100),
+			checksum:      sha256sum,
+			shouldErr:     true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "download")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			dst := filepath.Join(tempDir, "kubelet")
+			if test.partial != "" {
+				if err := ioutil.WriteFile(dst+".partial", []byte(test.partial), 0644); err != nil {
+					t.Fatalf("Error writing partial download: %s", err)
+				}
+			}
+
+			var requests int32
+			var ranges atomic.Value
+			ranges.Store("")
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					ranges.Store(r.Header.Get("Range"))
+				}
+				if atomic.AddInt32(&requests, 1) == 1 && test.partial == "" {
+					interrupt(t, w, contents, len(contents)/2)
+					return
+				}
+				if !test.supportsRange {
+					fmt.Fprint(w, contents)
+					return
+				}
+				http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+			}))
+			defer server.Close()
+
+			options := download.FileOptions{Mkdirs: download.MkdirAll}
+			options.Checksum = test.checksum
+			options.ChecksumHash = crypto.SHA256
+			err = testDownloader.downloadFile(context.Background(), server.URL+"/kubelet", dst, options, nil)
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				if _, err := os.Stat(dst + ".partial"); !os.IsNotExist(err) {
+					t.Errorf("Expected a corrupt partial download to be removed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error downloading: %s", err)
+			}
+
+			if r := ranges.Load().(string); test.expectedRange != "" && r != test.expectedRange {
+				t.Errorf("Expected range request %q, got %q", test.expectedRange, r)
+			}
+			b, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatalf("Error reading download: %s", err)
+			}
+			if actual := fmt.Sprintf("%x", sha256.Sum256(b)); actual != sha256sum {
+				t.Errorf("Expected resumed download to have checksum %s, got %s", sha256sum, actual)
+			}
+			if _, err := os.Stat(dst + ".partial"); !os.IsNotExist(err) {
+				t.Errorf("Expected the partial download to be renamed into place")
+			}
+		})
+	}
+}
+
+func TestMaybeDownloadAndCacheMirror(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	var paths []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	_, err = testDownloader.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL})
+	if err == nil {
+		t.Fatal("Expected error downloading from a mirror without the binary, got nil")
+	}
+	if !strings.Contains(err.Error(), server.URL) {
+		t.Errorf("Expected error to name the mirror %s, got: %s", server.URL, err)
+	}
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet") {
+			t.Errorf("Expected all requests to use the release path layout, got %s", p)
+		}
+	}
+	if len(paths) == 0 {
+		t.Error("Expected requests to the mirror, got none")
+	}
+}
+
+func TestMaybeDownloadAndCacheForPlatform(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := "darwin kubectl"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kubernetes-release/release/v1.8.0/bin/darwin/amd64/kubectl":
+			http.ServeContent(w, r, "kubectl", time.Time{}, strings.NewReader(contents))
+		case "/kubernetes-release/release/v1.8.0/bin/darwin/amd64/kubectl.sha256":
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL}
+	path, err := testDownloader.maybeDownloadAndCacheForPlatform(context.Background(), "kubectl", "darwin", "amd64", k8s)
+	if err != nil {
+		t.Fatalf("Error downloading kubectl: %s", err)
+	}
+	if expected := filepath.Join(tempDir, ".minikube", "cache", "v1.8.0", "darwin-amd64", "kubectl"); path != expected {
+		t.Errorf("Expected kubectl to be cached at %s, got %s", expected, path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading cached kubectl: %s", err)
+	}
+	if string(b) != contents {
+		t.Errorf("Expected cached kubectl %q, got %q", contents, b)
+	}
+	if err := verifyChecksumFile(path); err != nil {
+		t.Errorf("Expected the cached kubectl's checksum to be recorded: %s", err)
+	}
+}
+
+func TestMaybeDownloadAndCacheConcurrent(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := strings.Repeat("kubelet binary ", 10000)
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+			return
+		}
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&downloads, 1)
+			// Keep the download going long enough for the others to wait.
+			time.Sleep(100 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL}
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := testDownloader.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, k8s)
+			if err != nil {
+				errs <- err
+				return
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(b) != contents {
+				errs <- fmt.Errorf("cached kubelet is corrupt: %d bytes", len(b))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Error downloading concurrently: %s", err)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("Expected exactly one download, got %d", n)
+	}
+	if _, err := os.Stat(constants.MakeMiniPath("cache", "v1.8.0", "kubelet.lock")); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released, got: %v", err)
+	}
+}
+
+func TestCachedBinaryPath(t *testing.T) {
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, "/home/minikube")
+
+	cases := []struct {
+		description string
+		goos        string
+		goarch      string
+		expected    string
+	}{
+		{
+			description: "node platform",
+			goos:        "linux",
+			goarch:      "amd64",
+			expected:    "/home/minikube/.minikube/cache/v1.8.0/kubectl",
+		},
+		{
+			description: "arm64 node",
+			goos:        "linux",
+			goarch:      "arm64",
+			expected:    "/home/minikube/.minikube/cache/v1.8.0/linux-arm64/kubectl",
+		},
+		{
+			description: "other platform",
+			goos:        "darwin",
+			goarch:      "amd64",
+			expected:    "/home/minikube/.minikube/cache/v1.8.0/darwin-amd64/kubectl",
+		},
+		{
+			description: "windows",
+			goos:        "windows",
+			goarch:      "amd64",
+			expected:    "/home/minikube/.minikube/cache/v1.8.0/windows-amd64/kubectl.exe",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if path := cachedBinaryPath("kubectl", "v1.8.0", test.goos, test.goarch); path != filepath.FromSlash(test.expected) {
+				t.Errorf("Expected %s, got %s", test.expected, path)
+			}
+		})
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := strings.Repeat("kubelet binary ", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+			return
+		}
+		http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	var reports []DownloadProgress
+	d := &downloader{
+		out:      &out,
+		progress: func(p DownloadProgress) { reports = append(reports, p) },
+	}
+	if _, err := d.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL}); err != nil {
+		t.Fatalf("Error downloading: %s", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("Expected several progress reports, got %v", reports)
+	}
+	var last int64
+	for _, r := range reports {
+		if r.Binary != "kubelet" || r.Version != "v1.8.0" {
+			t.Errorf("Expected reports for kubelet v1.8.0, got %+v", r)
+		}
+		if r.Total != int64(len(contents)) {
+			t.Errorf("Expected total %d, got %+v", len(contents), r)
+		}
+		if r.Done < last {
+			t.Errorf("Expected progress to only increase, got %+v after %d", r, last)
+		}
+		last = r.Done
+	}
+	if final := reports[len(reports)-1]; !final.Finished || final.Done != int64(len(contents)) {
+		t.Errorf("Expected a final finished report, got %+v", final)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no status messages when reporting progress, got %q", out.String())
+	}
+}
+
+func TestMaybeDownloadAndCacheOffline(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, "kubelet binary")
+	}))
+	defer server.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		cached      bool
+		shouldErr   bool
+	}{
+		{
+			description: "offline mode",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL, Offline: true},
+			shouldErr:   true,
+		},
+		{
+			description: "offline mode with cached binary",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL, Offline: true},
+			cached:      true,
+		},
+		{
+			description: "unreachable",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: unreachable.URL},
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			cachePath := constants.MakeMiniPath("cache", "v1.8.0", "kubelet")
+			os.Remove(cachePath)
+			if test.cached {
+				if err := os.MkdirAll(filepath.Dir(cachePath), 0777); err != nil {
+					t.Fatalf("Error making cache dir: %s", err)
+				}
+				if err := ioutil.WriteFile(cachePath, []byte("kubelet binary"), 0644); err != nil {
+					t.Fatalf("Error caching binary: %s", err)
+				}
+			}
+
+			path, err := testDownloader.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, test.k8s)
+			if n := atomic.LoadInt32(&requests); test.k8s.Offline && n != 0 {
+				t.Errorf("Expected no requests in offline mode, got %d", n)
+			}
+			if !test.shouldErr {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				if path != cachePath {
+					t.Errorf("Expected cached binary %s, got %s", cachePath, path)
+				}
+				return
+			}
+
+			notCached, ok := err.(*NotCachedError)
+			if !ok {
+				t.Fatalf("Expected NotCachedError, got %v", err)
+			}
+			if notCached.CachePath != cachePath {
+				t.Errorf("Expected cache path %s, got %s", cachePath, notCached.CachePath)
+			}
+			for _, s := range []string{"kubelet v1.8.0", cachePath, notCached.URL, "curl -fLo"} {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("Expected error to contain %q, got: %s", s, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMaybeDownloadAndCacheRemovesOrphanedPartials(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	cachePath := constants.MakeMiniPath("cache", "v1.8.0", "kubelet")
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0777); err != nil {
+		t.Fatalf("Error making cache dir: %s", err)
+	}
+	files := map[string]time.Duration{
+		cachePath: 0,
+		constants.MakeMiniPath("cache", "v1.8.0", "kubeadm.partial"): 48 * time.Hour,
+		constants.MakeMiniPath("cache", "v1.8.0", "kubectl.partial"): time.Minute,
+	}
+	for path, age := range files {
+		if err := ioutil.WriteFile(path, []byte("binary"), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", path, err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatalf("Error setting the modification time of %s: %s", path, err)
+		}
+	}
+
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", Offline: true}
+	if _, err := testDownloader.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, k8s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := os.Stat(constants.MakeMiniPath("cache", "v1.8.0", "kubeadm.partial")); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned partial download to be removed, got %v", err)
+	}
+	if _, err := os.Stat(constants.MakeMiniPath("cache", "v1.8.0", "kubectl.partial")); err != nil {
+		t.Errorf("Expected the recent partial download to be kept, got %v", err)
+	}
+}
+
+// trickle sends the headers for the whole of contents, then a byte of it
+// every 10ms until the client goes away.
+func trickle(w http.ResponseWriter, r *http.Request, contents string) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+	w.WriteHeader(http.StatusOK)
+	for i := 0; i < len(contents); i++ {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		fmt.Fprint(w, contents[i:i+1])
+		w.(http.Flusher).Flush()
+	}
+}
+
+func TestDownloadFileStalled(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	contents := strings.Repeat("kubelet binary ", 1000)
+	cases := []struct {
+		description      string
+		stalls           int
+		expectedRequests int
+		shouldErr        bool
+	}{
+		{
+			description:      "stalled attempt is retried",
+			stalls:           1,
+			expectedRequests: 2,
+		},
+		{
+			description:      "gives up after the last attempt",
+			stalls:           downloadAttempts,
+			expectedRequests: downloadAttempts,
+			shouldErr:        true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "download")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if n := atomic.AddInt32(&requests, 1); int(n) <= test.stalls {
+					trickle(w, r, contents)
+					return
+				}
+				http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+			}))
+			defer server.Close()
+
+			d := &downloader{out: ioutil.Discard, stallBytes: 1024, stallPeriod: 100 * time.Millisecond}
+			dst := filepath.Join(tempDir, "kubelet")
+			err = d.downloadFile(context.Background(), server.URL+"/kubelet", dst, download.FileOptions{Mkdirs: download.MkdirAll}, nil)
+			if n := int(atomic.LoadInt32(&requests)); n != test.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", test.expectedRequests, n)
+			}
+			if test.shouldErr {
+				if _, ok := err.(*DownloadStalledError); !ok {
+					t.Fatalf("Expected DownloadStalledError, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "--kubernetes-release-mirror") {
+					t.Errorf("Expected the error to suggest a mirror, got: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if b, err := ioutil.ReadFile(dst); err != nil || string(b) != contents {
+				t.Errorf("Expected the downloaded file to be complete, got %d bytes, %v", len(b), err)
+			}
+		})
+	}
+}
+
+func TestMaybeDownloadAndCacheTimeout(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := strings.Repeat("kubelet binary ", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			return
+		case strings.HasSuffix(r.URL.Path, ".sha256"):
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+		default:
+			trickle(w, r, contents)
+		}
+	}))
+	defer server.Close()
+
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL, DownloadTimeout: 200 * time.Millisecond}
+	_, err = testDownloader.maybeDownloadAndCache(context.Background(), "kubelet", constants.NodeArch, k8s)
+	if err == nil {
+		t.Fatal("Expected the download to time out, got nil")
+	}
+	for _, s := range []string{"timed out after 200ms", "--kubernetes-release-mirror"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q, got: %s", s, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// eventsCommand lists the events in namespace, or in all namespaces if
+// namespace is metav1.NamespaceAll, oldest first.
+func eventsCommand(namespace string) string {
+	scope := "-n " + namespace
+	if namespace == metav1.NamespaceAll {
+		scope = "--all-namespaces"
+	}
+	return kubectl(fmt.Sprintf("get events %s --sort-by=.lastTimestamp", scope))
+}
+
+// GetClusterEvents returns the cluster's events in namespace, or in all
+// namespaces if namespace is metav1.NamespaceAll, as kubectl lists them,
+// e.g. to see why pods can't be scheduled or their images pulled. An error
+// saying so is returned if the apiserver isn't ready to list them.
+func (k *KubeadmBootstrapper) GetClusterEvents(namespace string) (string, error) {
+	if out, err := k.c.CombinedOutput(apiServerHealthCommand); err != nil {
+		return "", errors.Wrapf(err, "the apiserver isn't ready, so events can't be listed: %s", strings.TrimSpace(out))
+	}
+	// kubectl says there are no events on stderr, which is logged.
+	res, err := k.c.Output(eventsCommand(namespace))
+	if err != nil {
+		return "", errors.Wrap(err, "getting events")
+	}
+	return res.Stdout, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestEventsCommand(t *testing.T) {
+	cases := []struct {
+		namespace string
+		expected  string
+	}{
+		{
+			namespace: "kube-system",
+			expected:  kubectl("get events -n kube-system --sort-by=.lastTimestamp"),
+		},
+		{
+			namespace: metav1.NamespaceAll,
+			expected:  kubectl("get events --all-namespaces --sort-by=.lastTimestamp"),
+		},
+	}
+	for _, test := range cases {
+		if cmd := eventsCommand(test.namespace); cmd != test.expected {
+			t.Errorf("eventsCommand(%q): expected %q, got %q", test.namespace, test.expected, cmd)
+		}
+	}
+}
+
+func TestGetClusterEvents(t *testing.T) {
+	const events = "LAST SEEN   TYPE      REASON             OBJECT      MESSAGE\n1m          Warning   FailedScheduling   pod/nginx   0/1 nodes are available\n"
+	cases := []struct {
+		description string
+		outputs     map[string]string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "ready",
+			outputs: map[string]string{
+				apiServerHealthCommand:   "ok",
+				eventsCommand("default"): events,
+			},
+			expected: events,
+		},
+		{
+			description: "apiserver not ready",
+			outputs: map[string]string{
+				eventsCommand("default"): events,
+			},
+			shouldErr: true,
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(test.outputs)
+			k := KubeadmBootstrapper{c: f}
+			out, err := k.GetClusterEvents("default")
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error, got %q", out)
+			}
+			if out != test.expected {
+				t.Errorf("Expected events %q, got %q", test.expected, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// The host aliases minikube adds to /etc/hosts are kept between these
+// markers, so that they can be replaced without touching the rest of the
+// file.
+const (
+	hostsBeginMarker = "# BEGIN minikube host aliases"
+	hostsEndMarker   = "# END minikube host aliases"
+)
+
+const (
+	hostsFile = "/etc/hosts"
+	// hostsStagingFile is where the new /etc/hosts is copied to, before
+	// it's copied over /etc/hosts in place.
+	hostsStagingFile = "/var/lib/minikube/hosts"
+)
+
+// updateHosts replaces the host aliases in the node's /etc/hosts with the
+// given ones. /etc/hosts isn't written if it wouldn't change.
+func (k *KubeadmBootstrapper) updateHosts(aliases []bootstrapper.HostAlias) error {
+	current, err := k.c.CombinedOutput("cat " + hostsFile)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", hostsFile)
+	}
+	updated := updateHostsFile(current, aliases)
+	if updated == current {
+		return nil
+	}
+
+	if err := k.c.Copy(assets.NewMemoryAssetTarget([]byte(updated), hostsStagingFile, "0644")); err != nil {
+		return errors.Wrap(err, "copying hosts file")
+	}
+	if err := k.c.Run(bootstrapper.Sudo(fmt.Sprintf("cp %s %s", hostsStagingFile, hostsFile))); err != nil {
+		return errors.Wrapf(err, "writing %s", hostsFile)
+	}
+	return nil
+}
+
+// updateHostsFile returns the hosts file hosts, with minikube's block of
+// host aliases replaced by aliases, or removed if there are none.
+func updateHostsFile(hosts string, aliases []bootstrapper.HostAlias) string {
+	var lines []string
+	inBlock := false
+	for _, line := range strings.SplitAfter(hosts, "\n") {
+		switch strings.TrimSpace(line) {
+		case hostsBeginMarker:
+			inBlock = true
+			continue
+		case hostsEndMarker:
+			inBlock = false
+			continue
+		}
+		if !inBlock && line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(aliases) == 0 {
+		return strings.Join(lines, "")
+	}
+
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	lines = append(lines, hostsBeginMarker+"\n")
+	for _, a := range aliases {
+		lines = append(lines, fmt.Sprintf("%s\t%s\n", a.IP, strings.Join(a.Hostnames, " ")))
+	}
+	lines = append(lines, hostsEndMarker+"\n")
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestUpdateHostsFile(t *testing.T) {
+	const hosts = "127.0.0.1\tlocalhost\n127.0.1.1\tminikube\n"
+	aliases := []bootstrapper.HostAlias{
+		{IP: "10.0.0.5", Hostnames: []string{"registry.corp", "registry"}},
+		{IP: "10.0.0.6", Hostnames: []string{"apiserver.corp"}},
+	}
+	const withAliases = hosts +
+		"# BEGIN minikube host aliases\n" +
+		"10.0.0.5\tregistry.corp registry\n" +
+		"10.0.0.6\tapiserver.corp\n" +
+		"# END minikube host aliases\n"
+
+	cases := []struct {
+		description string
+		hosts       string
+		aliases     []bootstrapper.HostAlias
+		expected    string
+	}{
+		{
+			description: "append",
+			hosts:       hosts,
+			aliases:     aliases,
+			expected:    withAliases,
+		},
+		{
+			description: "append already applied",
+			hosts:       withAliases,
+			aliases:     aliases,
+			expected:    withAliases,
+		},
+		{
+			description: "replace",
+			hosts:       withAliases,
+			aliases:     []bootstrapper.HostAlias{{IP: "10.0.0.7", Hostnames: []string{"registry.corp"}}},
+			expected:    hosts + "# BEGIN minikube host aliases\n10.0.0.7\tregistry.corp\n# END minikube host aliases\n",
+		},
+		{
+			description: "remove",
+			hosts:       withAliases,
+			expected:    hosts,
+		},
+		{
+			description: "no aliases",
+			hosts:       hosts,
+			expected:    hosts,
+		},
+		{
+			description: "keeps entries added after the block",
+			hosts:       withAliases + "10.0.0.9\tother\n",
+			aliases:     aliases,
+			expected:    hosts + "10.0.0.9\tother\n" + withAliases[len(hosts):],
+		},
+		{
+			description: "no trailing newline",
+			hosts:       "127.0.0.1\tlocalhost",
+			aliases:     aliases[1:],
+			expected:    "127.0.0.1\tlocalhost\n# BEGIN minikube host aliases\n10.0.0.6\tapiserver.corp\n# END minikube host aliases\n",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			updated := updateHostsFile(test.hosts, test.aliases)
+			if updated != test.expected {
+				t.Errorf("Expected hosts file:\n%q\ngot:\n%q", test.expected, updated)
+			}
+			if again := updateHostsFile(updated, test.aliases); again != updated {
+				t.Errorf("Expected updating again to change nothing, got:\n%q", again)
+			}
+		})
+	}
+}
+
+func TestUpdateHostsUnchanged(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{"cat /etc/hosts": "127.0.0.1\tlocalhost\n"})
+	k := KubeadmBootstrapper{c: f}
+	// Writing /etc/hosts would fail, as the fake runner has no output set
+	// for the cp command.
+	if err := k.updateHosts(nil); err != nil {
+		t.Errorf("Expected /etc/hosts not to be written, got: %s", err)
+	}
+}
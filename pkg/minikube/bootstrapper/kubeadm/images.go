@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/minikube/machine"
+)
+
+// loadImages loads cached images into the node's container runtime.
+var loadImages = machine.LoadCachedImages
+
+// imagesNotInRuntime lists the images that aren't loaded into the node's
+// container runtime yet.
+var imagesNotInRuntime = machine.ImagesNotInRuntime
+
+// loadBundle loads a bundle of cached images into the node's container
+// runtime.
+var loadBundle = machine.LoadCachedBundle
+
+// verifyCachedImages lists the images missing from the host's image cache.
+var verifyCachedImages = machine.MissingCachedImages
+
+// loadCachedImages starts loading the images kubeadm needs from the host's
+// image cache, so that kubeadm init needn't pull them, and returns a
+// function that waits for them to load. Images that fail to load are
+// pulled by kubeadm init instead, with a warning naming each, unless
+// k8s.RequireCachedImages, when waiting returns an error naming them.
+// Nothing is loaded unless k8s.LoadsCachedImages, and images already in the
+// node's container runtime aren't loaded again unless k8s.ForceReload.
+// With k8s.BundleCachedImages, they're loaded from their bundle if it's
+// cached and none of them are loaded yet.
+func (k *KubeadmBootstrapper) loadCachedImages(k8s bootstrapper.KubernetesConfig) func() error {
+	if !k8s.LoadsCachedImages() {
+		return func() error { return nil }
+	}
+	if !constants.KubeadmImagesKnown(k8s.KubernetesVersion) {
+		glog.Warningf("The images kubeadm uses for %s aren't known, so the cached ones may not be the ones it pulls", k8s.KubernetesVersion)
+	}
+	images := k8s.GetKubeadmCachedImages()
+	if missing, err := verifyCachedImages(images, k8s.GetImageCacheDir()); err != nil {
+		glog.Warningf("Error verifying cached images: %s", err)
+	} else if len(missing) > 0 {
+		glog.Warningf("Images missing from the cache, which must be pulled before they can be loaded: %s", strings.Join(missing, ", "))
+	}
+
+	done := make(chan map[string]error, 1)
+	go func() {
+		if !k8s.ForceReload {
+			if missing, err := imagesNotInRuntime(k.c, k8s.ContainerRuntime, images); err != nil {
+				glog.Warningf("Loading every cached image, as the container runtime's couldn't be listed: %s", err)
+			} else {
+				images = missing
+			}
+		}
+		if k8s.BundleCachedImages {
+			images = k.loadCachedBundle(k8s, images)
+		}
+		done <- loadImages(k.c, k8s.ContainerRuntime, images, k8s.GetImageCacheDir(), machine.DefaultImageLoadParallelism, k.imageProgress)
+	}()
+
+	return func() error {
+		failed := <-done
+		if len(failed) == 0 {
+			return nil
+		}
+		var names []string
+		for image := range failed {
+			names = append(names, image)
+		}
+		sort.Strings(names)
+		for _, image := range names {
+			glog.Warningf("Error loading cached image %s: %s", image, failed[image])
+		}
+		if k8s.RequireCachedImages {
+			lines := []string{"cached images are required, but couldn't be loaded:"}
+			for _, image := range names {
+				lines = append(lines, fmt.Sprintf("  %s: %s", image, failed[image]))
+			}
+			return errors.New(strings.Join(lines, "\n"))
+		}
+		k.d.printf("WARNING: Couldn't load %d cached images, which will be pulled instead: %s\n", len(names), strings.Join(names, ", "))
+		return nil
+	}
+}
+
+// loadCachedBundle loads the bundle of the images kubeadm needs if it's
+// cached and every image in it is missing from the node, and returns the
+// images left to load one by one: all of missing if the bundle wasn't
+// loaded, e.g. when an image was added since some were loaded.
+func (k *KubeadmBootstrapper) loadCachedBundle(k8s bootstrapper.KubernetesConfig, missing []string) []string {
+	images := k8s.GetKubeadmCachedImages()
+	bundle := machine.BundlePath(k8s.GetImageCacheDir(), k8s.KubernetesVersion, images)
+	if _, err := os.Stat(bundle); err != nil {
+		glog.Infof("Loading cached images one by one, as they aren't bundled: %s", err)
+		return missing
+	}
+	if len(missing) != len(images) {
+		glog.Infof("Loading %d of %d cached images one by one rather than their bundle", len(missing), len(images))
+		return missing
+	}
+	if err := loadBundle(k.c, k8s.ContainerRuntime, bundle, images, k.imageProgress); err != nil {
+		glog.Warningf("Loading cached images one by one, as their bundle couldn't be loaded: %s", err)
+		return missing
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/minikube/machine"
+)
+
+func TestLoadCachedImages(t *testing.T) {
+	images := constants.GetKubeadmCachedImages("", "v1.8.0")
+	cases := []struct {
+		description string
+		failed      []string
+		require     bool
+		shouldErr   bool
+		expectedOut string
+	}{
+		{
+			description: "loaded",
+		},
+		{
+			description: "failures pulled instead",
+			failed:      images[:2],
+			expectedOut: "Couldn't load 2 cached images",
+		},
+		{
+			description: "failures required",
+			failed:      images[:2],
+			require:     true,
+			shouldErr:   true,
+		},
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error) {
+		loadImages = f
+	}(loadImages)
+	defer func(f func([]string, string) ([]string, error)) { verifyCachedImages = f }(verifyCachedImages)
+	verifyCachedImages = func([]string, string) ([]string, error) { return nil, nil }
+	defer func(f func(bootstrapper.CommandRunner, string, []string) ([]string, error)) { imagesNotInRuntime = f }(imagesNotInRuntime)
+	imagesNotInRuntime = func(_ bootstrapper.CommandRunner, _ string, images []string) ([]string, error) { return images, nil }
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			release := make(chan struct{})
+			loadImages = func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error {
+				<-release
+				failed := map[string]error{}
+				for _, image := range test.failed {
+					failed[image] = errors.New("no space left on device")
+				}
+				return failed
+			}
+
+			var out bytes.Buffer
+			k := &KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), d: downloader{out: &out}}
+			wait := k.loadCachedImages(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ShouldLoadCachedImages: true, RequireCachedImages: test.require})
+
+			done := make(chan error)
+			go func() { done <- wait() }()
+			select {
+			case <-done:
+				t.Fatal("Expected waiting to block until the images are loaded")
+			default:
+			}
+			close(release)
+			err := <-done
+
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				for _, image := range test.failed {
+					if !strings.Contains(err.Error(), image+": no space left on device") {
+						t.Errorf("Expected the error to name %s and why, got: %s", image, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !strings.Contains(out.String(), test.expectedOut) {
+				t.Errorf("Expected output to contain %q, got %q", test.expectedOut, out.String())
+			}
+			for _, image := range test.failed {
+				if !strings.Contains(out.String(), image) {
+					t.Errorf("Expected the warning to name %s, got %q", image, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCachedImagesDisabled(t *testing.T) {
+	cases := []struct {
+		description string
+		config      bootstrapper.KubernetesConfig
+	}{
+		{
+			description: "not loaded",
+			config:      bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0"},
+		},
+		{
+			description: "skipped for this start",
+			config:      bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ShouldLoadCachedImages: true, NoCacheImages: true},
+		},
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error) {
+		loadImages = f
+	}(loadImages)
+	loadImages = func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error {
+		t.Error("Expected cached images not to be loaded")
+		return nil
+	}
+	defer func(f func([]string, string) ([]string, error)) { verifyCachedImages = f }(verifyCachedImages)
+	verifyCachedImages = func([]string, string) ([]string, error) {
+		t.Error("Expected the image cache not to be checked")
+		return nil, nil
+	}
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := &KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), d: *testDownloader}
+			if err := k.loadCachedImages(test.config)(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestLoadCachedImagesSkipsLoaded(t *testing.T) {
+	images := constants.GetKubeadmCachedImages("", "v1.8.0")
+	cases := []struct {
+		description string
+		forceReload bool
+		listErr     error
+		expected    []string
+	}{
+		{
+			description: "loaded images skipped",
+			expected:    images[2:],
+		},
+		{
+			description: "forced reload",
+			forceReload: true,
+			expected:    images,
+		},
+		{
+			description: "runtime images unlisted",
+			listErr:     errors.New("Cannot connect to the Docker daemon"),
+			expected:    images,
+		},
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error) {
+		loadImages = f
+	}(loadImages)
+	defer func(f func([]string, string) ([]string, error)) { verifyCachedImages = f }(verifyCachedImages)
+	verifyCachedImages = func([]string, string) ([]string, error) { return nil, nil }
+	defer func(f func(bootstrapper.CommandRunner, string, []string) ([]string, error)) { imagesNotInRuntime = f }(imagesNotInRuntime)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			listed := false
+			imagesNotInRuntime = func(_ bootstrapper.CommandRunner, _ string, images []string) ([]string, error) {
+				listed = true
+				if test.listErr != nil {
+					return nil, test.listErr
+				}
+				return images[2:], nil
+			}
+			var loaded []string
+			loadImages = func(_ bootstrapper.CommandRunner, _ string, images []string, _ string, _ int, _ machine.ImageLoadProgressFunc) map[string]error {
+				loaded = images
+				return nil
+			}
+
+			k := &KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), d: *testDownloader}
+			k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ShouldLoadCachedImages: true, ForceReload: test.forceReload}
+			if err := k.loadCachedImages(k8s)(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if listed == test.forceReload {
+				t.Errorf("Expected the runtime's images to be listed: %t, got %t", !test.forceReload, listed)
+			}
+			if !reflect.DeepEqual(loaded, test.expected) {
+				t.Errorf("Expected images %v to be loaded, got %v", test.expected, loaded)
+			}
+		})
+	}
+}
+
+func TestLoadCachedImagesBundle(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "minikube-image-bundle")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	images := constants.GetKubeadmCachedImages("", "v1.10.0")
+	bundle := machine.BundlePath(cacheDir, "v1.10.0", images)
+
+	cases := []struct {
+		description string
+		bundled     bool
+		missing     []string
+		bundleErr   error
+		loaded      []string
+		bundleUsed  bool
+	}{
+		{
+			description: "new node",
+			bundled:     true,
+			missing:     images,
+			bundleUsed:  true,
+		},
+		{
+			description: "one image missing",
+			bundled:     true,
+			missing:     images[:1],
+			loaded:      images[:1],
+		},
+		{
+			description: "not bundled",
+			missing:     images,
+			loaded:      images,
+		},
+		{
+			description: "bundle fails to load",
+			bundled:     true,
+			missing:     images,
+			bundleErr:   errors.New("no space left on device"),
+			loaded:      images,
+			bundleUsed:  true,
+		},
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string, []string, string, int, machine.ImageLoadProgressFunc) map[string]error) {
+		loadImages = f
+	}(loadImages)
+	defer func(f func(bootstrapper.CommandRunner, string, string, []string, machine.ImageLoadProgressFunc) error) {
+		loadBundle = f
+	}(loadBundle)
+	defer func(f func([]string, string) ([]string, error)) { verifyCachedImages = f }(verifyCachedImages)
+	verifyCachedImages = func([]string, string) ([]string, error) { return nil, nil }
+	defer func(f func(bootstrapper.CommandRunner, string, []string) ([]string, error)) { imagesNotInRuntime = f }(imagesNotInRuntime)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			os.RemoveAll(filepath.Dir(bundle))
+			if test.bundled {
+				if err := os.MkdirAll(filepath.Dir(bundle), 0777); err != nil {
+					t.Fatalf("Error making dir: %s", err)
+				}
+				if err := ioutil.WriteFile(bundle, []byte("bundle"), 0644); err != nil {
+					t.Fatalf("Error writing bundle: %s", err)
+				}
+			}
+			imagesNotInRuntime = func(bootstrapper.CommandRunner, string, []string) ([]string, error) { return test.missing, nil }
+			bundleUsed := false
+			loadBundle = func(_ bootstrapper.CommandRunner, _ string, path string, _ []string, _ machine.ImageLoadProgressFunc) error {
+				bundleUsed = path == bundle
+				return test.bundleErr
+			}
+			var loaded []string
+			loadImages = func(_ bootstrapper.CommandRunner, _ string, images []string, _ string, _ int, _ machine.ImageLoadProgressFunc) map[string]error {
+				loaded = images
+				return nil
+			}
+
+			k := &KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), d: *testDownloader}
+			k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ShouldLoadCachedImages: true, BundleCachedImages: true, ImageCacheDir: cacheDir}
+			if err := k.loadCachedImages(k8s)(); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if bundleUsed != test.bundleUsed {
+				t.Errorf("Expected the bundle to be loaded: %t, got %t", test.bundleUsed, bundleUsed)
+			}
+			if !reflect.DeepEqual(loaded, test.loaded) {
+				t.Errorf("Expected images %v to be loaded one by one, got %v", test.loaded, loaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/config"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+const previousInitLogSuffix = ".1"
+
+// initLogSource is the source name of the kubeadm init output in the
+// cluster logs.
+const initLogSource = "kubeadm init"
+
+// rotateInitLogCmd moves the output of the previous kubeadm init aside, so
+// that a failed attempt followed by a successful one can still be debugged.
+var rotateInitLogCmd = bootstrapper.Sudo(fmt.Sprintf("mkdir -p %s && if [ -f %s ]; then mv -f %s %s%s; fi",
+	path.Dir(constants.KubeadmInitLogFile), constants.KubeadmInitLogFile, constants.KubeadmInitLogFile, constants.KubeadmInitLogFile, previousInitLogSuffix))
+
+// loggedInitCommand runs cmd as root, keeping its output in the kubeadm
+// init log on the node.
+func loggedInitCommand(cmd string) string {
+	return bootstrapper.Sudo(fmt.Sprintf("%s > %s 2>&1", cmd, constants.KubeadmInitLogFile))
+}
+
+// streamedInitCommand is loggedInitCommand, also writing cmd's output to
+// its own, so that it can be streamed as it runs. pipefail keeps cmd's exit
+// status rather than tee's.
+func streamedInitCommand(cmd string) string {
+	return bootstrapper.Sudo(fmt.Sprintf("set -o pipefail; %s 2>&1 | tee %s", cmd, constants.KubeadmInitLogFile))
+}
+
+// alreadyExistsRe matches the errors the apiserver returns when kubeadm
+// init creates an object which exists already, e.g.
+//
+//	configmaps "kubeadm-config" already exists
+//
+// Files kubeadm finds on the node, e.g. a kubeconfig with the wrong CA, are
+// reported differently, and aren't matched.
+var alreadyExistsRe = regexp.MustCompile(`[a-z.]+ "[^"]+" already exists`)
+
+// alreadyBootstrapped returns what in the output of a failed kubeadm init
+// shows that it failed because the cluster had been bootstrapped already,
+// or "" if nothing does.
+func alreadyBootstrapped(out string) string {
+	return alreadyExistsRe.FindString(out)
+}
+
+var readInitLogCmd = bootstrapper.Sudo("cat " + constants.KubeadmInitLogFile)
+
+// getInitLog returns the output of the last kubeadm init on the node, or
+// the empty string if kubeadm init hasn't been run.
+func (k *KubeadmBootstrapper) getInitLog(ctx context.Context) string {
+	out, err := k.c.CombinedOutputContext(ctx, readInitLogCmd)
+	if err != nil {
+		glog.Infof("No kubeadm init output: %s", err)
+		return ""
+	}
+	return out
+}
+
+// writeInitLog writes the output of the last kubeadm init to w, if there
+// is any.
+func (k *KubeadmBootstrapper) writeInitLog(ctx context.Context, w io.Writer) {
+	if out := k.getInitLog(ctx); out != "" {
+		fmt.Fprintf(w, "\n==> %s <==\n", initLogSource)
+		fmt.Fprint(w, out)
+	}
+}
+
+// hostInitLogFile is where the kubeadm init output is mirrored on the host.
+func hostInitLogFile() string {
+	return constants.GetProfilePath(config.GetMachineName(), path.Base(constants.KubeadmInitLogFile))
+}
+
+// saveInitLog mirrors the kubeadm init output to the host's profile
+// directory, rotating the previous attempt's output like on the node, and
+// returns it. The output of an aborted kubeadm init is worth keeping too,
+// so it's read even if the init's context is done.
+func (k *KubeadmBootstrapper) saveInitLog() string {
+	out := k.getInitLog(context.Background())
+	if err := writeRotated(hostInitLogFile(), out); err != nil {
+		glog.Warningf("Error saving kubeadm init output: %s", err)
+	}
+	return out
+}
+
+// writeRotated writes contents to file, keeping any existing contents in
+// file.1.
+func writeRotated(file, contents string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.Wrap(err, "making directory")
+	}
+	if err := os.Rename(file, file+previousInitLogSuffix); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "rotating file")
+	}
+	return ioutil.WriteFile(file, []byte(contents), 0644)
+}
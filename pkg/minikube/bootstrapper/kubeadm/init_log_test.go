@@ -0,0 +1,144 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestWriteRotated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-init-log")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "profile", "kubeadm-init.log")
+
+	for _, attempt := range []string{"first attempt", "second attempt", "third attempt"} {
+		if err := writeRotated(file, attempt); err != nil {
+			t.Fatalf("Error writing %s: %s", attempt, err)
+		}
+	}
+
+	expected := map[string]string{
+		file:                         "third attempt",
+		file + previousInitLogSuffix: "second attempt",
+	}
+	for f, contents := range expected {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatalf("Error reading %s: %s", f, err)
+		}
+		if string(b) != contents {
+			t.Errorf("Expected %s to contain %q, got %q", f, contents, string(b))
+		}
+	}
+}
+
+func TestInitLogInClusterLogs(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		"sudo journalctl  -u kubelet": "kubelet logs\n",
+		readInitLogCmd:                "[init] Using Kubernetes version: v1.8.0\n",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	logs, err := k.GetClusterLogs(bootstrapper.LogOptions{})
+	if err != nil {
+		t.Fatalf("Error getting logs: %s", err)
+	}
+	expected := "kubelet logs\n\n==> kubeadm init <==\n[init] Using Kubernetes version: v1.8.0\n"
+	if logs != expected {
+		t.Errorf("Expected logs %q, got %q", expected, logs)
+	}
+
+	f = bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{"sudo journalctl  -u kubelet": "kubelet logs\n"})
+	k = KubeadmBootstrapper{c: f}
+	logs, err = k.GetClusterLogs(bootstrapper.LogOptions{})
+	if err != nil {
+		t.Fatalf("Error getting logs: %s", err)
+	}
+	if strings.Contains(logs, initLogSource) {
+		t.Errorf("Expected no kubeadm init section without an init log, got %q", logs)
+	}
+}
+
+func TestRunInit(t *testing.T) {
+	initCmd := "/usr/bin/kubeadm init --config /var/lib/kubeadm.yaml"
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		loggedInitCommand(initCmd):   "",
+		streamedInitCommand(initCmd): "[init] Using Kubernetes version: v1.10.0\n",
+	})
+	k := &KubeadmBootstrapper{c: f}
+
+	var out bytes.Buffer
+	if err := k.runInit(context.Background(), initCmd, &out); err != nil {
+		t.Fatalf("Error running streamed kubeadm init: %s", err)
+	}
+	if out.String() != "[init] Using Kubernetes version: v1.10.0\n" {
+		t.Errorf("Expected kubeadm init's output to be streamed, got %q", out.String())
+	}
+	if !strings.Contains(streamedInitCommand(initCmd), "pipefail") {
+		t.Errorf("Expected a failed kubeadm init to fail its streamed command: %s", streamedInitCommand(initCmd))
+	}
+
+	if err := k.runInit(context.Background(), initCmd, nil); err != nil {
+		t.Errorf("Error running kubeadm init: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := k.runInit(ctx, initCmd, &out); err != context.Canceled {
+		t.Errorf("Expected a cancelled kubeadm init not to run, got %v", err)
+	}
+}
+
+func TestAlreadyBootstrapped(t *testing.T) {
+	cases := []struct {
+		out      string
+		expected string
+	}{
+		{
+			out:      "error execution phase upload-config/kubeadm: unable to create ConfigMap: configmaps \"kubeadm-config\" already exists\n",
+			expected: `configmaps "kubeadm-config" already exists`,
+		},
+		{
+			out:      "error creating bootstrap token: secrets \"bootstrap-token-abcdef\" already exists\n",
+			expected: `secrets "bootstrap-token-abcdef" already exists`,
+		},
+		{
+			out: "a kubeconfig file \"/etc/kubernetes/admin.conf\" exists already but has got the wrong CA cert\n",
+		},
+		{
+			out: "[kubelet-check] It seems like the kubelet isn't running or healthy.\n",
+		},
+	}
+	for _, test := range cases {
+		if exists := alreadyBootstrapped(test.out); exists != test.expected {
+			t.Errorf("Expected %q in %q, got %q", test.expected, test.out, exists)
+		}
+	}
+}
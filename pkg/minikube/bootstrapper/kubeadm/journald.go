@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// journaldConfFile is a journald drop-in, which overrides the node's
+// journald.conf.
+const journaldConfFile = "/etc/systemd/journald.conf.d/99-minikube.conf"
+
+// journaldConf returns the journald drop-in capping its logs at maxUse.
+func journaldConf(maxUse string) string {
+	return fmt.Sprintf("# Written by minikube.\n[Journal]\nSystemMaxUse=%s\n", maxUse)
+}
+
+// updateJournald caps the disk space journald's logs use at maxUse, and
+// restarts journald if the cap changed. An empty maxUse removes the cap,
+// which journald keeps until it's restarted, e.g. when the node reboots.
+func (k *KubeadmBootstrapper) updateJournald(maxUse string) error {
+	if maxUse == "" {
+		if err := k.c.Run(bootstrapper.Sudo("rm -f " + journaldConfFile)); err != nil {
+			return errors.Wrapf(err, "removing %s", journaldConfFile)
+		}
+		return nil
+	}
+
+	conf := journaldConf(maxUse)
+	if current, err := k.c.CombinedOutput(bootstrapper.Sudo("cat " + journaldConfFile)); err == nil && current == conf {
+		glog.Infof("%s is up to date", journaldConfFile)
+		return nil
+	}
+	if err := bootstrapper.CopyAtomically(k.c, assets.NewMemoryAssetTarget([]byte(conf), journaldConfFile, "0644")); err != nil {
+		return errors.Wrapf(err, "copying %s", journaldConfFile)
+	}
+	if err := k.c.Run(bootstrapper.Sudo("systemctl restart systemd-journald")); err != nil {
+		return errors.Wrap(err, "restarting journald")
+	}
+	return nil
+}
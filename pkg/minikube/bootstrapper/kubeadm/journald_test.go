@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestUpdateJournald(t *testing.T) {
+	tmp := "/etc/systemd/journald.conf.d/.99-minikube.conf.tmp"
+	cases := []struct {
+		description string
+		maxUse      string
+		current     string
+		commands    map[string]string
+		written     bool
+	}{
+		{
+			description: "changed",
+			maxUse:      "500M",
+			current:     journaldConf("1G"),
+			commands: map[string]string{
+				"sudo mv -f " + tmp + " " + journaldConfFile: "",
+				"sudo systemctl restart systemd-journald":    "",
+			},
+			written: true,
+		},
+		{
+			description: "unchanged",
+			maxUse:      "500M",
+			current:     journaldConf("500M"),
+		},
+		{
+			description: "none",
+			commands:    map[string]string{"sudo rm -f " + journaldConfFile: ""},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+			r.SetCommandToOutput(map[string]string{"sudo cat " + journaldConfFile: test.current})
+			r.SetCommandToOutput(test.commands)
+			k := KubeadmBootstrapper{c: r}
+
+			if err := k.updateJournald(test.maxUse); err != nil {
+				t.Fatalf("Error updating journald: %s", err)
+			}
+			written, ok := r.files[tmp]
+			if ok != test.written {
+				t.Fatalf("Expected journald config to be written: %t, got %t", test.written, ok)
+			}
+			if expected := "# Written by minikube.\n[Journal]\nSystemMaxUse=500M\n"; ok && written != expected {
+				t.Errorf("Expected journald config:\n%s\ngot:\n%s", expected, written)
+			}
+		})
+	}
+}
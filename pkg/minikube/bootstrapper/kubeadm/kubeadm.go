@@ -18,17 +18,19 @@ package kubeadm
 
 import (
 	"bytes"
-	"crypto"
+	"context"
 	"fmt"
 	"html/template"
-	"os"
-	"path/filepath"
+	"io"
+	"runtime"
 	"strings"
 	"time"
 
+	"github.com/blang/semver"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/state"
-	download "github.com/jimmidyson/go-download"
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/minikube/pkg/minikube/assets"
@@ -38,22 +40,47 @@ import (
 	"k8s.io/minikube/pkg/minikube/machine"
 	"k8s.io/minikube/pkg/minikube/sshutil"
 	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
 )
 
 type KubeadmBootstrapper struct {
 	c bootstrapper.CommandRunner
+	d downloader
+	// driver is the name of the machine's driver, e.g. none.
+	driver string
+	// imageProgress, if set, is called as cached images are loaded.
+	imageProgress machine.ImageLoadProgressFunc
 }
 
-// TODO(r2d4): template this with bootstrapper.KubernetesConfig
-const kubeletSystemdConf = `
+// runnerReadyTimeout is how long to wait for the node to accept commands,
+// e.g. for sshd to start on a new VM.
+const runnerReadyTimeout = 2 * time.Minute
+
+// probeTimeout is how long quick checks of the node, e.g. of kubelet's
+// status, may take before they're killed.
+const probeTimeout = 10 * time.Second
+
+// The cluster domain must match the kubeadm config's networking.dnsDomain,
+// or the cluster's DNS breaks. The node IP is set so that kubelet registers
+// with the IP the apiserver advertises, rather than guessing on nodes with
+// several network interfaces. The pause image is always set, as kubelet's
+// default may not be the one kubeadm uses, or the one cached. kubelet's
+// cgroup driver must match the container runtime's. kubelet only rotates
+// the container logs of remote runtimes.
+const kubeletSystemdConfTmpl = `
 [Service]
 Environment="KUBELET_KUBECONFIG_ARGS=--kubeconfig=/etc/kubernetes/kubelet.conf --require-kubeconfig=true"
-Environment="KUBELET_SYSTEM_PODS_ARGS=--pod-manifest-path=/etc/kubernetes/manifests --allow-privileged=true"
-Environment="KUBELET_DNS_ARGS=--cluster-dns=10.0.0.10 --cluster-domain=cluster.local"
+Environment="KUBELET_SYSTEM_PODS_ARGS=--pod-manifest-path=/etc/kubernetes/manifests --allow-privileged=true --pod-infra-container-image={{.PodInfraContainerImage}}"
+Environment="KUBELET_DNS_ARGS=--cluster-dns=10.0.0.10 --cluster-domain={{.DNSDomain}}"
 Environment="KUBELET_CADVISOR_ARGS=--cadvisor-port=0"
-Environment="KUBELET_CGROUP_ARGS=--cgroup-driver=cgroupfs"
-ExecStart=
-ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_SYSTEM_PODS_ARGS $KUBELET_DNS_ARGS $KUBELET_CADVISOR_ARGS $KUBELET_CGROUP_ARGS $KUBELET_EXTRA_ARGS
+Environment="KUBELET_CGROUP_ARGS=--cgroup-driver={{.CgroupDriver}}"
+{{if .RuntimeEndpoint}}Environment="KUBELET_RUNTIME_ARGS=--container-runtime=remote --container-runtime-endpoint={{.RuntimeEndpoint}}"
+{{end}}{{if .NetworkPlugin}}Environment="KUBELET_NETWORK_ARGS=--network-plugin={{.NetworkPlugin}}"
+{{end}}{{if .NodeIP}}Environment="KUBELET_NODE_IP_ARGS=--node-ip={{.NodeIP}}"
+{{end}}{{if .FeatureGates}}Environment="KUBELET_FEATURE_GATES_ARGS=--feature-gates={{.FeatureGates}}"
+{{end}}{{if .ContainerLogMaxSize}}Environment="KUBELET_LOG_ROTATION_ARGS=--container-log-max-size={{.ContainerLogMaxSize}} --container-log-max-files={{.ContainerLogMaxFiles}}"
+{{end}}ExecStart=
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_SYSTEM_PODS_ARGS $KUBELET_DNS_ARGS $KUBELET_NETWORK_ARGS $KUBELET_NODE_IP_ARGS $KUBELET_FEATURE_GATES_ARGS $KUBELET_CADVISOR_ARGS $KUBELET_CGROUP_ARGS $KUBELET_RUNTIME_ARGS $KUBELET_LOG_ROTATION_ARGS $KUBELET_EXTRA_ARGS
 `
 
 const kubeletService = `
@@ -71,6 +98,9 @@ RestartSec=10
 WantedBy=multi-user.target
 `
 
+// The bootstrap token is set here rather than with kubeadm init's --token
+// and --token-ttl flags, which can't be mixed with --config. Dual-stack
+// service and pod subnets are comma separated.
 const kubeadmConfigTmpl = `
 apiVersion: kubeadm.k8s.io/v1alpha1
 kind: MasterConfiguration
@@ -78,13 +108,83 @@ api:
   advertiseAddress: {{.AdvertiseAddress}}
   bindPort: {{.APIServerPort}}
 kubernetesVersion: {{.KubernetesVersion}}
-certificatesDir: {{.CertDir}}
+{{if .ImageRepository}}imageRepository: {{.ImageRepository}}
+{{end}}certificatesDir: {{.CertDir}}
 networking:
   serviceSubnet: {{.ServiceCIDR}}
-etcd:
+  dnsDomain: {{.DNSDomain}}
+{{if .PodCIDR}}  podSubnet: {{.PodCIDR}}
+{{end}}etcd:
   dataDir: {{.EtcdDataDir}}
 nodeName: {{.NodeName}}
-`
+{{if .CRISocket}}criSocket: {{.CRISocket}}
+{{end}}{{if .Token}}token: {{.Token}}
+{{end}}tokenTTL: {{.TokenTTL}}
+{{if .DualStackFeatureGate}}featureGates:
+  IPv6DualStack: true
+{{end}}{{if .ClusterSigningDuration}}controllerManagerExtraArgs:
+  cluster-signing-duration: {{.ClusterSigningDuration}}
+{{end}}{{if .ServiceNodePortRange}}apiServerExtraArgs:
+  service-node-port-range: {{.ServiceNodePortRange}}
+{{end}}`
+
+// kubeadmConfigV1Beta1Tmpl is kubeadmConfigTmpl in the v1beta1 config,
+// which splits the node's settings from the cluster's.
+const kubeadmConfigV1Beta1Tmpl = `
+apiVersion: kubeadm.k8s.io/v1beta1
+kind: InitConfiguration
+localAPIEndpoint:
+  advertiseAddress: {{.AdvertiseAddress}}
+  bindPort: {{.APIServerPort}}
+bootstrapTokens:
+- {{if .Token}}token: {{.Token}}
+  {{end}}ttl: {{.TokenTTL}}
+nodeRegistration:
+  name: {{.NodeName}}
+{{if .CRISocket}}  criSocket: {{.CRISocket}}
+{{end}}---
+apiVersion: kubeadm.k8s.io/v1beta1
+kind: ClusterConfiguration
+kubernetesVersion: {{.KubernetesVersion}}
+{{if .ImageRepository}}imageRepository: {{.ImageRepository}}
+{{end}}certificatesDir: {{.CertDir}}
+networking:
+  serviceSubnet: {{.ServiceCIDR}}
+  dnsDomain: {{.DNSDomain}}
+{{if .PodCIDR}}  podSubnet: {{.PodCIDR}}
+{{end}}etcd:
+  local:
+    dataDir: {{.EtcdDataDir}}
+{{if .DualStackFeatureGate}}featureGates:
+  IPv6DualStack: true
+{{end}}{{if or .ServiceNodePortRange .ControlPlaneTimeout}}apiServer:
+{{end}}{{if .ServiceNodePortRange}}  extraArgs:
+    service-node-port-range: {{.ServiceNodePortRange}}
+{{end}}{{if .ControlPlaneTimeout}}  timeoutForControlPlane: {{.ControlPlaneTimeout}}
+{{end}}{{if .ClusterSigningDuration}}controllerManager:
+  extraArgs:
+    cluster-signing-duration: {{.ClusterSigningDuration}}
+{{end}}`
+
+const (
+	// kubeadmConfigV1Alpha1 and kubeadmConfigV1Beta1 are the versions of
+	// kubeadm's config generateConfig renders.
+	kubeadmConfigV1Alpha1 = "kubeadm.k8s.io/v1alpha1"
+	kubeadmConfigV1Beta1  = "kubeadm.k8s.io/v1beta1"
+)
+
+// SetDownloadProgress sets where status messages about binary downloads are
+// written, and a function that's called as they progress. By default, the
+// start and end of each download are printed to stdout.
+func (k *KubeadmBootstrapper) SetDownloadProgress(out io.Writer, progress DownloadProgressFunc) {
+	k.d = downloader{out: out, progress: progress}
+}
+
+// SetImageLoadProgress sets a function that's called as cached images are
+// loaded into the node.
+func (k *KubeadmBootstrapper) SetImageLoadProgress(progress machine.ImageLoadProgressFunc) {
+	k.imageProgress = progress
+}
 
 func NewKubeadmBootstrapper(api libmachine.API) (*KubeadmBootstrapper, error) {
 	h, err := api.Load(config.GetMachineName())
@@ -103,75 +203,216 @@ func NewKubeadmBootstrapper(api libmachine.API) (*KubeadmBootstrapper, error) {
 		cmd = bootstrapper.NewSSHRunner(client)
 	}
 	return &KubeadmBootstrapper{
-		c: cmd,
+		c:      cmd,
+		driver: h.Driver.DriverName(),
 	}, nil
 }
 
+// NewKubeadmBootstrapperWithRunner returns a bootstrapper which runs its
+// commands on the node with c, e.g. a bootstrapper.KubectlExecRunner, so
+// that GetClusterLogs can be used when the node can't be reached with SSH.
+func NewKubeadmBootstrapperWithRunner(c bootstrapper.CommandRunner) *KubeadmBootstrapper {
+	return &KubeadmBootstrapper{c: c}
+}
+
 //TODO(r2d4): This should most likely check the health of the apiserver
 func (k *KubeadmBootstrapper) GetClusterStatus() (string, error) {
-	statusCmd := `sudo systemctl is-active kubelet &>/dev/null && echo "Running" || echo "Stopped"`
-	status, err := k.c.CombinedOutput(statusCmd)
+	return k.GetClusterStatusContext(context.Background())
+}
+
+// GetClusterStatusContext is GetClusterStatus, but cancelling ctx aborts
+// the status check. The check is killed if it takes longer than
+// probeTimeout, e.g. because sshd or systemd is wedged.
+func (k *KubeadmBootstrapper) GetClusterStatusContext(ctx context.Context) (string, error) {
+	statusCmd := bootstrapper.Sudo(`systemctl is-active kubelet &>/dev/null && echo "Running" || echo "Stopped"`)
+	res, err := bootstrapper.OutputWithTimeout(ctx, k.c, statusCmd, probeTimeout)
 	if err != nil {
 		return "", errors.Wrap(err, "getting status")
 	}
-	status = strings.TrimSpace(status)
+	status := strings.TrimSpace(res.Stdout)
 	if status == state.Running.String() || status == state.Stopped.String() {
 		return status, nil
 	}
 	return "", fmt.Errorf("Error: Unrecognized output from ClusterStatus: %s", status)
 }
 
-// TODO(r2d4): Should this aggregate all the logs from the control plane?
-// Maybe subcommands for each component? minikube logs apiserver?
-func (k *KubeadmBootstrapper) GetClusterLogs(follow bool) (string, error) {
-	var flags []string
-	if follow {
-		flags = append(flags, "-f")
+// GetRunningVersion returns the Kubernetes version the apiserver is running.
+func (k *KubeadmBootstrapper) GetRunningVersion() (string, error) {
+	return bootstrapper.GetRunningVersion(k.c)
+}
+
+// GetClusterLogs returns the kubelet logs selected by opts, followed by an
+// excerpt of each control plane container's logs and the output of the last
+// kubeadm init. If opts.Component is set, only that component's logs are
+// returned.
+//
+// When following, the kubelet logs are streamed to opts.Writer until the
+// command exits or the connection to the node is closed, and the returned
+// string is empty.
+//
+// The control plane containers only exist for the current boot, so only the
+// kubelet logs are returned for the previous boot.
+func (k *KubeadmBootstrapper) GetClusterLogs(opts bootstrapper.LogOptions) (string, error) {
+	return k.GetClusterLogsContext(context.Background(), opts)
+}
+
+// GetClusterLogsContext is GetClusterLogs, but cancelling ctx aborts the
+// commands getting the logs, and stops following them.
+func (k *KubeadmBootstrapper) GetClusterLogsContext(ctx context.Context, opts bootstrapper.LogOptions) (string, error) {
+	if opts.Follow && opts.PreviousBoot {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "Follow", Reason: "the previous boot's logs can't be followed"}
+	}
+	if opts.Component != "" {
+		return k.getComponentLogs(ctx, opts)
+	}
+	since, err := k.getSinceTimestamp(ctx, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "getting logs command")
 	}
-	logsCommand := fmt.Sprintf("sudo journalctl %s -u kubelet", strings.Join(flags, " "))
+	logsCommand := getLogsCommand(opts, since)
 
-	if follow {
-		if err := k.c.Run(logsCommand); err != nil {
-			return "", errors.Wrap(err, "getting shell")
+	if opts.Follow {
+		if opts.Writer == nil {
+			return "", errors.New("following logs requires a writer")
+		}
+		if err := k.c.RunWithOutputContext(ctx, logsCommand, opts.Writer, opts.Writer); err != nil {
+			return "", errors.Wrap(err, "following cluster logs")
 		}
+		return "", nil
 	}
 
-	logs, err := k.c.CombinedOutput(logsCommand)
+	logs, err := k.c.CombinedOutputContext(ctx, logsCommand)
+	if err := checkPreviousBoot(opts, logs, err); err != nil {
+		return "", err
+	}
 	if err != nil {
 		return "", errors.Wrap(err, "getting cluster logs")
 	}
+	if opts.PreviousBoot {
+		return logs, nil
+	}
 
-	return logs, nil
+	b := bytes.NewBufferString(logs)
+	k.writeControlPlaneLogs(ctx, b, opts, since)
+	k.writeInitLog(ctx, b)
+	return b.String(), nil
 }
 
 func (k *KubeadmBootstrapper) StartCluster(k8s bootstrapper.KubernetesConfig) error {
-	// We use --skip-preflight-checks since we have our own custom addons
-	// that we also stick in /etc/kubernetes/manifests
-	kubeadmTmpl := "sudo /usr/bin/kubeadm init --config {{.KubeadmConfigFile}} --skip-preflight-checks"
-	t := template.Must(template.New("kubeadmTmpl").Parse(kubeadmTmpl))
-	b := bytes.Buffer{}
-	if err := t.Execute(&b, struct{ KubeadmConfigFile string }{constants.KubeadmConfigFile}); err != nil {
+	return k.StartClusterContext(context.Background(), k8s)
+}
+
+// StartClusterContext is StartCluster, but cancelling ctx kills kubeadm init.
+func (k *KubeadmBootstrapper) StartClusterContext(ctx context.Context, k8s bootstrapper.KubernetesConfig) error {
+	return k.StartClusterWithOptions(ctx, k8s, StartOptions{})
+}
+
+// StartClusterWithOptions is StartClusterContext, reporting its phases to
+// opts.Progress.
+func (k *KubeadmBootstrapper) StartClusterWithOptions(ctx context.Context, k8s bootstrapper.KubernetesConfig, opts StartOptions) error {
+	if err := bootstrapper.ValidateConfig(k8s); err != nil {
+		return err
+	}
+	if err := bootstrapper.WaitForRunner(k.c, runnerReadyTimeout); err != nil {
+		return err
+	}
+
+	initCmd, err := initCommand(k8s)
+	if err != nil {
+		return err
+	}
+
+	err = opts.phase(PhaseRunningKubeadmInit, func() error {
+		if err := k.c.RunContext(ctx, rotateInitLogCmd); err != nil {
+			glog.Warningf("Error rotating kubeadm init output: %s", err)
+		}
+		err := k.runInit(ctx, initCmd, opts.Output)
+		out := k.saveInitLog()
+		if err != nil && ctx.Err() == nil {
+			// A rerun's kubeadm init can fail on what the first one
+			// created, which the steps after it cope with.
+			if exists := alreadyBootstrapped(out); exists != "" {
+				glog.Infof("kubeadm init found the cluster bootstrapped already (%s), continuing", exists)
+				return nil
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "kubeadm init error running command: %s\noutput: %s", initCmd, out)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
-	err := k.c.Run(b.String())
+	if err := k.applyControlPlaneRequests(k8s); err != nil {
+		return errors.Wrap(err, "applying control plane resource requests")
+	}
+
+	err = opts.phase(PhaseWaitingForControlPlane, func() error { return setUpControlPlane(k.c, k8s.NodeName) })
 	if err != nil {
-		return errors.Wrapf(err, "kubeadm init error running command: %s", b.String())
+		return err
+	}
+
+	if err := k.applyManifests(k8s.Manifests); err != nil {
+		return errors.Wrap(err, "applying manifests")
 	}
 
-	//TODO(r2d4): get rid of global here
-	master = k8s.NodeName
-	if err := util.RetryAfter(100, unmarkMaster, time.Millisecond*500); err != nil {
-		return errors.Wrap(err, "timed out waiting to unmark master")
+	return nil
+}
+
+// runInit runs the kubeadm init command initCmd, keeping its output in the
+// init log, and streaming it to out, if set, as it runs.
+func (k *KubeadmBootstrapper) runInit(ctx context.Context, initCmd string, out io.Writer) error {
+	if out == nil {
+		return k.c.RunContext(ctx, loggedInitCommand(initCmd))
 	}
+	return k.c.RunWithOutputContext(ctx, streamedInitCommand(initCmd), out, out)
+}
 
-	if err := util.RetryAfter(100, elevateKubeSystemPrivileges, time.Millisecond*500); err != nil {
-		return errors.Wrap(err, "timed out waiting to elevate kube-system RBAC privileges")
+// setUpControlPlane unmarks node as the master and elevates kube-system's
+// RBAC privileges with r, retrying until the control plane is up to accept
+// them.
+var setUpControlPlane = func(r bootstrapper.CommandRunner, node string) error {
+	if err := unmarkMaster(r, node); err != nil {
+		return errors.Wrap(err, "unmarking master")
 	}
 
+	if err := elevateKubeSystemPrivileges(r); err != nil {
+		return errors.Wrap(err, "elevating kube-system RBAC privileges")
+	}
 	return nil
 }
 
+// clusterFiles returns the configuration files and addons UpdateCluster
+// copies to the node.
+func (k *KubeadmBootstrapper) clusterFiles(cfg bootstrapper.KubernetesConfig) ([]assets.CopyableFile, error) {
+	kubeadmCfg, err := k.generateConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating kubeadm cfg")
+	}
+
+	kubeletCfg, err := generateKubeletSystemdConf(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating kubelet systemd conf")
+	}
+
+	files := []assets.CopyableFile{
+		assets.NewMemoryAssetTarget([]byte(kubeletService), constants.KubeletServiceFile, "0640"),
+		assets.NewMemoryAssetTarget([]byte(kubeletCfg), constants.KubeletSystemdConfFile, "0640"),
+		assets.NewMemoryAssetTarget([]byte(kubeadmCfg), constants.KubeadmConfigFile, "0640"),
+	}
+
+	// kubeadm installs DNS itself, so the cluster has it without addons.
+	if cfg.SkipAddons {
+		return files, nil
+	}
+	if err := addAddons(&files); err != nil {
+		return nil, errors.Wrap(err, "adding addons to copyable files")
+	}
+	return files, nil
+}
+
 //TODO(r2d4): Split out into shared function between localkube and kubeadm
 func addAddons(files *[]assets.CopyableFile) error {
 	// add addons to file list
@@ -181,7 +422,7 @@ func addAddons(files *[]assets.CopyableFile) error {
 	for addonName, addonBundle := range assets.Addons {
 		// TODO(r2d4): Kubeadm ignores the kube-dns addon and uses its own.
 		// expose this in a better way
-		if addonName == "kube-dns" {
+		if addonName == kubeadmDNSAddon {
 			continue
 		}
 		if isEnabled, err := addonBundle.IsEnabled(); err == nil && isEnabled {
@@ -197,11 +438,17 @@ func addAddons(files *[]assets.CopyableFile) error {
 }
 
 func (k *KubeadmBootstrapper) RestartCluster(k8s bootstrapper.KubernetesConfig) error {
+	if k8s.RestartContainerRuntime {
+		if err := k.restartContainerRuntime(k8s.ContainerRuntime); err != nil {
+			return errors.Wrap(err, "restarting container runtime")
+		}
+	}
+
 	restoreTmpl := `
-	sudo kubeadm alpha phase certs all --config {{.KubeadmConfigFile}} &&
-	sudo /usr/bin/kubeadm alpha phase kubeconfig all --config {{.KubeadmConfigFile}} &&
-	sudo /usr/bin/kubeadm alpha phase controlplane all --config {{.KubeadmConfigFile}} &&
-	sudo /usr/bin/kubeadm alpha phase etcd local --config {{.KubeadmConfigFile}}
+	kubeadm alpha phase certs all --config {{.KubeadmConfigFile}} &&
+	/usr/bin/kubeadm alpha phase kubeconfig all --config {{.KubeadmConfigFile}} &&
+	/usr/bin/kubeadm alpha phase controlplane all --config {{.KubeadmConfigFile}} &&
+	/usr/bin/kubeadm alpha phase etcd local --config {{.KubeadmConfigFile}}
 	`
 	t := template.Must(template.New("restoreTmpl").Parse(restoreTmpl))
 
@@ -216,14 +463,23 @@ func (k *KubeadmBootstrapper) RestartCluster(k8s bootstrapper.KubernetesConfig)
 		return err
 	}
 
-	if err := k.c.Run(b.String()); err != nil {
-		return errors.Wrapf(err, "running cmd: %s", b.String())
+	restoreCmd := bootstrapper.Sudo(b.String())
+	if err := k.c.Run(restoreCmd); err != nil {
+		return errors.Wrapf(err, "running cmd: %s", restoreCmd)
+	}
+
+	if err := k.applyControlPlaneRequests(k8s); err != nil {
+		return errors.Wrap(err, "applying control plane resource requests")
 	}
 
-	if err := restartKubeProxy(k8s); err != nil {
+	if err := k.restartKubeProxy(k8s); err != nil {
 		return errors.Wrap(err, "restarting kube-proxy")
 	}
 
+	if err := k.applyManifests(k8s.Manifests); err != nil {
+		return errors.Wrap(err, "applying manifests")
+	}
+
 	return nil
 }
 
@@ -232,83 +488,205 @@ func (k *KubeadmBootstrapper) SetupCerts(k8s bootstrapper.KubernetesConfig) erro
 }
 
 func (k *KubeadmBootstrapper) UpdateCluster(cfg bootstrapper.KubernetesConfig) error {
-	if cfg.ShouldLoadCachedImages {
-		// Make best effort to load any cached images
-		go machine.LoadImages(k.c, constants.GetKubeadmCachedImages(cfg.KubernetesVersion), constants.ImageCacheDir)
+	return k.UpdateClusterContext(context.Background(), cfg)
+}
+
+// UpdateClusterContext is UpdateCluster, but cancelling ctx aborts any
+// in-flight binary downloads.
+func (k *KubeadmBootstrapper) UpdateClusterContext(ctx context.Context, cfg bootstrapper.KubernetesConfig) error {
+	return k.UpdateClusterWithOptions(ctx, cfg, StartOptions{})
+}
+
+// UpdateClusterWithOptions is UpdateClusterContext, reporting its phases to
+// opts.Progress.
+func (k *KubeadmBootstrapper) UpdateClusterWithOptions(ctx context.Context, cfg bootstrapper.KubernetesConfig, opts StartOptions) error {
+	if err := bootstrapper.ValidateConfig(cfg); err != nil {
+		return err
 	}
-	kubeadmCfg, err := k.generateConfig(cfg)
-	if err != nil {
-		return errors.Wrap(err, "generating kubeadm cfg")
+	if err := bootstrapper.WaitForRunner(k.c, runnerReadyTimeout); err != nil {
+		return err
 	}
 
-	files := []assets.CopyableFile{
-		assets.NewMemoryAssetTarget([]byte(kubeletService), constants.KubeletServiceFile, "0640"),
-		assets.NewMemoryAssetTarget([]byte(kubeletSystemdConf), constants.KubeletSystemdConfFile, "0640"),
-		assets.NewMemoryAssetTarget([]byte(kubeadmCfg), constants.KubeadmConfigFile, "0640"),
+	waitForImages := k.loadCachedImages(cfg)
+	if err := opts.phase(PhaseCopyingConfig, func() error { return k.copyConfig(ctx, cfg) }); err != nil {
+		return err
 	}
 
-	if err := addAddons(&files); err != nil {
-		return errors.Wrap(err, "adding addons to copyable files")
+	arch, err := k.nodeArch()
+	if err != nil {
+		return err
+	}
+	err = opts.phase(PhaseDownloadingBinaries, func() error {
+		binaries := []string{"kubelet", "kubeadm"}
+		if cfg.InstallKubectl {
+			binaries = append(binaries, "kubectl")
+		}
+		g, ctx := errgroup.WithContext(ctx)
+		for _, bin := range binaries {
+			bin := bin
+			g.Go(func() error {
+				return k.installBinary(ctx, bin, arch, cfg)
+			})
+		}
+		if cfg.CacheKubectl {
+			g.Go(func() error {
+				_, err := k.d.maybeDownloadAndCacheForPlatform(ctx, "kubectl", runtime.GOOS, runtime.GOARCH, cfg)
+				return errors.Wrap(err, "downloading kubectl for the host")
+			})
+		}
+		return errors.Wrap(g.Wait(), "downloading binaries")
+	})
+	// The images must be loaded, or have failed to, before kubeadm init
+	// would pull them.
+	if imagesErr := waitForImages(); imagesErr != nil {
+		return imagesErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = k.c.RunContext(ctx, bootstrapper.Sudo(strings.Join([]string{
+		"systemctl daemon-reload",
+		"systemctl enable kubelet",
+		"systemctl start kubelet",
+	}, " && ")))
+	if err != nil {
+		return errors.Wrap(err, "starting kubelet")
 	}
 
+	return nil
+}
+
+// copyConfig copies the cluster's configuration files, addons and manifests
+// to the node, and updates its host aliases, registry credentials, sysctls,
+// journald config and containerd config.
+func (k *KubeadmBootstrapper) copyConfig(ctx context.Context, cfg bootstrapper.KubernetesConfig) error {
+	files, err := k.clusterFiles(cfg)
+	if err != nil {
+		return err
+	}
 	for _, f := range files {
-		if err := k.c.Copy(f); err != nil {
+		if err := bootstrapper.CopyAtomicallyContext(ctx, k.c, f); err != nil {
 			return errors.Wrapf(err, "transferring kubeadm file: %+v", f)
 		}
 	}
-	var g errgroup.Group
-	for _, bin := range []string{"kubelet", "kubeadm"} {
-		bin := bin
-		g.Go(func() error {
-			path, err := maybeDownloadAndCache(bin, cfg.KubernetesVersion)
-			if err != nil {
-				return errors.Wrapf(err, "downloading %s", bin)
-			}
-			f, err := assets.NewFileAsset(path, "/usr/bin", bin, "0641")
-			if err != nil {
-				return errors.Wrap(err, "making new file asset")
-			}
-			if err := k.c.Copy(f); err != nil {
-				return errors.Wrapf(err, "transferring kubeadm file: %+v", f)
-			}
-			return nil
-		})
+	if err := k.copyManifests(cfg.Manifests); err != nil {
+		return errors.Wrap(err, "copying manifests")
 	}
-	if err := g.Wait(); err != nil {
-		return errors.Wrap(err, "downloading binaries")
+	if err := k.copyStaticPods(cfg.StaticPodManifests); err != nil {
+		return errors.Wrap(err, "copying static pod manifests")
 	}
+	if err := k.updateHosts(cfg.HostAliases); err != nil {
+		return errors.Wrap(err, "updating host aliases")
+	}
+	if err := k.updateRegistryCredentials(cfg.RegistryCredentials); err != nil {
+		return errors.Wrap(err, "updating registry credentials")
+	}
+	if err := k.updateSysctls(cfg.Sysctls); err != nil {
+		return errors.Wrap(err, "updating sysctls")
+	}
+	if err := k.updateJournald(cfg.JournaldMaxUse); err != nil {
+		return errors.Wrap(err, "updating journald config")
+	}
+	if err := k.updateContainerdConfig(cfg); err != nil {
+		return errors.Wrap(err, "updating containerd config")
+	}
+	return nil
+}
 
-	err = k.c.Run(`
-sudo systemctl daemon-reload &&
-sudo systemctl enable kubelet &&
-sudo systemctl start kubelet
-`)
+// installBinary installs k8s's version of a Kubernetes binary for arch on
+// the node, either by downloading it there, or by downloading it on the
+// host, if it isn't cached already, and copying it over. Downloading on the
+// node falls back to the host unless k8s.BinaryDownload requires the node.
+// A binary in k8s.BinaryOverrides is copied over as it is, unverified.
+func (k *KubeadmBootstrapper) installBinary(ctx context.Context, bin, arch string, k8s bootstrapper.KubernetesConfig) error {
+	if override, ok := k8s.BinaryOverrides[bin]; ok {
+		glog.Infof("Using custom %s from %s", bin, override)
+		return k.copyBinary(ctx, override, bin)
+	}
+	if k.shouldDownloadOnNode(bin, arch, k8s) {
+		err := k.downloadOnNode(bin, arch, k8s)
+		if err == nil {
+			return nil
+		}
+		if k8s.BinaryDownload == bootstrapper.BinaryDownloadNode {
+			return err
+		}
+		glog.Infof("Falling back to downloading %s on the host: %s", bin, err)
+	}
+
+	path, err := k.d.maybeDownloadAndCache(ctx, bin, arch, k8s)
 	if err != nil {
-		return errors.Wrap(err, "starting kubelet")
+		return errors.Wrapf(err, "downloading %s", bin)
 	}
+	return k.copyBinary(ctx, path, bin)
+}
 
+// copyBinary copies the binary at path on the host to the node as bin.
+func (k *KubeadmBootstrapper) copyBinary(ctx context.Context, path, bin string) error {
+	f, err := assets.NewFileAsset(path, binaryDir, bin, binaryMode)
+	if err != nil {
+		return errors.Wrap(err, "making new file asset")
+	}
+	if err := k.c.CopyContext(ctx, f); err != nil {
+		return errors.Wrapf(err, "transferring kubeadm file: %+v", f)
+	}
 	return nil
 }
 
 func (k *KubeadmBootstrapper) generateConfig(k8s bootstrapper.KubernetesConfig) (string, error) {
-	t := template.Must(template.New("kubeadmConfigTmpl").Parse(kubeadmConfigTmpl))
+	if err := k8s.ValidateCertDir(); err != nil {
+		return "", err
+	}
+
+	tmpl := kubeadmConfigTmpl
+	if kubeadmConfigAPIVersion(k8s.KubernetesVersion) == kubeadmConfigV1Beta1 {
+		tmpl = kubeadmConfigV1Beta1Tmpl
+	}
+	t := template.Must(template.New("kubeadmConfigTmpl").Parse(tmpl))
 
 	opts := struct {
-		CertDir           string
-		ServiceCIDR       string
-		AdvertiseAddress  string
-		APIServerPort     int
-		KubernetesVersion string
-		EtcdDataDir       string
-		NodeName          string
+		CertDir                string
+		ServiceCIDR            string
+		AdvertiseAddress       string
+		APIServerPort          int
+		KubernetesVersion      string
+		EtcdDataDir            string
+		NodeName               string
+		DNSDomain              string
+		PodCIDR                string
+		Token                  string
+		TokenTTL               time.Duration
+		DualStackFeatureGate   bool
+		ControlPlaneTimeout    time.Duration
+		ImageRepository        string
+		CRISocket              string
+		ClusterSigningDuration time.Duration
+		ServiceNodePortRange   string
 	}{
-		CertDir:           util.DefaultCertPath,
-		ServiceCIDR:       util.DefaultInsecureRegistry,
-		AdvertiseAddress:  k8s.NodeIP,
-		APIServerPort:     util.APIServerPort,
-		KubernetesVersion: k8s.KubernetesVersion,
-		EtcdDataDir:       "/data", //TODO(r2d4): change to something else persisted
-		NodeName:          k8s.NodeName,
+		CertDir:                k8s.GetCertDir(),
+		ServiceCIDR:            k8s.GetServiceCIDR(),
+		AdvertiseAddress:       k8s.NodeIP,
+		APIServerPort:          util.APIServerPort,
+		KubernetesVersion:      k8s.KubernetesVersion,
+		EtcdDataDir:            "/data", //TODO(r2d4): change to something else persisted
+		NodeName:               k8s.NodeName,
+		DNSDomain:              k8s.GetDNSDomain(),
+		PodCIDR:                k8s.PodCIDR,
+		Token:                  k8s.BootstrapToken,
+		TokenTTL:               k8s.GetBootstrapTokenTTL(),
+		DualStackFeatureGate:   k8s.IsDualStack() && needsDualStackFeatureGate(k8s.KubernetesVersion),
+		ClusterSigningDuration: k8s.ClusterSigningDuration,
+		ServiceNodePortRange:   k8s.ServiceNodePortRange,
+	}
+	if k8s.ImageRepository != "" {
+		opts.ImageRepository = constants.GetImageRepository(k8s.ImageRepository, k8s.KubernetesVersion)
+	}
+	if supportsControlPlaneTimeout(k8s.KubernetesVersion) {
+		opts.ControlPlaneTimeout = k8s.ControlPlaneTimeout
+	}
+	if supportsCRISocket(k8s.KubernetesVersion) {
+		opts.CRISocket = criSocket(k8s.ContainerRuntime, k.driver)
 	}
 
 	b := bytes.Buffer{}
@@ -316,39 +694,149 @@ func (k *KubeadmBootstrapper) generateConfig(k8s bootstrapper.KubernetesConfig)
 		return "", err
 	}
 
+	if len(k8s.KubeletConfiguration) > 0 && supportsKubeletConfiguration(k8s.KubernetesVersion) {
+		doc, err := kubeletConfigurationDocument(k8s.KubeletConfiguration)
+		if err != nil {
+			return "", errors.Wrap(err, "generating kubelet configuration")
+		}
+		b.WriteString("---\n")
+		b.WriteString(doc)
+	}
+
 	return b.String(), nil
 }
 
-func maybeDownloadAndCache(binary, version string) (string, error) {
-	targetDir := constants.MakeMiniPath("cache", version)
-	targetFilepath := filepath.Join(targetDir, binary)
+// kubeletConfigurationDocument returns cfg as a YAML KubeletConfiguration
+// document, for kubeadm's config.
+func kubeletConfigurationDocument(cfg map[string]interface{}) (string, error) {
+	doc := map[string]interface{}{}
+	for k, v := range cfg {
+		doc[k] = v
+	}
+	doc["apiVersion"] = bootstrapper.KubeletConfigurationAPIVersion
+	doc["kind"] = bootstrapper.KubeletConfigurationKind
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
 
-	_, err := os.Stat(targetFilepath)
-	// If it exists, do no verification and continue
-	if err == nil {
-		return targetFilepath, nil
+// needsDualStackFeatureGate returns whether dual-stack must be enabled with
+// the IPv6DualStack feature gate, which was alpha, and off by default,
+// before Kubernetes v1.21.
+func needsDualStackFeatureGate(kubernetesVersion string) bool {
+	v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix))
+	if err != nil {
+		return false
 	}
-	if !os.IsNotExist(err) {
-		return "", errors.Wrapf(err, "stat %s version %s at %s", binary, version, targetDir)
+	return v.LT(semver.MustParse("1.21.0"))
+}
+
+// kubeadmConfigAPIVersion returns the version of kubeadm's config
+// generateConfig renders for kubernetesVersion: v1beta1 from Kubernetes
+// v1.13, and v1alpha1 before.
+func kubeadmConfigAPIVersion(kubernetesVersion string) string {
+	v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix))
+	if err != nil {
+		return kubeadmConfigV1Alpha1
+	}
+	if v.GTE(semver.MustParse("1.13.0")) {
+		return kubeadmConfigV1Beta1
+	}
+	return kubeadmConfigV1Alpha1
+}
+
+// supportsControlPlaneTimeout returns whether the kubeadm config rendered
+// for kubernetesVersion can set how long kubeadm init waits for the control
+// plane, which v1beta1 can.
+func supportsControlPlaneTimeout(kubernetesVersion string) bool {
+	return kubeadmConfigAPIVersion(kubernetesVersion) == kubeadmConfigV1Beta1
+}
+
+// supportsKubeletConfiguration returns whether the kubeadm config rendered
+// for kubernetesVersion can be followed by a KubeletConfiguration document,
+// which v1beta1 can.
+func supportsKubeletConfiguration(kubernetesVersion string) bool {
+	return kubeadmConfigAPIVersion(kubernetesVersion) == kubeadmConfigV1Beta1
+}
+
+// supportsCRISocket returns whether kubeadm's config can set the CRI socket
+// the node is registered with, which it can from Kubernetes v1.9.
+func supportsCRISocket(kubernetesVersion string) bool {
+	v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix))
+	if err != nil {
+		return false
 	}
+	return v.GTE(semver.MustParse("1.9.0"))
+}
 
-	if err = os.MkdirAll(targetDir, 0777); err != nil {
-		return "", errors.Wrapf(err, "mkdir %s", targetDir)
+// supportsContainerLogRotation returns whether kubelet rotates container
+// logs without an alpha feature gate, which it does from Kubernetes v1.11.
+func supportsContainerLogRotation(kubernetesVersion string) bool {
+	v, err := semver.Make(strings.TrimPrefix(kubernetesVersion, version.VersionPrefix))
+	if err != nil {
+		return false
 	}
+	return v.GTE(semver.MustParse("1.11.0"))
+}
 
-	url := constants.GetKubernetesReleaseURL(binary, version)
-	options := download.FileOptions{
-		Mkdirs: download.MkdirAll,
+// initCommand returns the command that runs kubeadm init, which needs root
+// privileges, as loggedInitCommand and streamedInitCommand grant. When k8s
+// sets a control plane timeout that kubeadm's config can't, kubeadm init is
+// bounded by it instead.
+func initCommand(k8s bootstrapper.KubernetesConfig) (string, error) {
+	// We use --skip-preflight-checks since we have our own custom addons,
+	// and the user's static pods, that we also stick in
+	// /etc/kubernetes/manifests
+	kubeadmTmpl := "{{if .Timeout}}timeout {{.Timeout}}s {{end}}/usr/bin/kubeadm init --config {{.KubeadmConfigFile}} --skip-preflight-checks"
+	t := template.Must(template.New("kubeadmTmpl").Parse(kubeadmTmpl))
+	opts := struct {
+		KubeadmConfigFile string
+		Timeout           int
+	}{KubeadmConfigFile: constants.KubeadmConfigFile}
+	if !supportsControlPlaneTimeout(k8s.KubernetesVersion) {
+		opts.Timeout = int(k8s.ControlPlaneTimeout.Seconds())
+	}
+	b := bytes.Buffer{}
+	if err := t.Execute(&b, opts); err != nil {
+		return "", err
 	}
+	return b.String(), nil
+}
 
-	options.Checksum = constants.GetKubernetesReleaseURLSha1(binary, version)
-	options.ChecksumHash = crypto.SHA1
+func generateKubeletSystemdConf(k8s bootstrapper.KubernetesConfig) (string, error) {
+	t := template.Must(template.New("kubeletSystemdConfTmpl").Parse(kubeletSystemdConfTmpl))
 
-	fmt.Printf("Downloading %s %s\n", binary, version)
-	if err := download.ToFile(url, targetFilepath, options); err != nil {
-		return "", errors.Wrapf(err, "Error downloading %s %s", binary, version)
+	opts := struct {
+		DNSDomain              string
+		NetworkPlugin          string
+		NodeIP                 string
+		FeatureGates           string
+		PodInfraContainerImage string
+		CgroupDriver           string
+		RuntimeEndpoint        string
+		ContainerLogMaxSize    string
+		ContainerLogMaxFiles   int
+	}{
+		DNSDomain:              k8s.GetDNSDomain(),
+		NetworkPlugin:          k8s.NetworkPlugin,
+		NodeIP:                 k8s.NodeIP,
+		FeatureGates:           k8s.KubeletFeatureGatesFlag(),
+		CgroupDriver:           k8s.GetCgroupDriver(),
+		PodInfraContainerImage: k8s.GetPauseImage(),
+	}
+	if k8s.ContainerRuntime == bootstrapper.ContainerRuntimeContainerd {
+		opts.RuntimeEndpoint = "unix://" + containerdSocket
+	}
+	if opts.RuntimeEndpoint != "" && supportsContainerLogRotation(k8s.KubernetesVersion) {
+		opts.ContainerLogMaxSize, opts.ContainerLogMaxFiles = k8s.GetContainerLogRotation()
 	}
-	fmt.Printf("Finished Downloading %s %s\n", binary, version)
 
-	return targetFilepath, nil
+	b := bytes.Buffer{}
+	if err := t.Execute(&b, opts); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
 }
@@ -0,0 +1,780 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+)
+
+func TestGenerateConfigCertDir(t *testing.T) {
+	cases := []struct {
+		description string
+		certDir     string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "default",
+			expected:    "certificatesDir: " + util.DefaultCertPath + "\n",
+		},
+		{
+			description: "custom",
+			certDir:     "/etc/kubernetes/pki",
+			expected:    "certificatesDir: /etc/kubernetes/pki\n",
+		},
+		{
+			description: "relative",
+			certDir:     "certs",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{CertDir: test.certDir})
+			if test.shouldErr {
+				if err == nil {
+					t.Error("Expected error generating config, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestDNSDomainInSync(t *testing.T) {
+	cases := []struct {
+		description string
+		dnsDomain   string
+		expected    string
+	}{
+		{
+			description: "default",
+			expected:    constants.ClusterDNSDomain,
+		},
+		{
+			description: "custom",
+			dnsDomain:   "minikube.local",
+			expected:    "minikube.local",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k8s := bootstrapper.KubernetesConfig{DNSDomain: test.dnsDomain}
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			kubeadmCfg, err := k.generateConfig(k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubeadm config: %s", err)
+			}
+			kubeletCfg, err := generateKubeletSystemdConf(k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+
+			if expected := "dnsDomain: " + test.expected + "\n"; !strings.Contains(kubeadmCfg, expected) {
+				t.Errorf("Expected kubeadm config to contain %q, got:\n%s", expected, kubeadmCfg)
+			}
+			if expected := "--cluster-domain=" + test.expected + "\""; !strings.Contains(kubeletCfg, expected) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", expected, kubeletCfg)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeletSystemdConfNodeIP(t *testing.T) {
+	cases := []struct {
+		description string
+		nodeIP      string
+		expected    string
+	}{
+		{
+			description: "auto",
+		},
+		{
+			description: "node IP",
+			nodeIP:      "192.168.99.100",
+			expected:    "Environment=\"KUBELET_NODE_IP_ARGS=--node-ip=192.168.99.100\"\n",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			cfg, err := generateKubeletSystemdConf(bootstrapper.KubernetesConfig{NodeIP: test.nodeIP})
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "--node-ip") {
+				t.Errorf("Expected kubelet systemd conf not to set the node IP, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", test.expected, cfg)
+			}
+			if !strings.Contains(cfg, "$KUBELET_NODE_IP_ARGS") {
+				t.Errorf("Expected kubelet to be started with $KUBELET_NODE_IP_ARGS, got:\n%s", cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeletSystemdConfFeatureGates(t *testing.T) {
+	cases := []struct {
+		description string
+		gates       map[string]string
+		expected    string
+	}{
+		{
+			description: "none",
+		},
+		{
+			description: "sorted",
+			gates:       map[string]string{"PodPriority": "true", "DevicePlugins": "false", "LocalStorageCapacityIsolation": "true"},
+			expected:    "Environment=\"KUBELET_FEATURE_GATES_ARGS=--feature-gates=DevicePlugins=false,LocalStorageCapacityIsolation=true,PodPriority=true\"\n",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k8s := bootstrapper.KubernetesConfig{
+				FeatureGates:        "APIServerOnly=true",
+				KubeletFeatureGates: test.gates,
+			}
+			cfg, err := generateKubeletSystemdConf(k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+			if strings.Contains(cfg, "APIServerOnly") {
+				t.Errorf("Expected kubelet systemd conf not to use the cluster's feature gates, got:\n%s", cfg)
+			}
+			if test.expected == "" && strings.Contains(cfg, "--feature-gates") {
+				t.Errorf("Expected kubelet systemd conf not to set feature gates, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", test.expected, cfg)
+			}
+			if !strings.Contains(cfg, "$KUBELET_FEATURE_GATES_ARGS") {
+				t.Errorf("Expected kubelet to be started with $KUBELET_FEATURE_GATES_ARGS, got:\n%s", cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeletSystemdConfLogRotation(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    string
+	}{
+		{
+			description: "default",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.11.0", ContainerRuntime: bootstrapper.ContainerRuntimeContainerd},
+			expected:    "Environment=\"KUBELET_LOG_ROTATION_ARGS=--container-log-max-size=10Mi --container-log-max-files=5\"\n",
+		},
+		{
+			description: "custom",
+			k8s: bootstrapper.KubernetesConfig{
+				KubernetesVersion:    "v1.11.0",
+				ContainerRuntime:     bootstrapper.ContainerRuntimeContainerd,
+				ContainerLogMaxSize:  "50Mi",
+				ContainerLogMaxFiles: 3,
+			},
+			expected: "Environment=\"KUBELET_LOG_ROTATION_ARGS=--container-log-max-size=50Mi --container-log-max-files=3\"\n",
+		},
+		{
+			description: "docker",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.11.0", ContainerLogMaxSize: "50Mi"},
+		},
+		{
+			description: "alpha",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ContainerRuntime: bootstrapper.ContainerRuntimeContainerd},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			cfg, err := generateKubeletSystemdConf(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "--container-log-max") {
+				t.Errorf("Expected kubelet systemd conf not to rotate container logs, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", test.expected, cfg)
+			}
+			if !strings.Contains(cfg, "$KUBELET_LOG_ROTATION_ARGS") {
+				t.Errorf("Expected kubelet to be started with $KUBELET_LOG_ROTATION_ARGS, got:\n%s", cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigImageRepository(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		config      string
+		kubelet     string
+	}{
+		{
+			description: "default",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0"},
+			kubelet:     " --pod-infra-container-image=k8s.gcr.io/pause-amd64:3.1\"",
+		},
+		{
+			description: "custom",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ImageRepository: "registry.example.com/k8s/"},
+			config:      "imageRepository: registry.example.com/k8s\n",
+			kubelet:     " --pod-infra-container-image=registry.example.com/k8s/pause-amd64:3.1\"",
+		},
+		{
+			description: "custom without architecture",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.12.0", ImageRepository: "registry.example.com:5000"},
+			config:      "imageRepository: registry.example.com:5000\n",
+			kubelet:     " --pod-infra-container-image=registry.example.com:5000/pause:3.1\"",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			kubelet, err := generateKubeletSystemdConf(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+			if test.config == "" {
+				if strings.Contains(cfg, "imageRepository") {
+					t.Errorf("Expected config not to set the image repository, got:\n%s", cfg)
+				}
+			} else if !strings.Contains(cfg, test.config) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.config, cfg)
+			}
+			if !strings.Contains(kubelet, test.kubelet) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", test.kubelet, kubelet)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeletSystemdConfPauseImage(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    string
+	}{
+		{
+			description: "v1.8",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0"},
+			expected:    "gcr.io/google_containers/pause-amd64:3.0",
+		},
+		{
+			description: "v1.11",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.11.3"},
+			expected:    "k8s.gcr.io/pause-amd64:3.1",
+		},
+		{
+			description: "v1.12",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.12.0"},
+			expected:    "k8s.gcr.io/pause:3.1",
+		},
+		{
+			description: "v1.22",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.22.1"},
+			expected:    "k8s.gcr.io/pause:3.5",
+		},
+		{
+			description: "custom",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.12.0", ImageRepository: "registry.example.com/k8s", PauseImage: "registry.example.com/pause:3.2"},
+			expected:    "registry.example.com/pause:3.2",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			kubelet, err := generateKubeletSystemdConf(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating kubelet systemd conf: %s", err)
+			}
+			if arg := " --pod-infra-container-image=" + test.expected + "\""; !strings.Contains(kubelet, arg) {
+				t.Errorf("Expected kubelet systemd conf to contain %q, got:\n%s", arg, kubelet)
+			}
+			images := test.k8s.GetKubeadmCachedImages()
+			found := false
+			for _, image := range images {
+				found = found || image == test.expected
+			}
+			if !found {
+				t.Errorf("Expected cached images to include %s, got %v", test.expected, images)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigAPIVersion(t *testing.T) {
+	cases := []struct {
+		version    string
+		apiVersion string
+		kinds      []string
+	}{
+		{
+			version:    "v1.12.0",
+			apiVersion: "kubeadm.k8s.io/v1alpha1",
+			kinds:      []string{"MasterConfiguration"},
+		},
+		{
+			version:    "v1.13.0",
+			apiVersion: "kubeadm.k8s.io/v1beta1",
+			kinds:      []string{"InitConfiguration", "ClusterConfiguration"},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.version, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), driver: "virtualbox"}
+			cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{
+				KubernetesVersion:      test.version,
+				ContainerRuntime:       "containerd",
+				ImageRepository:        "registry.example.com",
+				PodCIDR:                "10.244.0.0/16",
+				BootstrapToken:         "abcdef.0123456789abcdef",
+				ControlPlaneTimeout:    10 * time.Minute,
+				ClusterSigningDuration: 48 * time.Hour,
+				ServiceNodePortRange:   "30000-30100",
+			})
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			docs := strings.Split(cfg, "\n---\n")
+			if len(docs) != len(test.kinds) {
+				t.Fatalf("Expected %d documents, got:\n%s", len(test.kinds), cfg)
+			}
+			for i, d := range docs {
+				var doc map[string]interface{}
+				if err := yaml.Unmarshal([]byte(d), &doc); err != nil {
+					t.Fatalf("Error parsing the config: %s\n%s", err, d)
+				}
+				if doc["apiVersion"] != test.apiVersion || doc["kind"] != test.kinds[i] {
+					t.Errorf("Expected a %s %s, got:\n%s", test.apiVersion, test.kinds[i], d)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateConfigControlPlaneTimeout(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    string
+		initCmd     string
+	}{
+		{
+			description: "default",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.13.0"},
+			initCmd:     "/usr/bin/kubeadm init --config " + constants.KubeadmConfigFile + " --skip-preflight-checks",
+		},
+		{
+			description: "supported",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.13.0", ControlPlaneTimeout: 10 * time.Minute},
+			expected:    "apiServer:\n  timeoutForControlPlane: 10m0s\n",
+			initCmd:     "/usr/bin/kubeadm init --config " + constants.KubeadmConfigFile + " --skip-preflight-checks",
+		},
+		{
+			description: "unsupported bounds kubeadm init",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ControlPlaneTimeout: 10 * time.Minute},
+			initCmd:     "timeout 600s /usr/bin/kubeadm init --config " + constants.KubeadmConfigFile + " --skip-preflight-checks",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "timeoutForControlPlane") {
+				t.Errorf("Expected config not to set the control plane timeout, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.expected, cfg)
+			}
+
+			initCmd, err := initCommand(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating init command: %s", err)
+			}
+			if initCmd != test.initCmd {
+				t.Errorf("Expected init command %q, got %q", test.initCmd, initCmd)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigClusterSigningDuration(t *testing.T) {
+	cases := []struct {
+		description string
+		duration    time.Duration
+		expected    string
+	}{
+		{description: "default"},
+		{description: "a year", duration: 365 * 24 * time.Hour, expected: "controllerManagerExtraArgs:\n  cluster-signing-duration: 8760h0m0s\n"},
+		{description: "an hour and a half", duration: 90 * time.Minute, expected: "controllerManagerExtraArgs:\n  cluster-signing-duration: 1h30m0s\n"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ClusterSigningDuration: test.duration})
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "controllerManagerExtraArgs") {
+				t.Errorf("Expected config not to set the controller manager's args, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigServiceNodePortRange(t *testing.T) {
+	cases := []struct {
+		description string
+		portRange   string
+		expected    string
+	}{
+		{description: "default"},
+		{description: "wider", portRange: "20000-40000", expected: "apiServerExtraArgs:\n  service-node-port-range: 20000-40000\n"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0", ServiceNodePortRange: test.portRange})
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "apiServerExtraArgs") {
+				t.Errorf("Expected config not to set the apiserver's args, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigKubeletConfiguration(t *testing.T) {
+	kubeletCfg := map[string]interface{}{
+		"kind":          "KubeletConfiguration",
+		"maxPods":       50,
+		"evictionHard":  map[string]interface{}{"memory.available": "100Mi"},
+		"clusterDomain": "cluster.local",
+	}
+	k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+	cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.13.0", KubeletConfiguration: kubeletCfg})
+	if err != nil {
+		t.Fatalf("Error generating config: %s", err)
+	}
+
+	docs := strings.Split(cfg, "\n---\n")
+	if len(docs) != 3 {
+		t.Fatalf("Expected the kubeadm config and a kubelet configuration, got:\n%s", cfg)
+	}
+	var kubeadmDoc, kubeletDoc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(docs[1]), &kubeadmDoc); err != nil {
+		t.Fatalf("Error parsing the kubeadm config: %s\n%s", err, docs[1])
+	}
+	if err := yaml.Unmarshal([]byte(docs[2]), &kubeletDoc); err != nil {
+		t.Fatalf("Error parsing the kubelet configuration: %s\n%s", err, docs[2])
+	}
+	if kubeadmDoc["kind"] != "ClusterConfiguration" {
+		t.Errorf("Expected the kubeadm config first, got:\n%s", docs[1])
+	}
+	expected := map[string]interface{}{
+		"apiVersion":    "kubelet.config.k8s.io/v1beta1",
+		"kind":          "KubeletConfiguration",
+		"maxPods":       float64(50),
+		"evictionHard":  map[string]interface{}{"memory.available": "100Mi"},
+		"clusterDomain": "cluster.local",
+	}
+	if !reflect.DeepEqual(kubeletDoc, expected) {
+		t.Errorf("Expected kubelet configuration %v, got %v", expected, kubeletDoc)
+	}
+	if _, ok := kubeletCfg["apiVersion"]; ok {
+		t.Error("Expected the kubelet configuration in the cluster config to be left as it was")
+	}
+
+	cfg, err = k.generateConfig(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.12.0", KubeletConfiguration: kubeletCfg})
+	if err != nil {
+		t.Fatalf("Error generating config: %s", err)
+	}
+	if strings.Contains(cfg, "KubeletConfiguration") {
+		t.Errorf("Expected no kubelet configuration in the v1alpha1 config, got:\n%s", cfg)
+	}
+}
+
+func TestGenerateConfigCRISocket(t *testing.T) {
+	cases := []struct {
+		description string
+		runtime     string
+		driver      string
+		version     string
+		expected    string
+	}{
+		{
+			description: "default",
+			expected:    "criSocket: /var/run/dockershim.sock\n",
+		},
+		{
+			description: "docker",
+			runtime:     "docker",
+			expected:    "criSocket: /var/run/dockershim.sock\n",
+		},
+		{
+			description: "containerd",
+			runtime:     "containerd",
+			expected:    "criSocket: /run/containerd/containerd.sock\n",
+		},
+		{
+			description: "cri-o in the ISO",
+			runtime:     "cri-o",
+			driver:      "virtualbox",
+			expected:    "criSocket: /var/run/crio.sock\n",
+		},
+		{
+			description: "cri-o on the host",
+			runtime:     "crio",
+			driver:      constants.DriverNone,
+			expected:    "criSocket: /var/run/crio/crio.sock\n",
+		},
+		{
+			description: "unsupported",
+			runtime:     "containerd",
+			version:     "v1.8.0",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			version := test.version
+			if version == "" {
+				version = "v1.10.0"
+			}
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner(), driver: test.driver}
+			cfg, err := k.generateConfig(bootstrapper.KubernetesConfig{KubernetesVersion: version, ContainerRuntime: test.runtime})
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			if test.expected == "" && strings.Contains(cfg, "criSocket") {
+				t.Errorf("Expected config not to set the CRI socket, got:\n%s", cfg)
+			}
+			if !strings.Contains(cfg, test.expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigBootstrapToken(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    []string
+		unexpected  string
+	}{
+		{
+			description: "default",
+			expected:    []string{"tokenTTL: 24h0m0s\n"},
+			unexpected:  "token: ",
+		},
+		{
+			description: "fixed token and TTL",
+			k8s: bootstrapper.KubernetesConfig{
+				BootstrapToken:    "abcdef.0123456789abcdef",
+				BootstrapTokenTTL: 30 * time.Minute,
+			},
+			expected: []string{"token: abcdef.0123456789abcdef\n", "tokenTTL: 30m0s\n"},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			for _, e := range test.expected {
+				if !strings.Contains(cfg, e) {
+					t.Errorf("Expected config to contain %q, got:\n%s", e, cfg)
+				}
+			}
+			if test.unexpected != "" && strings.Contains(cfg, test.unexpected) {
+				t.Errorf("Expected config not to contain %q, got:\n%s", test.unexpected, cfg)
+			}
+		})
+	}
+}
+
+func TestGenerateConfigDualStack(t *testing.T) {
+	cases := []struct {
+		description string
+		k8s         bootstrapper.KubernetesConfig
+		expected    []string
+		unexpected  string
+	}{
+		{
+			description: "single stack",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0"},
+			expected:    []string{"  serviceSubnet: 10.0.0.0/24\n"},
+			unexpected:  "featureGates",
+		},
+		{
+			description: "single stack with pod CIDR",
+			k8s:         bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", PodCIDR: "10.244.0.0/16"},
+			expected:    []string{"  serviceSubnet: 10.0.0.0/24\n", "  podSubnet: 10.244.0.0/16\n"},
+			unexpected:  "featureGates",
+		},
+		{
+			description: "dual-stack with feature gate",
+			k8s: bootstrapper.KubernetesConfig{
+				KubernetesVersion: "v1.16.0",
+				ServiceCIDR:       "10.0.0.0/24,fd00::/112",
+				PodCIDR:           "10.244.0.0/16,fd00:10:244::/56",
+			},
+			expected: []string{
+				"  serviceSubnet: 10.0.0.0/24,fd00::/112\n",
+				"  podSubnet: 10.244.0.0/16,fd00:10:244::/56\n",
+				"featureGates:\n  IPv6DualStack: true\n",
+			},
+		},
+		{
+			description: "dual-stack without feature gate",
+			k8s: bootstrapper.KubernetesConfig{
+				KubernetesVersion: "v1.21.0",
+				ServiceCIDR:       "10.0.0.0/24,fd00::/112",
+				PodCIDR:           "10.244.0.0/16,fd00:10:244::/56",
+			},
+			expected:   []string{"  serviceSubnet: 10.0.0.0/24,fd00::/112\n"},
+			unexpected: "featureGates",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			cfg, err := k.generateConfig(test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating config: %s", err)
+			}
+			for _, e := range test.expected {
+				if !strings.Contains(cfg, e) {
+					t.Errorf("Expected config to contain %q, got:\n%s", e, cfg)
+				}
+			}
+			if test.unexpected != "" && strings.Contains(cfg, test.unexpected) {
+				t.Errorf("Expected config not to contain %q, got:\n%s", test.unexpected, cfg)
+			}
+		})
+	}
+}
+
+func TestClusterFilesSkipAddons(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	addonsDir := constants.MakeMiniPath("addons")
+	if err := os.MkdirAll(addonsDir, 0777); err != nil {
+		t.Fatalf("Error making addons dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(addonsDir, "custom.yaml"), []byte("custom addon"), 0644); err != nil {
+		t.Fatalf("Error writing custom addon: %s", err)
+	}
+
+	cases := []struct {
+		description string
+		skipAddons  bool
+	}{
+		{description: "addons"},
+		{description: "skip addons", skipAddons: true},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			files, err := k.clusterFiles(bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", SkipAddons: test.skipAddons})
+			if err != nil {
+				t.Fatalf("Error getting cluster files: %s", err)
+			}
+
+			configs := map[string]bool{
+				constants.KubeletServiceFile:     true,
+				constants.KubeletSystemdConfFile: true,
+				constants.KubeadmConfigFile:      true,
+			}
+			var addons []string
+			for _, f := range files {
+				target := path.Join(f.GetTargetDir(), f.GetTargetName())
+				if configs[target] {
+					delete(configs, target)
+				} else {
+					addons = append(addons, target)
+				}
+			}
+			if test.skipAddons && len(addons) != 0 {
+				t.Errorf("Expected no addons to be copied, got %v", addons)
+			}
+			if !test.skipAddons && len(addons) == 0 {
+				t.Error("Expected the custom addon to be copied, got none")
+			}
+			if len(configs) != 0 {
+				t.Errorf("Expected the kubelet and kubeadm configs to be copied, missing %v", configs)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/config"
+	"k8s.io/minikube/pkg/util/kubeconfig"
+)
+
+// GetKubeConfig returns the cluster admin's kubeconfig from the node, which
+// kubeadm regenerates along with the cluster's certificates.
+func (k *KubeadmBootstrapper) GetKubeConfig() (string, error) {
+	out, err := k.c.CombinedOutput(bootstrapper.Sudo("cat " + adminKubeconfig))
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s: %s", adminKubeconfig, out)
+	}
+	return out, nil
+}
+
+// RefreshHostKubeConfig merges the node's admin kubeconfig into the host's
+// kubeconfig at path, under the machine's name, so that kubectl trusts the
+// cluster again after its certificates change, e.g. when it's recreated.
+// The other clusters, users and contexts in path are kept.
+func (k *KubeadmBootstrapper) RefreshHostKubeConfig(path string) error {
+	data, err := k.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+	if err := kubeconfig.MergeKubeConfig([]byte(data), path, config.GetMachineName()); err != nil {
+		return errors.Wrapf(err, "merging the admin kubeconfig into %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/util/kubeconfig"
+)
+
+const testAdminConf = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: Y2E=
+    server: https://10.0.2.15:8443
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: kubernetes-admin
+  name: kubernetes-admin@kubernetes
+current-context: kubernetes-admin@kubernetes
+kind: Config
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+`
+
+func TestRefreshHostKubeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+
+	f := bootstrapper.NewFakeCommandRunner()
+	k := KubeadmBootstrapper{c: f}
+	if err := k.RefreshHostKubeConfig(path); err == nil {
+		t.Error("Expected an error when admin.conf can't be read, got nil")
+	}
+
+	f.SetCommandToOutput(map[string]string{"sudo cat " + adminKubeconfig: testAdminConf})
+	if err := k.RefreshHostKubeConfig(path); err != nil {
+		t.Fatalf("Error refreshing kubeconfig: %s", err)
+	}
+	cfg, err := kubeconfig.ReadConfigOrNew(path)
+	if err != nil {
+		t.Fatalf("Error reading kubeconfig: %s", err)
+	}
+	if user := cfg.AuthInfos["minikube"]; user == nil || string(user.ClientKeyData) != "key" {
+		t.Errorf("Expected the admin credentials under minikube, got %+v", cfg.AuthInfos)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockPollInterval is how often a held lock is checked for release.
+var lockPollInterval = 100 * time.Millisecond
+
+// lockFile takes an exclusive lock on path, waiting while another process
+// or goroutine holds it. The lock is released, and path removed, by the
+// returned func, or, where the OS can tell, when the process holding it
+// dies.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	for {
+		unlock, err := tryLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if unlock != nil {
+			return unlock, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "waiting for lock %s", path)
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
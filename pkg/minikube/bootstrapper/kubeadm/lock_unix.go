@@ -0,0 +1,69 @@
+// +build !windows
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// tryLockFile takes an flock on path, which the kernel releases if the
+// process holding it dies, so that a crashed minikube never leaves a
+// download locked. It returns a nil func if the lock is held already.
+func tryLockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening lock %s", path)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "locking %s", path)
+	}
+
+	// The holder removes path when releasing the lock, so the file locked
+	// may have been removed, and another created in its place, since it
+	// was opened.
+	held, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "stat %s", path)
+	}
+	if current, err := os.Stat(path); err != nil || !os.SameFile(held, current) {
+		f.Close()
+		return nil, nil
+	}
+
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return func() {
+		if err := os.Remove(path); err != nil {
+			glog.Warningf("Error removing lock %s: %s", path, err)
+		}
+		if err := f.Close(); err != nil {
+			glog.Warningf("Error releasing lock %s: %s", path, err)
+		}
+	}, nil
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLockFileHolderDied(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kubelet.lock")
+
+	// A process that dies holding the lock leaves the file behind, but its
+	// flock is released with its files.
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Error creating lock: %s", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("Error locking: %s", err)
+	}
+	f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("Expected the lock of a dead process to be taken at once, got: %s", err)
+	}
+	unlock()
+}
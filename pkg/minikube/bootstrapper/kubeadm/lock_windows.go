@@ -0,0 +1,93 @@
+// +build windows
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+var (
+	// lockStaleAfter is how long after its last refresh a lock is assumed to
+	// have been left behind by a process that crashed.
+	lockStaleAfter = 30 * time.Second
+	// lockRefreshInterval is how often a held lock is refreshed.
+	lockRefreshInterval = 10 * time.Second
+)
+
+// tryLockFile takes a lock on path by creating it. Windows has no flock,
+// so the lock's modification time is refreshed while it's held, so that a
+// lock left by a crashed process can be told apart from a long download,
+// and taken over once stale. It returns a nil func if the lock is held
+// already.
+func tryLockFile(path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return holdLock(path), nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrapf(err, "creating lock %s", path)
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil || time.Since(fi.ModTime()) <= lockStaleAfter {
+			return nil, nil
+		}
+		glog.Infof("Removing stale lock %s, last refreshed at %s", path, fi.ModTime())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "removing stale lock %s", path)
+		}
+	}
+}
+
+// holdLock refreshes the lock at path until the returned func is called,
+// which removes it.
+func holdLock(path string) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if err := os.Chtimes(path, now, now); err != nil {
+					glog.Warningf("Error refreshing lock %s: %s", path, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		if err := os.Remove(path); err != nil {
+			glog.Warningf("Error releasing lock %s: %s", path, err)
+		}
+	}
+}
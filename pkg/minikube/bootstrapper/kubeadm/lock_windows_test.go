@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kubelet.lock")
+
+	if err := ioutil.WriteFile(path, []byte("12345\n"), 0644); err != nil {
+		t.Fatalf("Error writing lock: %s", err)
+	}
+	old := time.Now().Add(-2 * lockStaleAfter)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Error setting lock time: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unlock, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got: %s", err)
+	}
+	unlock()
+}
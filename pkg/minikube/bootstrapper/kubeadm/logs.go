@@ -0,0 +1,213 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const nodeTimeCmd = "date +%s"
+
+// getNodeTime returns the current time according to the node's clock.
+// The host and VM clocks can drift apart (e.g. after the host resumes from
+// sleep), so time-bounded queries are computed against the node instead.
+func (k *KubeadmBootstrapper) getNodeTime(ctx context.Context) (time.Time, error) {
+	out, err := k.c.CombinedOutputContext(ctx, nodeTimeCmd)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "getting node time")
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parsing node time: %s", out)
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// getSinceTimestamp converts opts.Since into an absolute unix timestamp on
+// the node. Relative syntax ("2 min ago") isn't understood by every version
+// of journalctl or the container runtimes, but a unix timestamp is.
+func (k *KubeadmBootstrapper) getSinceTimestamp(ctx context.Context, opts bootstrapper.LogOptions) (int64, error) {
+	if opts.Since <= 0 {
+		return 0, nil
+	}
+	now, err := k.getNodeTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return now.Add(-opts.Since).Unix(), nil
+}
+
+func getLogsCommand(opts bootstrapper.LogOptions, since int64) string {
+	var flags []string
+	if opts.Follow {
+		flags = append(flags, "-f")
+	}
+	if opts.Tail > 0 {
+		flags = append(flags, fmt.Sprintf("-n %d", opts.Tail))
+	}
+	if opts.PreviousBoot {
+		flags = append(flags, "-b -1")
+	}
+	if since > 0 {
+		flags = append(flags, fmt.Sprintf("--since=@%d", since))
+	}
+	return bootstrapper.Sudo(fmt.Sprintf("journalctl %s -u kubelet", strings.Join(flags, " ")))
+}
+
+// ErrNoPreviousBoot is returned when logs from the node's previous boot are
+// requested, but the journal doesn't have any.
+var ErrNoPreviousBoot = errors.New(`no previous boot found in the journal. journald may not be persisting logs; enable it with: minikube ssh "sudo mkdir -p /var/log/journal && sudo systemctl restart systemd-journald"`)
+
+// noPreviousBootMessages are printed by journalctl when the requested boot
+// isn't in the journal, which is always the case without persistent storage.
+var noPreviousBootMessages = []string{
+	"no persistent journal was found",
+	"No such boot ID in journal",
+	"Data from the specified boot (-1) is not available",
+}
+
+// checkPreviousBoot returns ErrNoPreviousBoot if the output or error of a
+// journalctl command for the previous boot shows it isn't in the journal.
+func checkPreviousBoot(opts bootstrapper.LogOptions, out string, err error) error {
+	if !opts.PreviousBoot {
+		return nil
+	}
+	if err != nil {
+		out += err.Error()
+	}
+	for _, msg := range noPreviousBootMessages {
+		if strings.Contains(out, msg) {
+			return ErrNoPreviousBoot
+		}
+	}
+	return nil
+}
+
+// controlPlaneComponents are the control plane containers run as static pods.
+var controlPlaneComponents = []string{
+	"kube-apiserver",
+	"kube-controller-manager",
+	"kube-scheduler",
+	"etcd",
+}
+
+// listContainersCommand lists the ids of all containers, running or exited,
+// for the named component. crictl works against any CRI runtime, including
+// dockershim; docker is the fallback for nodes without crictl.
+func listContainersCommand(name string) string {
+	return bootstrapper.Sudo(fmt.Sprintf("crictl ps -a --quiet --name=%s 2>/dev/null || docker ps -a --filter=name=k8s_%s --format={{.ID}}", name, name))
+}
+
+// containerLogsCommand returns the logs of the container with the given id,
+// limited by opts.Tail and the since unix timestamp.
+func containerLogsCommand(id string, opts bootstrapper.LogOptions, since int64) string {
+	return buildContainerLogsCommand(id, opts, since, false)
+}
+
+// timestampedContainerLogsCommand is like containerLogsCommand, but prefixes
+// each line with its RFC3339 timestamp.
+func timestampedContainerLogsCommand(id string, opts bootstrapper.LogOptions, since int64) string {
+	return buildContainerLogsCommand(id, opts, since, true)
+}
+
+func buildContainerLogsCommand(id string, opts bootstrapper.LogOptions, since int64, timestamps bool) string {
+	var crictlFlags, dockerFlags []string
+	if timestamps {
+		crictlFlags = append(crictlFlags, "--timestamps")
+		dockerFlags = append(dockerFlags, "--timestamps")
+	}
+	if opts.Tail > 0 {
+		crictlFlags = append(crictlFlags, fmt.Sprintf("--tail=%d", opts.Tail))
+		dockerFlags = append(dockerFlags, fmt.Sprintf("--tail %d", opts.Tail))
+	}
+	if since > 0 {
+		crictlFlags = append(crictlFlags, "--since="+time.Unix(since, 0).UTC().Format(time.RFC3339))
+		dockerFlags = append(dockerFlags, fmt.Sprintf("--since %d", since))
+	}
+	crictl := strings.Join(append([]string{"crictl logs"}, append(crictlFlags, id)...), " ")
+	docker := strings.Join(append([]string{"docker logs"}, append(dockerFlags, id)...), " ")
+	return bootstrapper.Sudo(fmt.Sprintf("%s 2>&1 || %s 2>&1", crictl, docker))
+}
+
+// controlPlaneLogLines is the maximum number of lines of each control plane
+// container's logs appended to the cluster logs.
+const controlPlaneLogLines = 50
+
+// writeControlPlaneLogs writes a bounded excerpt of the logs of the most
+// recent container of each control plane component to w. Components
+// without a container, e.g. because the cluster is still starting, are
+// skipped.
+func (k *KubeadmBootstrapper) writeControlPlaneLogs(ctx context.Context, w io.Writer, opts bootstrapper.LogOptions, since int64) {
+	tail := controlPlaneTail(opts)
+	containerOpts := bootstrapper.LogOptions{Tail: tail}
+
+	for _, component := range controlPlaneComponents {
+		ids, err := k.getContainerIDs(ctx, component)
+		if err != nil {
+			glog.Infof("Skipping %s logs: %s", component, err)
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		logs, err := k.c.CombinedOutputContext(ctx, containerLogsCommand(ids[0], containerOpts, since))
+		if err != nil {
+			glog.Infof("Skipping %s logs: %s", component, err)
+			continue
+		}
+		fmt.Fprintf(w, "\n==> %s <==\n", component)
+		fmt.Fprint(w, lastLines(logs, tail))
+	}
+}
+
+// controlPlaneTail returns the number of lines of each control plane
+// container's logs to return for opts.
+func controlPlaneTail(opts bootstrapper.LogOptions) int {
+	if opts.Tail > 0 && opts.Tail < controlPlaneLogLines {
+		return opts.Tail
+	}
+	return controlPlaneLogLines
+}
+
+// lastLines returns the last n lines of s, in case the runtime didn't
+// honor the requested tail.
+func lastLines(s string, n int) string {
+	lines := strings.SplitAfter(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "") + "\n"
+}
+
+// getContainerIDs returns the ids of the containers for the named component,
+// most recent first.
+func (k *KubeadmBootstrapper) getContainerIDs(ctx context.Context, name string) ([]string, error) {
+	out, err := k.c.CombinedOutputContext(ctx, listContainersCommand(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing %s containers", name)
+	}
+	return strings.Fields(out), nil
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestGetLogsCommand(t *testing.T) {
+	cases := []struct {
+		description string
+		opts        bootstrapper.LogOptions
+		expected    string
+	}{
+		{
+			description: "no options",
+			expected:    "sudo journalctl  -u kubelet",
+		},
+		{
+			description: "tail",
+			opts:        bootstrapper.LogOptions{Tail: 50},
+			expected:    "sudo journalctl -n 50 -u kubelet",
+		},
+		{
+			description: "since",
+			opts:        bootstrapper.LogOptions{Since: 2 * time.Minute},
+			expected:    "sudo journalctl --since=@1499999880 -u kubelet",
+		},
+		{
+			description: "since and tail",
+			opts:        bootstrapper.LogOptions{Tail: 10, Since: time.Hour},
+			expected:    "sudo journalctl -n 10 --since=@1499996400 -u kubelet",
+		},
+		{
+			description: "previous boot",
+			opts:        bootstrapper.LogOptions{Tail: 10, PreviousBoot: true},
+			expected:    "sudo journalctl -n 10 -b -1 -u kubelet",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{
+				nodeTimeCmd:   "1500000000\n",
+				test.expected: "logs",
+			})
+			k := KubeadmBootstrapper{c: f}
+			logs, err := k.GetClusterLogs(test.opts)
+			if err != nil {
+				t.Fatalf("Error getting logs: %s", err)
+			}
+			if logs != "logs" {
+				t.Errorf("Expected command %q to be run, got output %q", test.expected, logs)
+			}
+		})
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		"sudo journalctl -f -u kubelet": "streamed logs",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	var b bytes.Buffer
+	logs, err := k.GetClusterLogs(bootstrapper.LogOptions{Follow: true, Writer: &b})
+	if err != nil {
+		t.Fatalf("Error following logs: %s", err)
+	}
+	if logs != "" {
+		t.Errorf("Expected followed logs to be written to the writer only, got %q returned", logs)
+	}
+	if b.String() != "streamed logs" {
+		t.Errorf("Expected writer to receive %q, got %q", "streamed logs", b.String())
+	}
+
+	if _, err := k.GetClusterLogs(bootstrapper.LogOptions{Follow: true}); err == nil {
+		t.Error("Expected error following logs without a writer, got nil")
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		kubeletStatusCommand:                         "Running\n",
+		getLogsCommand(bootstrapper.LogOptions{}, 0): "kubelet logs\n",
+	})
+	k := KubeadmBootstrapper{c: f}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := k.GetClusterStatusContext(ctx); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected the status check to be cancelled, got %v", err)
+	}
+	if _, err := k.GetClusterLogsContext(ctx, bootstrapper.LogOptions{}); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected getting logs to be cancelled, got %v", err)
+	}
+	if _, err := k.GetClusterLogsContext(context.Background(), bootstrapper.LogOptions{}); err != nil {
+		t.Errorf("Unexpected error getting logs: %s", err)
+	}
+}
+
+func TestGetLogsCommandBadNodeTime(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{nodeTimeCmd: "not a time"})
+	k := KubeadmBootstrapper{c: f}
+	if _, err := k.GetClusterLogs(bootstrapper.LogOptions{Since: time.Minute}); err == nil {
+		t.Error("Expected error parsing node time, got nil")
+	}
+}
+
+func TestNoPreviousBoot(t *testing.T) {
+	cases := []struct {
+		description string
+		output      string
+	}{
+		{
+			description: "volatile journal",
+			output:      "Specifying boot ID or boot offset has no effect, no persistent journal was found.",
+		},
+		{
+			description: "missing boot",
+			output:      "Data from the specified boot (-1) is not available: No such boot ID in journal",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{"sudo journalctl -b -1 -u kubelet": test.output})
+			k := KubeadmBootstrapper{c: f}
+			if _, err := k.GetClusterLogs(bootstrapper.LogOptions{PreviousBoot: true}); err != ErrNoPreviousBoot {
+				t.Errorf("Expected ErrNoPreviousBoot, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnsupportedLogOptions(t *testing.T) {
+	cases := []struct {
+		description string
+		opts        bootstrapper.LogOptions
+		unsupported string
+	}{
+		{
+			description: "follow previous boot",
+			opts:        bootstrapper.LogOptions{Follow: true, PreviousBoot: true, Writer: &bytes.Buffer{}},
+			unsupported: "Follow",
+		},
+		{
+			description: "follow component",
+			opts:        bootstrapper.LogOptions{Follow: true, Component: "etcd", Writer: &bytes.Buffer{}},
+			unsupported: "Follow",
+		},
+		{
+			description: "component previous boot",
+			opts:        bootstrapper.LogOptions{PreviousBoot: true, Component: "etcd"},
+			unsupported: "PreviousBoot",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+			_, err := k.GetClusterLogs(test.opts)
+			unsupported, ok := err.(*bootstrapper.UnsupportedLogOptionError)
+			if !ok {
+				t.Fatalf("Expected UnsupportedLogOptionError, got %v", err)
+			}
+			if unsupported.Option != test.unsupported {
+				t.Errorf("Expected option %s to be unsupported, got %s", test.unsupported, unsupported.Option)
+			}
+		})
+	}
+}
+
+func TestControlPlaneLogs(t *testing.T) {
+	var longLogs bytes.Buffer
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&longLogs, "apiserver line %d\n", i)
+	}
+
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		"sudo journalctl  -u kubelet":                    "kubelet logs\n",
+		listContainersCommand("kube-apiserver"):          "apiserver2\napiserver1\n",
+		listContainersCommand("kube-controller-manager"): "",
+		listContainersCommand("etcd"):                    "etcd1\n",
+		containerLogsCommand("apiserver2", bootstrapper.LogOptions{Tail: controlPlaneLogLines}, 0): longLogs.String(),
+		containerLogsCommand("etcd1", bootstrapper.LogOptions{Tail: controlPlaneLogLines}, 0):      "etcd logs\n",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	logs, err := k.GetClusterLogs(bootstrapper.LogOptions{})
+	if err != nil {
+		t.Fatalf("Error getting logs: %s", err)
+	}
+
+	sections := []string{"kubelet logs", "==> kube-apiserver <==", "apiserver line 99", "==> etcd <==", "etcd logs"}
+	last := -1
+	for _, s := range sections {
+		i := strings.Index(logs, s)
+		if i < 0 {
+			t.Fatalf("Expected logs to contain %q, got:\n%s", s, logs)
+		}
+		if i < last {
+			t.Errorf("Expected %q to come after the previous section, got:\n%s", s, logs)
+		}
+		last = i
+	}
+
+	for _, absent := range []string{"kube-controller-manager", "kube-scheduler", "apiserver line 49\n"} {
+		if strings.Contains(logs, absent) {
+			t.Errorf("Expected logs not to contain %q, got:\n%s", absent, logs)
+		}
+	}
+	if !strings.Contains(logs, "apiserver line 50\n") {
+		t.Errorf("Expected the last %d apiserver lines, got:\n%s", controlPlaneLogLines, logs)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/util"
+)
+
+// manifestsDir is where the user's manifests are copied to on the node. Each
+// manifest gets a numbered directory, so that they're applied in order.
+const manifestsDir = "/var/lib/minikube/manifests"
+
+// manifestExtensions are the extensions of the files kubectl applies from a
+// directory.
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// manifestDir returns the directory on the node the i'th manifest is copied
+// to.
+func manifestDir(i int) string {
+	return path.Join(manifestsDir, fmt.Sprintf("%02d", i))
+}
+
+// manifestAssets returns the files of the given manifests, each of which is
+// a file or a directory of files.
+func manifestAssets(manifests []string) ([]assets.CopyableFile, error) {
+	var files []assets.CopyableFile
+	for i, manifest := range manifests {
+		fi, err := os.Stat(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading manifest %s", manifest)
+		}
+
+		paths := []string{manifest}
+		if fi.IsDir() {
+			paths = nil
+			entries, err := ioutil.ReadDir(manifest)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading manifest directory %s", manifest)
+			}
+			for _, e := range entries {
+				if !e.IsDir() && manifestExtensions[filepath.Ext(e.Name())] {
+					paths = append(paths, filepath.Join(manifest, e.Name()))
+				}
+			}
+		}
+
+		for _, p := range paths {
+			f, err := assets.NewFileAsset(p, manifestDir(i), filepath.Base(p), "0640")
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading manifest %s", p)
+			}
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// copyManifests replaces the manifests on the node with the given ones.
+func (k *KubeadmBootstrapper) copyManifests(manifests []string) error {
+	files, err := manifestAssets(manifests)
+	if err != nil {
+		return err
+	}
+	if err := k.c.Run(bootstrapper.Sudo("rm -rf " + manifestsDir)); err != nil {
+		return errors.Wrap(err, "removing old manifests")
+	}
+	for _, f := range files {
+		if err := k.c.Copy(f); err != nil {
+			return errors.Wrapf(err, "transferring manifest: %+v", f)
+		}
+	}
+	return nil
+}
+
+// applyManifests applies the manifests copied by copyManifests, in order,
+// and must only be called once the apiserver is up. A manifest failing to
+// apply doesn't stop the ones after it, and the error names every manifest
+// that failed.
+func (k *KubeadmBootstrapper) applyManifests(manifests []string) error {
+	m := util.MultiError{}
+	for i, manifest := range manifests {
+		cmd := kubectl("apply -f " + manifestDir(i))
+		if out, err := k.c.CombinedOutput(cmd); err != nil {
+			m.Collect(errors.Wrapf(err, "applying manifest %s: %s", manifest, out))
+		}
+	}
+	return m.ToError()
+}
+
+// staticPodsDir is where kubelet runs static pods from. kubeadm writes the
+// control plane's manifests there, and the addon manager's is copied there
+// too.
+const staticPodsDir = "/etc/kubernetes/manifests"
+
+// staticPodPrefix is prepended to the names of the user's static pod
+// manifests, so that they can't replace the control plane's, and can be
+// told apart to be removed.
+const staticPodPrefix = "minikube-extra-"
+
+// staticPodAssets returns the user's static pod manifests.
+func staticPodAssets(manifests []string) ([]assets.CopyableFile, error) {
+	var files []assets.CopyableFile
+	for _, p := range manifests {
+		f, err := assets.NewFileAsset(p, staticPodsDir, staticPodPrefix+filepath.Base(p), "0640")
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading static pod manifest %s", p)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// copyStaticPods replaces the user's static pod manifests on the node with
+// the given ones, leaving the control plane's and the addon manager's.
+func (k *KubeadmBootstrapper) copyStaticPods(manifests []string) error {
+	files, err := staticPodAssets(manifests)
+	if err != nil {
+		return err
+	}
+	if err := k.c.Run(bootstrapper.Sudo(fmt.Sprintf("rm -f %s/%s*", staticPodsDir, staticPodPrefix))); err != nil {
+		return errors.Wrap(err, "removing old static pod manifests")
+	}
+	for _, f := range files {
+		if err := k.c.Copy(f); err != nil {
+			return errors.Wrapf(err, "transferring static pod manifest: %+v", f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestCopyManifests(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"crd.yaml":              "crd",
+		"operator/deploy.yaml":  "deployment",
+		"operator/service.json": "service",
+		"operator/README.md":    "readme",
+	}
+	for name, contents := range files {
+		p := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatalf("Error making dir: %s", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", p, err)
+		}
+	}
+
+	manifests := []string{filepath.Join(tempDir, "crd.yaml"), filepath.Join(tempDir, "operator")}
+	assets, err := manifestAssets(manifests)
+	if err != nil {
+		t.Fatalf("Error getting manifest assets: %s", err)
+	}
+
+	var targets []string
+	for _, a := range assets {
+		targets = append(targets, filepath.ToSlash(filepath.Join(a.GetTargetDir(), a.GetTargetName())))
+	}
+	expected := []string{
+		"/var/lib/minikube/manifests/00/crd.yaml",
+		"/var/lib/minikube/manifests/01/deploy.yaml",
+		"/var/lib/minikube/manifests/01/service.json",
+	}
+	if strings.Join(targets, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected manifests to be copied to %v, got %v", expected, targets)
+	}
+
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{"sudo rm -rf " + manifestsDir: ""})
+	k := KubeadmBootstrapper{c: f}
+	if err := k.copyManifests(manifests); err != nil {
+		t.Fatalf("Error copying manifests: %s", err)
+	}
+	if contents, err := f.GetFileToContents(filepath.Join(tempDir, "operator", "deploy.yaml")); err != nil || contents != "deployment" {
+		t.Errorf("Expected deploy.yaml to be copied, got %q: %v", contents, err)
+	}
+
+	if err := k.copyManifests([]string{filepath.Join(tempDir, "missing.yaml")}); err == nil {
+		t.Error("Expected error copying a missing manifest, got nil")
+	}
+}
+
+func TestCopyStaticPods(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "static-pods")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	manifest := filepath.Join(tempDir, "registry.yaml")
+	if err := ioutil.WriteFile(manifest, []byte("registry pod"), 0644); err != nil {
+		t.Fatalf("Error writing %s: %s", manifest, err)
+	}
+
+	r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+	r.SetCommandToOutput(map[string]string{bootstrapper.Sudo("rm -f /etc/kubernetes/manifests/minikube-extra-*"): ""})
+	k := KubeadmBootstrapper{c: r}
+	if err := k.copyStaticPods([]string{manifest}); err != nil {
+		t.Fatalf("Error copying static pods: %s", err)
+	}
+	target := "/etc/kubernetes/manifests/minikube-extra-registry.yaml"
+	if contents := r.files[target]; contents != "registry pod" {
+		t.Errorf("Expected the static pod to be copied to %s, got %v", target, r.files)
+	}
+
+	if err := k.copyStaticPods([]string{filepath.Join(tempDir, "missing.yaml")}); err == nil {
+		t.Error("Expected error copying a missing static pod manifest, got nil")
+	}
+}
+
+func TestApplyManifests(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		kubectl("apply -f /var/lib/minikube/manifests/00"): "customresourcedefinition \"foos.example.com\" created",
+		kubectl("apply -f /var/lib/minikube/manifests/02"): "deployment \"foo-operator\" created",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	err := k.applyManifests([]string{"crd.yaml", "bad.yaml", "operator"})
+	if err == nil {
+		t.Fatal("Expected error applying manifests, got nil")
+	}
+	if !strings.Contains(err.Error(), "applying manifest bad.yaml") {
+		t.Errorf("Expected error to name the failed manifest, got: %s", err)
+	}
+	for _, ok := range []string{"crd.yaml", "operator"} {
+		if strings.Contains(err.Error(), "applying manifest "+ok) {
+			t.Errorf("Expected no error for %s, got: %s", ok, err)
+		}
+	}
+
+	if err := k.applyManifests(nil); err != nil {
+		t.Errorf("Unexpected error applying no manifests: %s", err)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// binaryDir and binaryMode are where and with which mode the node's
+// Kubernetes binaries are installed, however they're downloaded.
+const (
+	binaryDir  = "/usr/bin"
+	binaryMode = "0641"
+)
+
+// shouldDownloadOnNode returns whether k8s's version of a Kubernetes binary
+// for arch should be downloaded on the node, rather than on the host. Unless
+// k8s.BinaryDownload says otherwise, it is when the node isn't the host and
+// the binary isn't cached on the host, as the node usually has a faster
+// connection than the host's connection to it.
+func (k *KubeadmBootstrapper) shouldDownloadOnNode(bin, arch string, k8s bootstrapper.KubernetesConfig) bool {
+	switch k8s.BinaryDownload {
+	case bootstrapper.BinaryDownloadNode:
+		return true
+	case bootstrapper.BinaryDownloadHost:
+		return false
+	}
+	if k8s.Offline {
+		return false
+	}
+	if _, ok := k.c.(*bootstrapper.ExecRunner); ok {
+		return false
+	}
+	if _, err := os.Stat(cachedBinaryPath(bin, k8s.KubernetesVersion, constants.NodeOS, arch)); err == nil {
+		return false
+	}
+	return true
+}
+
+// downloadOnNode downloads k8s's version of a Kubernetes binary for arch
+// straight into place on the node, verified there against the same checksum
+// as a download on the host.
+func (k *KubeadmBootstrapper) downloadOnNode(bin, arch string, k8s bootstrapper.KubernetesConfig) error {
+	k.d.printf("Downloading %s %s on the node\n", bin, k8s.KubernetesVersion)
+	cmd := bootstrapper.Sudo(nodeDownloadCommand(bin, arch, k8s))
+	if out, err := k.c.CombinedOutput(cmd); err != nil {
+		return errors.Wrapf(err, "downloading %s on the node: %s", bin, out)
+	}
+	return nil
+}
+
+// nodeDownloadCommand downloads a binary to a temporary file and verifies
+// it against its SHA256 checksum, or its SHA1 checksum for old releases,
+// before installing it, which needs root privileges. A failed download
+// leaves any installed binary as it was.
+func nodeDownloadCommand(bin, arch string, k8s bootstrapper.KubernetesConfig) string {
+	version := k8s.KubernetesVersion
+	curl := "curl -fsSL --connect-timeout 10 --retry 3"
+	if k8s.DownloadProxy != "" {
+		curl += fmt.Sprintf(" -x '%s'", k8s.DownloadProxy)
+	}
+	return fmt.Sprintf(`set -e
+tmp=$(mktemp)
+trap 'rm -f "$tmp"' EXIT
+%[1]s -o "$tmp" '%[2]s'
+if sum=$(%[1]s '%[3]s'); then check=sha256sum; else sum=$(%[1]s '%[4]s'); check=sha1sum; fi
+echo "${sum%%%% *}  $tmp" | $check -c -
+install -m %[5]s "$tmp" %[6]s`,
+		curl,
+		constants.GetKubernetesReleaseURLForPlatform(k8s.ReleaseMirror, bin, version, constants.NodeOS, arch),
+		constants.GetKubernetesReleaseURLSha256ForPlatform(k8s.ReleaseMirror, bin, version, constants.NodeOS, arch),
+		constants.GetKubernetesReleaseURLSha1ForPlatform(k8s.ReleaseMirror, bin, version, constants.NodeOS, arch),
+		binaryMode,
+		path.Join(binaryDir, bin))
+}
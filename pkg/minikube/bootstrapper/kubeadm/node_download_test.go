@@ -0,0 +1,262 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+func TestShouldDownloadOnNode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	cached := cachedBinaryPath("kubeadm", "v1.8.0", constants.NodeOS, constants.NodeArch)
+	if err := os.MkdirAll(constants.MakeMiniPath("cache", "v1.8.0"), 0777); err != nil {
+		t.Fatalf("Error making cache dir: %s", err)
+	}
+	if err := ioutil.WriteFile(cached, []byte("kubeadm"), 0644); err != nil {
+		t.Fatalf("Error caching kubeadm: %s", err)
+	}
+
+	cases := []struct {
+		description string
+		runner      bootstrapper.CommandRunner
+		bin         string
+		download    string
+		offline     bool
+		expected    bool
+	}{
+		{
+			description: "auto",
+			runner:      bootstrapper.NewFakeCommandRunner(),
+			bin:         "kubelet",
+			expected:    true,
+		},
+		{
+			description: "auto with cached binary",
+			runner:      bootstrapper.NewFakeCommandRunner(),
+			bin:         "kubeadm",
+		},
+		{
+			description: "auto offline",
+			runner:      bootstrapper.NewFakeCommandRunner(),
+			bin:         "kubelet",
+			offline:     true,
+		},
+		{
+			description: "auto on the host",
+			runner:      &bootstrapper.ExecRunner{},
+			bin:         "kubelet",
+		},
+		{
+			description: "node with cached binary",
+			runner:      bootstrapper.NewFakeCommandRunner(),
+			bin:         "kubeadm",
+			download:    bootstrapper.BinaryDownloadNode,
+			expected:    true,
+		},
+		{
+			description: "host",
+			runner:      bootstrapper.NewFakeCommandRunner(),
+			bin:         "kubelet",
+			download:    bootstrapper.BinaryDownloadHost,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := KubeadmBootstrapper{c: test.runner}
+			k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", BinaryDownload: test.download, Offline: test.offline}
+			if actual := k.shouldDownloadOnNode(test.bin, constants.NodeArch, k8s); actual != test.expected {
+				t.Errorf("Expected %t, got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInstallBinaryOnNode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := "kubelet binary"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+			return
+		}
+		http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL}
+	cached := cachedBinaryPath("kubelet", "v1.8.0", constants.NodeOS, constants.NodeArch)
+
+	cases := []struct {
+		description string
+		download    string
+		nodeWorks   bool
+		onHost      bool
+		shouldErr   bool
+	}{
+		{
+			description: "node download",
+			nodeWorks:   true,
+		},
+		{
+			description: "fall back to host",
+			onHost:      true,
+		},
+		{
+			description: "node download required",
+			download:    bootstrapper.BinaryDownloadNode,
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			os.RemoveAll(constants.MakeMiniPath("cache"))
+			k8s := k8s
+			k8s.BinaryDownload = test.download
+
+			f := bootstrapper.NewFakeCommandRunner()
+			if test.nodeWorks {
+				f.SetCommandToOutput(map[string]string{bootstrapper.Sudo(nodeDownloadCommand("kubelet", constants.NodeArch, k8s)): ""})
+			}
+			k := KubeadmBootstrapper{c: f, d: *testDownloader}
+
+			err := k.installBinary(context.Background(), "kubelet", constants.NodeArch, k8s)
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error, got nil")
+			}
+			copied, err := f.GetFileToContents(cached)
+			if test.onHost && copied != contents {
+				t.Errorf("Expected kubelet to be copied from the host, got %q, %v", copied, err)
+			}
+			if !test.onHost && err == nil {
+				t.Error("Expected kubelet not to be copied from the host")
+			}
+		})
+	}
+}
+
+func TestNodeDownloadCommand(t *testing.T) {
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", DownloadProxy: "http://proxy.example.com:3128"}
+	cmd := nodeDownloadCommand("kubelet", constants.NodeArch, k8s)
+	for _, expected := range []string{
+		"-x 'http://proxy.example.com:3128' -o \"$tmp\" 'https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet'",
+		"'https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet.sha256'",
+		"'https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet.sha1'",
+		`echo "${sum%% *}  $tmp" | $check -c -`,
+		`install -m 0641 "$tmp" /usr/bin/kubelet`,
+	} {
+		if !strings.Contains(cmd, expected) {
+			t.Errorf("Expected command to contain %q, got:\n%s", expected, cmd)
+		}
+	}
+}
+
+func TestInstallBinaryForArch(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	contents := "arm64 kubelet binary"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/bin/linux/arm64/") {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+			return
+		}
+		http.ServeContent(w, r, "kubelet", time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", ReleaseMirror: server.URL, BinaryDownload: bootstrapper.BinaryDownloadHost}
+
+	f := bootstrapper.NewFakeCommandRunner()
+	k := KubeadmBootstrapper{c: f, d: *testDownloader}
+	if err := k.installBinary(context.Background(), "kubelet", "arm64", k8s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cached := cachedBinaryPath("kubelet", "v1.8.0", constants.NodeOS, "arm64")
+	if copied, err := f.GetFileToContents(cached); copied != contents {
+		t.Errorf("Expected the arm64 kubelet to be copied from %s, got %q, %v", cached, copied, err)
+	}
+
+	if cmd := nodeDownloadCommand("kubelet", "arm64", k8s); !strings.Contains(cmd, server.URL+"/kubernetes-release/release/v1.8.0/bin/linux/arm64/kubelet'") {
+		t.Errorf("Expected the node to download the arm64 kubelet, got:\n%s", cmd)
+	}
+}
+
+func TestInstallBinaryOverride(t *testing.T) {
+	f, err := ioutil.TempFile("", "kubelet")
+	if err != nil {
+		t.Fatalf("Error making temp file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("custom kubelet"); err != nil {
+		t.Fatalf("Error writing %s: %s", f.Name(), err)
+	}
+	f.Close()
+
+	// Nothing is downloaded, so the release mirror is never reached.
+	k8s := bootstrapper.KubernetesConfig{
+		KubernetesVersion: "v1.8.0",
+		ReleaseMirror:     "http://127.0.0.1:0",
+		BinaryDownload:    bootstrapper.BinaryDownloadNode,
+		BinaryOverrides:   map[string]string{"kubelet": f.Name()},
+	}
+	runner := bootstrapper.NewFakeCommandRunner()
+	k := KubeadmBootstrapper{c: runner, d: *testDownloader}
+	if err := k.installBinary(context.Background(), "kubelet", constants.NodeArch, k8s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if copied, err := runner.GetFileToContents(f.Name()); copied != "custom kubelet" {
+		t.Errorf("Expected the custom kubelet to be copied, got %q, %v", copied, err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/service"
+)
+
+// nodeReadyInterval is how often WaitForNodeReady checks the node.
+var nodeReadyInterval = 2 * time.Second
+
+// getNodeConditions returns the conditions of the node named name, from the
+// apiserver.
+var getNodeConditions = func(name string) ([]clientv1.NodeCondition, error) {
+	client, err := service.K8s.GetCoreClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting core client")
+	}
+	n, err := client.Nodes().Get(name, v1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting node %s", name)
+	}
+	return n.Status.Conditions, nil
+}
+
+// NodeNotReadyError is returned by WaitForNodeReady when the node isn't
+// ready in time.
+type NodeNotReadyError struct {
+	Node string
+	// Conditions are the node's last known conditions, e.g. whether its
+	// network is unavailable. They're empty if the node never registered.
+	Conditions []clientv1.NodeCondition
+	// Err is why the node's conditions couldn't be got the last time, if
+	// they couldn't.
+	Err error
+}
+
+func (e *NodeNotReadyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("node %s isn't ready: %v", e.Node, e.Err)
+	}
+	return fmt.Sprintf("node %s isn't ready: %s", e.Node, describeNodeConditions(e.Conditions))
+}
+
+// WaitForNodeReady waits for k8s's node to be registered with the apiserver
+// and report that it's ready. Unlike the apiserver being healthy, this
+// means that the node can run pods, e.g. that its network is configured.
+// When timeout passes first, a *NodeNotReadyError says why the node isn't
+// ready.
+func (k *KubeadmBootstrapper) WaitForNodeReady(k8s bootstrapper.KubernetesConfig, timeout time.Duration) error {
+	notReady := &NodeNotReadyError{Node: k8s.NodeName}
+	deadline := time.Now().Add(timeout)
+	for {
+		conditions, err := getNodeConditions(k8s.NodeName)
+		if err == nil && nodeReady(conditions) {
+			return nil
+		}
+		if err == nil {
+			notReady.Conditions, notReady.Err = conditions, nil
+		} else {
+			notReady.Err = err
+		}
+		if time.Now().Add(nodeReadyInterval).After(deadline) {
+			return notReady
+		}
+		glog.Infof("Waiting for %s", notReady)
+		time.Sleep(nodeReadyInterval)
+	}
+}
+
+// nodeReady returns whether conditions say that the node is ready.
+func nodeReady(conditions []clientv1.NodeCondition) bool {
+	for _, c := range conditions {
+		if c.Type == clientv1.NodeReady {
+			return c.Status == clientv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// describeNodeConditions describes the conditions that keep a node from
+// being ready: Ready not being True, and any other condition, such as
+// NetworkUnavailable or MemoryPressure, not being False.
+func describeNodeConditions(conditions []clientv1.NodeCondition) string {
+	if len(conditions) == 0 {
+		return "no conditions reported"
+	}
+	var problems []string
+	for _, c := range conditions {
+		healthy := clientv1.ConditionFalse
+		if c.Type == clientv1.NodeReady {
+			healthy = clientv1.ConditionTrue
+		}
+		if c.Status == healthy {
+			continue
+		}
+		problem := fmt.Sprintf("%s=%s", c.Type, c.Status)
+		switch {
+		case c.Reason != "" && c.Message != "":
+			problem += fmt.Sprintf(" (%s: %s)", c.Reason, c.Message)
+		case c.Reason != "" || c.Message != "":
+			problem += fmt.Sprintf(" (%s%s)", c.Reason, c.Message)
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		return "no Ready condition reported"
+	}
+	return strings.Join(problems, ", ")
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	clientv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+var notReadyConditions = []clientv1.NodeCondition{
+	{Type: clientv1.NodeMemoryPressure, Status: clientv1.ConditionFalse, Reason: "KubeletHasSufficientMemory"},
+	{Type: clientv1.NodeNetworkUnavailable, Status: clientv1.ConditionTrue, Reason: "NoRouteCreated"},
+	{Type: clientv1.NodeReady, Status: clientv1.ConditionFalse, Reason: "KubeletNotReady", Message: "runtime network not ready: cni config uninitialized"},
+}
+
+func TestNodeConditions(t *testing.T) {
+	cases := []struct {
+		description string
+		conditions  []clientv1.NodeCondition
+		ready       bool
+		expected    string
+	}{
+		{
+			description: "ready",
+			conditions: []clientv1.NodeCondition{
+				{Type: clientv1.NodeMemoryPressure, Status: clientv1.ConditionFalse},
+				{Type: clientv1.NodeReady, Status: clientv1.ConditionTrue},
+			},
+			ready: true,
+		},
+		{
+			description: "network unavailable",
+			conditions:  notReadyConditions,
+			expected:    "NetworkUnavailable=True (NoRouteCreated), Ready=False (KubeletNotReady: runtime network not ready: cni config uninitialized)",
+		},
+		{
+			description: "unknown",
+			conditions: []clientv1.NodeCondition{
+				{Type: clientv1.NodeReady, Status: clientv1.ConditionUnknown},
+			},
+			expected: "Ready=Unknown",
+		},
+		{
+			description: "no ready condition",
+			conditions: []clientv1.NodeCondition{
+				{Type: clientv1.NodeMemoryPressure, Status: clientv1.ConditionFalse},
+			},
+			expected: "no Ready condition reported",
+		},
+		{
+			description: "not registered",
+			expected:    "no conditions reported",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if ready := nodeReady(test.conditions); ready != test.ready {
+				t.Errorf("Expected ready %t, got %t", test.ready, ready)
+			}
+			if test.ready {
+				return
+			}
+			if description := describeNodeConditions(test.conditions); description != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, description)
+			}
+		})
+	}
+}
+
+func TestWaitForNodeReady(t *testing.T) {
+	defer func(f func(string) ([]clientv1.NodeCondition, error), interval time.Duration) {
+		getNodeConditions, nodeReadyInterval = f, interval
+	}(getNodeConditions, nodeReadyInterval)
+	nodeReadyInterval = time.Millisecond
+	k8s := bootstrapper.KubernetesConfig{NodeName: "minikube"}
+	k := KubeadmBootstrapper{}
+
+	calls := 0
+	getNodeConditions = func(name string) ([]clientv1.NodeCondition, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, errors.New("nodes \"minikube\" not found")
+		case 2:
+			return notReadyConditions, nil
+		}
+		return []clientv1.NodeCondition{{Type: clientv1.NodeReady, Status: clientv1.ConditionTrue}}, nil
+	}
+	if err := k.WaitForNodeReady(k8s, time.Minute); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	getNodeConditions = func(name string) ([]clientv1.NodeCondition, error) {
+		return notReadyConditions, nil
+	}
+	err := k.WaitForNodeReady(k8s, 10*time.Millisecond)
+	notReady, ok := err.(*NodeNotReadyError)
+	if !ok {
+		t.Fatalf("Expected NodeNotReadyError, got %v", err)
+	}
+	if len(notReady.Conditions) != len(notReadyConditions) {
+		t.Errorf("Expected the last known conditions, got %v", notReady.Conditions)
+	}
+	if !strings.Contains(err.Error(), "NetworkUnavailable=True") {
+		t.Errorf("Expected the error to say why the node isn't ready, got: %s", err)
+	}
+}
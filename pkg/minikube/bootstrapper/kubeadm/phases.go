@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"io"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// The phases of starting a cluster reported to StartOptions.Progress, in
+// the order they run. UpdateCluster copies the config and downloads the
+// binaries, and StartCluster runs kubeadm init and waits for the control
+// plane.
+const (
+	PhaseCopyingConfig          = "copying config"
+	PhaseDownloadingBinaries    = "downloading binaries"
+	PhaseRunningKubeadmInit     = "running kubeadm init"
+	PhaseWaitingForControlPlane = "waiting for control plane"
+)
+
+// StartOptions are the optional parameters of UpdateClusterWithOptions and
+// StartClusterWithOptions.
+type StartOptions struct {
+	// Progress, if set, is called as each phase starts and finishes.
+	Progress PhaseProgressFunc
+	// Output, if set, is where kubeadm init's output is streamed as it
+	// runs. It's still kept in the init log.
+	Output io.Writer
+}
+
+// PhaseProgress is the progress of one phase of starting a cluster.
+type PhaseProgress struct {
+	// Phase is the phase's name, e.g. PhaseRunningKubeadmInit.
+	Phase string
+	// Finished is set once the phase has succeeded, or failed.
+	Finished bool
+	// Err is why the phase failed.
+	Err error
+	// Elapsed is how long the phase took, once it's finished.
+	Elapsed time.Duration
+}
+
+// PhaseProgressFunc is called with the progress of the phases of starting
+// a cluster.
+type PhaseProgressFunc func(PhaseProgress)
+
+// phase runs f as the named phase, reporting when it starts and finishes,
+// and logging how long it took.
+func (o StartOptions) phase(name string, f func() error) error {
+	if o.Progress != nil {
+		o.Progress(PhaseProgress{Phase: name})
+	}
+	start := time.Now()
+	err := f()
+	elapsed := time.Since(start)
+	glog.Infof("Phase %q took %s", name, elapsed)
+	if o.Progress != nil {
+		o.Progress(PhaseProgress{Phase: name, Finished: true, Err: err, Elapsed: elapsed})
+	}
+	return err
+}
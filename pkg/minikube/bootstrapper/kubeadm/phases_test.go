@@ -0,0 +1,203 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// acceptingRunner runs every command successfully, except kubeadm init if
+// initErr is set, whose output is initLog.
+type acceptingRunner struct {
+	*bootstrapper.FakeCommandRunner
+	initErr error
+	initLog string
+}
+
+func (r *acceptingRunner) Run(cmd string) error {
+	_, err := r.CombinedOutput(cmd)
+	return err
+}
+
+func (r *acceptingRunner) CombinedOutput(cmd string) (string, error) {
+	if strings.Contains(cmd, "kubeadm init") {
+		return "", r.initErr
+	}
+	if cmd == readInitLogCmd {
+		return r.initLog, nil
+	}
+	if cmd == "uname -m" {
+		return "x86_64\n", nil
+	}
+	return "", nil
+}
+
+func (r *acceptingRunner) Copy(assets.CopyableFile) error {
+	return nil
+}
+
+func (r *acceptingRunner) RunContext(_ context.Context, cmd string) error {
+	return r.Run(cmd)
+}
+
+func (r *acceptingRunner) CombinedOutputContext(_ context.Context, cmd string) (string, error) {
+	return r.CombinedOutput(cmd)
+}
+
+func (r *acceptingRunner) CopyContext(_ context.Context, f assets.CopyableFile) error {
+	return r.Copy(f)
+}
+
+func TestStartPhases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-binaries")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	overrides := map[string]string{}
+	for _, bin := range []string{"kubelet", "kubeadm", "kubectl"} {
+		overrides[bin] = filepath.Join(dir, bin)
+		if err := ioutil.WriteFile(overrides[bin], []byte(bin), 0755); err != nil {
+			t.Fatalf("Error writing %s: %s", bin, err)
+		}
+	}
+	k8s := bootstrapper.KubernetesConfig{
+		KubernetesVersion: "v1.8.0",
+		NodeIP:            "192.168.99.100",
+		NodeName:          "minikube",
+		BinaryOverrides:   overrides,
+		InstallKubectl:    true,
+	}
+
+	defer func(f func(bootstrapper.CommandRunner, string) error) { setUpControlPlane = f }(setUpControlPlane)
+	setUpControlPlane = func(bootstrapper.CommandRunner, string) error { return nil }
+
+	initErr := errors.New("kubeadm init failed")
+	cases := []struct {
+		description string
+		initErr     error
+		initLog     string
+		expected    []PhaseProgress
+	}{
+		{
+			description: "started",
+			expected: []PhaseProgress{
+				{Phase: PhaseCopyingConfig},
+				{Phase: PhaseCopyingConfig, Finished: true},
+				{Phase: PhaseDownloadingBinaries},
+				{Phase: PhaseDownloadingBinaries, Finished: true},
+				{Phase: PhaseRunningKubeadmInit},
+				{Phase: PhaseRunningKubeadmInit, Finished: true},
+				{Phase: PhaseWaitingForControlPlane},
+				{Phase: PhaseWaitingForControlPlane, Finished: true},
+			},
+		},
+		{
+			description: "already bootstrapped",
+			initErr:     initErr,
+			initLog:     "[uploadconfig] Storing the configuration used in ConfigMap \"kubeadm-config\" in the \"kube-system\" Namespace\nunable to create configmap: configmaps \"kubeadm-config\" already exists\n",
+			expected: []PhaseProgress{
+				{Phase: PhaseCopyingConfig},
+				{Phase: PhaseCopyingConfig, Finished: true},
+				{Phase: PhaseDownloadingBinaries},
+				{Phase: PhaseDownloadingBinaries, Finished: true},
+				{Phase: PhaseRunningKubeadmInit},
+				{Phase: PhaseRunningKubeadmInit, Finished: true},
+				{Phase: PhaseWaitingForControlPlane},
+				{Phase: PhaseWaitingForControlPlane, Finished: true},
+			},
+		},
+		{
+			description: "kubeadm init failed",
+			initErr:     initErr,
+			initLog:     "error execution phase kubeconfig/admin: a kubeconfig file \"/etc/kubernetes/admin.conf\" exists already but has got the wrong CA cert\n",
+			expected: []PhaseProgress{
+				{Phase: PhaseCopyingConfig},
+				{Phase: PhaseCopyingConfig, Finished: true},
+				{Phase: PhaseDownloadingBinaries},
+				{Phase: PhaseDownloadingBinaries, Finished: true},
+				{Phase: PhaseRunningKubeadmInit},
+				{Phase: PhaseRunningKubeadmInit, Finished: true, Err: initErr},
+			},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := &KubeadmBootstrapper{
+				c: &acceptingRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), initErr: test.initErr, initLog: test.initLog},
+				d: *testDownloader,
+			}
+			var events []PhaseProgress
+			opts := StartOptions{Progress: func(p PhaseProgress) { events = append(events, p) }}
+
+			if err := k.UpdateClusterWithOptions(context.Background(), k8s, opts); err != nil {
+				t.Fatalf("Error updating cluster: %s", err)
+			}
+			err := k.StartClusterWithOptions(context.Background(), k8s, opts)
+			failed := test.expected[len(test.expected)-1].Err != nil
+			if (err != nil) != failed {
+				t.Fatalf("Expected failure %t, got %v", failed, err)
+			}
+
+			// Only the cause of a failure is compared, and not how long
+			// phases took.
+			for i, e := range events {
+				if e.Err != nil && strings.Contains(e.Err.Error(), initErr.Error()) {
+					events[i].Err = initErr
+				}
+				events[i].Elapsed = 0
+			}
+			if !reflect.DeepEqual(events, test.expected) {
+				t.Errorf("Expected phase events:\n%+v\ngot:\n%+v", test.expected, events)
+			}
+		})
+	}
+}
+
+func TestPhaseElapsed(t *testing.T) {
+	var finished PhaseProgress
+	opts := StartOptions{Progress: func(p PhaseProgress) { finished = p }}
+	opts.phase(PhaseCopyingConfig, func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	if !finished.Finished || finished.Elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the finished phase to have taken at least 10ms, got %+v", finished)
+	}
+}
+
+func TestStartPhasesWithoutProgress(t *testing.T) {
+	ran := false
+	if err := (StartOptions{}).phase(PhaseCopyingConfig, func() error { ran = true; return nil }); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !ran {
+		t.Error("Expected the phase to run without a progress func")
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// podContainersCommand lists the names of the containers in a pod.
+func podContainersCommand(namespace, pod string) string {
+	return kubectl(fmt.Sprintf("-n %s get pod %s -o jsonpath='{.spec.containers[*].name}'", namespace, pod))
+}
+
+// GetPodLogs returns the logs of a container in a pod, or of each of its
+// containers if container is empty. When following, the logs are streamed
+// to stdout and the returned string is empty; a container must be chosen to
+// follow a pod with several containers.
+func (k *KubeadmBootstrapper) GetPodLogs(namespace, pod, container string, follow bool) (string, error) {
+	containers := []string{container}
+	if container == "" {
+		var err error
+		if containers, err = k.getPodContainers(namespace, pod); err != nil {
+			return "", err
+		}
+	}
+	opts := bootstrapper.LogOptions{Follow: follow}
+
+	if follow {
+		if len(containers) > 1 {
+			return "", fmt.Errorf("pod %s/%s has several containers, choose one of: %s", namespace, pod, strings.Join(containers, ", "))
+		}
+		cmd := podLogsCommand(namespace, pod, containers[0], opts, 0)
+		if err := k.c.RunWithOutput(cmd, os.Stdout, os.Stdout); err != nil {
+			return "", errors.Wrapf(err, "following logs of pod %s/%s", namespace, pod)
+		}
+		return "", nil
+	}
+
+	if len(containers) == 1 {
+		return k.getPodContainerLogs(namespace, pod, containers[0], opts)
+	}
+	var b bytes.Buffer
+	for _, c := range containers {
+		logs, err := k.getPodContainerLogs(namespace, pod, c, opts)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "==> %s <==\n", c)
+		fmt.Fprint(&b, logs)
+	}
+	return b.String(), nil
+}
+
+func (k *KubeadmBootstrapper) getPodContainers(namespace, pod string) ([]string, error) {
+	out, err := k.c.CombinedOutput(podContainersCommand(namespace, pod))
+	if err != nil {
+		return nil, podError(namespace, pod, err)
+	}
+	containers := strings.Fields(out)
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("pod %s/%s has no containers", namespace, pod)
+	}
+	return containers, nil
+}
+
+func (k *KubeadmBootstrapper) getPodContainerLogs(namespace, pod, container string, opts bootstrapper.LogOptions) (string, error) {
+	logs, err := k.c.CombinedOutput(podLogsCommand(namespace, pod, container, opts, 0))
+	if err != nil {
+		return "", podError(namespace, pod, err)
+	}
+	return logs, nil
+}
+
+// podError turns kubectl's NotFound errors into a clear error.
+func podError(namespace, pod string, err error) error {
+	if strings.Contains(err.Error(), "NotFound") {
+		return fmt.Errorf("pod %s/%s not found", namespace, pod)
+	}
+	return errors.Wrapf(err, "getting pod %s/%s", namespace, pod)
+}
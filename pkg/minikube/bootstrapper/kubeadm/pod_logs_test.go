@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestGetPodLogs(t *testing.T) {
+	cases := []struct {
+		description string
+		container   string
+		outputs     map[string]string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "single container",
+			outputs: map[string]string{
+				podContainersCommand("default", "nginx"):                                  "nginx",
+				podLogsCommand("default", "nginx", "nginx", bootstrapper.LogOptions{}, 0): "nginx logs\n",
+			},
+			expected: "nginx logs\n",
+		},
+		{
+			description: "several containers",
+			outputs: map[string]string{
+				podContainersCommand("default", "nginx"):                                    "nginx sidecar",
+				podLogsCommand("default", "nginx", "nginx", bootstrapper.LogOptions{}, 0):   "nginx logs\n",
+				podLogsCommand("default", "nginx", "sidecar", bootstrapper.LogOptions{}, 0): "sidecar logs\n",
+			},
+			expected: "==> nginx <==\nnginx logs\n==> sidecar <==\nsidecar logs\n",
+		},
+		{
+			description: "chosen container",
+			container:   "sidecar",
+			outputs: map[string]string{
+				podLogsCommand("default", "nginx", "sidecar", bootstrapper.LogOptions{}, 0): "sidecar logs\n",
+			},
+			expected: "sidecar logs\n",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(test.outputs)
+			k := KubeadmBootstrapper{c: f}
+			logs, err := k.GetPodLogs("default", "nginx", test.container, false)
+			if err != nil {
+				t.Fatalf("Error getting pod logs: %s", err)
+			}
+			if logs != test.expected {
+				t.Errorf("Expected logs %q, got %q", test.expected, logs)
+			}
+		})
+	}
+}
+
+func TestGetPodLogsFollowSeveralContainers(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{podContainersCommand("default", "nginx"): "nginx sidecar"})
+	k := KubeadmBootstrapper{c: f}
+	if _, err := k.GetPodLogs("default", "nginx", "", true); err == nil || !strings.Contains(err.Error(), "nginx, sidecar") {
+		t.Errorf("Expected an error listing the containers to choose from, got %v", err)
+	}
+}
+
+func TestPodError(t *testing.T) {
+	err := podError("default", "nginx", errors.New(`running command: kubectl get pod nginx
+ output: Error from server (NotFound): pods "nginx" not found`))
+	if err.Error() != "pod default/nginx not found" {
+		t.Errorf("Expected a not found error, got %q", err)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/minikube/machine"
+)
+
+// DefaultCacheParallelism is how many artifacts CacheArtifacts caches at
+// once by default.
+const DefaultCacheParallelism = 4
+
+// cacheImage caches an image on the host, in cacheDir, unless it's cached
+// already.
+var cacheImage = machine.CacheImageByDigest
+
+// CacheOptions configures CacheArtifactsWithOptions.
+type CacheOptions struct {
+	// Config supplies the release mirror, download proxy and download limits
+	// the binaries are downloaded with, and the repository the images are
+	// pulled from and the directory they're cached in. Its
+	// KubernetesVersion is ignored.
+	Config bootstrapper.KubernetesConfig
+	// Arch is the architecture of the nodes the binaries are for. Empty
+	// means constants.NodeArch.
+	Arch string
+	// HostKubectl also caches kubectl for the host, for
+	// KubernetesConfig.CacheKubectl.
+	HostKubectl bool
+	// Parallelism is how many artifacts are cached at once. Zero means
+	// DefaultCacheParallelism.
+	Parallelism int
+	// Out receives status messages about the downloads. nil means
+	// os.Stdout.
+	Out io.Writer
+	// Progress, if set, is called as each artifact starts and finishes
+	// caching. Artifacts are cached concurrently, so it must be safe to call
+	// from several goroutines.
+	Progress CacheProgressFunc
+}
+
+// CacheProgress is the progress of caching one artifact.
+type CacheProgress struct {
+	// Artifact is the name of a binary, e.g. kubelet, or an image.
+	Artifact string
+	// Finished is set once the artifact is cached, or has failed to be.
+	Finished bool
+	// Err is why the artifact couldn't be cached.
+	Err error
+}
+
+// CacheProgressFunc is called as artifacts are cached.
+type CacheProgressFunc func(CacheProgress)
+
+// CacheArtifactsError lists the artifacts CacheArtifacts couldn't cache.
+type CacheArtifactsError struct {
+	// Failed maps each artifact that wasn't cached to why.
+	Failed map[string]error
+}
+
+func (e *CacheArtifactsError) Error() string {
+	var artifacts []string
+	for a := range e.Failed {
+		artifacts = append(artifacts, a)
+	}
+	sort.Strings(artifacts)
+	lines := []string{fmt.Sprintf("caching %d artifacts failed:", len(artifacts))}
+	for _, a := range artifacts {
+		lines = append(lines, fmt.Sprintf("  %s: %s", a, e.Failed[a]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CacheArtifacts caches everything the kubeadm bootstrapper needs to start
+// a cluster of version without downloading anything: the node's kubelet,
+// kubeadm and kubectl, with their checksums, and the control plane and addon
+// images, which are loaded when images are cached. It's
+// CacheArtifactsWithOptions with the default options.
+func CacheArtifacts(version string) error {
+	return CacheArtifactsWithOptions(context.Background(), version, CacheOptions{})
+}
+
+// CacheArtifactsWithOptions is CacheArtifacts with options. Artifacts which
+// are cached already are skipped, and every artifact is tried, even once
+// one has failed. The error is a *CacheArtifactsError listing those that
+// failed.
+func CacheArtifactsWithOptions(ctx context.Context, version string, opts CacheOptions) error {
+	if !isKubernetesVersion(version) {
+		return fmt.Errorf("invalid kubernetes version %q", version)
+	}
+	k8s := opts.Config
+	k8s.KubernetesVersion = version
+	arch := opts.Arch
+	if arch == "" {
+		arch = constants.NodeArch
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultCacheParallelism
+	}
+	d := &downloader{out: opts.Out}
+
+	artifacts := map[string]func() error{}
+	for _, bin := range []string{"kubelet", "kubeadm", "kubectl"} {
+		bin := bin
+		artifacts[bin] = func() error {
+			_, err := d.maybeDownloadAndCache(ctx, bin, arch, k8s)
+			return err
+		}
+	}
+	if opts.HostKubectl {
+		artifacts[fmt.Sprintf("kubectl (%s/%s)", runtime.GOOS, runtime.GOARCH)] = func() error {
+			_, err := d.maybeDownloadAndCacheForPlatform(ctx, "kubectl", runtime.GOOS, runtime.GOARCH, k8s)
+			return err
+		}
+	}
+	for _, image := range k8s.GetKubeadmCachedImages() {
+		image := image
+		artifacts[image] = func() error {
+			return cacheImage(image, k8s.GetImageCacheDir())
+		}
+	}
+
+	report := func(p CacheProgress) {
+		if opts.Progress != nil {
+			opts.Progress(p)
+		}
+	}
+	var (
+		mu     sync.Mutex
+		failed = map[string]error{}
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, parallelism)
+	)
+	for name, cache := range artifacts {
+		name, cache := name, cache
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			report(CacheProgress{Artifact: name})
+			err := cache()
+			report(CacheProgress{Artifact: name, Finished: true, Err: err})
+			if err != nil {
+				mu.Lock()
+				failed[name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return &CacheArtifactsError{Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+func TestCacheArtifacts(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, tempDir)
+
+	// kubeadm isn't on the mirror.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bin := strings.TrimSuffix(path.Base(r.URL.Path), ".sha256")
+		if bin == "kubeadm" {
+			http.NotFound(w, r)
+			return
+		}
+		contents := bin + " binary"
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(contents)))
+			return
+		}
+		http.ServeContent(w, r, bin, time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+
+	images := constants.GetKubeadmCachedImages("", "v1.8.0")
+	failedImage := images[0]
+	defer func(f func(string, string) error) { cacheImage = f }(cacheImage)
+	var running, maxRunning int32
+	cacheImage = func(image, _ string) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if image == failedImage {
+			return errors.New("pull denied")
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	started := map[string]bool{}
+	finished := map[string]error{}
+	opts := CacheOptions{
+		Config:      bootstrapper.KubernetesConfig{ReleaseMirror: server.URL},
+		Parallelism: 2,
+		Out:         ioutil.Discard,
+		Progress: func(p CacheProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if p.Finished {
+				finished[p.Artifact] = p.Err
+			} else {
+				started[p.Artifact] = true
+			}
+		},
+	}
+	err = CacheArtifactsWithOptions(context.Background(), "v1.8.0", opts)
+	cacheErr, ok := err.(*CacheArtifactsError)
+	if !ok {
+		t.Fatalf("Expected a CacheArtifactsError, got %v", err)
+	}
+	var failed []string
+	for a := range cacheErr.Failed {
+		failed = append(failed, a)
+	}
+	sort.Strings(failed)
+	if expected := []string{failedImage, "kubeadm"}; strings.Join(failed, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v to fail, got %v", expected, failed)
+	}
+	for _, s := range []string{"kubeadm", failedImage, "pull denied"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q, got: %s", s, err)
+		}
+	}
+
+	if expected := len(images) + 3; len(started) != expected || len(finished) != expected {
+		t.Errorf("Expected progress for %d artifacts, got %d started and %d finished", expected, len(started), len(finished))
+	}
+	if finished["kubelet"] != nil || finished[failedImage] == nil {
+		t.Errorf("Expected progress to report each artifact's error, got %v", finished)
+	}
+	if maxRunning > int32(opts.Parallelism) {
+		t.Errorf("Expected at most %d artifacts to be cached at once, got %d", opts.Parallelism, maxRunning)
+	}
+
+	// What was cached can be used offline.
+	server.Close()
+	for _, bin := range []string{"kubelet", "kubectl"} {
+		k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.8.0", Offline: true}
+		if _, err := testDownloader.maybeDownloadAndCache(context.Background(), bin, constants.NodeArch, k8s); err != nil {
+			t.Errorf("Expected %s to be cached: %s", bin, err)
+		}
+	}
+}
+
+func TestCacheArtifactsInvalidVersion(t *testing.T) {
+	if err := CacheArtifactsWithOptions(context.Background(), "1.8.0", CacheOptions{}); err == nil {
+		t.Fatal("Expected an error for a version without the v prefix")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// proxyFunc returns the proxy for a request, or nil to connect directly.
+type proxyFunc func(*http.Request) (*url.URL, error)
+
+// overrideProxy returns a proxyFunc that sends every request through proxy,
+// rather than the proxy from the HTTP_PROXY and HTTPS_PROXY environment
+// variables, except for the hosts excluded by noProxy, which has the format
+// of the NO_PROXY environment variable.
+func overrideProxy(proxy *url.URL, noProxy string) proxyFunc {
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Host, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// envNoProxy returns the NO_PROXY environment variable.
+func envNoProxy() string {
+	if v := os.Getenv("NO_PROXY"); v != "" {
+		return v
+	}
+	return os.Getenv("no_proxy")
+}
+
+// bypassProxy returns whether host, which may have a port, is excluded from
+// proxying by noProxy: a comma separated list of "*", hosts, domains, IPs
+// and CIDRs. A domain matches itself and its subdomains, or only its
+// subdomains with a leading dot, as in Go's http.ProxyFromEnvironment.
+func bypassProxy(host, noProxy string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		if host == entry {
+			return true
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if ip == nil && strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newDownloadTransport returns the transport downloads use, configured like
+// http.DefaultTransport but with the given proxy, or the proxy from the
+// environment if it's nil.
+func newDownloadTransport(proxy proxyFunc) http.RoundTripper {
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	return &proxyErrorTransport{
+		proxy: proxy,
+		rt: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// proxyErrorTransport names the proxy in the errors of requests that go
+// through one, so that a misconfigured proxy is obvious.
+type proxyErrorTransport struct {
+	proxy proxyFunc
+	rt    http.RoundTripper
+}
+
+func (t *proxyErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		if p, perr := t.proxy(req); perr == nil && p != nil {
+			// Only the host, the URL may have credentials.
+			return nil, errors.Wrapf(err, "connecting through proxy %s", p.Host)
+		}
+	}
+	return resp, err
+}
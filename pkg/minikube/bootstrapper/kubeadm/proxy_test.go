@@ -0,0 +1,157 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	download "github.com/jimmidyson/go-download"
+)
+
+func TestBypassProxy(t *testing.T) {
+	cases := []struct {
+		host    string
+		noProxy string
+		bypass  bool
+	}{
+		{host: "dl.k8s.io", noProxy: "", bypass: false},
+		{host: "dl.k8s.io", noProxy: "*", bypass: true},
+		{host: "mirror.corp.example.com:8080", noProxy: "example.com", bypass: true},
+		{host: "mirror.corp.example.com", noProxy: ".corp.example.com", bypass: true},
+		{host: "example.com", noProxy: ".example.com", bypass: false},
+		{host: "notexample.com", noProxy: "example.com", bypass: false},
+		{host: "Mirror.Local", noProxy: "localhost, mirror.local", bypass: true},
+		{host: "192.168.1.20:80", noProxy: "192.168.0.0/16", bypass: true},
+		{host: "10.0.0.1", noProxy: "192.168.0.0/16,10.0.0.1", bypass: true},
+		{host: "10.0.0.2", noProxy: "192.168.0.0/16,10.0.0.1", bypass: false},
+		{host: "[::1]:8080", noProxy: "::1", bypass: true},
+	}
+
+	for _, test := range cases {
+		t.Run(test.host+" "+test.noProxy, func(t *testing.T) {
+			if bypass := bypassProxy(test.host, test.noProxy); bypass != test.bypass {
+				t.Errorf("Expected bypass %t for %s with NO_PROXY %q, got %t", test.bypass, test.host, test.noProxy, bypass)
+			}
+		})
+	}
+}
+
+func TestDownloadThroughProxy(t *testing.T) {
+	defer func(d time.Duration) { downloadBackoff = d }(downloadBackoff)
+	downloadBackoff = time.Millisecond
+
+	const contents = "kubelet binary"
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, contents)
+	}))
+	defer origin.Close()
+
+	// The proxy stub forwards requests for absolute URLs to the origin.
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(&proxied, 1)
+		resp, err := http.DefaultTransport.RoundTrip(&http.Request{Method: r.Method, URL: r.URL, Header: r.Header})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL, _ := url.Parse(closed.URL)
+	closed.Close()
+
+	cases := []struct {
+		description string
+		proxy       *url.URL
+		noProxy     string
+		proxied     bool
+		expectedErr string
+	}{
+		{
+			description: "through proxy",
+			proxy:       proxyURL,
+			proxied:     true,
+		},
+		{
+			description: "mirror in NO_PROXY",
+			proxy:       proxyURL,
+			noProxy:     "example.com,127.0.0.1",
+		},
+		{
+			description: "unreachable proxy",
+			proxy:       closedURL,
+			expectedErr: "connecting through proxy " + closedURL.Host,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "download")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(tempDir)
+			atomic.StoreInt32(&proxied, 0)
+
+			d := &downloader{out: ioutil.Discard, proxy: overrideProxy(test.proxy, test.noProxy)}
+			dst := filepath.Join(tempDir, "kubelet")
+			err = d.downloadFile(context.Background(), origin.URL+"/kubelet", dst, download.FileOptions{}, nil)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error downloading: %s", err)
+			}
+
+			if p := atomic.LoadInt32(&proxied) > 0; p != test.proxied {
+				t.Errorf("Expected proxied %t, got %t", test.proxied, p)
+			}
+			b, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatalf("Error reading download: %s", err)
+			}
+			if string(b) != contents {
+				t.Errorf("Expected download to contain %q, got %q", contents, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// registryAuthFile is the kubelet's registry credentials, in the format of
+// docker's config.json. The kubelet passes them to the container runtime
+// with each image pull, whichever runtime it is.
+const registryAuthFile = "/var/lib/kubelet/config.json"
+
+// dockerHubAuthKey is the key docker's config.json uses for Docker Hub.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+type dockerAuth struct {
+	Auth string `json:"auth"`
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+// updateRegistryCredentials replaces the node's registry credentials with
+// creds, or removes them if there are none. The credentials are only ever
+// copied, never part of a command, so that they aren't logged.
+func (k *KubeadmBootstrapper) updateRegistryCredentials(creds []bootstrapper.RegistryCredential) error {
+	if len(creds) == 0 {
+		if err := k.c.Run(bootstrapper.Sudo("rm -f " + registryAuthFile)); err != nil {
+			return errors.Wrapf(err, "removing %s", registryAuthFile)
+		}
+		return nil
+	}
+
+	f, err := registryAuthAsset(creds)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Writing registry credentials %v to %s", creds, registryAuthFile)
+	if err := k.c.Copy(f); err != nil {
+		return errors.Wrapf(err, "copying %s", registryAuthFile)
+	}
+	return nil
+}
+
+// registryAuthAsset returns creds as the kubelet's registry credentials
+// file, only readable by root.
+func registryAuthAsset(creds []bootstrapper.RegistryCredential) (*assets.MemoryAsset, error) {
+	config := dockerConfig{Auths: map[string]dockerAuth{}}
+	for _, c := range creds {
+		server := c.Server
+		if server == "docker.io" {
+			server = dockerHubAuthKey
+		}
+		config.Auths[server] = dockerAuth{Auth: base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))}
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling registry credentials")
+	}
+	return assets.NewMemoryAssetTarget(data, registryAuthFile, "0600"), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// targetRunner is a FakeCommandRunner which keeps copied files by their
+// target path.
+type targetRunner struct {
+	*bootstrapper.FakeCommandRunner
+	files map[string]string
+}
+
+func (r *targetRunner) Copy(f assets.CopyableFile) error {
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, f); err != nil {
+		return err
+	}
+	r.files[path.Join(f.GetTargetDir(), f.GetTargetName())] = b.String()
+	return nil
+}
+
+func (r *targetRunner) CopyContext(_ context.Context, f assets.CopyableFile) error {
+	return r.Copy(f)
+}
+
+func TestUpdateRegistryCredentials(t *testing.T) {
+	r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+	r.SetCommandToOutput(map[string]string{"sudo rm -f " + registryAuthFile: ""})
+	k := KubeadmBootstrapper{c: r}
+
+	cases := []struct {
+		description string
+		creds       []bootstrapper.RegistryCredential
+		expected    map[string]string
+	}{
+		{
+			description: "credentials",
+			creds: []bootstrapper.RegistryCredential{
+				{Server: "registry.example.com:5000", Username: "user", Password: "secret"},
+				{Server: "docker.io", Username: "hub", Password: "hubsecret"},
+			},
+			expected: map[string]string{
+				"registry.example.com:5000": "dXNlcjpzZWNyZXQ=",
+				dockerHubAuthKey:            "aHViOmh1YnNlY3JldA==",
+			},
+		},
+		{
+			description: "rotated credentials",
+			creds: []bootstrapper.RegistryCredential{
+				{Server: "registry.example.com:5000", Username: "user", Password: "rotated"},
+			},
+			expected: map[string]string{
+				"registry.example.com:5000": "dXNlcjpyb3RhdGVk",
+			},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if err := k.updateRegistryCredentials(test.creds); err != nil {
+				t.Fatalf("Error updating registry credentials: %s", err)
+			}
+			var config dockerConfig
+			if err := json.Unmarshal([]byte(r.files[registryAuthFile]), &config); err != nil {
+				t.Fatalf("Error parsing %s: %s", registryAuthFile, err)
+			}
+			if len(config.Auths) != len(test.expected) {
+				t.Errorf("Expected credentials for %v, got %v", test.expected, config.Auths)
+			}
+			for server, auth := range test.expected {
+				if config.Auths[server].Auth != auth {
+					t.Errorf("Expected auth %s for %s, got %s", auth, server, config.Auths[server].Auth)
+				}
+			}
+			for _, c := range test.creds {
+				if strings.Contains(fmt.Sprint(test.creds), c.Password) {
+					t.Errorf("Expected password to be redacted, got %v", test.creds)
+				}
+			}
+		})
+	}
+
+	if err := k.updateRegistryCredentials(nil); err != nil {
+		t.Errorf("Error removing registry credentials: %s", err)
+	}
+}
+
+func TestRegistryAuthAssetPermissions(t *testing.T) {
+	f, err := registryAuthAsset([]bootstrapper.RegistryCredential{{Server: "registry.example.com", Username: "user"}})
+	if err != nil {
+		t.Fatalf("Error making registry credentials: %s", err)
+	}
+	if f.GetPermissions() != "0600" {
+		t.Errorf("Expected registry credentials to only be readable by root, got %s", f.GetPermissions())
+	}
+}
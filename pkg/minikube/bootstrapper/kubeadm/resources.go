@@ -0,0 +1,108 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// controlPlaneManifests maps each of bootstrapper.ControlPlaneComponents to
+// the static pod manifest kubeadm writes for it in staticPodsDir.
+var controlPlaneManifests = map[string]string{
+	"apiserver":          "kube-apiserver.yaml",
+	"controller-manager": "kube-controller-manager.yaml",
+	"scheduler":          "kube-scheduler.yaml",
+	"etcd":               "etcd.yaml",
+}
+
+// setResourceRequests returns the static pod manifest with its container's
+// requests replaced by the given ones, leaving the rest of the manifest,
+// and requests that aren't given, as kubeadm wrote them. The manifest is
+// patched as YAML rather than as a Pod, so that fields newer kubeadms write
+// survive.
+func setResourceRequests(manifest []byte, requests bootstrapper.ResourceRequests) ([]byte, error) {
+	var pod map[string]interface{}
+	if err := yaml.Unmarshal(manifest, &pod); err != nil {
+		return nil, errors.Wrap(err, "parsing manifest")
+	}
+	spec, _ := pod["spec"].(map[string]interface{})
+	containers, _ := spec["containers"].([]interface{})
+	if len(containers) != 1 {
+		return nil, fmt.Errorf("expected a pod with one container, got %d", len(containers))
+	}
+	container, ok := containers[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid container %v", containers[0])
+	}
+
+	resources, _ := container["resources"].(map[string]interface{})
+	if resources == nil {
+		resources = map[string]interface{}{}
+		container["resources"] = resources
+	}
+	reqs, _ := resources["requests"].(map[string]interface{})
+	if reqs == nil {
+		reqs = map[string]interface{}{}
+		resources["requests"] = reqs
+	}
+	if requests.CPU != "" {
+		reqs["cpu"] = requests.CPU
+	}
+	if requests.Memory != "" {
+		reqs["memory"] = requests.Memory
+	}
+	return yaml.Marshal(pod)
+}
+
+// applyControlPlaneRequests patches the control plane's static pod
+// manifests with k8s.ControlPlaneRequests, once kubeadm has written them.
+// kubelet restarts the pods it's patched.
+func (k *KubeadmBootstrapper) applyControlPlaneRequests(k8s bootstrapper.KubernetesConfig) error {
+	var components []string
+	for component := range k8s.ControlPlaneRequests {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		manifest := path.Join(staticPodsDir, controlPlaneManifests[component])
+		current, err := k.c.CombinedOutput(bootstrapper.Sudo("cat " + manifest))
+		if err != nil {
+			return errors.Wrapf(err, "reading %s: %s", manifest, current)
+		}
+		patched, err := setResourceRequests([]byte(current), k8s.ControlPlaneRequests[component])
+		if err != nil {
+			return errors.Wrapf(err, "setting %s's resource requests", component)
+		}
+		if string(patched) == current {
+			continue
+		}
+		glog.Infof("Setting %s's resource requests to %+v", component, k8s.ControlPlaneRequests[component])
+		if err := bootstrapper.CopyAtomically(k.c, assets.NewMemoryAssetTarget(patched, manifest, "0600")); err != nil {
+			return errors.Wrapf(err, "copying %s", manifest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const schedulerManifest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-scheduler
+  namespace: kube-system
+spec:
+  containers:
+  - command:
+    - kube-scheduler
+    image: k8s.gcr.io/kube-scheduler-amd64:v1.10.0
+    name: kube-scheduler
+    resources:
+      requests:
+        cpu: 100m
+  hostNetwork: true
+`
+
+func TestSetResourceRequests(t *testing.T) {
+	cases := []struct {
+		description string
+		manifest    string
+		requests    bootstrapper.ResourceRequests
+		expected    map[string]interface{}
+		err         bool
+	}{
+		{
+			description: "replace cpu, add memory",
+			manifest:    schedulerManifest,
+			requests:    bootstrapper.ResourceRequests{CPU: "50m", Memory: "64Mi"},
+			expected:    map[string]interface{}{"cpu": "50m", "memory": "64Mi"},
+		},
+		{
+			description: "keep kubeadm's cpu",
+			manifest:    schedulerManifest,
+			requests:    bootstrapper.ResourceRequests{Memory: "64Mi"},
+			expected:    map[string]interface{}{"cpu": "100m", "memory": "64Mi"},
+		},
+		{
+			description: "no resources",
+			manifest:    strings.Replace(schedulerManifest, "    resources:\n      requests:\n        cpu: 100m\n", "", 1),
+			requests:    bootstrapper.ResourceRequests{CPU: "200m"},
+			expected:    map[string]interface{}{"cpu": "200m"},
+		},
+		{
+			description: "no containers",
+			manifest:    "apiVersion: v1\nkind: Pod\nspec: {}\n",
+			requests:    bootstrapper.ResourceRequests{CPU: "200m"},
+			err:         true,
+		},
+		{
+			description: "invalid yaml",
+			manifest:    "spec: [",
+			err:         true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			patched, err := setResourceRequests([]byte(test.manifest), test.requests)
+			if err != nil {
+				if !test.err {
+					t.Fatalf("Unexpected error setting resource requests: %s", err)
+				}
+				return
+			}
+			if test.err {
+				t.Fatalf("Expected an error, got manifest:\n%s", patched)
+			}
+
+			var pod struct {
+				Spec struct {
+					HostNetwork bool `json:"hostNetwork"`
+					Containers  []struct {
+						Image     string `json:"image"`
+						Resources struct {
+							Requests map[string]interface{} `json:"requests"`
+						} `json:"resources"`
+					} `json:"containers"`
+				} `json:"spec"`
+			}
+			if err := yaml.Unmarshal(patched, &pod); err != nil {
+				t.Fatalf("Error parsing patched manifest: %s\n%s", err, patched)
+			}
+			c := pod.Spec.Containers[0]
+			if len(c.Resources.Requests) != len(test.expected) {
+				t.Errorf("Expected requests %v, got %v", test.expected, c.Resources.Requests)
+			}
+			for k, v := range test.expected {
+				if c.Resources.Requests[k] != v {
+					t.Errorf("Expected requests %v, got %v", test.expected, c.Resources.Requests)
+				}
+			}
+			if !pod.Spec.HostNetwork || c.Image != "k8s.gcr.io/kube-scheduler-amd64:v1.10.0" {
+				t.Errorf("Expected the rest of the manifest to be kept, got:\n%s", patched)
+			}
+		})
+	}
+}
+
+func TestApplyControlPlaneRequests(t *testing.T) {
+	r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+	r.SetCommandToOutput(map[string]string{
+		"sudo cat /etc/kubernetes/manifests/kube-scheduler.yaml":                                                      schedulerManifest,
+		"sudo mv -f /etc/kubernetes/manifests/.kube-scheduler.yaml.tmp /etc/kubernetes/manifests/kube-scheduler.yaml": "",
+	})
+	k := KubeadmBootstrapper{c: r}
+
+	k8s := bootstrapper.KubernetesConfig{
+		ControlPlaneRequests: map[string]bootstrapper.ResourceRequests{"scheduler": {Memory: "64Mi"}},
+	}
+	if err := k.applyControlPlaneRequests(k8s); err != nil {
+		t.Fatalf("Error applying control plane requests: %s", err)
+	}
+	patched := r.files["/etc/kubernetes/manifests/.kube-scheduler.yaml.tmp"]
+	if !strings.Contains(patched, "memory: 64Mi") || !strings.Contains(patched, "cpu: 100m") {
+		t.Errorf("Expected the scheduler's manifest to request 64Mi of memory, got:\n%s", patched)
+	}
+
+	// kubeadm's manifests are left alone without requests.
+	if err := k.applyControlPlaneRequests(bootstrapper.KubernetesConfig{}); err != nil {
+		t.Errorf("Unexpected error applying no requests: %s", err)
+	}
+
+	k8s.ControlPlaneRequests = map[string]bootstrapper.ResourceRequests{"etcd": {CPU: "100m"}}
+	if err := k.applyControlPlaneRequests(k8s); err == nil {
+		t.Error("Expected an error when etcd's manifest can't be read, got nil")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+)
+
+// runtimeUnits are the systemd units of the container runtimes, keyed by
+// the KubernetesConfig.ContainerRuntime that selects them. Empty means
+// docker, kubelet's default.
+var runtimeUnits = map[string]string{
+	"":           "docker",
+	"docker":     "docker",
+	"containerd": "containerd",
+	"cri-o":      "crio",
+	"crio":       "crio",
+}
+
+// dockerSocket is the CRI socket of kubelet's dockershim, which kubeadm
+// registers the node with by default.
+const dockerSocket = "/var/run/dockershim.sock"
+
+// criSocket returns the CRI socket kubeadm registers the node with for the
+// container runtime, so that it needn't guess when several are installed.
+// cri-o serves it at /var/run/crio.sock in the minikube ISO, but at
+// /var/run/crio/crio.sock when installed on the host, as with the none
+// driver. Unknown runtimes get docker's, kubeadm's default.
+func criSocket(runtime, driver string) string {
+	switch runtimeUnits[runtime] {
+	case "containerd":
+		return containerdSocket
+	case "crio":
+		if driver == constants.DriverNone {
+			return "/var/run/crio/crio.sock"
+		}
+		return "/var/run/crio.sock"
+	default:
+		return dockerSocket
+	}
+}
+
+// runtimeActiveAttempts and runtimeActiveInterval bound how long
+// restartContainerRuntime waits for the runtime to become active.
+var (
+	runtimeActiveAttempts = 30
+	runtimeActiveInterval = time.Second
+)
+
+// restartContainerRuntime restarts the systemd unit of the container
+// runtime, and waits for it to become active again.
+func (k *KubeadmBootstrapper) restartContainerRuntime(runtime string) error {
+	unit, ok := runtimeUnits[runtime]
+	if !ok {
+		return fmt.Errorf("restarting container runtime %q is not supported", runtime)
+	}
+	glog.Infof("Restarting container runtime %s", unit)
+	if err := k.c.Run(bootstrapper.Sudo("systemctl restart " + unit)); err != nil {
+		return errors.Wrapf(err, "restarting %s", unit)
+	}
+	checkActive := func() error {
+		out, err := k.c.CombinedOutput(bootstrapper.Sudo("systemctl is-active " + unit))
+		if state := strings.TrimSpace(out); err != nil || state != "active" {
+			return &util.RetriableError{Err: fmt.Errorf("%s is %s", unit, state)}
+		}
+		return nil
+	}
+	if err := util.RetryAfter(runtimeActiveAttempts, checkActive, runtimeActiveInterval); err != nil {
+		return errors.Wrapf(err, "waiting for %s to become active", unit)
+	}
+	return nil
+}
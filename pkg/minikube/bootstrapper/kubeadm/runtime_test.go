@@ -0,0 +1,88 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestRestartContainerRuntime(t *testing.T) {
+	defer func(attempts int, interval time.Duration) {
+		runtimeActiveAttempts, runtimeActiveInterval = attempts, interval
+	}(runtimeActiveAttempts, runtimeActiveInterval)
+	runtimeActiveAttempts, runtimeActiveInterval = 2, 0
+
+	cases := []struct {
+		description string
+		runtime     string
+		commands    map[string]string
+		shouldErr   bool
+	}{
+		{
+			description: "default runtime",
+			commands: map[string]string{
+				"sudo systemctl restart docker":   "",
+				"sudo systemctl is-active docker": "active\n",
+			},
+		},
+		{
+			description: "containerd",
+			runtime:     "containerd",
+			commands: map[string]string{
+				"sudo systemctl restart containerd":   "",
+				"sudo systemctl is-active containerd": "active\n",
+			},
+		},
+		{
+			description: "runtime doesn't become active",
+			runtime:     "cri-o",
+			commands: map[string]string{
+				"sudo systemctl restart crio":   "",
+				"sudo systemctl is-active crio": "failed\n",
+			},
+			shouldErr: true,
+		},
+		{
+			description: "restart fails",
+			runtime:     "docker",
+			shouldErr:   true,
+		},
+		{
+			description: "unsupported runtime",
+			runtime:     "rkt",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(test.commands)
+			k := KubeadmBootstrapper{c: f}
+			err := k.restartContainerRuntime(test.runtime)
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
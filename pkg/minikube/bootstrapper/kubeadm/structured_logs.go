@@ -0,0 +1,194 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const kubeletSource = "kubelet"
+
+// journalTimeFormat is the timestamp format of journalctl's default short
+// output.
+const journalTimeFormat = "Jan 02 15:04:05"
+
+// GetClusterLogEntries returns the same logs as GetClusterLogs,
+// as structured entries: the kubelet's journal, followed by an excerpt of
+// each control plane container's logs and the output of the last kubeadm
+// init. Following isn't supported, and only the kubelet's journal is
+// returned for the previous boot.
+func (k *KubeadmBootstrapper) GetClusterLogEntries(opts bootstrapper.LogOptions) ([]bootstrapper.LogEntry, error) {
+	if opts.Follow {
+		return nil, &bootstrapper.UnsupportedLogOptionError{Option: "Follow", Reason: "structured logs can't be followed"}
+	}
+	since, err := k.getSinceTimestamp(context.Background(), opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting logs command")
+	}
+
+	out, err := k.c.CombinedOutput(getLogsCommand(opts, since) + " -o json")
+	if err := checkPreviousBoot(opts, out, err); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting cluster logs")
+	}
+	entries := parseJournalJSON(kubeletSource, out)
+	if opts.PreviousBoot {
+		return entries, nil
+	}
+
+	tail := controlPlaneTail(opts)
+	for _, component := range controlPlaneComponents {
+		ids, err := k.getContainerIDs(context.Background(), component)
+		if err != nil || len(ids) == 0 {
+			continue
+		}
+		logs, err := k.c.CombinedOutput(timestampedContainerLogsCommand(ids[0], bootstrapper.LogOptions{Tail: tail}, since))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, parseContainerLogs(component, lastLines(logs, tail))...)
+	}
+	for _, line := range splitLines(k.getInitLog(context.Background())) {
+		entries = append(entries, bootstrapper.LogEntry{Source: initLogSource, Line: line})
+	}
+	return entries, nil
+}
+
+// journalEntry holds the fields of a `journalctl -o json` entry needed to
+// reproduce its short output.
+type journalEntry struct {
+	RealtimeTimestamp string          `json:"__REALTIME_TIMESTAMP"`
+	Hostname          string          `json:"_HOSTNAME"`
+	Identifier        string          `json:"SYSLOG_IDENTIFIER"`
+	PID               string          `json:"_PID"`
+	Message           json.RawMessage `json:"MESSAGE"`
+}
+
+// parseJournalJSON parses journalctl's JSON output, one entry per line.
+// Lines that aren't valid entries, such as the "-- No entries --" marker,
+// are passed through as raw lines with a zero timestamp.
+func parseJournalJSON(source, out string) []bootstrapper.LogEntry {
+	var entries []bootstrapper.LogEntry
+	for _, line := range splitLines(out) {
+		entry, err := parseJournalEntry(line)
+		if err != nil {
+			entries = append(entries, bootstrapper.LogEntry{Source: source, Line: line})
+			continue
+		}
+		entry.Source = source
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func parseJournalEntry(line string) (bootstrapper.LogEntry, error) {
+	var j journalEntry
+	if err := json.Unmarshal([]byte(line), &j); err != nil {
+		return bootstrapper.LogEntry{}, errors.Wrap(err, "parsing journal entry")
+	}
+	usecs, err := strconv.ParseInt(j.RealtimeTimestamp, 10, 64)
+	if err != nil {
+		return bootstrapper.LogEntry{}, errors.Wrap(err, "parsing journal timestamp")
+	}
+	msg, err := journalMessage(j.Message)
+	if err != nil {
+		return bootstrapper.LogEntry{}, err
+	}
+
+	ident := j.Identifier
+	if j.PID != "" {
+		ident = fmt.Sprintf("%s[%s]", ident, j.PID)
+	}
+	return bootstrapper.LogEntry{
+		Timestamp: time.Unix(0, usecs*int64(time.Microsecond)).UTC(),
+		Line:      fmt.Sprintf("%s %s: %s", j.Hostname, ident, msg),
+	}, nil
+}
+
+// journalMessage decodes a journal MESSAGE field, which journalctl encodes
+// as an array of bytes rather than a string when it isn't valid UTF-8.
+func journalMessage(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var b []int
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return "", errors.Wrap(err, "parsing journal message")
+	}
+	msg := make([]byte, len(b))
+	for i, c := range b {
+		msg[i] = byte(c)
+	}
+	return string(msg), nil
+}
+
+// parseContainerLogs parses container logs whose lines are prefixed with
+// an RFC3339 timestamp. Lines without one are passed through as raw lines
+// with a zero timestamp.
+func parseContainerLogs(source, out string) []bootstrapper.LogEntry {
+	var entries []bootstrapper.LogEntry
+	for _, line := range splitLines(out) {
+		entry := bootstrapper.LogEntry{Source: source, Line: line}
+		if i := strings.Index(line, " "); i > 0 {
+			if ts, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+				entry.Timestamp = ts
+				entry.Line = line[i+1:]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// FormatLogEntries formats entries returned by GetClusterLogEntries as the
+// plain text returned by GetClusterLogs.
+func FormatLogEntries(entries []bootstrapper.LogEntry) string {
+	var b bytes.Buffer
+	source := kubeletSource
+	for _, e := range entries {
+		if e.Source != source {
+			source = e.Source
+			fmt.Fprintf(&b, "\n==> %s <==\n", source)
+		}
+		if source == kubeletSource && !e.Timestamp.IsZero() {
+			fmt.Fprintf(&b, "%s %s\n", e.Timestamp.UTC().Format(journalTimeFormat), e.Line)
+			continue
+		}
+		fmt.Fprintln(&b, e.Line)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const journalJSON = `{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1506968426123456","_HOSTNAME":"minikube","SYSLOG_IDENTIFIER":"kubelet","_PID":"3171","MESSAGE":"I1002 18:20:26.123456    3171 server.go:182] Version: v1.8.0"}
+{"__REALTIME_TIMESTAMP":"1506968427000000","_HOSTNAME":"minikube","SYSLOG_IDENTIFIER":"kubelet","_PID":"3171","MESSAGE":[98,97,100,255]}
+-- No entries --
+{"__REALTIME_TIMESTAMP":"not a time","MESSAGE":"broken"}
+`
+
+func TestParseJournalJSON(t *testing.T) {
+	expected := []bootstrapper.LogEntry{
+		{
+			Source:    "kubelet",
+			Timestamp: time.Date(2017, 10, 2, 18, 20, 26, 123456000, time.UTC),
+			Line:      "minikube kubelet[3171]: I1002 18:20:26.123456    3171 server.go:182] Version: v1.8.0",
+		},
+		{
+			Source:    "kubelet",
+			Timestamp: time.Date(2017, 10, 2, 18, 20, 27, 0, time.UTC),
+			Line:      "minikube kubelet[3171]: bad\xff",
+		},
+		{Source: "kubelet", Line: "-- No entries --"},
+		{Source: "kubelet", Line: `{"__REALTIME_TIMESTAMP":"not a time","MESSAGE":"broken"}`},
+	}
+	actual := parseJournalJSON("kubelet", journalJSON)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected entries.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func TestParseContainerLogs(t *testing.T) {
+	out := "2017-10-02T18:20:26.123456789Z I1002 apiserver started\nno timestamp here\n"
+	expected := []bootstrapper.LogEntry{
+		{
+			Source:    "kube-apiserver",
+			Timestamp: time.Date(2017, 10, 2, 18, 20, 26, 123456789, time.UTC),
+			Line:      "I1002 apiserver started",
+		},
+		{Source: "kube-apiserver", Line: "no timestamp here"},
+	}
+	actual := parseContainerLogs("kube-apiserver", out)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected entries.\nExpected: %+v\nActual:   %+v", expected, actual)
+	}
+}
+
+func TestGetClusterLogEntries(t *testing.T) {
+	f := bootstrapper.NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{
+		"sudo journalctl  -u kubelet -o json":            journalJSON,
+		listContainersCommand("kube-apiserver"):          "apiserver1\n",
+		listContainersCommand("kube-controller-manager"): "",
+		listContainersCommand("kube-scheduler"):          "",
+		listContainersCommand("etcd"):                    "",
+		timestampedContainerLogsCommand("apiserver1", bootstrapper.LogOptions{Tail: controlPlaneLogLines}, 0): "2017-10-02T18:20:26.123456789Z I1002 apiserver started\n",
+	})
+	k := KubeadmBootstrapper{c: f}
+
+	entries, err := k.GetClusterLogEntries(bootstrapper.LogOptions{})
+	if err != nil {
+		t.Fatalf("Error getting log entries: %s", err)
+	}
+
+	expected := `Oct 02 18:20:26 minikube kubelet[3171]: I1002 18:20:26.123456    3171 server.go:182] Version: v1.8.0
+Oct 02 18:20:27 minikube kubelet[3171]: bad` + "\xff" + `
+-- No entries --
+{"__REALTIME_TIMESTAMP":"not a time","MESSAGE":"broken"}
+
+==> kube-apiserver <==
+I1002 apiserver started
+`
+	if actual := FormatLogEntries(entries); actual != expected {
+		t.Errorf("Unexpected formatted logs.\nExpected:\n%s\nActual:\n%s", expected, actual)
+	}
+}
+
+func TestGetClusterLogEntriesFollow(t *testing.T) {
+	k := KubeadmBootstrapper{c: bootstrapper.NewFakeCommandRunner()}
+	if _, err := k.GetClusterLogEntries(bootstrapper.LogOptions{Follow: true}); err == nil {
+		t.Error("Expected an error following structured logs")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// sysctlFile persists the configured sysctls across reboots of the node.
+const sysctlFile = "/etc/sysctl.d/99-minikube.conf"
+
+// bridgeSysctlPrefix is the prefix of the sysctls which only exist once the
+// br_netfilter module is loaded.
+const bridgeSysctlPrefix = "net.bridge."
+
+// updateSysctls sets sysctls on the node and persists them to sysctlFile,
+// replacing any set before. Sysctls no longer configured keep their current
+// values until the node reboots.
+func (k *KubeadmBootstrapper) updateSysctls(sysctls map[string]string) error {
+	if len(sysctls) == 0 {
+		if err := k.c.Run(bootstrapper.Sudo("rm -f " + sysctlFile)); err != nil {
+			return errors.Wrapf(err, "removing %s", sysctlFile)
+		}
+		return nil
+	}
+
+	if err := k.c.Copy(assets.NewMemoryAssetTarget([]byte(sysctlConf(sysctls)), sysctlFile, "0644")); err != nil {
+		return errors.Wrapf(err, "copying %s", sysctlFile)
+	}
+	cmd := sysctlCommand(sysctls)
+	if err := k.c.Run(cmd); err != nil {
+		return errors.Wrapf(err, "running cmd: %s", cmd)
+	}
+	return nil
+}
+
+// sysctlKeys returns the keys of sysctls, sorted.
+func sysctlKeys(sysctls map[string]string) []string {
+	var keys []string
+	for key := range sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sysctlConf returns sysctls in sysctl.conf's format.
+func sysctlConf(sysctls map[string]string) string {
+	var b bytes.Buffer
+	b.WriteString("# Written by minikube.\n")
+	for _, key := range sysctlKeys(sysctls) {
+		fmt.Fprintf(&b, "%s = %s\n", key, sysctls[key])
+	}
+	return b.String()
+}
+
+// sysctlCommand sets sysctls, loading br_netfilter first if any of them
+// need it.
+func sysctlCommand(sysctls map[string]string) string {
+	var cmds []string
+	for _, key := range sysctlKeys(sysctls) {
+		cmds = append(cmds, fmt.Sprintf("sysctl -w '%s=%s'", key, sysctls[key]))
+	}
+	for key := range sysctls {
+		if strings.HasPrefix(key, bridgeSysctlPrefix) {
+			cmds = append([]string{"modprobe br_netfilter"}, cmds...)
+			break
+		}
+	}
+	return bootstrapper.Sudo(strings.Join(cmds, " && "))
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestUpdateSysctls(t *testing.T) {
+	cases := []struct {
+		description  string
+		sysctls      map[string]string
+		expectedConf string
+		expectedCmd  string
+	}{
+		{
+			description: "bridge",
+			sysctls: map[string]string{
+				"net.bridge.bridge-nf-call-iptables": "1",
+				"fs.inotify.max_user_watches":        "524288",
+			},
+			expectedConf: `# Written by minikube.
+fs.inotify.max_user_watches = 524288
+net.bridge.bridge-nf-call-iptables = 1
+`,
+			expectedCmd: bootstrapper.Sudo("modprobe br_netfilter && sysctl -w 'fs.inotify.max_user_watches=524288' && sysctl -w 'net.bridge.bridge-nf-call-iptables=1'"),
+		},
+		{
+			description: "no bridge",
+			sysctls: map[string]string{
+				"net.ipv4.ip_forward": "1",
+			},
+			expectedConf: `# Written by minikube.
+net.ipv4.ip_forward = 1
+`,
+			expectedCmd: "sudo sysctl -w 'net.ipv4.ip_forward=1'",
+		},
+		{
+			description: "none",
+			expectedCmd: "sudo rm -f " + sysctlFile,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := &targetRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), files: map[string]string{}}
+			r.SetCommandToOutput(map[string]string{test.expectedCmd: ""})
+			k := KubeadmBootstrapper{c: r}
+
+			if err := k.updateSysctls(test.sysctls); err != nil {
+				t.Fatalf("Error updating sysctls: %s", err)
+			}
+			if conf := r.files[sysctlFile]; conf != test.expectedConf {
+				t.Errorf("Expected %s to be:\n%s\ngot:\n%s", sysctlFile, test.expectedConf, conf)
+			}
+		})
+	}
+}
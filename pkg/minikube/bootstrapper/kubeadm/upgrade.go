@@ -0,0 +1,122 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
+)
+
+// UpgradeCluster upgrades the control plane and kubelet in place from
+// Kubernetes version from to version to using kubeadm upgrade, and checks
+// that the apiserver is running the new version afterwards.
+func (k *KubeadmBootstrapper) UpgradeCluster(from, to string, k8s bootstrapper.KubernetesConfig) error {
+	upgradeCmd, err := upgradeCommand(from, to)
+	if err != nil {
+		return err
+	}
+
+	k8s.KubernetesVersion = to
+	if err := bootstrapper.ValidateConfig(k8s); err != nil {
+		return err
+	}
+
+	kubeadmCfg, err := k.generateConfig(k8s)
+	if err != nil {
+		return errors.Wrap(err, "generating kubeadm cfg")
+	}
+	if err := k.c.Copy(assets.NewMemoryAssetTarget([]byte(kubeadmCfg), constants.KubeadmConfigFile, "0640")); err != nil {
+		return errors.Wrap(err, "transferring kubeadm config")
+	}
+
+	arch, err := k.nodeArch()
+	if err != nil {
+		return err
+	}
+	// kubeadm upgrades the control plane, which must happen before the
+	// kubelet is upgraded: a kubelet newer than the apiserver isn't supported.
+	if err := k.installBinary(context.Background(), "kubeadm", arch, k8s); err != nil {
+		return err
+	}
+	if err := k.c.Run(upgradeCmd); err != nil {
+		return errors.Wrapf(err, "running cmd: %s", upgradeCmd)
+	}
+	if err := k.installBinary(context.Background(), "kubelet", arch, k8s); err != nil {
+		return err
+	}
+	if err := k.c.Run(bootstrapper.Sudo("systemctl daemon-reload && systemctl restart kubelet")); err != nil {
+		return errors.Wrap(err, "restarting kubelet")
+	}
+
+	if err := util.RetryAfter(100, func() error { return k.checkRunningVersion(to) }, time.Millisecond*500); err != nil {
+		return errors.Wrap(err, "verifying upgraded version")
+	}
+	return nil
+}
+
+// upgradeCommand returns the kubeadm command that upgrades the control plane
+// from Kubernetes version from to version to.
+func upgradeCommand(from, to string) (string, error) {
+	fromVersion, err := semver.Make(strings.TrimPrefix(from, version.VersionPrefix))
+	if err != nil {
+		return "", errors.Wrap(err, "parsing current kubernetes version")
+	}
+	toVersion, err := semver.Make(strings.TrimPrefix(to, version.VersionPrefix))
+	if err != nil {
+		return "", errors.Wrap(err, "parsing new kubernetes version")
+	}
+	if toVersion.LT(semver.MustParse("1.8.0")) {
+		return "", fmt.Errorf("upgrading requires kubernetes v1.8.0 or later, got %s", to)
+	}
+	if !toVersion.GT(fromVersion) {
+		return "", fmt.Errorf("can't upgrade from %s to %s: not a newer version", from, to)
+	}
+	if toVersion.Major != fromVersion.Major || toVersion.Minor > fromVersion.Minor+1 {
+		return "", fmt.Errorf("can't upgrade from %s to %s: kubeadm only upgrades one minor version at a time", from, to)
+	}
+
+	// kubeadm v1.9 replaced --skip-preflight-checks with
+	// --ignore-preflight-errors.
+	preflight := "--ignore-preflight-errors=all"
+	if toVersion.LT(semver.MustParse("1.9.0")) {
+		preflight = "--skip-preflight-checks"
+	}
+	return bootstrapper.Sudo(fmt.Sprintf("/usr/bin/kubeadm upgrade apply %s --config %s %s -y", to, constants.KubeadmConfigFile, preflight)), nil
+}
+
+// checkRunningVersion returns a retriable error until the apiserver is up
+// and running the expected version.
+func (k *KubeadmBootstrapper) checkRunningVersion(expected string) error {
+	v, err := k.GetRunningVersion()
+	if err != nil {
+		return &util.RetriableError{Err: err}
+	}
+	if v != expected {
+		return &util.RetriableError{Err: fmt.Errorf("apiserver is running %s, expected %s", v, expected)}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+func TestUpgradeCommand(t *testing.T) {
+	cases := []struct {
+		description string
+		from        string
+		to          string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "patch upgrade v1.8",
+			from:        "v1.8.0",
+			to:          "v1.8.4",
+			expected:    "sudo /usr/bin/kubeadm upgrade apply v1.8.4 --config " + constants.KubeadmConfigFile + " --skip-preflight-checks -y",
+		},
+		{
+			description: "minor upgrade to v1.9",
+			from:        "v1.8.4",
+			to:          "v1.9.0",
+			expected:    "sudo /usr/bin/kubeadm upgrade apply v1.9.0 --config " + constants.KubeadmConfigFile + " --ignore-preflight-errors=all -y",
+		},
+		{
+			description: "skipping a minor version",
+			from:        "v1.7.5",
+			to:          "v1.9.0",
+			shouldErr:   true,
+		},
+		{
+			description: "downgrade",
+			from:        "v1.9.0",
+			to:          "v1.8.4",
+			shouldErr:   true,
+		},
+		{
+			description: "same version",
+			from:        "v1.8.0",
+			to:          "v1.8.0",
+			shouldErr:   true,
+		},
+		{
+			description: "before kubeadm upgrade",
+			from:        "v1.7.0",
+			to:          "v1.7.5",
+			shouldErr:   true,
+		},
+		{
+			description: "invalid version",
+			from:        "v1.8.0",
+			to:          "latest",
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			actual, err := upgradeCommand(test.from, test.to)
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error but got none: %s", actual)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected command %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -18,104 +18,70 @@ package kubeadm
 
 import (
 	"bytes"
-	"encoding/json"
+	"fmt"
 	"html/template"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
 	"github.com/pkg/errors"
-	apierrs "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/strategicpatch"
-	clientv1 "k8s.io/client-go/pkg/api/v1"
-	rbacv1beta1 "k8s.io/client-go/pkg/apis/rbac/v1beta1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/minikube/pkg/minikube/bootstrapper"
-	"k8s.io/minikube/pkg/minikube/service"
 	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
 )
 
 const masterTaint = "node-role.kubernetes.io/master"
 
-var master = ""
-
-func unmarkMaster() error {
-	k8s := service.K8s
-	client, err := k8s.GetCoreClient()
-	if err != nil {
-		return errors.Wrap(err, "getting core client")
-	}
-	n, err := client.Nodes().Get(master, v1.GetOptions{})
-	if err != nil {
-		return errors.Wrapf(err, "getting node %s", master)
-	}
-
-	oldData, err := json.Marshal(n)
-	if err != nil {
-		return errors.Wrap(err, "json marshalling data before patch")
-	}
-
-	newTaints := []clientv1.Taint{}
-	for _, taint := range n.Spec.Taints {
-		if taint.Key == masterTaint {
-			continue
-		}
-
-		newTaints = append(newTaints, taint)
-	}
-	n.Spec.Taints = newTaints
-
-	newData, err := json.Marshal(n)
-	if err != nil {
-		return errors.Wrapf(err, "json marshalling data after patch")
-	}
+var (
+	// controlPlaneAttempts and controlPlaneBackoff bound how long kubectl
+	// commands are retried for while the control plane comes up.
+	controlPlaneAttempts = 100
+	controlPlaneBackoff  = 100 * time.Millisecond
+)
 
-	patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldData, newData, clientv1.Node{})
-	if err != nil {
-		return errors.Wrap(err, "creating strategic patch")
-	}
+// masterTaintNotFoundRe matches kubectl failing to remove the master taint
+// because it's gone already, e.g.
+//
+//	error: taint "node-role.kubernetes.io/master" not found
+var masterTaintNotFoundRe = regexp.MustCompile(`taint "` + regexp.QuoteMeta(masterTaint) + `[^"]*" not found`)
 
-	if _, err := client.Nodes().Patch(n.Name, types.StrategicMergePatchType, patchBytes); err != nil {
-		if apierrs.IsConflict(err) {
-			return errors.Wrap(err, "strategic patch conflict")
-		}
-		return errors.Wrap(err, "applying strategic patch")
+// runUntilDone runs the kubectl command cmd with r, retrying while the
+// control plane comes up. Failures whose output matches done, meaning
+// there's nothing left to do, e.g. because an earlier start did it, aren't
+// errors.
+func runUntilDone(r bootstrapper.CommandRunner, cmd string, done *regexp.Regexp) error {
+	_, err := bootstrapper.RunWithRetry(r, cmd, controlPlaneAttempts, controlPlaneBackoff, func(_ error, out string) bool {
+		return done == nil || !done.MatchString(out)
+	})
+	if retryErr, ok := err.(*bootstrapper.RetryError); ok && done != nil && done.MatchString(retryErr.Output) {
+		glog.Infof("Nothing to do for %s: %s", cmd, strings.TrimSpace(retryErr.Output))
+		return nil
 	}
+	return err
+}
 
-	return nil
+// unmarkMaster removes the master taint from node, so that pods may be
+// scheduled on the cluster's only node.
+func unmarkMaster(r bootstrapper.CommandRunner, node string) error {
+	return runUntilDone(r, kubectl(fmt.Sprintf("taint nodes %s %s-", node, masterTaint)), masterTaintNotFoundRe)
 }
 
 // elevateKubeSystemPrivileges gives the kube-system service account
 // cluster admin privileges to work with RBAC.
-func elevateKubeSystemPrivileges() error {
-	k8s := service.K8s
-	client, err := k8s.GetClientset()
-	clusterRoleBinding := &rbacv1beta1.ClusterRoleBinding{
-		ObjectMeta: v1.ObjectMeta{
-			Name: "minikube-rbac",
-		},
-		Subjects: []rbacv1beta1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      "default",
-				Namespace: "kube-system",
-			},
-		},
-		RoleRef: rbacv1beta1.RoleRef{
-			Kind: "ClusterRole",
-			Name: "cluster-admin",
-		},
-	}
-
-	_, err = client.RbacV1beta1().ClusterRoleBindings().Create(clusterRoleBinding)
-	if err != nil {
-		return errors.Wrap(err, "creating clusterrolebinding")
-	}
-	return nil
+func elevateKubeSystemPrivileges(r bootstrapper.CommandRunner) error {
+	cmd := kubectl("create clusterrolebinding minikube-rbac --clusterrole=cluster-admin --serviceaccount=kube-system:default")
+	return runUntilDone(r, cmd, alreadyExistsRe)
 }
 
 const (
 	kubeconfigConf         = "kubeconfig.conf"
+	kubeProxyConfigConf    = "config.conf"
 	kubeProxyConfigmapTmpl = `apiVersion: v1
 kind: Config
 clusters:
@@ -137,20 +103,12 @@ users:
 `
 )
 
-func restartKubeProxy(k8s bootstrapper.KubernetesConfig) error {
-	client, err := util.GetClient()
-	if err != nil {
-		return errors.Wrap(err, "getting k8s client")
-	}
-
-	selector := labels.SelectorFromSet(labels.Set(map[string]string{"k8s-app": "kube-proxy"}))
-	if err := util.WaitForPodsWithLabelRunning(client, "kube-system", selector); err != nil {
-		return errors.Wrap(err, "waiting for kube-proxy to be up for configmap update")
-	}
-
-	cfgMap, err := client.CoreV1().ConfigMaps("kube-system").Get("kube-proxy", metav1.GetOptions{})
-	if err != nil {
-		return errors.Wrap(err, "getting kube-proxy configmap")
+// kubeProxyConfigMapData returns the kube-proxy configmap data for k8s.
+// Entries minikube doesn't manage are preserved.
+func kubeProxyConfigMapData(current map[string]string, k8s bootstrapper.KubernetesConfig) (map[string]string, error) {
+	data := map[string]string{}
+	for k, v := range current {
+		data[k] = v
 	}
 
 	t := template.Must(template.New("kubeProxyTmpl").Parse(kubeProxyConfigmapTmpl))
@@ -164,29 +122,179 @@ func restartKubeProxy(k8s bootstrapper.KubernetesConfig) error {
 
 	kubeconfig := bytes.Buffer{}
 	if err := t.Execute(&kubeconfig, opts); err != nil {
-		return errors.Wrap(err, "executing kube proxy configmap template")
+		return nil, errors.Wrap(err, "executing kube proxy configmap template")
+	}
+	data[kubeconfigConf] = kubeconfig.String()
+
+	if k8s.KubeProxyMetricsBindAddress == "" && k8s.KubeProxyConntrackMaxPerCore == 0 && k8s.KubeProxyMode == "" {
+		return data, nil
+	}
+
+	// Older versions of kube-proxy are configured only through flags.
+	proxyConfig, ok := data[kubeProxyConfigConf]
+	if !ok {
+		glog.Warningf("kube-proxy configmap has no %s, ignoring kube-proxy configuration", kubeProxyConfigConf)
+		return data, nil
 	}
 
-	data := map[string]string{
-		kubeconfigConf: kubeconfig.String(),
+	proxyConfig, err := patchKubeProxyConfig(proxyConfig, k8s)
+	if err != nil {
+		return nil, errors.Wrap(err, "patching kube-proxy configuration")
+	}
+	data[kubeProxyConfigConf] = proxyConfig
+
+	return data, nil
+}
+
+// patchKubeProxyConfig sets the kube-proxy options from k8s in the
+// serialized KubeProxyConfiguration.
+func patchKubeProxyConfig(proxyConfig string, k8s bootstrapper.KubernetesConfig) (string, error) {
+	cfg := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(proxyConfig), &cfg); err != nil {
+		return "", errors.Wrap(err, "unmarshalling kube-proxy configuration")
+	}
+
+	if k8s.KubeProxyMetricsBindAddress != "" {
+		cfg["metricsBindAddress"] = k8s.KubeProxyMetricsBindAddress
+	}
+	if k8s.KubeProxyMode != "" {
+		cfg["mode"] = k8s.KubeProxyMode
+	}
+	if k8s.KubeProxyConntrackMaxPerCore != 0 {
+		conntrack, ok := cfg["conntrack"].(map[string]interface{})
+		if !ok {
+			conntrack = map[string]interface{}{}
+		}
+		conntrack["maxPerCore"] = k8s.KubeProxyConntrackMaxPerCore
+		cfg["conntrack"] = conntrack
+	}
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling kube-proxy configuration")
+	}
+	return string(b), nil
+}
+
+// kubeProxyNeedsReset reports whether changing the kube-proxy configmap
+// data from current to desired requires kube-proxy's rules to be flushed.
+// Restarting kube-proxy in a new proxy mode leaves the rules installed by
+// the old mode behind.
+func kubeProxyNeedsReset(current, desired map[string]string) (bool, error) {
+	getMode := func(data map[string]string) (string, error) {
+		cfg := struct {
+			Mode string `json:"mode"`
+		}{}
+		if err := yaml.Unmarshal([]byte(data[kubeProxyConfigConf]), &cfg); err != nil {
+			return "", errors.Wrap(err, "unmarshalling kube-proxy configuration")
+		}
+		return cfg.Mode, nil
+	}
+	currentMode, err := getMode(current)
+	if err != nil {
+		return false, err
+	}
+	desiredMode, err := getMode(desired)
+	if err != nil {
+		return false, err
+	}
+	return currentMode != desiredMode, nil
+}
+
+// updateKubeProxy writes the kube-proxy configuration for k8s to its
+// configmap, and reports whether kube-proxy needs to be reset to apply it.
+func updateKubeProxy(client kubernetes.Interface, k8s bootstrapper.KubernetesConfig) (bool, error) {
+	selector := labels.SelectorFromSet(labels.Set(map[string]string{"k8s-app": "kube-proxy"}))
+	if err := util.WaitForPodsWithLabelRunning(client, "kube-system", selector); err != nil {
+		return false, errors.Wrap(err, "waiting for kube-proxy to be up for configmap update")
+	}
+
+	cfgMap, err := client.CoreV1().ConfigMaps("kube-system").Get("kube-proxy", metav1.GetOptions{})
+	if err != nil {
+		return false, errors.Wrap(err, "getting kube-proxy configmap")
+	}
+
+	data, err := kubeProxyConfigMapData(cfgMap.Data, k8s)
+	if err != nil {
+		return false, errors.Wrap(err, "generating kube-proxy configmap data")
+	}
+
+	needsReset, err := kubeProxyNeedsReset(cfgMap.Data, data)
+	if err != nil {
+		return false, errors.Wrap(err, "comparing kube-proxy configuration")
 	}
 
 	cfgMap.Data = data
 	if _, err := client.CoreV1().ConfigMaps("kube-system").Update(cfgMap); err != nil {
-		return errors.Wrap(err, "updating configmap")
+		return false, errors.Wrap(err, "updating configmap")
 	}
+	return needsReset, nil
+}
 
-	pods, err := client.CoreV1().Pods("kube-system").List(metav1.ListOptions{
-		LabelSelector: "k8s-app=kube-proxy",
-	})
+// deleteKubeProxyPods deletes the kube-proxy pods, so that they're
+// recreated with the current configuration.
+func deleteKubeProxyPods(r bootstrapper.CommandRunner) error {
+	return runUntilDone(r, kubectl("-n kube-system delete pods -l k8s-app=kube-proxy"), nil)
+}
+
+// kubeProxyCleanupCommand runs kube-proxy once on the node to remove all of
+// the iptables and ipvs rules it installed.
+func kubeProxyCleanupCommand(k8s bootstrapper.KubernetesConfig) (string, error) {
+	v, err := semver.Make(strings.TrimPrefix(k8s.KubernetesVersion, version.VersionPrefix))
 	if err != nil {
-		return errors.Wrap(err, "listing kube-proxy pods")
+		return "", errors.Wrap(err, "parsing kubernetes version")
 	}
-	for _, pod := range pods.Items {
-		if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
-			return errors.Wrapf(err, "deleting pod %+v", pod)
-		}
+	flag := "--cleanup"
+	if v.LT(semver.MustParse("1.9.0")) {
+		flag = "--cleanup-iptables"
 	}
+	image := "gcr.io/google_containers/kube-proxy-amd64:" + k8s.KubernetesVersion
+	return bootstrapper.Sudo(fmt.Sprintf("docker run --rm --privileged --net=host -v /lib/modules:/lib/modules:ro %s kube-proxy %s", image, flag)), nil
+}
 
+// restartKubeProxy applies the kube-proxy configuration for k8s by
+// restarting kube-proxy, resetting it instead if the change requires it.
+func (k *KubeadmBootstrapper) restartKubeProxy(k8s bootstrapper.KubernetesConfig) error {
+	client, err := util.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "getting k8s client")
+	}
+
+	needsReset, err := updateKubeProxy(client, k8s)
+	if err != nil {
+		return err
+	}
+	if needsReset {
+		glog.Infoln("kube-proxy mode changed, resetting kube-proxy")
+		return k.resetKubeProxy(k8s)
+	}
+	return deleteKubeProxyPods(k.c)
+}
+
+// ResetKubeProxy recreates kube-proxy with the configuration for k8s,
+// flushing the rules installed by the previous instance. Unlike a restart,
+// this doesn't leave stale rules behind when the proxy mode changes.
+func (k *KubeadmBootstrapper) ResetKubeProxy(k8s bootstrapper.KubernetesConfig) error {
+	client, err := util.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "getting k8s client")
+	}
+	if _, err := updateKubeProxy(client, k8s); err != nil {
+		return err
+	}
+	return k.resetKubeProxy(k8s)
+}
+
+func (k *KubeadmBootstrapper) resetKubeProxy(k8s bootstrapper.KubernetesConfig) error {
+	if err := deleteKubeProxyPods(k.c); err != nil {
+		return err
+	}
+	cleanupCmd, err := kubeProxyCleanupCommand(k8s)
+	if err != nil {
+		return errors.Wrap(err, "generating kube-proxy cleanup command")
+	}
+	if err := k.c.Run(cleanupCmd); err != nil {
+		return errors.Wrapf(err, "cleaning up kube-proxy rules: %s", cleanupCmd)
+	}
 	return nil
 }
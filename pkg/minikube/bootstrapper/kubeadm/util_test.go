@@ -0,0 +1,286 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeadm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+const testKubeProxyConfig = `apiVersion: kubeproxy.config.k8s.io/v1alpha1
+kind: KubeProxyConfiguration
+conntrack:
+  maxPerCore: 32768
+  min: 131072
+metricsBindAddress: 127.0.0.1:10249
+mode: ""
+`
+
+func TestKubeProxyConfigMapData(t *testing.T) {
+	cases := []struct {
+		description        string
+		k8s                bootstrapper.KubernetesConfig
+		metricsBindAddress string
+		maxPerCore         float64
+	}{
+		{
+			description:        "defaults leave configuration unchanged",
+			metricsBindAddress: "127.0.0.1:10249",
+			maxPerCore:         32768,
+		},
+		{
+			description: "metrics bind address",
+			k8s: bootstrapper.KubernetesConfig{
+				KubeProxyMetricsBindAddress: "0.0.0.0:10249",
+			},
+			metricsBindAddress: "0.0.0.0:10249",
+			maxPerCore:         32768,
+		},
+		{
+			description: "conntrack max per core",
+			k8s: bootstrapper.KubernetesConfig{
+				KubeProxyConntrackMaxPerCore: 131072,
+			},
+			metricsBindAddress: "127.0.0.1:10249",
+			maxPerCore:         131072,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			test.k8s.NodeIP = "192.168.99.100"
+			data, err := kubeProxyConfigMapData(map[string]string{
+				kubeProxyConfigConf: testKubeProxyConfig,
+				kubeconfigConf:      "stale",
+			}, test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating configmap data: %s", err)
+			}
+
+			if !strings.Contains(data[kubeconfigConf], "server: https://192.168.99.100:8443") {
+				t.Errorf("Expected kubeconfig to point at the node, got:\n%s", data[kubeconfigConf])
+			}
+
+			cfg := struct {
+				MetricsBindAddress string `json:"metricsBindAddress"`
+				Conntrack          struct {
+					MaxPerCore float64 `json:"maxPerCore"`
+					Min        float64 `json:"min"`
+				} `json:"conntrack"`
+			}{}
+			if err := yaml.Unmarshal([]byte(data[kubeProxyConfigConf]), &cfg); err != nil {
+				t.Fatalf("Error unmarshalling patched config: %s", err)
+			}
+			if cfg.MetricsBindAddress != test.metricsBindAddress {
+				t.Errorf("Expected metricsBindAddress %s, got %s", test.metricsBindAddress, cfg.MetricsBindAddress)
+			}
+			if cfg.Conntrack.MaxPerCore != test.maxPerCore {
+				t.Errorf("Expected conntrack.maxPerCore %v, got %v", test.maxPerCore, cfg.Conntrack.MaxPerCore)
+			}
+			if cfg.Conntrack.Min != 131072 {
+				t.Errorf("Expected unrelated conntrack settings to be kept, got min %v", cfg.Conntrack.Min)
+			}
+		})
+	}
+}
+
+func TestKubeProxyConfigMapDataNoConfig(t *testing.T) {
+	data, err := kubeProxyConfigMapData(nil, bootstrapper.KubernetesConfig{
+		KubeProxyMetricsBindAddress: "0.0.0.0:10249",
+	})
+	if err != nil {
+		t.Fatalf("Error generating configmap data: %s", err)
+	}
+	if _, ok := data[kubeProxyConfigConf]; ok {
+		t.Errorf("Expected no %s to be added for flag-configured kube-proxy", kubeProxyConfigConf)
+	}
+	if _, ok := data[kubeconfigConf]; !ok {
+		t.Errorf("Expected %s to be set", kubeconfigConf)
+	}
+}
+
+func TestKubeProxyNeedsReset(t *testing.T) {
+	iptables := map[string]string{kubeProxyConfigConf: "mode: iptables\n"}
+	cases := []struct {
+		description string
+		current     map[string]string
+		k8s         bootstrapper.KubernetesConfig
+		needsReset  bool
+	}{
+		{
+			description: "unchanged",
+			current:     iptables,
+		},
+		{
+			description: "same mode",
+			current:     iptables,
+			k8s:         bootstrapper.KubernetesConfig{KubeProxyMode: "iptables"},
+		},
+		{
+			description: "other settings changed",
+			current:     iptables,
+			k8s:         bootstrapper.KubernetesConfig{KubeProxyMetricsBindAddress: "0.0.0.0:10249"},
+		},
+		{
+			description: "mode changed",
+			current:     iptables,
+			k8s:         bootstrapper.KubernetesConfig{KubeProxyMode: "ipvs"},
+			needsReset:  true,
+		},
+		{
+			description: "flag configured kube-proxy",
+			current:     map[string]string{},
+			k8s:         bootstrapper.KubernetesConfig{KubeProxyMode: "ipvs"},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			desired, err := kubeProxyConfigMapData(test.current, test.k8s)
+			if err != nil {
+				t.Fatalf("Error generating configmap data: %s", err)
+			}
+			needsReset, err := kubeProxyNeedsReset(test.current, desired)
+			if err != nil {
+				t.Fatalf("Error comparing configuration: %s", err)
+			}
+			if needsReset != test.needsReset {
+				t.Errorf("Expected needsReset %t, got %t", test.needsReset, needsReset)
+			}
+		})
+	}
+}
+
+func TestKubeProxyCleanupCommand(t *testing.T) {
+	cases := map[string]string{
+		"v1.8.0": "--cleanup-iptables",
+		"v1.9.0": "--cleanup",
+	}
+	for version, flag := range cases {
+		cmd, err := kubeProxyCleanupCommand(bootstrapper.KubernetesConfig{KubernetesVersion: version})
+		if err != nil {
+			t.Fatalf("Error generating cleanup command: %s", err)
+		}
+		if !strings.HasSuffix(cmd, "kube-proxy-amd64:"+version+" kube-proxy "+flag) {
+			t.Errorf("Expected %s cleanup command to use %s, got %s", version, flag, cmd)
+		}
+	}
+}
+
+// startingControlPlaneRunner is a CommandRunner whose commands fail, like
+// kubectl against a starting apiserver, until they've been tried ready
+// times, after which they fail with output, if set, or succeed.
+type startingControlPlaneRunner struct {
+	*bootstrapper.FakeCommandRunner
+	ready    int
+	output   string
+	attempts int
+}
+
+func (r *startingControlPlaneRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	r.attempts++
+	if r.attempts < r.ready {
+		fmt.Fprintln(stderr, "The connection to the server localhost:8443 was refused")
+		return errors.New("exit status 1")
+	}
+	if r.output != "" {
+		fmt.Fprint(stderr, r.output)
+		return errors.New("exit status 1")
+	}
+	return r.FakeCommandRunner.RunWithOutput(cmd, stdout, stderr)
+}
+
+func TestSetUpControlPlane(t *testing.T) {
+	defer func(d time.Duration) { controlPlaneBackoff = d }(controlPlaneBackoff)
+	controlPlaneBackoff = time.Millisecond
+
+	unmarkCmd := kubectl("taint nodes minikube node-role.kubernetes.io/master-")
+	cases := []struct {
+		description string
+		run         func(bootstrapper.CommandRunner) error
+		cmd         string
+		ready       int
+		output      string
+		attempts    int
+		shouldErr   bool
+	}{
+		{
+			description: "unmark master",
+			run:         func(r bootstrapper.CommandRunner) error { return unmarkMaster(r, "minikube") },
+			cmd:         unmarkCmd,
+			ready:       3,
+			attempts:    3,
+		},
+		{
+			description: "unmark master already unmarked",
+			run:         func(r bootstrapper.CommandRunner) error { return unmarkMaster(r, "minikube") },
+			cmd:         unmarkCmd,
+			ready:       1,
+			output:      "error: taint \"node-role.kubernetes.io/master:\" not found\n",
+			attempts:    1,
+		},
+		{
+			description: "unmark master of unknown node",
+			run:         func(r bootstrapper.CommandRunner) error { return unmarkMaster(r, "minikube") },
+			cmd:         unmarkCmd,
+			ready:       1,
+			output:      "Error from server (NotFound): nodes \"minikube\" not found\n",
+			attempts:    controlPlaneAttempts,
+			shouldErr:   true,
+		},
+		{
+			description: "elevate privileges already elevated",
+			run:         elevateKubeSystemPrivileges,
+			cmd:         kubectl("create clusterrolebinding minikube-rbac --clusterrole=cluster-admin --serviceaccount=kube-system:default"),
+			ready:       2,
+			output:      "Error from server (AlreadyExists): clusterrolebindings.rbac.authorization.k8s.io \"minikube-rbac\" already exists\n",
+			attempts:    2,
+		},
+		{
+			description: "delete kube-proxy pods",
+			run:         deleteKubeProxyPods,
+			cmd:         kubectl("-n kube-system delete pods -l k8s-app=kube-proxy"),
+			ready:       2,
+			attempts:    2,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			f := bootstrapper.NewFakeCommandRunner()
+			f.SetCommandToOutput(map[string]string{test.cmd: ""})
+			r := &startingControlPlaneRunner{FakeCommandRunner: f, ready: test.ready, output: test.output}
+			err := test.run(r)
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+			if r.attempts != test.attempts {
+				t.Errorf("Expected %d attempts, got %d", test.attempts, r.attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+const (
+	// debugPodNamespace is where debug pods are started.
+	debugPodNamespace = "kube-system"
+	// debugPodContainer is the name of the debug pod's container.
+	debugPodContainer = "debug"
+	// debugPodImage has the chroot and tar that commands and kubectl cp
+	// need.
+	debugPodImage = "busybox:1.28"
+	// debugPodHostRoot is where the node's root filesystem is mounted in
+	// the debug pod.
+	debugPodHostRoot = "/host"
+)
+
+// debugPodTmpl is a privileged pod on a node, sharing its namespaces and
+// with its root filesystem mounted, so that commands chrooted into it run
+// much as they would on the node. It tolerates every taint, so that it can
+// be scheduled on control plane and unhealthy nodes.
+const debugPodTmpl = `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+  labels:
+    app: minikube-debug
+spec:
+  nodeName: {{.Node}}
+  hostNetwork: true
+  hostPID: true
+  hostIPC: true
+  restartPolicy: Never
+  tolerations:
+  - operator: Exists
+  containers:
+  - name: {{.Container}}
+    image: {{.Image}}
+    command: ["sleep", "2147483647"]
+    securityContext:
+      privileged: true
+    volumeMounts:
+    - name: host
+      mountPath: {{.HostRoot}}
+  volumes:
+  - name: host
+    hostPath:
+      path: /
+`
+
+var (
+	// debugPodReadyTimeout is how long to wait for a debug pod to run.
+	debugPodReadyTimeout = 2 * time.Minute
+	// debugPodReadyInterval is how often a debug pod's phase is checked.
+	debugPodReadyInterval = 2 * time.Second
+)
+
+// kubectlFunc runs kubectl with args, connected to stdin, stdout and
+// stderr, any of which may be nil, killing it if ctx is done before it
+// exits.
+type kubectlFunc func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+// KubectlExecRunner runs commands on a node through kubectl exec, in a
+// privileged debug pod, for when the node can't be reached with SSH.
+//
+// It implements the CommandRunner interface.
+type KubectlExecRunner struct {
+	// Context is the kubeconfig context of the cluster, or empty for the
+	// current context.
+	Context string
+	// Pod is the name of the debug pod, in kube-system.
+	Pod string
+
+	kubectl kubectlFunc
+}
+
+// NewKubectlExecRunner starts a debug pod on node, with the kubectl binary
+// at kubectlPath and its kubeconfig context, and returns a runner for it
+// once it's running. Close deletes the pod.
+func NewKubectlExecRunner(kubectlPath, context, node string) (*KubectlExecRunner, error) {
+	r := &KubectlExecRunner{
+		Context: context,
+		Pod:     "minikube-debug-" + node,
+		kubectl: execKubectl(kubectlPath),
+	}
+	if err := r.startDebugPod(node); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// execKubectl returns a kubectlFunc which runs the kubectl binary at path.
+func execKubectl(path string) kubectlFunc {
+	return func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		c := exec.CommandContext(ctx, path, args...)
+		c.Stdin = stdin
+		c.Stdout = stdout
+		c.Stderr = stderr
+		if err := c.Run(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// run runs kubectl with args against r's context, returning its combined
+// output.
+func (r *KubectlExecRunner) run(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	var out bytes.Buffer
+	if err := r.kubectl(ctx, r.args(args...), stdin, &out, &out); err != nil {
+		return out.String(), errors.Wrapf(err, "running kubectl %s\n output: %s", strings.Join(args, " "), out.String())
+	}
+	return out.String(), nil
+}
+
+func (r *KubectlExecRunner) args(args ...string) []string {
+	var all []string
+	if r.Context != "" {
+		all = append(all, "--context", r.Context)
+	}
+	return append(append(all, "-n", debugPodNamespace), args...)
+}
+
+// startDebugPod creates the debug pod on node, unless it exists already,
+// and waits for it to run.
+func (r *KubectlExecRunner) startDebugPod(node string) error {
+	var manifest bytes.Buffer
+	t := template.Must(template.New("debugPodTmpl").Parse(debugPodTmpl))
+	if err := t.Execute(&manifest, struct {
+		Name, Namespace, Node, Container, Image, HostRoot string
+	}{r.Pod, debugPodNamespace, node, debugPodContainer, debugPodImage, debugPodHostRoot}); err != nil {
+		return errors.Wrap(err, "rendering debug pod")
+	}
+	if _, err := r.run(context.Background(), &manifest, "apply", "-f", "-"); err != nil {
+		return errors.Wrapf(err, "creating debug pod on %s", node)
+	}
+
+	deadline := time.Now().Add(debugPodReadyTimeout)
+	for {
+		phase, err := r.run(context.Background(), nil, "get", "pod", r.Pod, "-o", "jsonpath={.status.phase}")
+		if err == nil && strings.TrimSpace(phase) == "Running" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("debug pod %s on %s not running after %s, phase %q: %v", r.Pod, node, debugPodReadyTimeout, strings.TrimSpace(phase), err)
+		}
+		time.Sleep(debugPodReadyInterval)
+	}
+}
+
+// Close deletes the debug pod.
+func (r *KubectlExecRunner) Close() error {
+	if _, err := r.run(context.Background(), nil, "delete", "pod", r.Pod, "--ignore-not-found"); err != nil {
+		return errors.Wrapf(err, "deleting debug pod %s", r.Pod)
+	}
+	return nil
+}
+
+// execArgs returns the kubectl arguments that run cmd in a bash shell in the
+// node's root filesystem.
+func (r *KubectlExecRunner) execArgs(cmd string) []string {
+	return r.args("exec", r.Pod, "-c", debugPodContainer, "--", "chroot", debugPodHostRoot, "/bin/bash", "-c", cmd)
+}
+
+// execStdinArgs is execArgs, passing kubectl's standard input to cmd.
+func (r *KubectlExecRunner) execStdinArgs(cmd string) []string {
+	return r.args("exec", "-i", r.Pod, "-c", debugPodContainer, "--", "chroot", debugPodHostRoot, "/bin/bash", "-c", cmd)
+}
+
+// Run starts the specified command on the node and waits for it to
+// complete.
+func (r *KubectlExecRunner) Run(cmd string) error {
+	return r.RunContext(context.Background(), cmd)
+}
+
+// RunContext is Run, killing kubectl if ctx is done before the command
+// completes.
+func (r *KubectlExecRunner) RunContext(ctx context.Context, cmd string) error {
+	glog.Infoln("Run:", cmd)
+	if err := r.kubectl(ctx, r.execArgs(cmd), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "running command: %s", cmd)
+	}
+	return nil
+}
+
+// CombinedOutput runs the command on the node and returns its combined
+// standard output and standard error.
+func (r *KubectlExecRunner) CombinedOutput(cmd string) (string, error) {
+	return r.CombinedOutputContext(context.Background(), cmd)
+}
+
+// CombinedOutputContext is CombinedOutput, killing kubectl if ctx is done
+// before the command completes.
+func (r *KubectlExecRunner) CombinedOutputContext(ctx context.Context, cmd string) (string, error) {
+	glog.Infoln("Run with output:", cmd)
+	var out bytes.Buffer
+	if err := r.kubectl(ctx, r.execArgs(cmd), nil, &out, &out); err != nil {
+		return "", errors.Wrapf(err, "running command: %s\n output: %s", cmd, out.String())
+	}
+	return out.String(), nil
+}
+
+// Output runs the command on the node and returns its result, with its
+// standard output and standard error apart.
+func (r *KubectlExecRunner) Output(cmd string) (*RunResult, error) {
+	return r.OutputContext(context.Background(), cmd)
+}
+
+// OutputContext is Output, killing kubectl if ctx is done before the
+// command completes.
+func (r *KubectlExecRunner) OutputContext(ctx context.Context, cmd string) (*RunResult, error) {
+	glog.Infoln("Run with separate output:", cmd)
+	var stdout, stderr bytes.Buffer
+	err := r.kubectl(ctx, r.execArgs(cmd), nil, &stdout, &stderr)
+	res := newRunResult(cmd, &stdout, &stderr, err)
+	if err != nil {
+		return res, errors.Wrapf(err, "running command: %s\n stderr: %s", cmd, res.Stderr)
+	}
+	return res, nil
+}
+
+// RunWithOutput starts the specified command on the node, streaming its
+// standard output and standard error to stdout and stderr.
+func (r *KubectlExecRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	return r.RunWithOutputContext(context.Background(), cmd, stdout, stderr)
+}
+
+// RunWithOutputContext is RunWithOutput, killing kubectl if ctx is done
+// before the command completes.
+func (r *KubectlExecRunner) RunWithOutputContext(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	glog.Infoln("Run with streaming output:", cmd)
+	if err := r.kubectl(ctx, r.execArgs(cmd), nil, stdout, stderr); err != nil {
+		return errors.Wrapf(err, "running command: %s", cmd)
+	}
+	return nil
+}
+
+// RunWithInput starts the specified command on the node, with stdin as its
+// standard input.
+func (r *KubectlExecRunner) RunWithInput(cmd string, stdin io.Reader) error {
+	glog.Infoln("Run with input:", cmd)
+	var out bytes.Buffer
+	if err := r.kubectl(context.Background(), r.execStdinArgs(cmd), stdin, &out, &out); err != nil {
+		return errors.Wrapf(err, "running command: %s\n output: %s", cmd, out.String())
+	}
+	return nil
+}
+
+// Copy copies a file to the node with kubectl cp, then sets its permissions
+// and owner. kubectl cp needs a local file, so f is written to a temporary
+// one first.
+func (r *KubectlExecRunner) Copy(f assets.CopyableFile) error {
+	return r.CopyContext(context.Background(), f)
+}
+
+// CopyContext is Copy, killing kubectl if ctx is done before the copy
+// completes.
+func (r *KubectlExecRunner) CopyContext(ctx context.Context, f assets.CopyableFile) error {
+	tmp, err := ioutil.TempFile("", "minikube-copy")
+	if err != nil {
+		return errors.Wrap(err, "creating temp file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, &contextReader{ctx: ctx, r: f}); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "writing %s to temp file", f.GetTargetName())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "closing temp file")
+	}
+
+	targetPath := path.Join(f.GetTargetDir(), f.GetTargetName())
+	if err := r.RunContext(ctx, Sudo("mkdir -p "+f.GetTargetDir())); err != nil {
+		return errors.Wrapf(err, "making dirs for %s", f.GetTargetDir())
+	}
+	dst := fmt.Sprintf("%s/%s:%s", debugPodNamespace, r.Pod, path.Join(debugPodHostRoot, targetPath))
+	if _, err := r.run(ctx, nil, "cp", tmp.Name(), dst, "-c", debugPodContainer); err != nil {
+		return errors.Wrapf(err, "copying %s", targetPath)
+	}
+	if err := r.RunContext(ctx, Sudo(fmt.Sprintf("chmod %s %s", f.GetPermissions(), targetPath))); err != nil {
+		return errors.Wrapf(err, "changing file permissions for %s", targetPath)
+	}
+	return chown(r, f)
+}
+
+// Remove removes a file from the node.
+func (r *KubectlExecRunner) Remove(f assets.CopyableFile) error {
+	return r.Run(getDeleteFileCommand(f))
+}
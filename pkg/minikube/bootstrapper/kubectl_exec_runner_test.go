@@ -0,0 +1,249 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// fakeKubectl is a kubectl backend for KubectlExecRunner which runs
+// commands in the debug pod from a map of their outputs, and records
+// manifests applied, files copied and commands' input.
+type fakeKubectl struct {
+	calls    [][]string
+	outputs  map[string]string
+	stderrs  map[string]string
+	phases   []string
+	applied  string
+	copied   map[string]string
+	commands []string
+	input    string
+}
+
+func (f *fakeKubectl) run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f.calls = append(f.calls, args)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		args = args[2:]
+	}
+	switch args[0] {
+	case "apply":
+		b, err := ioutil.ReadAll(stdin)
+		f.applied = string(b)
+		return err
+	case "get":
+		phase := f.phases[0]
+		if len(f.phases) > 1 {
+			f.phases = f.phases[1:]
+		}
+		fmt.Fprint(stdout, phase)
+	case "cp":
+		b, err := ioutil.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		f.copied[args[2]] = string(b)
+	case "exec":
+		cmd := args[len(args)-1]
+		f.commands = append(f.commands, cmd)
+		if stdin != nil {
+			b, err := ioutil.ReadAll(stdin)
+			if err != nil {
+				return err
+			}
+			f.input = string(b)
+		}
+		out, ok := f.outputs[cmd]
+		if !ok {
+			fmt.Fprint(stderr, "command not found")
+			return fmt.Errorf("exit status 127")
+		}
+		fmt.Fprint(stdout, out)
+		fmt.Fprint(stderr, f.stderrs[cmd])
+	case "delete":
+	default:
+		return fmt.Errorf("unexpected kubectl command %v", args)
+	}
+	return nil
+}
+
+func newFakeKubectlRunner(f *fakeKubectl) *KubectlExecRunner {
+	return &KubectlExecRunner{Context: "minikube", Pod: "minikube-debug-minikube", kubectl: f.run}
+}
+
+func TestKubectlExecRunnerCommands(t *testing.T) {
+	f := &fakeKubectl{outputs: map[string]string{"sudo systemctl is-active kubelet": "active\n"}}
+	r := newFakeKubectlRunner(f)
+
+	out, err := r.CombinedOutput("sudo systemctl is-active kubelet")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if out != "active\n" {
+		t.Errorf("Expected output %q, got %q", "active\n", out)
+	}
+	expected := []string{"--context", "minikube", "-n", "kube-system", "exec", "minikube-debug-minikube", "-c", "debug", "--", "chroot", "/host", "/bin/bash", "-c", "sudo systemctl is-active kubelet"}
+	if !reflect.DeepEqual(f.calls[0], expected) {
+		t.Errorf("Expected kubectl %v, got %v", expected, f.calls[0])
+	}
+
+	if err := r.Run("false"); err == nil {
+		t.Error("Expected an error for a failing command")
+	}
+	_, err = r.CombinedOutput("false")
+	if err == nil || !strings.Contains(err.Error(), "command not found") {
+		t.Errorf("Expected the error to include the output, got %v", err)
+	}
+}
+
+func TestKubectlExecRunnerOutput(t *testing.T) {
+	f := &fakeKubectl{
+		outputs: map[string]string{"kubectl get cs -o json": "{}"},
+		stderrs: map[string]string{"kubectl get cs -o json": "Warning: v1 ComponentStatus is deprecated"},
+	}
+	r := newFakeKubectlRunner(f)
+
+	res, err := r.Output("kubectl get cs -o json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if res.Stdout != "{}" {
+		t.Errorf("Expected stdout %q, got %q", "{}", res.Stdout)
+	}
+	if res.Stderr != "Warning: v1 ComponentStatus is deprecated" {
+		t.Errorf("Expected the warning on stderr, got %q", res.Stderr)
+	}
+
+	res, err = r.Output("false")
+	if err == nil || !strings.Contains(err.Error(), "command not found") {
+		t.Errorf("Expected the error to include stderr, got %v", err)
+	}
+	if res == nil || res.Stderr != "command not found" || res.Stdout != "" {
+		t.Errorf("Expected only stderr for a failing command, got %+v", res)
+	}
+}
+
+func TestKubectlExecRunnerRunWithInput(t *testing.T) {
+	f := &fakeKubectl{outputs: map[string]string{"docker load": ""}}
+	r := newFakeKubectlRunner(f)
+
+	if err := r.RunWithInput("docker load", strings.NewReader("image")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{"--context", "minikube", "-n", "kube-system", "exec", "-i", "minikube-debug-minikube", "-c", "debug", "--", "chroot", "/host", "/bin/bash", "-c", "docker load"}
+	if !reflect.DeepEqual(f.calls[0], expected) {
+		t.Errorf("Expected kubectl %v, got %v", expected, f.calls[0])
+	}
+	if f.input != "image" {
+		t.Errorf("Expected the command's input to be %q, got %q", "image", f.input)
+	}
+}
+
+func TestKubectlExecRunnerCopy(t *testing.T) {
+	f := &fakeKubectl{
+		copied: map[string]string{},
+		outputs: map[string]string{
+			"sudo mkdir -p /var/lib/kubelet":                  "",
+			"sudo chmod 0600 /var/lib/kubelet/config.json":    "",
+			"sudo chown kubelet /var/lib/kubelet/config.json": "",
+			"sudo rm /var/lib/kubelet/config.json":            "",
+		},
+	}
+	r := newFakeKubectlRunner(f)
+
+	a := assets.NewMemoryAssetTarget([]byte("{}"), "/var/lib/kubelet/config.json", "0600")
+	a.Owner = "kubelet"
+	if err := r.Copy(a); err != nil {
+		t.Fatalf("Error copying: %s", err)
+	}
+	dst := "kube-system/minikube-debug-minikube:/host/var/lib/kubelet/config.json"
+	if f.copied[dst] != "{}" {
+		t.Errorf("Expected the file to be copied to %s, got %v", dst, f.copied)
+	}
+	expected := []string{
+		"sudo mkdir -p /var/lib/kubelet",
+		"sudo chmod 0600 /var/lib/kubelet/config.json",
+		"sudo chown kubelet /var/lib/kubelet/config.json",
+	}
+	if !reflect.DeepEqual(f.commands, expected) {
+		t.Errorf("Expected commands %v, got %v", expected, f.commands)
+	}
+
+	if err := r.Remove(a); err != nil {
+		t.Errorf("Error removing: %s", err)
+	}
+}
+
+func TestKubectlExecRunnerStartDebugPod(t *testing.T) {
+	defer func(i time.Duration) { debugPodReadyInterval = i }(debugPodReadyInterval)
+	debugPodReadyInterval = time.Millisecond
+
+	f := &fakeKubectl{phases: []string{"Pending", "Pending", "Running"}}
+	r := newFakeKubectlRunner(f)
+	if err := r.startDebugPod("minikube"); err != nil {
+		t.Fatalf("Error starting debug pod: %s", err)
+	}
+	for _, s := range []string{"name: minikube-debug-minikube", "nodeName: minikube", "privileged: true", "mountPath: /host"} {
+		if !strings.Contains(f.applied, s) {
+			t.Errorf("Expected debug pod to contain %q, got:\n%s", s, f.applied)
+		}
+	}
+	if len(f.calls) != 4 {
+		t.Errorf("Expected the pod's phase to be checked until it's running, got %v", f.calls)
+	}
+
+	defer func(d time.Duration) { debugPodReadyTimeout = d }(debugPodReadyTimeout)
+	debugPodReadyTimeout = 10 * time.Millisecond
+	f = &fakeKubectl{phases: []string{"Pending"}}
+	if err := newFakeKubectlRunner(f).startDebugPod("minikube"); err == nil || !strings.Contains(err.Error(), "Pending") {
+		t.Errorf("Expected an error for a pod that never runs, got %v", err)
+	}
+}
+
+func TestKubectlExecRunnerContext(t *testing.T) {
+	f := &fakeKubectl{outputs: map[string]string{"true": ""}}
+	r := newFakeKubectlRunner(f)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := r.RunContext(ctx, "true"); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	cancel()
+	if err := r.RunContext(ctx, "true"); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected the command to be cancelled, got %v", err)
+	}
+	if _, err := r.CombinedOutputContext(ctx, "true"); errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected the command to be cancelled, got %v", err)
+	}
+}
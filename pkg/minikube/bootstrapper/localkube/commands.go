@@ -190,15 +190,21 @@ else
 fi
 `
 
-func GetLogsCommand(follow bool) (string, error) {
+// GetLogsCommand returns the command that gets the localkube logs, from the
+// journal or from its log files. The -f and -n flags mean the same to both
+// journalctl and tail.
+func GetLogsCommand(opts bootstrapper.LogOptions) (string, error) {
 	t, err := template.New("logsTemplate").Parse(logsTemplate)
 	if err != nil {
 		return "", err
 	}
 	var flags []string
-	if follow {
+	if opts.Follow {
 		flags = append(flags, "-f")
 	}
+	if opts.Tail > 0 {
+		flags = append(flags, fmt.Sprintf("-n %d", opts.Tail))
+	}
 
 	buf := bytes.Buffer{}
 	data := struct {
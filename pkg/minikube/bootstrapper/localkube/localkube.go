@@ -57,13 +57,35 @@ func NewLocalkubeBootstrapper(api libmachine.API) (*LocalkubeBootstrapper, error
 	}, nil
 }
 
-// GetClusterLogs If follow is specified, it will tail the logs
-func (lk *LocalkubeBootstrapper) GetClusterLogs(follow bool) (string, error) {
-	logsCommand, err := GetLogsCommand(follow)
+// GetClusterLogs returns the localkube logs selected by opts. When
+// following, the logs are streamed to opts.Writer and the returned string is
+// empty.
+func (lk *LocalkubeBootstrapper) GetClusterLogs(opts bootstrapper.LogOptions) (string, error) {
+	if opts.Since > 0 {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "Since", Reason: "localkube's log files can't be filtered by time"}
+	}
+	if opts.PreviousBoot {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "PreviousBoot", Reason: "localkube's log files only cover the current boot"}
+	}
+	if opts.Component != "" {
+		return "", &bootstrapper.UnsupportedLogOptionError{Option: "Component", Reason: "localkube runs all components in a single process"}
+	}
+
+	logsCommand, err := GetLogsCommand(opts)
 	if err != nil {
 		return "", errors.Wrap(err, "Error getting logs command")
 	}
 
+	if opts.Follow {
+		if opts.Writer == nil {
+			return "", errors.New("following logs requires a writer")
+		}
+		if err := lk.cmd.RunWithOutput(logsCommand, opts.Writer, opts.Writer); err != nil {
+			return "", errors.Wrap(err, "following cluster logs")
+		}
+		return "", nil
+	}
+
 	logs, err := lk.cmd.CombinedOutput(logsCommand)
 	if err != nil {
 		return "", errors.Wrap(err, "getting cluster logs")
@@ -88,6 +110,17 @@ func (lk *LocalkubeBootstrapper) GetClusterStatus() (string, error) {
 	}
 }
 
+// GetRunningVersion returns the Kubernetes version localkube is running.
+func (lk *LocalkubeBootstrapper) GetRunningVersion() (string, error) {
+	return bootstrapper.GetRunningVersion(lk.cmd)
+}
+
+// ConfigureCNI isn't supported, localkube configures the network plugin
+// itself.
+func (lk *LocalkubeBootstrapper) ConfigureCNI(plugin string, k8s bootstrapper.KubernetesConfig) error {
+	return errors.New("installing a CNI plugin isn't supported by the localkube bootstrapper")
+}
+
 // StartCluster starts a k8s cluster on the specified Host.
 func (lk *LocalkubeBootstrapper) StartCluster(kubernetesConfig bootstrapper.KubernetesConfig) error {
 	startCommand, err := GetStartCommand(kubernetesConfig)
@@ -106,9 +139,9 @@ func (lk *LocalkubeBootstrapper) RestartCluster(kubernetesConfig bootstrapper.Ku
 }
 
 func (lk *LocalkubeBootstrapper) UpdateCluster(config bootstrapper.KubernetesConfig) error {
-	if config.ShouldLoadCachedImages {
+	if config.LoadsCachedImages() {
 		// Make best effort to load any cached images
-		go machine.LoadImages(lk.cmd, constants.LocalkubeCachedImages, constants.ImageCacheDir)
+		go machine.LoadImages(lk.cmd, constants.LocalkubeCachedImages, config.GetImageCacheDir())
 	}
 
 	copyableFiles := []assets.CopyableFile{}
@@ -17,8 +17,11 @@ limitations under the License.
 package localkube
 
 import (
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/minikube/pkg/minikube/bootstrapper"
 	"k8s.io/minikube/pkg/minikube/constants"
@@ -171,11 +174,11 @@ func TestGetLocalkubeStatus(t *testing.T) {
 }
 
 func TestGetHostLogs(t *testing.T) {
-	logs, err := GetLogsCommand(false)
+	logs, err := GetLogsCommand(bootstrapper.LogOptions{})
 	if err != nil {
 		t.Fatalf("Error getting logs command: %s", err)
 	}
-	logsf, err := GetLogsCommand(true)
+	logsf, err := GetLogsCommand(bootstrapper.LogOptions{Follow: true})
 	if err != nil {
 		t.Fatalf("Error gettings logs -f command: %s", err)
 	}
@@ -208,7 +211,7 @@ func TestGetHostLogs(t *testing.T) {
 			f := bootstrapper.NewFakeCommandRunner()
 			f.SetCommandToOutput(test.logsCmdMap)
 			l := LocalkubeBootstrapper{f}
-			_, err := l.GetClusterLogs(test.follow)
+			_, err := l.GetClusterLogs(bootstrapper.LogOptions{Follow: test.follow, Writer: ioutil.Discard})
 			if err != nil && !test.shouldErr {
 				t.Errorf("Error getting localkube logs: %s", err)
 				return
@@ -221,6 +224,52 @@ func TestGetHostLogs(t *testing.T) {
 	}
 }
 
+func TestGetHostLogsOptions(t *testing.T) {
+	tail, err := GetLogsCommand(bootstrapper.LogOptions{Tail: 50})
+	if err != nil {
+		t.Fatalf("Error getting logs command: %s", err)
+	}
+	if !strings.Contains(tail, "journalctl -n 50 -u localkube") || !strings.Contains(tail, "tail -n +1 -n 50 ") {
+		t.Errorf("Expected both journalctl and tail to be limited to 50 lines, got:\n%s", tail)
+	}
+
+	cases := []struct {
+		description string
+		opts        bootstrapper.LogOptions
+		unsupported string
+	}{
+		{
+			description: "since",
+			opts:        bootstrapper.LogOptions{Since: time.Minute},
+			unsupported: "Since",
+		},
+		{
+			description: "previous boot",
+			opts:        bootstrapper.LogOptions{PreviousBoot: true},
+			unsupported: "PreviousBoot",
+		},
+		{
+			description: "component",
+			opts:        bootstrapper.LogOptions{Component: "kube-apiserver"},
+			unsupported: "Component",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			l := LocalkubeBootstrapper{bootstrapper.NewFakeCommandRunner()}
+			_, err := l.GetClusterLogs(test.opts)
+			unsupported, ok := err.(*bootstrapper.UnsupportedLogOptionError)
+			if !ok {
+				t.Fatalf("Expected UnsupportedLogOptionError, got %v", err)
+			}
+			if unsupported.Option != test.unsupported {
+				t.Errorf("Expected option %s to be unsupported, got %s", test.unsupported, unsupported.Option)
+			}
+		})
+	}
+}
+
 func TestIsLocalkubeCached(t *testing.T) {
 	tempDir := tests.MakeTempDir()
 	defer os.RemoveAll(tempDir)
@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Sudo returns cmd, run with root privileges. It's how every command asks
+// for them, and each CommandRunner decides how they're granted: SSHRunner
+// runs sudo as is, as the VM's docker user may use it without a password,
+// while ExecRunner decides by who minikube is run as.
+//
+// All of cmd is run as root, so a shell script, e.g. commands joined with
+// && or redirected to a root-owned file, is run by a root shell, and
+// mustn't ask for root privileges itself.
+func Sudo(cmd string) string {
+	if strings.ContainsAny(cmd, shellMetachars) {
+		cmd = "/bin/bash -c " + shellQuote(cmd)
+	}
+	return sudoPrefix + cmd
+}
+
+// sudoPrefix starts the commands Sudo returns, which runners look for.
+const sudoPrefix = "sudo "
+
+// shellMetachars are the characters which make a command a shell script,
+// rather than a single command sudo can run.
+const shellMetachars = ";&|<>()$`*?\n"
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// privilege is how ExecRunner grants the root privileges commands ask for.
+type privilege int
+
+const (
+	// privilegeRoot runs commands directly, as minikube is run as root,
+	// e.g. with sudo itself, which mustn't be nested.
+	privilegeRoot privilege = iota
+	// privilegeSudo runs commands with sudo -n, so that sudo fails rather
+	// than prompting for a password mid-bootstrap.
+	privilegeSudo
+	// privilegeDoas runs commands with doas -n, where sudo isn't installed.
+	privilegeDoas
+	// privilegeNone can't run commands as root at all.
+	privilegeNone
+)
+
+// execPrivilege returns how ExecRunner grants root privileges on this host.
+var execPrivilege = func() privilege {
+	if os.Geteuid() == 0 {
+		return privilegeRoot
+	}
+	if _, err := exec.LookPath("sudo"); err == nil {
+		return privilegeSudo
+	}
+	if _, err := exec.LookPath("doas"); err == nil {
+		return privilegeDoas
+	}
+	return privilegeNone
+}
+
+// elevate returns cmd, with the root privileges it asks for with Sudo
+// granted as p allows. An error with instructions is returned if cmd asks
+// for root privileges that p can't grant.
+func elevate(cmd string, p privilege) (string, error) {
+	if !strings.HasPrefix(cmd, sudoPrefix) {
+		return cmd, nil
+	}
+	privileged := strings.TrimPrefix(cmd, sudoPrefix)
+	switch p {
+	case privilegeRoot:
+		return privileged, nil
+	case privilegeSudo:
+		return "sudo -n " + privileged, nil
+	case privilegeDoas:
+		return "doas -n " + privileged, nil
+	default:
+		return "", fmt.Errorf(`running %q needs root privileges, but minikube isn't run as root, and neither sudo nor doas is installed.
+Run minikube as root, or install sudo and allow %s to run commands with it without a password`, cmd, currentUser())
+	}
+}
+
+// currentUser returns the name of the user minikube is run as, for
+// instructions.
+func currentUser() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return "your user"
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSudo(t *testing.T) {
+	cases := map[string]string{
+		"systemctl restart kubelet": "sudo systemctl restart kubelet",
+		"cat 'a b' > c":             `sudo /bin/bash -c 'cat '\''a b'\'' > c'`,
+		"rm -f /etc/foo-*":          "sudo /bin/bash -c 'rm -f /etc/foo-*'",
+	}
+	for cmd, expected := range cases {
+		if got := Sudo(cmd); got != expected {
+			t.Errorf("Sudo(%q): expected %q, got %q", cmd, expected, got)
+		}
+	}
+}
+
+func TestElevate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-privilege")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	// The fake sudo and doas print how they were run, rather than running
+	// anything.
+	for _, bin := range []string{"sudo", "doas"} {
+		script := "#!/bin/sh\necho " + bin + " \"$@\"\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, bin), []byte(script), 0755); err != nil {
+			t.Fatalf("Error writing fake %s: %s", bin, err)
+		}
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cases := []struct {
+		description string
+		privilege   privilege
+		cmd         string
+		expected    string
+	}{
+		{
+			description: "root",
+			privilege:   privilegeRoot,
+			cmd:         Sudo("echo a && echo b"),
+			expected:    "a\nb\n",
+		},
+		{
+			description: "sudo",
+			privilege:   privilegeSudo,
+			cmd:         Sudo("echo a"),
+			expected:    "sudo -n echo a\n",
+		},
+		{
+			description: "sudo script",
+			privilege:   privilegeSudo,
+			cmd:         Sudo("echo a && echo b"),
+			expected:    "sudo -n /bin/bash -c echo a && echo b\n",
+		},
+		{
+			description: "doas",
+			privilege:   privilegeDoas,
+			cmd:         Sudo("echo a"),
+			expected:    "doas -n echo a\n",
+		},
+		{
+			description: "unprivileged command",
+			privilege:   privilegeNone,
+			cmd:         "echo sudo rm",
+			expected:    "sudo rm\n",
+		},
+	}
+
+	defer func(f func() privilege) { execPrivilege = f }(execPrivilege)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			execPrivilege = func() privilege { return test.privilege }
+			out, err := (&ExecRunner{}).CombinedOutput(test.cmd)
+			if err != nil {
+				t.Fatalf("Error running %q: %s", test.cmd, err)
+			}
+			if out != test.expected {
+				t.Errorf("Expected %q to output %q, got %q", test.cmd, test.expected, out)
+			}
+		})
+	}
+
+	execPrivilege = func() privilege { return privilegeNone }
+	err = (&ExecRunner{}).Run(Sudo("echo a"))
+	if err == nil || !strings.Contains(err.Error(), "Run minikube as root") {
+		t.Errorf("Expected instructions for running commands as root, got %v", err)
+	}
+}
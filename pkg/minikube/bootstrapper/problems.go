@@ -0,0 +1,92 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LogProblem is a known problem found in the cluster logs.
+type LogProblem struct {
+	// Name is a short identifier for the problem.
+	Name string
+	// Explanation describes the problem and how to fix it.
+	Explanation string
+	// Line is the first log line the problem was found in.
+	Line string
+}
+
+// knownProblems maps log lines to the problems they indicate. To detect a
+// new problem, add an entry here and a captured log line to the tests.
+var knownProblems = []struct {
+	name        string
+	re          *regexp.Regexp
+	explanation string
+}{
+	{
+		name:        "cgroup-driver-mismatch",
+		re:          regexp.MustCompile(`misconfiguration: kubelet cgroup driver: "\w+" is different from docker cgroup driver`),
+		explanation: "The kubelet and docker use different cgroup drivers. Pass --extra-config=kubelet.CgroupDriver=<docker's driver> to minikube start.",
+	},
+	{
+		name:        "swap-enabled",
+		re:          regexp.MustCompile(`Running with swap on is not supported`),
+		explanation: "The kubelet refuses to run with swap enabled. Disable swap on the node with 'sudo swapoff -a'.",
+	},
+	{
+		name:        "kubelet-unreachable",
+		re:          regexp.MustCompile(`dial tcp [\d.]+:10250: (getsockopt: )?connection refused`),
+		explanation: "The apiserver can't reach the kubelet. Check that the kubelet is running with 'minikube ssh sudo systemctl status kubelet'.",
+	},
+	{
+		name:        "apiserver-unreachable",
+		re:          regexp.MustCompile(`dial tcp [\d.]+:8443: (getsockopt: )?connection refused`),
+		explanation: "The apiserver isn't accepting connections. It may still be starting, or crashing; check the kube-apiserver logs below.",
+	},
+	{
+		name:        "certificate-ip-mismatch",
+		re:          regexp.MustCompile(`x509: certificate is valid for .*, not `),
+		explanation: "A certificate doesn't match the address it's being used for, usually because the VM's IP changed. Run 'minikube delete' and 'minikube start' to regenerate the certificates.",
+	},
+	{
+		name:        "image-pull-failure",
+		re:          regexp.MustCompile(`Failed to pull image "[^"]+"`),
+		explanation: "An image couldn't be pulled. Check the VM's network access and any HTTP proxy settings, or use --cache-images.",
+	},
+}
+
+// AnalyzeLogs scans logs for known problems, returning each problem found
+// once, in the order they were first seen.
+func AnalyzeLogs(logs string) []LogProblem {
+	var problems []LogProblem
+	found := map[string]bool{}
+	for _, line := range strings.Split(logs, "\n") {
+		for _, p := range knownProblems {
+			if found[p.name] || !p.re.MatchString(line) {
+				continue
+			}
+			found[p.name] = true
+			problems = append(problems, LogProblem{
+				Name:        p.name,
+				Explanation: p.explanation,
+				Line:        strings.TrimSpace(line),
+			})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeLogs(t *testing.T) {
+	cases := map[string]string{
+		"cgroup-driver-mismatch":  `Oct 02 18:20:26 minikube kubelet[3171]: error: failed to run Kubelet: failed to create kubelet: misconfiguration: kubelet cgroup driver: "cgroupfs" is different from docker cgroup driver: "systemd"`,
+		"swap-enabled":            `Oct 02 18:21:01 minikube kubelet[2999]: error: failed to run Kubelet: Running with swap on is not supported, please disable swap! or set --fail-swap-on flag to false. /proc/swaps contained: [Filename Type Size Used Priority /dev/sda2 partition 1048572 0 -1]`,
+		"kubelet-unreachable":     `E1002 18:25:11.224215       1 status.go:62] apiserver received an error that is not an metav1.Status: error dialing backend: dial tcp 192.168.99.100:10250: getsockopt: connection refused`,
+		"apiserver-unreachable":   `Oct 02 18:19:52 minikube kubelet[3171]: E1002 18:19:52.451352    3171 reflector.go:205] k8s.io/kubernetes/pkg/kubelet/kubelet.go:413: Failed to list *v1.Service: Get https://192.168.99.100:8443/api/v1/services?limit=500&resourceVersion=0: dial tcp 192.168.99.100:8443: getsockopt: connection refused`,
+		"certificate-ip-mismatch": `Unable to connect to the server: x509: certificate is valid for 10.0.2.15, 10.0.0.1, not 192.168.99.101`,
+		"image-pull-failure":      `Oct 02 18:30:03 minikube kubelet[3171]: E1002 18:30:03.123457    3171 kuberuntime_manager.go:706] Failed to pull image "gcr.io/google_containers/pause-amd64:3.0": rpc error: code = Unknown desc = Error response from daemon: Get https://gcr.io/v2/: net/http: request canceled while waiting for connection`,
+	}
+
+	for name, line := range cases {
+		t.Run(name, func(t *testing.T) {
+			logs := "unrelated line\n" + line + "\n" + line + "\nanother line"
+			problems := AnalyzeLogs(logs)
+			if len(problems) != 1 {
+				t.Fatalf("Expected exactly one problem, got %+v", problems)
+			}
+			if problems[0].Name != name {
+				t.Errorf("Expected problem %s, got %s", name, problems[0].Name)
+			}
+			if problems[0].Line != line {
+				t.Errorf("Expected offending line %q, got %q", line, problems[0].Line)
+			}
+		})
+	}
+
+	if problems := AnalyzeLogs("Oct 02 18:19:52 minikube kubelet[3171]: I1002 Started kubelet"); len(problems) != 0 {
+		t.Errorf("Expected no problems in healthy logs, got %+v", problems)
+	}
+
+	all := []string{}
+	for _, line := range cases {
+		all = append(all, line)
+	}
+	if problems := AnalyzeLogs(strings.Join(all, "\n")); len(problems) != len(cases) {
+		t.Errorf("Expected %d problems, got %d", len(cases), len(problems))
+	}
+}
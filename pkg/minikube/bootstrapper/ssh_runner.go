@@ -17,10 +17,13 @@ limitations under the License.
 package bootstrapper
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
@@ -28,94 +31,264 @@ import (
 	"k8s.io/minikube/pkg/minikube/assets"
 )
 
-// SSHRunner runs commands through SSH.
+// maxSSHSessions is how many sessions an SSHRunner has open at once on its
+// connection. It's OpenSSH's default MaxSessions, beyond which sshd refuses
+// new sessions.
+const maxSSHSessions = 10
+
+var (
+	// sessionRetries is how many more times a session refused by a server
+	// with a lower MaxSessions is requested, once sessionRetryInterval has
+	// passed for others to close.
+	sessionRetries       = 20
+	sessionRetryInterval = 100 * time.Millisecond
+)
+
+// SSHRunner runs commands through SSH. Commands asking for root privileges
+// with Sudo are run as is, as the VM's docker user may use sudo without a
+// password.
+//
+// Every command is run in a session of its own, as an ssh.Session runs only
+// one command, but all sessions are multiplexed over the one connection, so
+// that its key exchange is made once. The sessions open at once are bounded,
+// so that concurrent callers, e.g. copying binaries in parallel, wait for a
+// session rather than being refused one.
 //
 // It implements the CommandRunner interface.
 type SSHRunner struct {
 	c *ssh.Client
+	// sessions holds a token for each open session.
+	sessions chan struct{}
 }
 
 // NewSSHRunner returns a new SSHRunner that will run commands
 // through the ssh.Client provided.
 func NewSSHRunner(c *ssh.Client) *SSHRunner {
-	return &SSHRunner{c}
+	return &SSHRunner{c: c, sessions: make(chan struct{}, maxSSHSessions)}
+}
+
+// newSession opens a session on the runner's connection once fewer than
+// maxSSHSessions are open, returning a func that closes it, which may be
+// called more than once. ctx's error is returned if it's done first.
+func (s *SSHRunner) newSession(ctx context.Context) (*ssh.Session, func(), error) {
+	select {
+	case s.sessions <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	for attempt := 0; ; attempt++ {
+		sess, err := s.c.NewSession()
+		if err == nil {
+			var once sync.Once
+			return sess, func() {
+				once.Do(func() {
+					sess.Close()
+					<-s.sessions
+				})
+			}, nil
+		}
+		// sshd refuses sessions beyond its MaxSessions, which may be lower
+		// than ours, until other sessions close.
+		if e, ok := err.(*ssh.OpenChannelError); !ok || e.Reason != ssh.Prohibited || attempt == sessionRetries {
+			<-s.sessions
+			return nil, nil, errors.Wrap(err, "getting ssh session")
+		}
+		select {
+		case <-time.After(sessionRetryInterval):
+		case <-ctx.Done():
+			<-s.sessions
+			return nil, nil, ctx.Err()
+		}
+	}
 }
 
 // Remove runs a command to delete a file on the remote.
 func (s *SSHRunner) Remove(f assets.CopyableFile) error {
-	sess, err := s.c.NewSession()
+	sess, closeSession, err := s.newSession(context.Background())
 	if err != nil {
-		return errors.Wrap(err, "getting ssh session")
+		return err
 	}
-	defer sess.Close()
+	defer closeSession()
 	cmd := getDeleteFileCommand(f)
 	return sess.Run(cmd)
 }
 
 // Run starts a command on the remote and waits for it to return.
 func (s *SSHRunner) Run(cmd string) error {
+	return s.RunContext(context.Background(), cmd)
+}
+
+// RunContext is Run, killing the command if ctx is done before it returns.
+func (s *SSHRunner) RunContext(ctx context.Context, cmd string) error {
 	glog.Infoln("Run:", cmd)
-	sess, err := s.c.NewSession()
-	if err != nil {
-		return errors.Wrap(err, "getting ssh session")
-	}
-	defer sess.Close()
-	return sess.Run(cmd)
+	return s.withSession(ctx, cmd, func(sess *ssh.Session) error {
+		return sess.Run(cmd)
+	})
 }
 
 // CombinedOutput runs the command on the remote and returns its combined
 // standard output and standard error.
 func (s *SSHRunner) CombinedOutput(cmd string) (string, error) {
+	return s.CombinedOutputContext(context.Background(), cmd)
+}
+
+// CombinedOutputContext is CombinedOutput, killing the command if ctx is
+// done before it returns.
+func (s *SSHRunner) CombinedOutputContext(ctx context.Context, cmd string) (string, error) {
 	glog.Infoln("Run with output:", cmd)
-	sess, err := s.c.NewSession()
-	if err != nil {
-		return "", errors.Wrap(err, "getting ssh session")
-	}
-	defer sess.Close()
-	out, err := sess.CombinedOutput(cmd)
+	var out []byte
+	err := s.withSession(ctx, cmd, func(sess *ssh.Session) error {
+		var err error
+		out, err = sess.CombinedOutput(cmd)
+		return err
+	})
 	if err != nil {
 		return "", errors.Wrapf(err, "running command: %s\n output: %s", cmd, out)
 	}
 	return string(out), nil
 }
 
-// Copy copies a file to the remote over SSH.
-func (s *SSHRunner) Copy(f assets.CopyableFile) error {
-	deleteCmd := fmt.Sprintf("sudo rm -f %s", path.Join(f.GetTargetDir(), f.GetTargetName()))
-	mkdirCmd := fmt.Sprintf("sudo mkdir -p %s", f.GetTargetDir())
-	for _, cmd := range []string{deleteCmd, mkdirCmd} {
-		if err := s.Run(cmd); err != nil {
-			return errors.Wrapf(err, "Error running command: %s", cmd)
-		}
-	}
+// Output runs the command on the remote and returns its result, with its
+// standard output and standard error apart.
+func (s *SSHRunner) Output(cmd string) (*RunResult, error) {
+	return s.OutputContext(context.Background(), cmd)
+}
 
-	sess, err := s.c.NewSession()
+// OutputContext is Output, killing the command if ctx is done before it
+// returns.
+func (s *SSHRunner) OutputContext(ctx context.Context, cmd string) (*RunResult, error) {
+	glog.Infoln("Run with separate output:", cmd)
+	var stdout, stderr bytes.Buffer
+	err := s.withSession(ctx, cmd, func(sess *ssh.Session) error {
+		sess.Stdout = &stdout
+		sess.Stderr = &stderr
+		return sess.Run(cmd)
+	})
+	res := newRunResult(cmd, &stdout, &stderr, err)
 	if err != nil {
-		return errors.Wrap(err, "Error creating new session via ssh client")
+		return res, errors.Wrapf(err, "running command: %s\n stderr: %s", cmd, res.Stderr)
 	}
+	return res, nil
+}
 
-	w, err := sess.StdinPipe()
+// withSession calls run with a new session for cmd. If ctx is done first,
+// the remote command is sent SIGKILL and the session is closed, which also
+// ends the command on servers that don't support signals, and ctx's error
+// is returned once run does.
+func (s *SSHRunner) withSession(ctx context.Context, cmd string, run func(*ssh.Session) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sess, closeSession, err := s.newSession(ctx)
 	if err != nil {
-		return errors.Wrap(err, "Error accessing StdinPipe via ssh session")
+		return err
 	}
-	// The scpcmd below *should not* return until all data is copied and the
-	// StdinPipe is closed. But let's use a WaitGroup to make it expicit.
-	var wg sync.WaitGroup
-	wg.Add(1)
+	defer closeSession()
+
+	done := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		defer w.Close()
-		header := fmt.Sprintf("C%s %d %s\n", f.GetPermissions(), f.GetLength(), f.GetTargetName())
-		fmt.Fprint(w, header)
-		io.Copy(w, f)
-		fmt.Fprint(w, "\x00")
+		done <- run(sess)
 	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		glog.Infof("Killing %q: %s", cmd, ctx.Err())
+		if err := sess.Signal(ssh.SIGKILL); err != nil {
+			glog.Warningf("Error signalling %q: %s", cmd, err)
+		}
+		sess.Close()
+		<-done
+		return ctx.Err()
+	}
+}
 
-	scpcmd := fmt.Sprintf("sudo scp -t %s", f.GetTargetDir())
-	if err := sess.Run(scpcmd); err != nil {
-		return errors.Wrapf(err, "Error running scp command: %s", scpcmd)
+// RunWithOutput runs the command on the remote, streaming its standard
+// output and standard error to stdout and stderr.
+//
+// The session is closed when the command exits or the connection drops,
+// which also terminates long-running commands such as journalctl -f.
+func (s *SSHRunner) RunWithOutput(cmd string, stdout, stderr io.Writer) error {
+	return s.RunWithOutputContext(context.Background(), cmd, stdout, stderr)
+}
+
+// RunWithOutputContext is RunWithOutput, killing the command and closing its
+// session if ctx is done before it returns.
+func (s *SSHRunner) RunWithOutputContext(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	glog.Infoln("Run with streaming output:", cmd)
+	err := s.withSession(ctx, cmd, func(sess *ssh.Session) error {
+		sess.Stdout = stdout
+		sess.Stderr = stderr
+		return sess.Run(cmd)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "running command: %s", cmd)
 	}
-	wg.Wait()
+	return nil
+}
 
+// RunWithInput runs the command on the remote, streaming stdin to its
+// standard input. The remote command sees the end of its input once stdin
+// is exhausted.
+func (s *SSHRunner) RunWithInput(cmd string, stdin io.Reader) error {
+	glog.Infoln("Run with input:", cmd)
+	sess, closeSession, err := s.newSession(context.Background())
+	if err != nil {
+		return err
+	}
+	defer closeSession()
+	sess.Stdin = stdin
+	out, err := sess.CombinedOutput(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "running command: %s\n output: %s", cmd, out)
+	}
 	return nil
 }
+
+// Copy copies a file to the remote over SSH, along with its owner.
+func (s *SSHRunner) Copy(f assets.CopyableFile) error {
+	return s.CopyContext(context.Background(), f)
+}
+
+// CopyContext is Copy, closing the scp session if ctx is done before the
+// copy completes.
+func (s *SSHRunner) CopyContext(ctx context.Context, f assets.CopyableFile) error {
+	// The target is removed and its directory made in one session, rather
+	// than one each, as a file's copy is otherwise dominated by setting up
+	// sessions.
+	cmd := Sudo(fmt.Sprintf("rm -f %s && mkdir -p %s", path.Join(f.GetTargetDir(), f.GetTargetName()), f.GetTargetDir()))
+	if err := s.RunContext(ctx, cmd); err != nil {
+		return errors.Wrapf(err, "Error running command: %s", cmd)
+	}
+
+	scpcmd := Sudo("scp -t " + f.GetTargetDir())
+	err := s.withSession(ctx, scpcmd, func(sess *ssh.Session) error {
+		w, err := sess.StdinPipe()
+		if err != nil {
+			return errors.Wrap(err, "Error accessing StdinPipe via ssh session")
+		}
+		// The scpcmd below *should not* return until all data is copied and the
+		// StdinPipe is closed. But let's use a WaitGroup to make it expicit.
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.Close()
+			header := fmt.Sprintf("C%s %d %s\n", f.GetPermissions(), f.GetLength(), f.GetTargetName())
+			fmt.Fprint(w, header)
+			io.Copy(w, &contextReader{ctx: ctx, r: f})
+			fmt.Fprint(w, "\x00")
+		}()
+
+		if err := sess.Run(scpcmd); err != nil {
+			return errors.Wrapf(err, "Error running scp command: %s", scpcmd)
+		}
+		wg.Wait()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return chown(s, f)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/minikube/pkg/minikube/tests"
+)
+
+func TestSSHRunnerTimeout(t *testing.T) {
+	s, err := tests.NewSSHServer()
+	if err != nil {
+		t.Fatalf("Error creating ssh server: %s", err)
+	}
+	s.SetCommandToOutput(map[string]string{"sleep 5": "", "echo": "\n"})
+	s.SetCommandToDelay(map[string]time.Duration{"sleep 5": 5 * time.Second})
+	port, err := s.Start()
+	if err != nil {
+		t.Fatalf("Error starting ssh server: %s", err)
+	}
+	c, err := ssh.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &ssh.ClientConfig{User: "docker"})
+	if err != nil {
+		t.Fatalf("Error connecting to ssh server: %s", err)
+	}
+	defer c.Close()
+	r := NewSSHRunner(c)
+
+	start := time.Now()
+	_, err = CombinedOutputWithTimeout(context.Background(), r, "sleep 5", 100*time.Millisecond)
+	timeoutErr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if timeoutErr.Command != "sleep 5" || timeoutErr.Elapsed < 100*time.Millisecond {
+		t.Errorf("Expected sleep 5 to time out after 100ms, got %+v", timeoutErr)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Expected the session to be closed on timeout, took %s", elapsed)
+	}
+
+	if out, err := CombinedOutputWithTimeout(context.Background(), r, "echo", time.Second); err != nil || out != "\n" {
+		t.Errorf("Expected a quick command to complete, got %q, %v", out, err)
+	}
+}
+
+func TestSSHRunnerFollow(t *testing.T) {
+	s, err := tests.NewSSHServer()
+	if err != nil {
+		t.Fatalf("Error creating ssh server: %s", err)
+	}
+	cmd := "journalctl -f"
+	s.SetCommandToOutput(map[string]string{cmd: ""})
+	s.SetCommandToDelay(map[string]time.Duration{cmd: 5 * time.Second})
+	port, err := s.Start()
+	if err != nil {
+		t.Fatalf("Error starting ssh server: %s", err)
+	}
+	c, err := ssh.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &ssh.ClientConfig{User: "docker"})
+	if err != nil {
+		t.Fatalf("Error connecting to ssh server: %s", err)
+	}
+	defer c.Close()
+	r := NewSSHRunner(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var out bytes.Buffer
+	err = r.RunWithOutputContext(ctx, cmd, &out, &out)
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Expected following to be stopped, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Expected the session to be closed on cancel, took %s", elapsed)
+	}
+}
+
+func TestSSHRunnerSessions(t *testing.T) {
+	s, err := tests.NewSSHServer()
+	if err != nil {
+		t.Fatalf("Error creating ssh server: %s", err)
+	}
+	s.SetCommandToOutput(map[string]string{"echo": "\n"})
+	port, err := s.Start()
+	if err != nil {
+		t.Fatalf("Error starting ssh server: %s", err)
+	}
+	c, err := ssh.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port), &ssh.ClientConfig{User: "docker"})
+	if err != nil {
+		t.Fatalf("Error connecting to ssh server: %s", err)
+	}
+	defer c.Close()
+
+	// Commands run concurrently, e.g. copying binaries, share the
+	// connection, waiting for sessions beyond maxSSHSessions.
+	r := NewSSHRunner(c)
+	var g errgroup.Group
+	for i := 0; i < 3*maxSSHSessions; i++ {
+		g.Go(func() error {
+			_, err := r.CombinedOutput("echo")
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("Error running commands concurrently: %s", err)
+	}
+
+	r = &SSHRunner{c: c, sessions: make(chan struct{}, 1)}
+	_, closeSession, err := r.newSession(context.Background())
+	if err != nil {
+		t.Fatalf("Error opening session: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := r.newSession(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected to wait for the open session to close, got %v", err)
+	}
+	closeSession()
+	closeSession()
+	if out, err := r.CombinedOutput("echo"); err != nil || out != "\n" {
+		t.Errorf("Expected a session once the open one closed, got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,477 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientv1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
+)
+
+// bootstrapTokenRe matches the token format kubeadm accepts.
+var bootstrapTokenRe = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
+
+// sysctlKeyRe and sysctlValueRe match the sysctls which can be set, which
+// excludes values with quotes or newlines that would break the sysctl
+// command or config file.
+var (
+	sysctlKeyRe   = regexp.MustCompile(`^[a-z0-9_]+([./][a-zA-Z0-9_-]+)+$`)
+	sysctlValueRe = regexp.MustCompile(`^[^'"\n\\]+$`)
+)
+
+// journaldSizeRe matches the sizes journald accepts, e.g. 500M.
+var journaldSizeRe = regexp.MustCompile(`^[0-9]+[KMGTPE]?$`)
+
+// ValidateConfig checks k8s before it's used to configure the node, so that
+// a bad config fails fast instead of leaving the node half configured. All
+// of the problems found are returned together.
+func ValidateConfig(k8s KubernetesConfig) error {
+	m := util.MultiError{}
+
+	var v *semver.Version
+	if !strings.HasPrefix(k8s.KubernetesVersion, version.VersionPrefix) {
+		m.Collect(fmt.Errorf("kubernetes version must start with %q: %q", version.VersionPrefix, k8s.KubernetesVersion))
+	} else if parsed, err := semver.Make(strings.TrimPrefix(k8s.KubernetesVersion, version.VersionPrefix)); err != nil {
+		m.Collect(errors.Wrapf(err, "invalid kubernetes version %q", k8s.KubernetesVersion))
+	} else {
+		v = &parsed
+	}
+
+	if k8s.NodeIP != "" && net.ParseIP(k8s.NodeIP) == nil {
+		m.Collect(fmt.Errorf("invalid node IP %q", k8s.NodeIP))
+	}
+	if k8s.NodeName != "" {
+		m.Collect(validateDNSName("node name", k8s.NodeName))
+	}
+	if k8s.DNSDomain != "" {
+		m.Collect(validateDNSName("DNS domain", k8s.DNSDomain))
+	}
+
+	m.Collect(k8s.ValidateCertDir())
+
+	if k8s.KubeProxyMetricsBindAddress != "" {
+		m.Collect(validateHostPort("kube-proxy metrics bind address", k8s.KubeProxyMetricsBindAddress))
+	}
+	if k8s.KubeProxyConntrackMaxPerCore < 0 {
+		m.Collect(fmt.Errorf("kube-proxy conntrack max per core must not be negative: %d", k8s.KubeProxyConntrackMaxPerCore))
+	}
+
+	for _, a := range k8s.HostAliases {
+		if net.ParseIP(a.IP) == nil {
+			m.Collect(fmt.Errorf("invalid host alias IP %q", a.IP))
+		}
+		if len(a.Hostnames) == 0 {
+			m.Collect(fmt.Errorf("host alias for %s has no hostnames", a.IP))
+		}
+		for _, h := range a.Hostnames {
+			m.Collect(validateDNSName("host alias hostname", h))
+		}
+	}
+
+	for key, value := range k8s.Sysctls {
+		if !sysctlKeyRe.MatchString(key) {
+			m.Collect(fmt.Errorf("invalid sysctl %q", key))
+		}
+		if !sysctlValueRe.MatchString(value) {
+			m.Collect(fmt.Errorf("invalid value %q for sysctl %s", value, key))
+		}
+	}
+
+	for name, value := range k8s.KubeletFeatureGates {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "=,") {
+			m.Collect(fmt.Errorf("invalid kubelet feature gate %q", name))
+		}
+		if _, err := strconv.ParseBool(value); err != nil {
+			m.Collect(fmt.Errorf("invalid value %q for kubelet feature gate %s, expected true or false", value, name))
+		}
+	}
+
+	staticPods := map[string]bool{}
+	for _, p := range k8s.StaticPodManifests {
+		m.Collect(validateStaticPodManifest(p))
+		if name := filepath.Base(p); staticPods[name] {
+			m.Collect(fmt.Errorf("static pod manifests must have different file names, %s is repeated", name))
+		}
+		staticPods[filepath.Base(p)] = true
+	}
+
+	servers := map[string]bool{}
+	for _, r := range k8s.RegistryCredentials {
+		if r.Server == "" || strings.Contains(r.Server, "/") {
+			m.Collect(fmt.Errorf("invalid registry %q for credentials, expected host[:port]", r.Server))
+		}
+		if r.Username == "" {
+			m.Collect(fmt.Errorf("credentials for registry %s have no username", r.Server))
+		}
+		if servers[r.Server] {
+			m.Collect(fmt.Errorf("duplicate credentials for registry %s", r.Server))
+		}
+		servers[r.Server] = true
+	}
+
+	if k8s.ImageRepository != "" {
+		m.Collect(validateImageRepository(k8s.ImageRepository))
+	}
+	if k8s.PauseImage != "" {
+		m.Collect(validatePauseImage(k8s.PauseImage))
+	}
+
+	for _, name := range k8s.CustomBinaries() {
+		m.Collect(validateBinaryOverride(name, k8s.BinaryOverrides[name]))
+	}
+
+	if k8s.DownloadTimeout < 0 || k8s.DownloadStallBytes < 0 || k8s.DownloadStallPeriod < 0 {
+		m.Collect(errors.New("download timeout and stall limits must not be negative"))
+	}
+
+	if k8s.ReleaseMirror != "" {
+		if u, err := url.Parse(k8s.ReleaseMirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			m.Collect(fmt.Errorf("invalid release mirror %q, must be an http or https URL", k8s.ReleaseMirror))
+		}
+	}
+
+	for _, mirror := range k8s.RegistryMirrors {
+		if u, err := url.Parse(mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			m.Collect(fmt.Errorf("invalid registry mirror %q, must be an http or https URL", mirror))
+		}
+	}
+
+	if k8s.ImageCacheDir != "" && !filepath.IsAbs(k8s.ImageCacheDir) {
+		m.Collect(fmt.Errorf("invalid image cache directory %q, must be an absolute path", k8s.ImageCacheDir))
+	}
+
+	if k8s.ContainerLogMaxSize != "" {
+		if _, err := resource.ParseQuantity(k8s.ContainerLogMaxSize); err != nil {
+			m.Collect(fmt.Errorf("invalid container log max size %q, expected a quantity such as 10Mi", k8s.ContainerLogMaxSize))
+		}
+	}
+	// kubelet needs to keep the log being written and a rotated one.
+	if k8s.ContainerLogMaxFiles != 0 && k8s.ContainerLogMaxFiles < 2 {
+		m.Collect(fmt.Errorf("container log max files must be at least 2: %d", k8s.ContainerLogMaxFiles))
+	}
+	if k8s.JournaldMaxUse != "" && !journaldSizeRe.MatchString(k8s.JournaldMaxUse) {
+		m.Collect(fmt.Errorf("invalid journald max use %q, expected a size such as 500M", k8s.JournaldMaxUse))
+	}
+
+	for component, requests := range k8s.ControlPlaneRequests {
+		m.Collect(validateControlPlaneRequests(component, requests))
+	}
+
+	switch k8s.CgroupDriver {
+	case "", CgroupDriverCgroupfs, CgroupDriverSystemd:
+	default:
+		m.Collect(fmt.Errorf("invalid cgroup driver %q, must be %s or %s", k8s.CgroupDriver, CgroupDriverCgroupfs, CgroupDriverSystemd))
+	}
+
+	if k8s.DownloadProxy != "" {
+		if u, err := url.Parse(k8s.DownloadProxy); err != nil || u.Scheme == "" || u.Host == "" {
+			m.Collect(fmt.Errorf("invalid download proxy %q, must be a URL such as http://proxy.example.com:3128", k8s.DownloadProxy))
+		}
+	}
+
+	switch k8s.BinaryDownload {
+	case "", BinaryDownloadAuto, BinaryDownloadNode, BinaryDownloadHost:
+	default:
+		m.Collect(fmt.Errorf("invalid binary download %q, must be one of %s, %s or %s", k8s.BinaryDownload, BinaryDownloadAuto, BinaryDownloadNode, BinaryDownloadHost))
+	}
+	if k8s.BinaryDownload == BinaryDownloadNode && k8s.Offline {
+		m.Collect(fmt.Errorf("binaries can't be downloaded on the node in offline mode"))
+	}
+	if k8s.RequireCachedImages && !k8s.LoadsCachedImages() {
+		m.Collect(fmt.Errorf("cached images can't be required when they aren't loaded"))
+	}
+	if k8s.ForceVerify && k8s.Offline {
+		m.Collect(fmt.Errorf("binaries can't be verified against their published checksums in offline mode"))
+	}
+
+	m.Collect(validateCIDRs(k8s))
+	if k8s.IsDualStack() && v != nil && v.LT(dualStackVersion) {
+		m.Collect(fmt.Errorf("dual-stack requires kubernetes v%s or later, got %s", dualStackVersion, k8s.KubernetesVersion))
+	}
+
+	if k8s.BootstrapToken != "" && !bootstrapTokenRe.MatchString(k8s.BootstrapToken) {
+		m.Collect(fmt.Errorf("invalid bootstrap token %q, must be of the form [a-z0-9]{6}.[a-z0-9]{16}", k8s.BootstrapToken))
+	}
+	if k8s.ControlPlaneTimeout < 0 {
+		m.Collect(fmt.Errorf("control plane timeout must not be negative: %s", k8s.ControlPlaneTimeout))
+	}
+	if k8s.ClusterSigningDuration < 0 {
+		m.Collect(fmt.Errorf("cluster signing duration must not be negative: %s", k8s.ClusterSigningDuration))
+	}
+	if k8s.ServiceNodePortRange != "" {
+		m.Collect(validatePortRange("service node port range", k8s.ServiceNodePortRange))
+	}
+	if len(k8s.KubeletConfiguration) > 0 {
+		m.Collect(validateKubeletConfiguration(k8s.KubeletConfiguration))
+		if v != nil && v.LT(kubeletConfigurationVersion) {
+			m.Collect(fmt.Errorf("a kubelet configuration requires kubernetes v%s or later, got %s", kubeletConfigurationVersion, k8s.KubernetesVersion))
+		}
+	}
+	if k8s.BootstrapTokenTTL < 0 {
+		m.Collect(fmt.Errorf("bootstrap token TTL must not be negative: %s", k8s.BootstrapTokenTTL))
+	}
+
+	for _, e := range k8s.ExtraOptions {
+		m.Collect(validateExtraOption(e))
+	}
+
+	if err := m.ToError(); err != nil {
+		return errors.Wrap(err, "invalid kubernetes config")
+	}
+	return nil
+}
+
+// dualStackVersion is the first Kubernetes version supporting dual-stack.
+var dualStackVersion = semver.MustParse("1.16.0")
+
+// kubeletConfigurationVersion is the first Kubernetes version whose kubeadm
+// configures kubelet with a KubeletConfiguration.
+var kubeletConfigurationVersion = semver.MustParse("1.12.0")
+
+// validateKubeletConfiguration checks that cfg's apiVersion and kind, if
+// set, are those of a KubeletConfiguration.
+func validateKubeletConfiguration(cfg map[string]interface{}) error {
+	for field, expected := range map[string]string{"apiVersion": KubeletConfigurationAPIVersion, "kind": KubeletConfigurationKind} {
+		if value, ok := cfg[field]; ok && value != expected {
+			return fmt.Errorf("invalid kubelet configuration %s %v, must be %s", field, value, expected)
+		}
+	}
+	return nil
+}
+
+// validateCIDRs checks that the service and pod CIDRs are each a single
+// range, or an IPv4 and an IPv6 range, and that their families match. The
+// first service range must be IPv4 and contain the apiserver's and DNS's
+// service IPs, which are fixed.
+func validateCIDRs(k8s KubernetesConfig) error {
+	services, err := parseCIDRs("service CIDR", k8s.GetServiceCIDR())
+	if err != nil {
+		return err
+	}
+	for _, ip := range []string{util.DefaultServiceClusterIP, util.DefaultDNSIP} {
+		if !services[0].Contains(net.ParseIP(ip)) {
+			return fmt.Errorf("invalid service CIDR %q, the first range must contain %s", k8s.GetServiceCIDR(), ip)
+		}
+	}
+	if k8s.PodCIDR == "" {
+		return nil
+	}
+	pods, err := parseCIDRs("pod CIDR", k8s.PodCIDR)
+	if err != nil {
+		return err
+	}
+	if len(pods) != len(services) {
+		return fmt.Errorf("pod CIDR %q and service CIDR %q must both be single or dual-stack", k8s.PodCIDR, k8s.GetServiceCIDR())
+	}
+	for i := range pods {
+		if isIPv4(pods[i]) != isIPv4(services[i]) {
+			return fmt.Errorf("pod CIDR %q must list the same IP families as service CIDR %q, in the same order", k8s.PodCIDR, k8s.GetServiceCIDR())
+		}
+	}
+	return nil
+}
+
+// parseCIDRs parses a CIDR, or an IPv4 and an IPv6 CIDR separated by a
+// comma.
+func parseCIDRs(field, s string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, c := range strings.Split(s, ",") {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", field, s)
+		}
+		cidrs = append(cidrs, n)
+	}
+	if len(cidrs) > 2 || (len(cidrs) == 2 && isIPv4(cidrs[0]) == isIPv4(cidrs[1])) {
+		return nil, fmt.Errorf("invalid %s %q, must be a single range or an IPv4 and an IPv6 range", field, s)
+	}
+	return cidrs, nil
+}
+
+func isIPv4(n *net.IPNet) bool {
+	return n.IP.To4() != nil
+}
+
+func validateDNSName(field, name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid %s %q: %s", field, name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateBinaryOverride checks that the binary overriding name is a file
+// on the host.
+func validateBinaryOverride(name, path string) error {
+	overridable := false
+	for _, b := range OverridableBinaries {
+		overridable = overridable || b == name
+	}
+	if !overridable {
+		return fmt.Errorf("%s can't be overridden, only %s can", name, strings.Join(OverridableBinaries, ", "))
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "%s override", name)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s override %s is not a file", name, path)
+	}
+	return nil
+}
+
+// validateImageRepository checks that repo is a registry, optionally with a
+// path, which image names can be appended to.
+func validateImageRepository(repo string) error {
+	invalid := fmt.Errorf("invalid image repository %q, expected host[:port][/path]", repo)
+	if strings.Contains(repo, "://") || strings.ContainsAny(repo, " @") {
+		return invalid
+	}
+	parts := strings.Split(strings.TrimSuffix(repo, "/"), "/")
+	for i, part := range parts {
+		// Only the registry's host may have a port; elsewhere it'd be a tag.
+		if part == "" || i > 0 && strings.Contains(part, ":") {
+			return invalid
+		}
+	}
+	return nil
+}
+
+// validatePauseImage checks that image is a name in an image repository
+// with a tag, as kubelet needs to know exactly which pause image to run.
+func validatePauseImage(image string) error {
+	i := strings.LastIndex(image, ":")
+	if i <= strings.LastIndex(image, "/") || i == len(image)-1 || validateImageRepository(image[:i]) != nil {
+		return fmt.Errorf("invalid pause image %q, expected [host[:port]/path/]name:tag", image)
+	}
+	return nil
+}
+
+// validateControlPlaneRequests checks that component is one of
+// ControlPlaneComponents, and that its requests are quantities.
+func validateControlPlaneRequests(component string, requests ResourceRequests) error {
+	known := false
+	for _, c := range ControlPlaneComponents {
+		known = known || c == component
+	}
+	if !known {
+		return fmt.Errorf("can't set the resources of %s, only of %s", component, strings.Join(ControlPlaneComponents, ", "))
+	}
+	for name, q := range map[string]string{"cpu": requests.CPU, "memory": requests.Memory} {
+		if q == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(q); err != nil {
+			return fmt.Errorf("invalid %s %s request %q, expected a quantity such as 250m or 128Mi", component, name, q)
+		}
+	}
+	return nil
+}
+
+// validateStaticPodManifest returns an error unless the file at path is a
+// pod kubelet can run.
+func validateStaticPodManifest(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "static pod manifest")
+	}
+	var pod clientv1.Pod
+	if err := yaml.Unmarshal(b, &pod); err != nil {
+		return errors.Wrapf(err, "parsing static pod manifest %s", path)
+	}
+	if pod.APIVersion != "v1" || pod.Kind != "Pod" {
+		return fmt.Errorf("static pod manifest %s must be a v1 Pod, got %s %s", path, pod.APIVersion, pod.Kind)
+	}
+	if pod.Name == "" {
+		return fmt.Errorf("static pod manifest %s has no name", path)
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("static pod %s in %s has no containers", pod.Name, path)
+	}
+	return nil
+}
+
+func validateHostPort(field, hostPort string) error {
+	_, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s", field)
+	}
+	return validatePort(field, port)
+}
+
+func validatePort(field, port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid %s: port %q is not a number", field, port)
+	}
+	if errs := validation.IsValidPortNum(p); len(errs) > 0 {
+		return fmt.Errorf("invalid %s: port %d %s", field, p, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateExtraOption checks the values of the extra options that take
+// CIDRs, e.g. controller-manager.ClusterCIDR or
+// apiserver.ServiceClusterIPRange, or port ranges, e.g.
+// apiserver.ServiceNodePortRange.
+func validateExtraOption(e util.ExtraOption) error {
+	field := fmt.Sprintf("%s.%s", e.Component, e.Key)
+	key := strings.ToLower(e.Key)
+	switch {
+	case strings.Contains(key, "cidr") || strings.Contains(key, "iprange"):
+		if _, _, err := net.ParseCIDR(e.Value); err != nil {
+			return errors.Wrapf(err, "invalid %s", field)
+		}
+	case strings.Contains(key, "portrange"):
+		return validatePortRange(field, e.Value)
+	}
+	return nil
+}
+
+// validatePortRange checks that the field r is a non-empty range of ports,
+// of the form <first>-<last>.
+func validatePortRange(field, r string) error {
+	ports := strings.Split(r, "-")
+	if len(ports) != 2 {
+		return fmt.Errorf("invalid %s: port range %q must be of the form <first>-<last>", field, r)
+	}
+	for _, p := range ports {
+		if err := validatePort(field, p); err != nil {
+			return err
+		}
+	}
+	first, _ := strconv.Atoi(ports[0])
+	last, _ := strconv.Atoi(ports[1])
+	if first > last {
+		return fmt.Errorf("invalid %s: port range %q is empty", field, r)
+	}
+	return nil
+}
@@ -0,0 +1,597 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/util"
+)
+
+func TestValidateConfig(t *testing.T) {
+	valid := KubernetesConfig{
+		KubernetesVersion:           "v1.8.0",
+		NodeIP:                      "192.168.99.100",
+		NodeName:                    "minikube",
+		DNSDomain:                   "cluster.local",
+		CertDir:                     "/var/lib/localkube/certs",
+		KubeProxyMetricsBindAddress: "0.0.0.0:10249",
+		BootstrapToken:              "abcdef.0123456789abcdef",
+		KubeletFeatureGates:         map[string]string{"DevicePlugins": "true"},
+		ImageRepository:             "registry.example.com:5000/k8s/",
+		PauseImage:                  "registry.example.com:5000/k8s/pause:3.1",
+		ControlPlaneRequests:        map[string]ResourceRequests{"apiserver": {CPU: "250m", Memory: "256Mi"}},
+		ContainerLogMaxSize:         "50Mi",
+		ContainerLogMaxFiles:        3,
+		JournaldMaxUse:              "500M",
+		ExtraOptions: util.ExtraOptionSlice{
+			{Component: "controller-manager", Key: "ClusterCIDR", Value: "10.244.0.0/16"},
+			{Component: "apiserver", Key: "ServiceClusterIPRange", Value: "10.0.0.0/24"},
+			{Component: "apiserver", Key: "ServiceNodePortRange", Value: "30000-32767"},
+			{Component: "kubelet", Key: "MaxPods", Value: "100"},
+		},
+	}
+	if err := ValidateConfig(valid); err != nil {
+		t.Fatalf("Unexpected error validating a valid config: %s", err)
+	}
+
+	cases := []struct {
+		description string
+		modify      func(k *KubernetesConfig)
+		expected    string
+	}{
+		{
+			description: "version without prefix",
+			modify:      func(k *KubernetesConfig) { k.KubernetesVersion = "1.8.0" },
+			expected:    "kubernetes version must start with",
+		},
+		{
+			description: "malformed version",
+			modify:      func(k *KubernetesConfig) { k.KubernetesVersion = "v1.8" },
+			expected:    "invalid kubernetes version",
+		},
+		{
+			description: "node IP",
+			modify:      func(k *KubernetesConfig) { k.NodeIP = "192.168.99" },
+			expected:    "invalid node IP",
+		},
+		{
+			description: "node name",
+			modify:      func(k *KubernetesConfig) { k.NodeName = "Minikube_1" },
+			expected:    "invalid node name",
+		},
+		{
+			description: "DNS domain",
+			modify:      func(k *KubernetesConfig) { k.DNSDomain = "cluster..local" },
+			expected:    "invalid DNS domain",
+		},
+		{
+			description: "relative cert dir",
+			modify:      func(k *KubernetesConfig) { k.CertDir = "certs" },
+			expected:    "certificates directory must be an absolute path",
+		},
+		{
+			description: "metrics bind address without port",
+			modify:      func(k *KubernetesConfig) { k.KubeProxyMetricsBindAddress = "0.0.0.0" },
+			expected:    "invalid kube-proxy metrics bind address",
+		},
+		{
+			description: "metrics bind address port out of range",
+			modify:      func(k *KubernetesConfig) { k.KubeProxyMetricsBindAddress = "0.0.0.0:70000" },
+			expected:    "invalid kube-proxy metrics bind address",
+		},
+		{
+			description: "negative conntrack max per core",
+			modify:      func(k *KubernetesConfig) { k.KubeProxyConntrackMaxPerCore = -1 },
+			expected:    "conntrack max per core must not be negative",
+		},
+		{
+			description: "release mirror without scheme",
+			modify:      func(k *KubernetesConfig) { k.ReleaseMirror = "mirror.example.com" },
+			expected:    "invalid release mirror",
+		},
+		{
+			description: "download proxy without scheme",
+			modify:      func(k *KubernetesConfig) { k.DownloadProxy = "proxy.example.com:3128" },
+			expected:    "invalid download proxy",
+		},
+		{
+			description: "host alias IP",
+			modify: func(k *KubernetesConfig) {
+				k.HostAliases = []HostAlias{{IP: "10.0.0", Hostnames: []string{"registry.local"}}}
+			},
+			expected: "invalid host alias IP",
+		},
+		{
+			description: "host alias hostname",
+			modify: func(k *KubernetesConfig) {
+				k.HostAliases = []HostAlias{{IP: "10.0.0.5", Hostnames: []string{"registry_local"}}}
+			},
+			expected: "invalid host alias hostname",
+		},
+		{
+			description: "missing binary override",
+			modify: func(k *KubernetesConfig) {
+				k.BinaryOverrides = map[string]string{"kubelet": "/nonexistent/_output/bin/kubelet"}
+			},
+			expected: "kubelet override",
+		},
+		{
+			description: "binary override not overridable",
+			modify: func(k *KubernetesConfig) {
+				k.BinaryOverrides = map[string]string{"kube-apiserver": "/nonexistent/_output/bin/kube-apiserver"}
+			},
+			expected: "kube-apiserver can't be overridden",
+		},
+		{
+			description: "invalid sysctl",
+			modify: func(k *KubernetesConfig) {
+				k.Sysctls = map[string]string{"net.ipv4.ip_forward; reboot": "1"}
+			},
+			expected: "invalid sysctl",
+		},
+		{
+			description: "sysctl value with quote",
+			modify: func(k *KubernetesConfig) {
+				k.Sysctls = map[string]string{"kernel.hostname": "mini'kube"}
+			},
+			expected: "invalid value",
+		},
+		{
+			description: "empty kubelet feature gate",
+			modify: func(k *KubernetesConfig) {
+				k.KubeletFeatureGates = map[string]string{"": "true"}
+			},
+			expected: "invalid kubelet feature gate",
+		},
+		{
+			description: "kubelet feature gate not boolean",
+			modify: func(k *KubernetesConfig) {
+				k.KubeletFeatureGates = map[string]string{"DevicePlugins": "yes"}
+			},
+			expected: "expected true or false",
+		},
+		{
+			description: "registry with path",
+			modify: func(k *KubernetesConfig) {
+				k.RegistryCredentials = []RegistryCredential{{Server: "registry.example.com/team", Username: "user"}}
+			},
+			expected: "invalid registry",
+		},
+		{
+			description: "duplicate registry credentials",
+			modify: func(k *KubernetesConfig) {
+				k.RegistryCredentials = []RegistryCredential{
+					{Server: "registry.example.com", Username: "user"},
+					{Server: "registry.example.com", Username: "other"},
+				}
+			},
+			expected: "duplicate credentials",
+		},
+		{
+			description: "malformed pod CIDR",
+			modify:      func(k *KubernetesConfig) { k.PodCIDR = "10.244.0.0" },
+			expected:    "invalid pod CIDR",
+		},
+		{
+			description: "negative bootstrap token TTL",
+			modify:      func(k *KubernetesConfig) { k.BootstrapTokenTTL = -time.Hour },
+			expected:    "bootstrap token TTL must not be negative",
+		},
+		{
+			description: "negative cluster signing duration",
+			modify:      func(k *KubernetesConfig) { k.ClusterSigningDuration = -time.Hour },
+			expected:    "cluster signing duration must not be negative",
+		},
+		{
+			description: "malformed service node port range",
+			modify:      func(k *KubernetesConfig) { k.ServiceNodePortRange = "30000:32767" },
+			expected:    "must be of the form <first>-<last>",
+		},
+		{
+			description: "service node port range out of order",
+			modify:      func(k *KubernetesConfig) { k.ServiceNodePortRange = "32767-30000" },
+			expected:    "invalid service node port range: port range \"32767-30000\" is empty",
+		},
+		{
+			description: "service node port range past the last port",
+			modify:      func(k *KubernetesConfig) { k.ServiceNodePortRange = "30000-70000" },
+			expected:    "invalid service node port range: port 70000",
+		},
+		{
+			description: "kubelet configuration on old kubernetes",
+			modify:      func(k *KubernetesConfig) { k.KubeletConfiguration = map[string]interface{}{"maxPods": 50} },
+			expected:    "a kubelet configuration requires kubernetes v1.12.0 or later, got v1.8.0",
+		},
+		{
+			description: "kubelet configuration of another kind",
+			modify: func(k *KubernetesConfig) {
+				k.KubernetesVersion = "v1.12.0"
+				k.KubeletConfiguration = map[string]interface{}{"kind": "KubeProxyConfiguration"}
+			},
+			expected: "invalid kubelet configuration kind KubeProxyConfiguration, must be KubeletConfiguration",
+		},
+		{
+			description: "malformed CIDR",
+			modify: func(k *KubernetesConfig) {
+				k.ExtraOptions = util.ExtraOptionSlice{{Component: "controller-manager", Key: "ClusterCIDR", Value: "10.244.0.0/33"}}
+			},
+			expected: "invalid controller-manager.ClusterCIDR",
+		},
+		{
+			description: "malformed port range",
+			modify: func(k *KubernetesConfig) {
+				k.ExtraOptions = util.ExtraOptionSlice{{Component: "apiserver", Key: "ServiceNodePortRange", Value: "30000"}}
+			},
+			expected: "invalid apiserver.ServiceNodePortRange",
+		},
+		{
+			description: "service CIDR without the apiserver's IP",
+			modify:      func(k *KubernetesConfig) { k.ServiceCIDR = "10.96.0.0/12" },
+			expected:    "the first range must contain 10.0.0.1",
+		},
+		{
+			description: "IPv6 service CIDR",
+			modify:      func(k *KubernetesConfig) { k.ServiceCIDR = "fd00::/112" },
+			expected:    "the first range must contain 10.0.0.1",
+		},
+		{
+			description: "two IPv4 service CIDRs",
+			modify:      func(k *KubernetesConfig) { k.ServiceCIDR = "10.0.0.0/24,10.1.0.0/24" },
+			expected:    "must be a single range or an IPv4 and an IPv6 range",
+		},
+		{
+			description: "dual-stack pod CIDR with single service CIDR",
+			modify:      func(k *KubernetesConfig) { k.PodCIDR = "10.244.0.0/16,fd00:10:244::/56" },
+			expected:    "must both be single or dual-stack",
+		},
+		{
+			description: "dual-stack on an old version",
+			modify:      func(k *KubernetesConfig) { k.ServiceCIDR = "10.0.0.0/24,fd00::/112" },
+			expected:    "dual-stack requires kubernetes v1.16.0 or later",
+		},
+		{
+			description: "invalid binary download",
+			modify:      func(k *KubernetesConfig) { k.BinaryDownload = "vm" },
+			expected:    "invalid binary download",
+		},
+		{
+			description: "node binary download offline",
+			modify: func(k *KubernetesConfig) {
+				k.BinaryDownload = BinaryDownloadNode
+				k.Offline = true
+			},
+			expected: "offline mode",
+		},
+		{
+			description: "required cached images not loaded",
+			modify: func(k *KubernetesConfig) {
+				k.RequireCachedImages = true
+			},
+			expected: "cached images can't be required",
+		},
+		{
+			description: "required cached images skipped",
+			modify: func(k *KubernetesConfig) {
+				k.ShouldLoadCachedImages = true
+				k.RequireCachedImages = true
+				k.NoCacheImages = true
+			},
+			expected: "cached images can't be required",
+		},
+		{
+			description: "image repository with a scheme",
+			modify:      func(k *KubernetesConfig) { k.ImageRepository = "https://registry.example.com" },
+			expected:    "invalid image repository",
+		},
+		{
+			description: "image repository with a tag",
+			modify:      func(k *KubernetesConfig) { k.ImageRepository = "registry.example.com/k8s:v1" },
+			expected:    "invalid image repository",
+		},
+		{
+			description: "pause image without a tag",
+			modify:      func(k *KubernetesConfig) { k.PauseImage = "registry.example.com:5000/pause" },
+			expected:    "invalid pause image",
+		},
+		{
+			description: "pause image with a digest",
+			modify:      func(k *KubernetesConfig) { k.PauseImage = "k8s.gcr.io/pause@sha256:abc" },
+			expected:    "invalid pause image",
+		},
+		{
+			description: "registry mirror without a scheme",
+			modify: func(k *KubernetesConfig) {
+				k.RegistryMirrors = []string{"https://mirror.example.com", "mirror.example.com"}
+			},
+			expected: "invalid registry mirror \"mirror.example.com\"",
+		},
+		{
+			description: "relative image cache directory",
+			modify:      func(k *KubernetesConfig) { k.ImageCacheDir = "cache/images" },
+			expected:    "invalid image cache directory",
+		},
+		{
+			description: "invalid container log max size",
+			modify:      func(k *KubernetesConfig) { k.ContainerLogMaxSize = "10 megs" },
+			expected:    "invalid container log max size",
+		},
+		{
+			description: "one container log file",
+			modify:      func(k *KubernetesConfig) { k.ContainerLogMaxFiles = 1 },
+			expected:    "container log max files must be at least 2",
+		},
+		{
+			description: "invalid journald max use",
+			modify:      func(k *KubernetesConfig) { k.JournaldMaxUse = "500MiB" },
+			expected:    "invalid journald max use",
+		},
+		{
+			description: "requests of an unknown component",
+			modify: func(k *KubernetesConfig) {
+				k.ControlPlaneRequests = map[string]ResourceRequests{"kube-proxy": {CPU: "100m"}}
+			},
+			expected: "can't set the resources of kube-proxy",
+		},
+		{
+			description: "invalid memory request",
+			modify: func(k *KubernetesConfig) {
+				k.ControlPlaneRequests = map[string]ResourceRequests{"etcd": {CPU: "100m", Memory: "lots"}}
+			},
+			expected: "invalid etcd memory request",
+		},
+		{
+			description: "invalid cgroup driver",
+			modify:      func(k *KubernetesConfig) { k.CgroupDriver = "cgroupv2" },
+			expected:    "invalid cgroup driver",
+		},
+		{
+			description: "forced verification offline",
+			modify: func(k *KubernetesConfig) {
+				k.ForceVerify = true
+				k.Offline = true
+			},
+			expected: "published checksums in offline mode",
+		},
+		{
+			description: "empty port range",
+			modify: func(k *KubernetesConfig) {
+				k.ExtraOptions = util.ExtraOptionSlice{{Component: "apiserver", Key: "ServiceNodePortRange", Value: "32767-30000"}}
+			},
+			expected: "port range \"32767-30000\" is empty",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k := valid
+			test.modify(&k)
+			err := ValidateConfig(k)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", test.expected)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q, got %q", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateDualStack(t *testing.T) {
+	cases := []struct {
+		description string
+		serviceCIDR string
+		podCIDR     string
+		expected    string
+	}{
+		{
+			description: "dual-stack",
+			serviceCIDR: "10.0.0.0/24,fd00::/112",
+			podCIDR:     "10.244.0.0/16,fd00:10:244::/56",
+		},
+		{
+			description: "dual-stack services without pod CIDR",
+			serviceCIDR: "10.0.0.0/24,fd00::/112",
+		},
+		{
+			description: "pod CIDR families reversed",
+			serviceCIDR: "10.0.0.0/24,fd00::/112",
+			podCIDR:     "fd00:10:244::/56,10.244.0.0/16",
+			expected:    "same IP families",
+		},
+		{
+			description: "two IPv6 pod CIDRs",
+			serviceCIDR: "10.0.0.0/24,fd00::/112",
+			podCIDR:     "fd00:10:244::/56,fd00:10:245::/56",
+			expected:    "must be a single range or an IPv4 and an IPv6 range",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			err := ValidateConfig(KubernetesConfig{KubernetesVersion: "v1.16.0", ServiceCIDR: test.serviceCIDR, PodCIDR: test.podCIDR})
+			if test.expected == "" && err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Expected error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCombinesErrors(t *testing.T) {
+	err := ValidateConfig(KubernetesConfig{KubernetesVersion: "latest", NodeIP: "nope", CertDir: "certs"})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	for _, expected := range []string{"kubernetes version", "node IP", "certificates directory"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to report %q, got %q", expected, err)
+		}
+	}
+}
+
+func TestValidateBootstrapToken(t *testing.T) {
+	cases := []struct {
+		token string
+		valid bool
+	}{
+		{token: "abcdef.0123456789abcdef", valid: true},
+		{token: "012345.abcdefghijklmnop", valid: true},
+		{token: "ABCDEF.0123456789ABCDEF"},
+		{token: "abcdef0123456789abcdef"},
+		{token: "abcdef:0123456789abcdef"},
+		{token: "abcde.0123456789abcdef"},
+		{token: "abcdef.0123456789abcdef0"},
+		{token: "abcdef.0123456789abcde_"},
+		{token: " abcdef.0123456789abcdef"},
+	}
+
+	for _, test := range cases {
+		t.Run(test.token, func(t *testing.T) {
+			err := ValidateConfig(KubernetesConfig{KubernetesVersion: "v1.8.0", BootstrapToken: test.token})
+			if test.valid && err != nil {
+				t.Errorf("Unexpected error validating token: %s", err)
+			}
+			if !test.valid && (err == nil || !strings.Contains(err.Error(), "invalid bootstrap token")) {
+				t.Errorf("Expected invalid bootstrap token error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateStaticPodManifests(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "static-pods")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"registry.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: registry
+spec:
+  containers:
+  - name: registry
+    image: registry:2
+`,
+		"other/registry.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: other-registry
+spec:
+  containers:
+  - name: registry
+    image: registry:2
+`,
+		"deployment.yaml": `apiVersion: apps/v1beta1
+kind: Deployment
+metadata:
+  name: registry
+`,
+		"unnamed.yaml": `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: registry
+    image: registry:2
+`,
+		"empty.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: empty
+`,
+		"invalid.yaml": "kind: [Pod",
+	}
+	for name, contents := range files {
+		p := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatalf("Error making dir: %s", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", p, err)
+		}
+	}
+
+	cases := []struct {
+		description string
+		manifests   []string
+		expected    string
+	}{
+		{
+			description: "pod",
+			manifests:   []string{"registry.yaml"},
+		},
+		{
+			description: "not a pod",
+			manifests:   []string{"deployment.yaml"},
+			expected:    "must be a v1 Pod",
+		},
+		{
+			description: "no name",
+			manifests:   []string{"unnamed.yaml"},
+			expected:    "has no name",
+		},
+		{
+			description: "no containers",
+			manifests:   []string{"empty.yaml"},
+			expected:    "has no containers",
+		},
+		{
+			description: "invalid yaml",
+			manifests:   []string{"invalid.yaml"},
+			expected:    "parsing static pod manifest",
+		},
+		{
+			description: "missing",
+			manifests:   []string{"missing.yaml"},
+			expected:    "no such file",
+		},
+		{
+			description: "repeated file name",
+			manifests:   []string{"registry.yaml", "other/registry.yaml"},
+			expected:    "registry.yaml is repeated",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			k8s := KubernetesConfig{KubernetesVersion: "v1.8.0"}
+			for _, m := range test.manifests {
+				k8s.StaticPodManifests = append(k8s.StaticPodManifests, filepath.Join(tempDir, m))
+			}
+			err := ValidateConfig(k8s)
+			if test.expected == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/util"
+)
+
+// ErrClusterNotRunning is the cause of the error returned by
+// GetRunningVersion when the apiserver can't be reached.
+var ErrClusterNotRunning = errors.New("cluster is not running")
+
+var versionCommand = fmt.Sprintf("curl -sSfk --max-time 5 https://localhost:%d/version", util.APIServerPort)
+
+// GetRunningVersion asks the apiserver on the node which Kubernetes version
+// it's running, which may differ from the version requested in the config.
+func GetRunningVersion(cmd CommandRunner) (string, error) {
+	out, err := cmd.CombinedOutput(versionCommand)
+	if err != nil {
+		return "", errors.Wrapf(ErrClusterNotRunning, "querying apiserver version: %v", err)
+	}
+	return parseServerVersion(out)
+}
+
+// parseServerVersion returns the gitVersion from the apiserver's /version
+// response.
+func parseServerVersion(out string) (string, error) {
+	var info struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &info); err != nil {
+		return "", errors.Wrapf(err, "parsing apiserver version: %s", out)
+	}
+	if info.GitVersion == "" {
+		return "", fmt.Errorf("apiserver version response has no gitVersion: %s", out)
+	}
+	return info.GitVersion, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapper
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const versionResponse = `{
+  "major": "1",
+  "minor": "8",
+  "gitVersion": "v1.8.0",
+  "gitCommit": "6e937839ac04a38cac63e6a7a306c5d035fe7b0a",
+  "gitTreeState": "clean",
+  "buildDate": "2017-09-28T22:46:41Z",
+  "goVersion": "go1.8.3",
+  "compiler": "gc",
+  "platform": "linux/amd64"
+}`
+
+func TestParseServerVersion(t *testing.T) {
+	cases := []struct {
+		description string
+		out         string
+		expected    string
+		shouldErr   bool
+	}{
+		{
+			description: "apiserver response",
+			out:         versionResponse,
+			expected:    "v1.8.0",
+		},
+		{
+			description: "not json",
+			out:         "404 page not found",
+			shouldErr:   true,
+		},
+		{
+			description: "missing gitVersion",
+			out:         `{"major": "1", "minor": "8"}`,
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			actual, err := parseServerVersion(test.out)
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error but got none: %s", actual)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected version %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetRunningVersion(t *testing.T) {
+	f := NewFakeCommandRunner()
+	f.SetCommandToOutput(map[string]string{versionCommand: versionResponse})
+	v, err := GetRunningVersion(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v != "v1.8.0" {
+		t.Errorf("Expected version v1.8.0, got %s", v)
+	}
+}
+
+func TestGetRunningVersionNotRunning(t *testing.T) {
+	f := NewFakeCommandRunner()
+	if _, err := GetRunningVersion(f); errors.Cause(err) != ErrClusterNotRunning {
+		t.Errorf("Expected ErrClusterNotRunning, got %v", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -33,6 +34,10 @@ const (
 	ClusterDNSDomain = "cluster.local"
 )
 
+// DefaultPodCIDR is the pod CIDR used when installing a CNI plugin, and
+// flannel's default.
+const DefaultPodCIDR = "10.244.0.0/16"
+
 const MinikubeHome = "MINIKUBE_HOME"
 
 // Minipath is the path to the user's minikube dir
@@ -90,7 +95,9 @@ const (
 	MinimumDiskSizeMB   = 2000
 	DefaultVMDriver     = "virtualbox"
 	DefaultStatusFormat = "minikube: {{.MinikubeStatus}}\n" +
-		"cluster: {{.ClusterStatus}}\n" + "kubectl: {{.KubeconfigStatus}}\n"
+		"cluster: {{.ClusterStatus}}\n" + "kubectl: {{.KubeconfigStatus}}\n" +
+		"{{if .Binaries}}binaries: {{.Binaries}}\n{{end}}" +
+		"{{if .Certs}}certs: {{.Certs}}\n{{end}}"
 	DefaultAddonListFormat     = "- {{.AddonName}}: {{.AddonStatus}}\n"
 	DefaultConfigViewFormat    = "- {{.ConfigKey}}: {{.ConfigValue}}\n"
 	GithubMinikubeReleasesURL  = "https://storage.googleapis.com/minikube/releases.json"
@@ -113,6 +120,11 @@ func GetProfileFile(profile string) string {
 	return filepath.Join(GetMinipath(), "profiles", profile, "config.json")
 }
 
+// GetProfilePath returns the path of a file in the profile's directory
+func GetProfilePath(profile string, fileName string) string {
+	return filepath.Join(GetMinipath(), "profiles", profile, fileName)
+}
+
 var LocalkubeDownloadURLPrefix = "https://storage.googleapis.com/minikube/k8sReleases/"
 var LocalkubeLinuxFilename = "localkube-linux-amd64"
 
@@ -134,6 +146,9 @@ const (
 	KubeletServiceFile     = "/lib/systemd/system/kubelet.service"
 	KubeletSystemdConfFile = "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf"
 	KubeadmConfigFile      = "/var/lib/kubeadm.yaml"
+	// KubeadmInitLogFile keeps the output of the last kubeadm init on the
+	// node. The previous attempt's output is kept in KubeadmInitLogFile.1.
+	KubeadmInitLogFile = "/var/lib/minikube/kubeadm-init.log"
 )
 
 const (
@@ -150,17 +165,57 @@ const (
 	DefaultMountVersion  = "9p2000.u"
 )
 
-func GetKubernetesReleaseURL(binaryName, version string) string {
+// NodeOS and NodeArch are the platform of the Kubernetes binaries run on
+// the node, unless the node reports another architecture.
+const (
+	NodeOS   = "linux"
+	NodeArch = "amd64"
+)
+
+// DefaultKubernetesReleaseMirror is the base URL Kubernetes release
+// binaries are downloaded from. A mirror must have the same path layout.
+const DefaultKubernetesReleaseMirror = "https://storage.googleapis.com"
+
+// GetKubernetesReleaseURL returns the URL of a Kubernetes release binary
+// for the node, which is linux/amd64, under mirror, or
+// DefaultKubernetesReleaseMirror if mirror is empty.
+func GetKubernetesReleaseURL(mirror, binaryName, version string) string {
+	return GetKubernetesReleaseURLForPlatform(mirror, binaryName, version, NodeOS, NodeArch)
+}
+
+// GetKubernetesReleaseURLForPlatform is GetKubernetesReleaseURL for a
+// binary built for goos and goarch, e.g. a kubectl for the host.
+func GetKubernetesReleaseURLForPlatform(mirror, binaryName, version, goos, goarch string) string {
+	if mirror == "" {
+		mirror = DefaultKubernetesReleaseMirror
+	}
+	mirror = strings.TrimSuffix(mirror, "/")
 	// TODO(r2d4): change this to official releases when the alpha controlplane commands are released.
-	// We are working with unreleased kubeadm changes at HEAD.
-	if binaryName == "kubeadm" {
-		return "https://storage.googleapis.com/minikube/kubeadm/kubeadm"
+	// We are working with unreleased kubeadm changes at HEAD, which are only
+	// built for the default node platform.
+	if binaryName == "kubeadm" && goos == NodeOS && goarch == NodeArch {
+		return mirror + "/minikube/kubeadm/kubeadm"
 	}
-	return fmt.Sprintf("https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/%s", version, binaryName)
+	if goos == "windows" {
+		binaryName += ".exe"
+	}
+	return fmt.Sprintf("%s/kubernetes-release/release/%s/bin/%s/%s/%s", mirror, version, goos, goarch, binaryName)
+}
+
+func GetKubernetesReleaseURLSha1(mirror, binaryName, version string) string {
+	return GetKubernetesReleaseURLSha1ForPlatform(mirror, binaryName, version, NodeOS, NodeArch)
 }
 
-func GetKubernetesReleaseURLSha1(binaryName, version string) string {
-	return fmt.Sprintf("%s.sha1", GetKubernetesReleaseURL(binaryName, version))
+func GetKubernetesReleaseURLSha1ForPlatform(mirror, binaryName, version, goos, goarch string) string {
+	return fmt.Sprintf("%s.sha1", GetKubernetesReleaseURLForPlatform(mirror, binaryName, version, goos, goarch))
+}
+
+func GetKubernetesReleaseURLSha256(mirror, binaryName, version string) string {
+	return GetKubernetesReleaseURLSha256ForPlatform(mirror, binaryName, version, NodeOS, NodeArch)
+}
+
+func GetKubernetesReleaseURLSha256ForPlatform(mirror, binaryName, version, goos, goarch string) string {
+	return fmt.Sprintf("%s.sha256", GetKubernetesReleaseURLForPlatform(mirror, binaryName, version, goos, goarch))
 }
 
 const IsMinikubeChildProcess = "IS_MINIKUBE_CHILD_PROCESS"
@@ -183,30 +238,4 @@ var LocalkubeCachedImages = []string{
 	"gcr.io/google_containers/pause-amd64:3.0",
 }
 
-func GetKubeadmCachedImages(version string) []string {
-	return []string{
-		// Dashboard
-		"gcr.io/google_containers/kubernetes-dashboard-amd64:v1.6.3",
-
-		// Addon Manager
-		"gcr.io/google-containers/kube-addon-manager:v6.4-beta.2",
-
-		// Pause
-		"gcr.io/google_containers/pause-amd64:3.0",
-
-		// DNS
-		"gcr.io/google_containers/k8s-dns-kube-dns-amd64:1.14.4",
-		"gcr.io/google_containers/k8s-dns-dnsmasq-nanny-amd64:1.14.4",
-		"gcr.io/google_containers/k8s-dns-sidecar-amd64:1.14.4",
-
-		// etcd
-		"gcr.io/google_containers/etcd-amd64:3.0.17",
-
-		"gcr.io/google_containers/kube-proxy-amd64:" + version,
-		"gcr.io/google_containers/kube-scheduler-amd64:" + version,
-		"gcr.io/google_containers/kube-controller-manager-amd64:" + version,
-		"gcr.io/google_containers/kube-apiserver-amd64:" + version,
-	}
-}
-
 var ImageCacheDir = MakeMiniPath("cache", "images")
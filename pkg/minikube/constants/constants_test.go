@@ -0,0 +1,267 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package constants
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetKubernetesReleaseURL(t *testing.T) {
+	cases := []struct {
+		description string
+		mirror      string
+		binary      string
+		expected    string
+	}{
+		{
+			description: "default",
+			binary:      "kubelet",
+			expected:    "https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet",
+		},
+		{
+			description: "mirror",
+			mirror:      "https://mirror.example.com",
+			binary:      "kubelet",
+			expected:    "https://mirror.example.com/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet",
+		},
+		{
+			description: "mirror with trailing slash",
+			mirror:      "https://mirror.example.com/k8s/",
+			binary:      "kubelet",
+			expected:    "https://mirror.example.com/k8s/kubernetes-release/release/v1.8.0/bin/linux/amd64/kubelet",
+		},
+		{
+			description: "kubeadm mirror",
+			mirror:      "http://mirror.example.com",
+			binary:      "kubeadm",
+			expected:    "http://mirror.example.com/minikube/kubeadm/kubeadm",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if url := GetKubernetesReleaseURL(test.mirror, test.binary, "v1.8.0"); url != test.expected {
+				t.Errorf("Expected binary URL %s, got %s", test.expected, url)
+			}
+			if url := GetKubernetesReleaseURLSha256(test.mirror, test.binary, "v1.8.0"); url != test.expected+".sha256" {
+				t.Errorf("Expected sha256 URL %s, got %s", test.expected+".sha256", url)
+			}
+			if url := GetKubernetesReleaseURLSha1(test.mirror, test.binary, "v1.8.0"); url != test.expected+".sha1" {
+				t.Errorf("Expected sha1 URL %s, got %s", test.expected+".sha1", url)
+			}
+		})
+	}
+}
+
+func TestGetKubernetesReleaseURLForPlatform(t *testing.T) {
+	cases := []struct {
+		description string
+		binary      string
+		goos        string
+		goarch      string
+		expected    string
+	}{
+		{
+			description: "darwin kubectl",
+			binary:      "kubectl",
+			goos:        "darwin",
+			goarch:      "amd64",
+			expected:    "https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/darwin/amd64/kubectl",
+		},
+		{
+			description: "windows kubectl",
+			binary:      "kubectl",
+			goos:        "windows",
+			goarch:      "386",
+			expected:    "https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/windows/386/kubectl.exe",
+		},
+		{
+			description: "arm64 kubelet",
+			binary:      "kubelet",
+			goos:        "linux",
+			goarch:      "arm64",
+			expected:    "https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/arm64/kubelet",
+		},
+		{
+			description: "arm kubeadm",
+			binary:      "kubeadm",
+			goos:        "linux",
+			goarch:      "arm",
+			expected:    "https://storage.googleapis.com/kubernetes-release/release/v1.8.0/bin/linux/arm/kubeadm",
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			if url := GetKubernetesReleaseURLForPlatform("", test.binary, "v1.8.0", test.goos, test.goarch); url != test.expected {
+				t.Errorf("Expected binary URL %s, got %s", test.expected, url)
+			}
+			if url := GetKubernetesReleaseURLSha256ForPlatform("", test.binary, "v1.8.0", test.goos, test.goarch); url != test.expected+".sha256" {
+				t.Errorf("Expected sha256 URL %s, got %s", test.expected+".sha256", url)
+			}
+		})
+	}
+}
+
+func TestGetKubeadmCachedImages(t *testing.T) {
+	cases := []struct {
+		description     string
+		imageRepository string
+		version         string
+		expected        []string
+	}{
+		{
+			description: "v1.8",
+			version:     "v1.8.0",
+			expected: []string{
+				"gcr.io/google_containers/pause-amd64:3.0",
+				"gcr.io/google_containers/k8s-dns-kube-dns-amd64:1.14.5",
+				"gcr.io/google_containers/k8s-dns-dnsmasq-nanny-amd64:1.14.5",
+				"gcr.io/google_containers/k8s-dns-sidecar-amd64:1.14.5",
+				"gcr.io/google_containers/etcd-amd64:3.0.17",
+				"gcr.io/google_containers/kube-proxy-amd64:v1.8.0",
+				"gcr.io/google_containers/kube-scheduler-amd64:v1.8.0",
+				"gcr.io/google_containers/kube-controller-manager-amd64:v1.8.0",
+				"gcr.io/google_containers/kube-apiserver-amd64:v1.8.0",
+			},
+		},
+		{
+			description: "v1.10 moved to k8s.gcr.io",
+			version:     "v1.10.3",
+			expected: []string{
+				"k8s.gcr.io/pause-amd64:3.1",
+				"k8s.gcr.io/k8s-dns-kube-dns-amd64:1.14.8",
+				"k8s.gcr.io/k8s-dns-dnsmasq-nanny-amd64:1.14.8",
+				"k8s.gcr.io/k8s-dns-sidecar-amd64:1.14.8",
+				"k8s.gcr.io/etcd-amd64:3.1.12",
+				"k8s.gcr.io/kube-proxy-amd64:v1.10.3",
+				"k8s.gcr.io/kube-scheduler-amd64:v1.10.3",
+				"k8s.gcr.io/kube-controller-manager-amd64:v1.10.3",
+				"k8s.gcr.io/kube-apiserver-amd64:v1.10.3",
+			},
+		},
+		{
+			description:     "v1.8 with a repository",
+			imageRepository: "registry.example.com:5000/k8s/",
+			version:         "v1.8.0",
+			expected: []string{
+				"registry.example.com:5000/k8s/pause-amd64:3.0",
+				"registry.example.com:5000/k8s/k8s-dns-kube-dns-amd64:1.14.5",
+				"registry.example.com:5000/k8s/k8s-dns-dnsmasq-nanny-amd64:1.14.5",
+				"registry.example.com:5000/k8s/k8s-dns-sidecar-amd64:1.14.5",
+				"registry.example.com:5000/k8s/etcd-amd64:3.0.17",
+				"registry.example.com:5000/k8s/kube-proxy-amd64:v1.8.0",
+				"registry.example.com:5000/k8s/kube-scheduler-amd64:v1.8.0",
+				"registry.example.com:5000/k8s/kube-controller-manager-amd64:v1.8.0",
+				"registry.example.com:5000/k8s/kube-apiserver-amd64:v1.8.0",
+			},
+		},
+		{
+			description:     "v1.13 with a repository drops the architecture, and uses CoreDNS",
+			imageRepository: "mirror.example.com",
+			version:         "v1.13.1",
+			expected: []string{
+				"mirror.example.com/pause:3.1",
+				"mirror.example.com/coredns:1.2.6",
+				"mirror.example.com/etcd:3.2.24",
+				"mirror.example.com/kube-proxy:v1.13.1",
+				"mirror.example.com/kube-scheduler:v1.13.1",
+				"mirror.example.com/kube-controller-manager:v1.13.1",
+				"mirror.example.com/kube-apiserver:v1.13.1",
+			},
+		},
+		{
+			description:     "newer than the newest known version",
+			imageRepository: "mirror.example.com",
+			version:         "v1.30.0",
+			expected: []string{
+				"mirror.example.com/pause:3.5",
+				"mirror.example.com/coredns/coredns:v1.8.4",
+				"mirror.example.com/etcd:3.5.0-0",
+				"mirror.example.com/kube-proxy:v1.30.0",
+				"mirror.example.com/kube-scheduler:v1.30.0",
+				"mirror.example.com/kube-controller-manager:v1.30.0",
+				"mirror.example.com/kube-apiserver:v1.30.0",
+			},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			images := GetKubeadmCachedImages(test.imageRepository, test.version)
+			// The addons' images don't come from the repository.
+			addons := []string{
+				"gcr.io/google_containers/kubernetes-dashboard-amd64:v1.6.3",
+				"gcr.io/google-containers/kube-addon-manager:v6.4-beta.2",
+			}
+			if !reflect.DeepEqual(images[:2], addons) {
+				t.Errorf("Expected addon images %v, got %v", addons, images[:2])
+			}
+			if !reflect.DeepEqual(images[2:], test.expected) {
+				t.Errorf("Expected images:\n%v\ngot:\n%v", test.expected, images[2:])
+			}
+			if pause := GetPauseImage(test.imageRepository, test.version); pause != test.expected[0] {
+				t.Errorf("Expected pause image %s, got %s", test.expected[0], pause)
+			}
+		})
+	}
+}
+
+func TestKubeadmImageTagsByMinor(t *testing.T) {
+	// A missing minor version would silently use the previous one's images.
+	for i, entry := range kubeadmImageTagsByMinor {
+		if expected := kubeadmImageTagsByMinor[0].minor + uint64(i); entry.minor != expected {
+			t.Fatalf("Expected the images of v1.%d to be listed after v1.%d, got v1.%d", expected, expected-1, entry.minor)
+		}
+		tags := entry.tags
+		if tags.pause == "" || tags.etcd == "" || (tags.kubeDNS == "") == (tags.coreDNS == "") {
+			t.Errorf("Expected v1.%d to list pause, etcd, and either kube-dns or CoreDNS, got %+v", entry.minor, tags)
+		}
+	}
+
+	components := map[string]bool{}
+	for _, image := range GetKubeadmImages("", "v1.10.0") {
+		components[image.Component] = true
+	}
+	for _, c := range []string{"pause", "dns", "etcd", "apiserver"} {
+		if !components[c] {
+			t.Errorf("Expected an image for %s, got %v", c, components)
+		}
+	}
+}
+
+func TestKubeadmImagesKnown(t *testing.T) {
+	cases := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "v1.8.0", expected: true},
+		{version: "v1.15.3", expected: true},
+		{version: "v1.22.0", expected: true},
+		{version: "v1.7.5"},
+		{version: "v1.30.0"},
+		{version: "latest"},
+	}
+	for _, test := range cases {
+		t.Run(test.version, func(t *testing.T) {
+			if known := KubeadmImagesKnown(test.version); known != test.expected {
+				t.Errorf("Expected KubeadmImagesKnown(%s) to be %t, got %t", test.version, test.expected, known)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package constants
+
+import (
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+const (
+	// DefaultImageRepository is where kubeadm pulls the control plane
+	// images from, from Kubernetes v1.10.
+	DefaultImageRepository = "k8s.gcr.io"
+	// legacyImageRepository is where kubeadm pulls them from before then.
+	legacyImageRepository = "gcr.io/google_containers"
+)
+
+// kubeadmImageTags are the versions of the images kubeadm uses which aren't
+// versioned with Kubernetes.
+type kubeadmImageTags struct {
+	pause string
+	etcd  string
+	// kubeDNS is the tag of kube-dns's images, which kubeadm installs
+	// before Kubernetes v1.11.
+	kubeDNS string
+	// coreDNS is CoreDNS's image, below the repository, with its tag, which
+	// kubeadm installs instead of kube-dns from Kubernetes v1.11.
+	coreDNS string
+}
+
+// kubeadmImageTagsByMinor maps each Kubernetes minor version, from v1.8, to
+// the images its kubeadm uses, as its constants name them. Every minor
+// version must be listed, so that a new one isn't missed. Versions newer
+// than the newest listed use its images, and older ones, or ones that
+// can't be parsed, use v1.8's.
+var kubeadmImageTagsByMinor = []struct {
+	minor uint64
+	tags  kubeadmImageTags
+}{
+	{8, kubeadmImageTags{pause: "3.0", etcd: "3.0.17", kubeDNS: "1.14.5"}},
+	{9, kubeadmImageTags{pause: "3.0", etcd: "3.1.10", kubeDNS: "1.14.7"}},
+	{10, kubeadmImageTags{pause: "3.1", etcd: "3.1.12", kubeDNS: "1.14.8"}},
+	{11, kubeadmImageTags{pause: "3.1", etcd: "3.2.18", coreDNS: "coredns:1.1.3"}},
+	{12, kubeadmImageTags{pause: "3.1", etcd: "3.2.24", coreDNS: "coredns:1.2.2"}},
+	{13, kubeadmImageTags{pause: "3.1", etcd: "3.2.24", coreDNS: "coredns:1.2.6"}},
+	{14, kubeadmImageTags{pause: "3.1", etcd: "3.3.10", coreDNS: "coredns:1.3.1"}},
+	{15, kubeadmImageTags{pause: "3.1", etcd: "3.3.10", coreDNS: "coredns:1.3.1"}},
+	{16, kubeadmImageTags{pause: "3.1", etcd: "3.3.15-0", coreDNS: "coredns:1.6.2"}},
+	{17, kubeadmImageTags{pause: "3.1", etcd: "3.4.3-0", coreDNS: "coredns:1.6.5"}},
+	{18, kubeadmImageTags{pause: "3.2", etcd: "3.4.3-0", coreDNS: "coredns:1.6.7"}},
+	{19, kubeadmImageTags{pause: "3.2", etcd: "3.4.9-1", coreDNS: "coredns:1.7.0"}},
+	{20, kubeadmImageTags{pause: "3.2", etcd: "3.4.13-0", coreDNS: "coredns:1.7.0"}},
+	{21, kubeadmImageTags{pause: "3.4.1", etcd: "3.4.13-0", coreDNS: "coredns/coredns:v1.8.0"}},
+	{22, kubeadmImageTags{pause: "3.5", etcd: "3.5.0-0", coreDNS: "coredns/coredns:v1.8.4"}},
+}
+
+// KubeadmImagesKnown returns whether the images kubeadm uses for version
+// are known, rather than guessed from the newest known version's, which
+// might be out of date.
+func KubeadmImagesKnown(version string) bool {
+	v, err := semver.Make(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return false
+	}
+	first, last := kubeadmImageTagsByMinor[0].minor, kubeadmImageTagsByMinor[len(kubeadmImageTagsByMinor)-1].minor
+	return v.Major == 1 && v.Minor >= first && v.Minor <= last
+}
+
+// parseKubernetesVersion parses a version such as v1.8.0, returning v1.8.0
+// if it can't be parsed.
+func parseKubernetesVersion(version string) semver.Version {
+	v, err := semver.Make(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return semver.Version{Major: 1, Minor: 8}
+	}
+	return v
+}
+
+func getKubeadmImageTags(v semver.Version) kubeadmImageTags {
+	tags := kubeadmImageTagsByMinor[0].tags
+	for _, t := range kubeadmImageTagsByMinor {
+		if v.Major == 1 && v.Minor < t.minor {
+			break
+		}
+		tags = t.tags
+	}
+	return tags
+}
+
+// GetImageRepository returns the registry kubeadm pulls the control plane
+// images for version from: imageRepository, or kubeadm's default if it's
+// empty.
+func GetImageRepository(imageRepository, version string) string {
+	if imageRepository != "" {
+		return strings.TrimSuffix(imageRepository, "/")
+	}
+	if parseKubernetesVersion(version).LT(semver.Version{Major: 1, Minor: 10}) {
+		return legacyImageRepository
+	}
+	return DefaultImageRepository
+}
+
+// imageName returns the name of a control plane image in repo. Before
+// Kubernetes v1.12 the names include the architecture.
+func imageName(repo, name string, v semver.Version) string {
+	if v.LT(semver.Version{Major: 1, Minor: 12}) {
+		name += "-" + NodeArch
+	}
+	return repo + "/" + name
+}
+
+// GetPauseImage returns the pause image kubelet uses for version's pods,
+// pulled from imageRepository.
+func GetPauseImage(imageRepository, version string) string {
+	v := parseKubernetesVersion(version)
+	return imageName(GetImageRepository(imageRepository, version), "pause", v) + ":" + getKubeadmImageTags(v).pause
+}
+
+// KubeadmImage is an image kubeadm uses.
+type KubeadmImage struct {
+	// Component is what the image runs, e.g. etcd or dns.
+	Component string
+	Image     string
+}
+
+// GetKubeadmImages returns the images kubeadm uses for version, with the
+// control plane's pulled from imageRepository, as kubeadm's config names
+// them, and what each runs, e.g. so that the images can be listed before
+// they're cached.
+func GetKubeadmImages(imageRepository, version string) []KubeadmImage {
+	v := parseKubernetesVersion(version)
+	repo := GetImageRepository(imageRepository, version)
+	tags := getKubeadmImageTags(v)
+	images := []KubeadmImage{
+		{Component: "dashboard", Image: "gcr.io/google_containers/kubernetes-dashboard-amd64:v1.6.3"},
+		{Component: "addon-manager", Image: "gcr.io/google-containers/kube-addon-manager:v6.4-beta.2"},
+		{Component: "pause", Image: GetPauseImage(imageRepository, version)},
+	}
+	if tags.coreDNS != "" {
+		images = append(images, KubeadmImage{Component: "dns", Image: repo + "/" + tags.coreDNS})
+	} else {
+		images = append(images,
+			KubeadmImage{Component: "dns", Image: imageName(repo, "k8s-dns-kube-dns", v) + ":" + tags.kubeDNS},
+			KubeadmImage{Component: "dns", Image: imageName(repo, "k8s-dns-dnsmasq-nanny", v) + ":" + tags.kubeDNS},
+			KubeadmImage{Component: "dns", Image: imageName(repo, "k8s-dns-sidecar", v) + ":" + tags.kubeDNS},
+		)
+	}
+	return append(images,
+		KubeadmImage{Component: "etcd", Image: imageName(repo, "etcd", v) + ":" + tags.etcd},
+		KubeadmImage{Component: "proxy", Image: imageName(repo, "kube-proxy", v) + ":" + version},
+		KubeadmImage{Component: "scheduler", Image: imageName(repo, "kube-scheduler", v) + ":" + version},
+		KubeadmImage{Component: "controller-manager", Image: imageName(repo, "kube-controller-manager", v) + ":" + version},
+		KubeadmImage{Component: "apiserver", Image: imageName(repo, "kube-apiserver", v) + ":" + version},
+	)
+}
+
+// GetKubeadmCachedImages returns the names of the images kubeadm uses for
+// version, with the control plane's pulled from imageRepository, as
+// GetKubeadmImages lists them.
+func GetKubeadmCachedImages(imageRepository, version string) []string {
+	var names []string
+	for _, image := range GetKubeadmImages(imageRepository, version) {
+		names = append(names, image.Image)
+	}
+	return names
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package machine
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
+	"k8s.io/client-go/util/homedir"
 	"k8s.io/minikube/pkg/minikube/assets"
 	"k8s.io/minikube/pkg/minikube/bootstrapper"
 	"k8s.io/minikube/pkg/minikube/constants"
@@ -32,6 +37,7 @@ import (
 	"github.com/containers/image/copy"
 	"github.com/containers/image/docker"
 	"github.com/containers/image/docker/archive"
+	"github.com/containers/image/manifest"
 	"github.com/containers/image/signature"
 	"github.com/containers/image/types"
 	"github.com/golang/glog"
@@ -40,10 +46,48 @@ import (
 
 const tempLoadDir = "/tmp"
 
-func CacheImagesForBootstrapper(version string, clusterBootstrapper string) error {
-	images := bootstrapper.GetCachedImageList(version, clusterBootstrapper)
+// cachedImageTimeout bounds how long LoadFromCacheBlocking waits for an
+// image to be cached, and cachedImagePollInterval is how often it checks.
+var (
+	cachedImageTimeout      = 10 * time.Minute
+	cachedImagePollInterval = 100 * time.Millisecond
+)
+
+// imageLoadAttempts is how many times loading a cached image is tried before
+// it's given up on, and imageLoadRetryInterval how long to wait in between.
+var (
+	imageLoadAttempts      = 3
+	imageLoadRetryInterval = 2 * time.Second
+)
+
+// ValidateImageCacheDir expands a leading ~ in dir to the user's home
+// directory, makes it absolute, and checks that images can be cached in it,
+// creating it if needed, so a bad cache directory fails before any image is
+// pulled. It returns the directory to use.
+func ValidateImageCacheDir(dir string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		dir = filepath.Join(homedir.HomeDir(), dir[1:])
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "image cache directory %s", dir)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", errors.Wrapf(err, "creating image cache directory %s", dir)
+	}
+	f, err := ioutil.TempFile(dir, ".writable")
+	if err != nil {
+		return "", errors.Wrapf(err, "image cache directory %s is not writable", dir)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return dir, nil
+}
+
+func CacheImagesForBootstrapper(imageRepository, version, clusterBootstrapper, cacheDir string) error {
+	images := bootstrapper.GetCachedImageList(imageRepository, version, clusterBootstrapper)
 
-	if err := CacheImages(images, constants.ImageCacheDir); err != nil {
+	if err := CacheImages(images, cacheDir); err != nil {
 		return errors.Wrapf(err, "Caching images for %s", clusterBootstrapper)
 	}
 
@@ -76,23 +120,205 @@ func CacheImages(images []string, cacheDir string) error {
 }
 
 func LoadImages(cmd bootstrapper.CommandRunner, images []string, cacheDir string) error {
-	var g errgroup.Group
+	failed := LoadCachedImages(cmd, "", images, cacheDir, DefaultImageLoadParallelism, nil)
+	if len(failed) > 0 {
+		var names []string
+		for image := range failed {
+			names = append(names, image)
+		}
+		sort.Strings(names)
+		return errors.Errorf("loading cached images %s", strings.Join(names, ", "))
+	}
+	glog.Infoln("Successfully loaded all cached images.")
+	return nil
+}
+
+// DefaultImageLoadParallelism is how many images LoadImages loads at once.
+// Each docker load buffers its image in the machine's memory, so loading
+// every image at once can run a small machine out of it.
+const DefaultImageLoadParallelism = 2
+
+// ImageLoadProgress is the progress of loading a cached image.
+type ImageLoadProgress struct {
+	Image string
+	// Size is the size of the image's cached tarball, or -1 if it's
+	// unknown.
+	Size int64
+	// Finished is set once the image has loaded, or failed to.
+	Finished bool
+	// Err is why the image failed to load.
+	Err error
+	// Duration is how long the image took to transfer to the node and
+	// load, once Finished.
+	Duration time.Duration
+	// Bundled are the images in a bundle made by BundleCachedImages, when
+	// Image is the bundle's name rather than an image's.
+	Bundled []string
+	// Repaired is set when the image loaded without the ID recorded when
+	// it was cached, e.g. as its tarball was corrupted, and was loaded
+	// again, once Finished.
+	Repaired bool
+}
+
+// ImageLoadProgressFunc is called as images start and finish loading.
+// Images are loaded concurrently, so it must be safe to call from several
+// goroutines.
+type ImageLoadProgressFunc func(ImageLoadProgress)
+
+// LoadCachedImages loads images from cacheDir into the container runtime,
+// e.g. containerd, at most parallelism at once, verifying their IDs, and
+// returns why each image that failed to load did. Each load is retried a
+// few times, and an image failing doesn't stop the others loading.
+// progress, if set, is called as each image starts and finishes loading.
+func LoadCachedImages(cmd bootstrapper.CommandRunner, runtime string, images []string, cacheDir string, parallelism int, progress ImageLoadProgressFunc) map[string]error {
+	if _, err := getImageRuntime(runtime); err != nil {
+		failed := map[string]error{}
+		for _, image := range images {
+			failed[image] = err
+		}
+		return failed
+	}
+	if parallelism <= 0 {
+		parallelism = DefaultImageLoadParallelism
+	}
+	if progress == nil {
+		progress = func(ImageLoadProgress) {}
+	}
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, parallelism)
+		failed = map[string]error{}
+	)
 	for _, image := range images {
 		image := image
-		g.Go(func() error {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 			src := filepath.Join(cacheDir, image)
 			src = sanitizeCacheDir(src)
-			if err := LoadFromCacheBlocking(cmd, src); err != nil {
-				return errors.Wrapf(err, "loading image %s", src)
+			// Images still being cached mustn't hold up loading those
+			// that are.
+			err := waitForCachedImage(src)
+			size := int64(-1)
+			if fi, statErr := os.Stat(src); statErr == nil {
+				size = fi.Size()
 			}
-			return nil
-		})
+			var d time.Duration
+			repaired := false
+			if err == nil {
+				progress(ImageLoadProgress{Image: image, Size: size})
+				start := time.Now()
+				repaired, err = loadCachedImage(cmd, runtime, image, src, sem)
+				d = time.Since(start)
+			}
+			if err != nil {
+				err = errors.Wrapf(err, "loading image %s", src)
+				mu.Lock()
+				failed[image] = err
+				mu.Unlock()
+			}
+			progress(ImageLoadProgress{Image: image, Size: size, Finished: true, Err: err, Duration: d, Repaired: repaired})
+		}()
 	}
-	if err := g.Wait(); err != nil {
-		return errors.Wrap(err, "loading cached images")
+	wg.Wait()
+	return failed
+}
+
+// loadCachedImage loads image from its cached tarball at src, holding sem
+// while it does, and verifies its ID, returning whether it was repaired.
+// Loads are retried, e.g. after the docker daemon fails to process the
+// tarball, up to imageLoadAttempts times, releasing sem in between.
+func loadCachedImage(cmd bootstrapper.CommandRunner, runtime, image, src string, sem chan struct{}) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		sem <- struct{}{}
+		err := loadCachedTarball(cmd, runtime, src, []string{image})
+		repaired := false
+		if err == nil {
+			repaired, err = verifyLoadedImage(cmd, runtime, image, src)
+		}
+		<-sem
+		if err == nil {
+			return repaired, nil
+		}
+		if attempt >= imageLoadAttempts {
+			if attempt > 1 {
+				err = errors.Wrapf(err, "after %d attempts", attempt)
+			}
+			return false, err
+		}
+		glog.Warningf("Error loading %s, retrying in %s: %s", image, imageLoadRetryInterval, err)
+		time.Sleep(imageLoadRetryInterval)
 	}
-	glog.Infoln("Successfully loaded all cached images.")
-	return nil
+}
+
+// ImagesNotInRuntime returns the images that aren't already in the node's
+// container runtime, e.g. containerd, so that loading them again can be
+// skipped. Images are matched by repo:tag, or by digest for images named by
+// one. Either may be <none> in the runtime, e.g. for an image whose tag has
+// moved to a newer one, or that was loaded from a tarball.
+func ImagesNotInRuntime(cmd bootstrapper.CommandRunner, runtime string, images []string) ([]string, error) {
+	r, err := getImageRuntime(runtime)
+	if err != nil {
+		return nil, err
+	}
+	present, err := runtimeImages(cmd, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, image := range images {
+		if !present[image] {
+			missing = append(missing, image)
+		}
+	}
+	if skipped := len(images) - len(missing); skipped > 0 {
+		glog.Infof("Skipping %d of %d cached images, already in the container runtime", skipped, len(images))
+	}
+	return missing, nil
+}
+
+// runtimeImages returns the references of the images in the node's container
+// runtime, as repo:tag and repo@digest, without those ending in <none>.
+func runtimeImages(cmd bootstrapper.CommandRunner, r imageRuntime) (map[string]bool, error) {
+	out, err := cmd.CombinedOutput(r.listCmd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing runtime images: %s", out)
+	}
+	present := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		for _, ref := range strings.Fields(line) {
+			if !strings.HasSuffix(ref, "<none>") {
+				present[ref] = true
+			}
+		}
+	}
+	return present, nil
+}
+
+// VerifyCachedImages returns the images kubeadm needs for the given
+// Kubernetes version, pulled from imageRepository, that aren't in the image
+// cache in cacheDir, so that offline users know what they still need to
+// pull.
+func VerifyCachedImages(imageRepository, version, cacheDir string) ([]string, error) {
+	return MissingCachedImages(constants.GetKubeadmCachedImages(imageRepository, version), cacheDir)
+}
+
+// MissingCachedImages returns the images that aren't in the image cache in
+// cacheDir.
+func MissingCachedImages(images []string, cacheDir string) ([]string, error) {
+	var missing []string
+	for _, image := range images {
+		path := sanitizeCacheDir(filepath.Join(cacheDir, image))
+		if _, err := os.Stat(path); err != nil {
+			if !os.IsNotExist(err) {
+				return nil, errors.Wrapf(err, "checking cached image %s", path)
+			}
+			missing = append(missing, image)
+		}
+	}
+	return missing, nil
 }
 
 // # ParseReference cannot have a : in the directory path
@@ -122,13 +348,77 @@ func hasWindowsDriveLetter(s string) bool {
 	return false
 }
 
-func LoadFromCacheBlocking(cmd bootstrapper.CommandRunner, src string) error {
-	glog.Infoln("Loading image from cache at ", src)
-	filename := filepath.Base(src)
+// waitForCachedImage waits for the image at src to be cached, as it may
+// still be being cached.
+func waitForCachedImage(src string) error {
+	deadline := time.Now().Add(cachedImageTimeout)
 	for {
 		if _, err := os.Stat(src); err == nil {
-			break
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("%s wasn't cached within %s", src, cachedImageTimeout)
 		}
+		time.Sleep(cachedImagePollInterval)
+	}
+}
+
+// loadCachedTarball loads the cached tarball at src, holding images, into the
+// container runtime. The tarball is streamed into the runtime's load command,
+// so that it isn't written to the node's disk first, which would need twice
+// its size free. If streaming fails, or the runtime doesn't list the images
+// afterwards, the tarball is copied to the node and loaded from there
+// instead.
+func loadCachedTarball(cmd bootstrapper.CommandRunner, runtime, src string, images []string) error {
+	err := streamFromCache(cmd, runtime, src, images)
+	if err == nil {
+		return nil
+	}
+	glog.Infof("Falling back to copying %s to the node: %v", src, err)
+	return LoadFromCacheBlocking(cmd, runtime, src)
+}
+
+// streamFromCache streams the cached tarball at src into the container
+// runtime's load command, and checks that the runtime lists images
+// afterwards.
+func streamFromCache(cmd bootstrapper.CommandRunner, runtime, src string, images []string) error {
+	r, err := getImageRuntime(runtime)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "opening cached image")
+	}
+	defer f.Close()
+	if err := cmd.RunWithInput(r.streamLoadCmd, f); err != nil {
+		return errors.Wrapf(err, "streaming image: %s", src)
+	}
+
+	present, err := runtimeImages(cmd, r)
+	if err != nil {
+		return errors.Wrap(err, "verifying streamed images")
+	}
+	for _, image := range images {
+		if !present[image] {
+			return errors.Errorf("%s isn't in the container runtime after streaming it", image)
+		}
+	}
+	glog.Infof("Successfully streamed image %s from cache", src)
+	return nil
+}
+
+// LoadFromCacheBlocking loads the cached image at src into the container
+// runtime, waiting for it to be cached first.
+func LoadFromCacheBlocking(cmd bootstrapper.CommandRunner, runtime, src string) error {
+	r, err := getImageRuntime(runtime)
+	if err != nil {
+		return err
+	}
+	glog.Infoln("Loading image from cache at ", src)
+	filename := filepath.Base(src)
+	if err := waitForCachedImage(src); err != nil {
+		return err
 	}
 	dst := filepath.Join(tempLoadDir, filename)
 	f, err := assets.NewFileAsset(src, tempLoadDir, filename, "0777")
@@ -139,14 +429,14 @@ func LoadFromCacheBlocking(cmd bootstrapper.CommandRunner, src string) error {
 		return errors.Wrap(err, "transferring cached image")
 	}
 
-	dockerLoadCmd := "docker load -i " + dst
+	loadCmd := fmt.Sprintf(r.loadCmd, dst)
 
-	if err := cmd.Run(dockerLoadCmd); err != nil {
-		return errors.Wrapf(err, "loading docker image: %s", dst)
+	if err := cmd.Run(loadCmd); err != nil {
+		return errors.Wrapf(err, "loading image: %s", dst)
 	}
 
 	if err := cmd.Run("rm -rf " + dst); err != nil {
-		return errors.Wrap(err, "deleting temp docker image location")
+		return errors.Wrap(err, "deleting temp image location")
 	}
 
 	glog.Infof("Successfully loaded image %s from cache", src)
@@ -202,24 +492,130 @@ func CacheImage(image, dst string) error {
 		return errors.Wrap(err, "getting policy context")
 	}
 
+	sourceCtx, cleanup, err := newSourceCtx()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = copy.Image(policyContext, dstRef, srcRef, &copy.Options{
+		SourceCtx: sourceCtx,
+	})
+	if err != nil {
+		return errors.Wrap(err, "copying image")
+	}
+
+	return recordImageID(dst)
+}
+
+// newSourceCtx returns the context images are pulled with, and a function
+// that cleans it up.
+func newSourceCtx() (*types.SystemContext, func(), error) {
 	tmp, err := ioutil.TempDir("", "")
 	if err != nil {
-		return errors.Wrap(err, "making temp dir")
+		return nil, nil, errors.Wrap(err, "making temp dir")
 	}
-	defer os.RemoveAll(tmp)
 	sourceCtx := &types.SystemContext{
 		// By default, the image library will try to look at /etc/docker/certs.d
 		// As a non-root user, this would result in a permissions error,
 		// so, we skip this step by just looking in a newly created tmpdir.
 		DockerCertPath: tmp,
 	}
+	return sourceCtx, func() { os.RemoveAll(tmp) }, nil
+}
 
-	err = copy.Image(policyContext, dstRef, srcRef, &copy.Options{
-		SourceCtx: sourceCtx,
-	})
+// digestSuffix is appended to a cached image's path for the file recording
+// the digest of the manifest it was cached from.
+const digestSuffix = ".digest"
+
+// remoteDigest returns the digest of image's manifest in its registry.
+var remoteDigest = func(image string) (string, error) {
+	ref, err := getSrcRef(image)
 	if err != nil {
-		return errors.Wrap(err, "copying image")
+		return "", err
+	}
+	sourceCtx, cleanup, err := newSourceCtx()
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	src, err := ref.NewImageSource(sourceCtx, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "reaching registry for %s", image)
+	}
+	defer src.Close()
+	m, _, err := src.GetManifest()
+	if err != nil {
+		return "", errors.Wrapf(err, "getting manifest of %s", image)
+	}
+	d, err := manifest.Digest(m)
+	if err != nil {
+		return "", errors.Wrapf(err, "computing digest of %s", image)
 	}
+	return d.String(), nil
+}
 
+// pullImage pulls image to dst.
+var pullImage = CacheImage
+
+// CacheImagesForVersion caches the images kubeadm needs for version, pulled
+// from imageRepository, on the host, in cacheDir, ahead of starting a
+// cluster, so it needs no machine. It returns why each image that couldn't
+// be cached wasn't; the others are cached regardless.
+func CacheImagesForVersion(imageRepository, version, cacheDir string) map[string]error {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = map[string]error{}
+	)
+	for _, image := range constants.GetKubeadmCachedImages(imageRepository, version) {
+		image := image
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := CacheImageByDigest(image, cacheDir); err != nil {
+				mu.Lock()
+				failed[image] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+// CacheImageByDigest caches image in cacheDir, unless the digest of the
+// cached image is still the one in its registry, so that caching images
+// again is cheap. A cached image is kept when the registry can't be
+// reached, so that it can be used offline.
+func CacheImageByDigest(image, cacheDir string) error {
+	dst := sanitizeCacheDir(filepath.Join(cacheDir, image))
+	digest, err := remoteDigest(image)
+
+	if _, statErr := os.Stat(dst); statErr == nil {
+		if err != nil {
+			glog.Infof("Keeping cached %s, as its digest couldn't be checked: %s", image, err)
+			return nil
+		}
+		recorded, readErr := ioutil.ReadFile(dst + digestSuffix)
+		if readErr == nil && strings.TrimSpace(string(recorded)) == digest {
+			glog.Infof("Cached %s is up to date", image)
+			return nil
+		}
+		glog.Infof("Cached %s is out of date, caching %s", image, digest)
+		if err := os.Remove(dst); err != nil {
+			return errors.Wrapf(err, "removing out of date %s", dst)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := pullImage(image, dst); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dst+digestSuffix, []byte(digest+"\n"), 0644); err != nil {
+		return errors.Wrapf(err, "recording digest of %s", image)
+	}
 	return nil
 }
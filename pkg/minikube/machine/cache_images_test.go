@@ -17,8 +17,19 @@ limitations under the License.
 package machine
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"k8s.io/minikube/pkg/minikube/assets"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
 	"k8s.io/minikube/pkg/minikube/constants"
 )
 
@@ -29,3 +40,589 @@ func TestGetSrcRef(t *testing.T) {
 		}
 	}
 }
+
+func TestMissingImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := constants.GetKubeadmCachedImages("", "v1.8.0")
+	cached := images[:len(images)/2]
+	for _, image := range cached {
+		path := sanitizeCacheDir(filepath.Join(dir, image))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Error making cache dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+			t.Fatalf("Error writing cached image: %s", err)
+		}
+	}
+
+	missing, err := MissingCachedImages(images, dir)
+	if err != nil {
+		t.Fatalf("Error checking cached images: %s", err)
+	}
+	if expected := images[len(images)/2:]; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing images %v, got %v", expected, missing)
+	}
+}
+
+func TestImagesNotInRuntime(t *testing.T) {
+	images := []string{
+		"gcr.io/google_containers/pause-amd64:3.0",
+		"gcr.io/google_containers/etcd-amd64:3.0.17",
+		"gcr.io/google_containers/kube-apiserver-amd64:v1.8.0",
+		"registry.example.com/kube-proxy@sha256:abc",
+	}
+	cases := []struct {
+		description string
+		output      string
+		expected    []string
+	}{
+		{
+			description: "empty runtime",
+			expected:    images,
+		},
+		{
+			description: "some loaded",
+			output: `gcr.io/google_containers/pause-amd64:3.0 gcr.io/google_containers/pause-amd64@<none>
+gcr.io/google_containers/etcd-amd64:3.0.17 gcr.io/google_containers/etcd-amd64@sha256:def
+`,
+			expected: images[2:],
+		},
+		{
+			description: "untagged images don't match",
+			output: `gcr.io/google_containers/kube-apiserver-amd64:<none> gcr.io/google_containers/kube-apiserver-amd64@<none>
+<none>:<none> <none>@<none>
+`,
+			expected: images,
+		},
+		{
+			description: "matched by digest",
+			output: `registry.example.com/kube-proxy:<none> registry.example.com/kube-proxy@sha256:abc
+`,
+			expected: images[:3],
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(map[string]string{dockerImageRuntime.listCmd: test.output})
+			missing, err := ImagesNotInRuntime(r, "", images)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(missing, test.expected) {
+				t.Errorf("Expected images to load %v, got %v", test.expected, missing)
+			}
+		})
+	}
+}
+
+func TestLoadCachedImagesNotCached(t *testing.T) {
+	defer func(d time.Duration) { cachedImageTimeout = d }(cachedImageTimeout)
+	cachedImageTimeout = 50 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := []string{"gcr.io/google_containers/pause-amd64:3.0"}
+	failed := LoadCachedImages(bootstrapper.NewFakeCommandRunner(), "", images, dir, 1, nil)
+	if err := failed[images[0]]; err == nil || !strings.Contains(err.Error(), "wasn't cached") {
+		t.Errorf("Expected %s to fail to load, got %v", images[0], failed)
+	}
+	if err := LoadImages(bootstrapper.NewFakeCommandRunner(), images, dir); err == nil || !strings.Contains(err.Error(), images[0]) {
+		t.Errorf("Expected an error naming %s, got %v", images[0], err)
+	}
+}
+
+func TestLoadCachedImagesRuntimes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	image := "gcr.io/google_containers/pause-amd64:3.0"
+	path := sanitizeCacheDir(filepath.Join(dir, image))
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Error making cache dir: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+		t.Fatalf("Error writing cached image: %s", err)
+	}
+
+	cases := []struct {
+		runtime   string
+		loadCmd   string
+		shouldErr bool
+	}{
+		{runtime: "", loadCmd: "docker load -i /tmp/pause-amd64_3.0"},
+		{runtime: "docker", loadCmd: "docker load -i /tmp/pause-amd64_3.0"},
+		{runtime: "containerd", loadCmd: "sudo ctr -n=k8s.io images import /tmp/pause-amd64_3.0"},
+		{runtime: "cri-o", loadCmd: "sudo podman load -i /tmp/pause-amd64_3.0"},
+		{runtime: "crio", loadCmd: "sudo podman load -i /tmp/pause-amd64_3.0"},
+		{runtime: "rkt", shouldErr: true},
+	}
+
+	for _, test := range cases {
+		t.Run(test.runtime, func(t *testing.T) {
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(map[string]string{
+				test.loadCmd:                  "",
+				"rm -rf /tmp/pause-amd64_3.0": "",
+			})
+			failed := LoadCachedImages(r, test.runtime, []string{image}, dir, 1, nil)
+			if test.shouldErr {
+				if err := failed[image]; err == nil || !strings.Contains(err.Error(), "not supported") {
+					t.Errorf("Expected %s to fail to load into %s, got %v", image, test.runtime, failed)
+				}
+				if _, err := ImagesNotInRuntime(r, test.runtime, []string{image}); err == nil {
+					t.Errorf("Expected listing %s's images to fail", test.runtime)
+				}
+				return
+			}
+			if len(failed) > 0 {
+				t.Errorf("Expected %s to load with %q, got %v", image, test.loadCmd, failed)
+			}
+		})
+	}
+}
+
+func TestLoadCachedImagesStreaming(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	image := "gcr.io/google_containers/pause-amd64:3.0"
+	path := sanitizeCacheDir(filepath.Join(dir, image))
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Error making cache dir: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+		t.Fatalf("Error writing cached image: %s", err)
+	}
+	fallback := map[string]string{
+		"docker load -i /tmp/pause-amd64_3.0": "",
+		"rm -rf /tmp/pause-amd64_3.0":         "",
+	}
+
+	cases := []struct {
+		description string
+		commands    map[string]string
+		copied      bool
+	}{
+		{
+			description: "streamed",
+			commands: map[string]string{
+				"docker load":              "",
+				dockerImageRuntime.listCmd: image + " gcr.io/google_containers/pause-amd64@<none>\n",
+			},
+		},
+		{
+			description: "streamed but not listed",
+			commands: map[string]string{
+				"docker load":              "",
+				dockerImageRuntime.listCmd: "",
+			},
+			copied: true,
+		},
+		{
+			description: "streaming failed",
+			copied:      true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(test.commands)
+			r.SetCommandToOutput(fallback)
+			if failed := LoadCachedImages(r, "", []string{image}, dir, 1, nil); len(failed) > 0 {
+				t.Fatalf("Expected %s to load, got %v", image, failed)
+			}
+			if _, err := r.GetFileToContents(path); (err == nil) != test.copied {
+				t.Errorf("Expected %s to be copied to the node: %t, got %v", image, test.copied, err == nil)
+			}
+			if in, err := r.GetCommandInput("docker load"); err == nil && in != "image" {
+				t.Errorf("Expected the cached image to be streamed, got %q", in)
+			}
+		})
+	}
+}
+
+func TestImagesNotInRuntimeContainerd(t *testing.T) {
+	images := []string{
+		"gcr.io/google_containers/pause-amd64:3.0",
+		"gcr.io/google_containers/etcd-amd64:3.0.17",
+	}
+	r := bootstrapper.NewFakeCommandRunner()
+	r.SetCommandToOutput(map[string]string{"sudo ctr -n=k8s.io images list -q": `gcr.io/google_containers/pause-amd64:3.0
+gcr.io/google_containers/pause-amd64@sha256:f04288efc7e65a84be74d4fc63e235ac3c6c603cf832e442e0bd3f240b10a91b
+`})
+	missing, err := ImagesNotInRuntime(r, "containerd", images)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(missing, images[1:]) {
+		t.Errorf("Expected images to load %v, got %v", images[1:], missing)
+	}
+}
+
+// slowLoadRunner is a command runner whose docker loads take loadTime, and
+// fail for images named in fail. It records how many run at once.
+type slowLoadRunner struct {
+	*bootstrapper.FakeCommandRunner
+	loadTime   time.Duration
+	fail       string
+	running    int32
+	maxRunning int32
+}
+
+func (r *slowLoadRunner) Copy(assets.CopyableFile) error {
+	return nil
+}
+
+func (r *slowLoadRunner) Run(cmd string) error {
+	if !strings.HasPrefix(cmd, "docker load") {
+		return nil
+	}
+	n := atomic.AddInt32(&r.running, 1)
+	defer atomic.AddInt32(&r.running, -1)
+	for {
+		max := atomic.LoadInt32(&r.maxRunning)
+		if n <= max || atomic.CompareAndSwapInt32(&r.maxRunning, max, n) {
+			break
+		}
+	}
+	time.Sleep(r.loadTime)
+	if r.fail != "" && strings.Contains(cmd, r.fail) {
+		return fmt.Errorf("invalid tar header")
+	}
+	return nil
+}
+
+func TestLoadCachedImagesParallelism(t *testing.T) {
+	// Retrying the failing image would skew the timing.
+	defer func(n int) { imageLoadAttempts = n }(imageLoadAttempts)
+	imageLoadAttempts = 1
+
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var images []string
+	for i := 0; i < 6; i++ {
+		image := fmt.Sprintf("gcr.io/google_containers/image-%d:v1", i)
+		images = append(images, image)
+		path := sanitizeCacheDir(filepath.Join(dir, image))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Error making cache dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+			t.Fatalf("Error writing cached image: %s", err)
+		}
+	}
+
+	loadTime := 50 * time.Millisecond
+	r := &slowLoadRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), loadTime: loadTime, fail: "image-2"}
+	var mu sync.Mutex
+	var started, finished []string
+	progress := func(p ImageLoadProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Finished {
+			finished = append(finished, p.Image)
+		} else {
+			started = append(started, p.Image)
+		}
+		if p.Size != int64(len("image")) {
+			t.Errorf("Expected %s's size to be reported, got %d", p.Image, p.Size)
+		}
+	}
+
+	start := time.Now()
+	failed := LoadCachedImages(r, "", images, dir, 2, progress)
+	elapsed := time.Since(start)
+
+	if r.maxRunning != 2 {
+		t.Errorf("Expected 2 images to load at once, got %d", r.maxRunning)
+	}
+	// Six loads, two at a time, take three rounds.
+	if min, max := 3*loadTime, 6*loadTime; elapsed < min || elapsed >= max {
+		t.Errorf("Expected loading to take between %s and %s, took %s", min, max, elapsed)
+	}
+	if len(failed) != 1 || failed[images[2]] == nil {
+		t.Errorf("Expected only %s to fail, got %v", images[2], failed)
+	}
+	if len(started) != len(images) || len(finished) != len(images) {
+		t.Errorf("Expected progress for all %d images, got %d started and %d finished", len(images), len(started), len(finished))
+	}
+}
+
+// flakyLoadRunner is a command runner whose docker loads of an image fail
+// as many times as failures has for it, and then succeed.
+type flakyLoadRunner struct {
+	*bootstrapper.FakeCommandRunner
+	mu       sync.Mutex
+	failures map[string]int
+	loads    map[string]int
+}
+
+func (r *flakyLoadRunner) Copy(assets.CopyableFile) error {
+	return nil
+}
+
+func (r *flakyLoadRunner) Run(cmd string) error {
+	if !strings.HasPrefix(cmd, "docker load") {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, n := range r.failures {
+		if strings.Contains(cmd, name) {
+			r.loads[name]++
+			if r.loads[name] <= n {
+				return fmt.Errorf("Error processing tar file(exit status 1): unexpected EOF")
+			}
+		}
+	}
+	return nil
+}
+
+func TestLoadCachedImagesRetries(t *testing.T) {
+	defer func(n int, d time.Duration) {
+		imageLoadAttempts, imageLoadRetryInterval = n, d
+	}(imageLoadAttempts, imageLoadRetryInterval)
+	imageLoadAttempts, imageLoadRetryInterval = 3, time.Millisecond
+
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var images []string
+	for i := 0; i < 3; i++ {
+		image := fmt.Sprintf("gcr.io/google_containers/image-%d:v1", i)
+		images = append(images, image)
+		path := sanitizeCacheDir(filepath.Join(dir, image))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Error making cache dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte("image"), 0644); err != nil {
+			t.Fatalf("Error writing cached image: %s", err)
+		}
+	}
+
+	r := &flakyLoadRunner{
+		FakeCommandRunner: bootstrapper.NewFakeCommandRunner(),
+		// image-0 fails once, and image-1 every time, which mustn't stop
+		// image-2 loading.
+		failures: map[string]int{"image-0": 1, "image-1": 3},
+		loads:    map[string]int{},
+	}
+	failed := LoadCachedImages(r, "", images, dir, 1, nil)
+	if len(failed) != 1 || failed[images[1]] == nil {
+		t.Fatalf("Expected only %s to fail, got %v", images[1], failed)
+	}
+	if err := failed[images[1]]; !strings.Contains(err.Error(), "after 3 attempts") || !strings.Contains(err.Error(), "Error processing tar file") {
+		t.Errorf("Expected the error to name the attempts and the last failure, got %s", err)
+	}
+	if r.loads["image-0"] != 2 || r.loads["image-1"] != 3 {
+		t.Errorf("Expected image-0 to load twice and image-1 three times, got %v", r.loads)
+	}
+}
+
+func TestCacheImageByDigest(t *testing.T) {
+	const image = "gcr.io/google_containers/pause-amd64:3.0"
+	cases := []struct {
+		description string
+		cached      bool
+		recorded    string
+		remote      string
+		pullErr     error
+		shouldPull  bool
+		shouldErr   bool
+	}{
+		{
+			description: "not cached",
+			remote:      "sha256:new",
+			shouldPull:  true,
+		},
+		{
+			description: "cached with the same digest",
+			cached:      true,
+			recorded:    "sha256:old",
+			remote:      "sha256:old",
+		},
+		{
+			description: "cached with a different digest",
+			cached:      true,
+			recorded:    "sha256:old",
+			remote:      "sha256:new",
+			shouldPull:  true,
+		},
+		{
+			description: "cached without a digest",
+			cached:      true,
+			remote:      "sha256:new",
+			shouldPull:  true,
+		},
+		{
+			description: "cached and registry unreachable",
+			cached:      true,
+			recorded:    "sha256:old",
+		},
+		{
+			description: "not cached and registry unreachable",
+			shouldErr:   true,
+		},
+		{
+			description: "pull fails",
+			remote:      "sha256:new",
+			pullErr:     fmt.Errorf("unauthorized"),
+			shouldPull:  true,
+			shouldErr:   true,
+		},
+	}
+
+	defer func(f func(string) (string, error)) { remoteDigest = f }(remoteDigest)
+	defer func(f func(string, string) error) { pullImage = f }(pullImage)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "minikube-image-cache")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(dir)
+			dst := sanitizeCacheDir(filepath.Join(dir, image))
+			if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+				t.Fatalf("Error making cache dir: %s", err)
+			}
+			if test.cached {
+				if err := ioutil.WriteFile(dst, []byte("old image"), 0644); err != nil {
+					t.Fatalf("Error writing cached image: %s", err)
+				}
+			}
+			if test.recorded != "" {
+				if err := ioutil.WriteFile(dst+digestSuffix, []byte(test.recorded+"\n"), 0644); err != nil {
+					t.Fatalf("Error writing digest: %s", err)
+				}
+			}
+
+			remoteDigest = func(string) (string, error) {
+				if test.remote == "" {
+					return "", fmt.Errorf("dial tcp: no route to host")
+				}
+				return test.remote, nil
+			}
+			pulled := false
+			pullImage = func(_, dst string) error {
+				pulled = true
+				if _, err := os.Stat(dst); err == nil {
+					t.Errorf("Expected the out of date image to be removed before pulling")
+				}
+				if test.pullErr != nil {
+					return test.pullErr
+				}
+				return ioutil.WriteFile(dst, []byte("new image"), 0644)
+			}
+
+			err = CacheImageByDigest(image, dir)
+			if test.shouldErr != (err != nil) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if pulled != test.shouldPull {
+				t.Errorf("Expected pulled to be %t, got %t", test.shouldPull, pulled)
+			}
+			if test.shouldPull && !test.shouldErr {
+				if b, err := ioutil.ReadFile(dst + digestSuffix); err != nil || strings.TrimSpace(string(b)) != test.remote {
+					t.Errorf("Expected digest %s to be recorded, got %q: %v", test.remote, b, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheImagesForVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := constants.GetKubeadmCachedImages("", "v1.8.0")
+	defer func(f func(string) (string, error)) { remoteDigest = f }(remoteDigest)
+	remoteDigest = func(string) (string, error) { return "sha256:abc", nil }
+	defer func(f func(string, string) error) { pullImage = f }(pullImage)
+	var pulls int32
+	pullImage = func(image, dst string) error {
+		atomic.AddInt32(&pulls, 1)
+		if image == images[0] {
+			return fmt.Errorf("manifest unknown")
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, []byte("image"), 0644)
+	}
+
+	failed := CacheImagesForVersion("", "v1.8.0", dir)
+	if len(failed) != 1 || failed[images[0]] == nil {
+		t.Errorf("Expected only %s to fail, got %v", images[0], failed)
+	}
+	missing, err := MissingCachedImages(images, dir)
+	if err != nil {
+		t.Fatalf("Error checking cached images: %s", err)
+	}
+	if !reflect.DeepEqual(missing, images[:1]) {
+		t.Errorf("Expected every other image to be cached, missing %v", missing)
+	}
+
+	// Caching again only pulls the image that failed.
+	atomic.StoreInt32(&pulls, 0)
+	CacheImagesForVersion("", "v1.8.0", dir)
+	if pulls != 1 {
+		t.Errorf("Expected only the missing image to be pulled again, got %d pulls", pulls)
+	}
+}
+
+func TestValidateImageCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	nested := filepath.Join(dir, "nested", "images")
+	got, err := ValidateImageCacheDir(nested)
+	if err != nil {
+		t.Fatalf("Unexpected error validating %s: %s", nested, err)
+	}
+	if got != nested {
+		t.Errorf("Expected %s, got %s", nested, got)
+	}
+	if fi, err := os.Stat(nested); err != nil || !fi.IsDir() {
+		t.Errorf("Expected %s to be created: %v", nested, err)
+	}
+	if files, _ := ioutil.ReadDir(nested); len(files) != 0 {
+		t.Errorf("Expected the writability check to clean up, found %d files", len(files))
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("not a dir"), 0644); err != nil {
+		t.Fatalf("Error writing %s: %s", file, err)
+	}
+	if _, err := ValidateImageCacheDir(file); err == nil {
+		t.Errorf("Expected an error validating a file as the cache directory")
+	}
+}
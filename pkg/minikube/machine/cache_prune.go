@@ -0,0 +1,204 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
+)
+
+// CachedVersion is a Kubernetes version with binaries in the cache.
+type CachedVersion struct {
+	Version string
+	// Size is the total size in bytes of the version's binaries and of the
+	// images specific to the version.
+	Size int64
+	// LastUsed is when the version's binaries were last downloaded or used
+	// to start a cluster.
+	LastUsed time.Time
+}
+
+// ListCachedVersions returns the Kubernetes versions with binaries in the
+// cache, oldest version first.
+func ListCachedVersions() ([]CachedVersion, error) {
+	return listCachedVersions(constants.MakeMiniPath("cache"), constants.ImageCacheDir)
+}
+
+// DeleteCachedVersions removes the binaries and version specific images of
+// the given Kubernetes versions from the cache. It refuses to remove the
+// version used by profile unless force is set.
+func DeleteCachedVersions(profile string, versions []string, force bool) error {
+	active, err := ActiveKubernetesVersion(profile)
+	if err != nil {
+		return err
+	}
+	return deleteCachedVersions(constants.MakeMiniPath("cache"), constants.ImageCacheDir, active, versions, force)
+}
+
+// PruneCachedVersions removes every Kubernetes version from the cache except
+// the one used by profile, and returns the versions removed.
+func PruneCachedVersions(profile string) ([]CachedVersion, error) {
+	active, err := ActiveKubernetesVersion(profile)
+	if err != nil {
+		return nil, err
+	}
+	return pruneCachedVersions(constants.MakeMiniPath("cache"), constants.ImageCacheDir, active)
+}
+
+// ActiveKubernetesVersion returns the Kubernetes version in profile's
+// config, or "" if the profile hasn't been started.
+func ActiveKubernetesVersion(profile string) (string, error) {
+	data, err := ioutil.ReadFile(constants.GetProfileFile(profile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "reading profile %s", profile)
+	}
+	var cc struct {
+		KubernetesConfig struct {
+			KubernetesVersion string
+		}
+	}
+	if err := json.Unmarshal(data, &cc); err != nil {
+		return "", errors.Wrapf(err, "parsing profile %s", profile)
+	}
+	return cc.KubernetesConfig.KubernetesVersion, nil
+}
+
+func listCachedVersions(cacheDir, imageCacheDir string) ([]CachedVersion, error) {
+	entries, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", cacheDir)
+	}
+
+	var versions []CachedVersion
+	for _, e := range entries {
+		if !e.IsDir() || !isVersionDir(e.Name()) {
+			continue
+		}
+		var size int64
+		for _, path := range cachedVersionPaths(cacheDir, imageCacheDir, e.Name()) {
+			s, err := diskUsage(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "getting size of %s", path)
+			}
+			size += s
+		}
+		versions = append(versions, CachedVersion{Version: e.Name(), Size: size, LastUsed: e.ModTime()})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.MustParse(strings.TrimPrefix(versions[i].Version, version.VersionPrefix)).LT(
+			semver.MustParse(strings.TrimPrefix(versions[j].Version, version.VersionPrefix)))
+	})
+	return versions, nil
+}
+
+func deleteCachedVersions(cacheDir, imageCacheDir, active string, versions []string, force bool) error {
+	m := util.MultiError{}
+	for _, v := range versions {
+		if !isVersionDir(v) {
+			m.Collect(fmt.Errorf("invalid kubernetes version %q", v))
+			continue
+		}
+		if v == active && !force {
+			m.Collect(fmt.Errorf("%s is used by the active profile, and can only be removed when forced", v))
+			continue
+		}
+		for _, path := range cachedVersionPaths(cacheDir, imageCacheDir, v) {
+			if err := os.RemoveAll(path); err != nil {
+				m.Collect(errors.Wrapf(err, "removing %s", path))
+			}
+		}
+	}
+	return m.ToError()
+}
+
+func pruneCachedVersions(cacheDir, imageCacheDir, active string) ([]CachedVersion, error) {
+	cached, err := listCachedVersions(cacheDir, imageCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []CachedVersion
+	var names []string
+	for _, v := range cached {
+		if v.Version != active {
+			removed = append(removed, v)
+			names = append(names, v.Version)
+		}
+	}
+	return removed, deleteCachedVersions(cacheDir, imageCacheDir, active, names, false)
+}
+
+// isVersionDir returns whether name is a Kubernetes version, as used for the
+// binary cache directories.
+func isVersionDir(name string) bool {
+	if !strings.HasPrefix(name, version.VersionPrefix) {
+		return false
+	}
+	_, err := semver.Parse(strings.TrimPrefix(name, version.VersionPrefix))
+	return err == nil
+}
+
+// cachedVersionPaths returns the paths in the cache belonging to a
+// Kubernetes version: its binaries directory, and its control plane images
+// with the files recorded beside them.
+// Images shared between versions, such as the addons', aren't included.
+func cachedVersionPaths(cacheDir, imageCacheDir, v string) []string {
+	paths := []string{filepath.Join(cacheDir, v)}
+	for _, image := range constants.GetKubeadmCachedImages("", v) {
+		if strings.HasSuffix(image, ":"+v) {
+			path := sanitizeCacheDir(filepath.Join(imageCacheDir, image))
+			paths = append(paths, cachedImagePaths(path)...)
+		}
+	}
+	return paths
+}
+
+// diskUsage returns the total size of the files under path, or 0 if it
+// doesn't exist.
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// makeCache creates a binary and image cache with the given versions, each
+// with a 10 byte kubelet and a 5 byte kube-apiserver image, and a shared
+// pause image.
+func makeCache(t *testing.T, versions ...string) (string, string) {
+	dir, err := ioutil.TempDir("", "minikube-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	imageCacheDir := filepath.Join(cacheDir, "images")
+	write := func(path string, size int) {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Error making dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Error writing file: %s", err)
+		}
+	}
+	for _, v := range versions {
+		write(filepath.Join(cacheDir, v, "kubelet"), 10)
+		images := constants.GetKubeadmCachedImages("", v)
+		// The last image is v's kube-apiserver.
+		write(sanitizeCacheDir(filepath.Join(imageCacheDir, images[len(images)-1])), 5)
+	}
+	write(sanitizeCacheDir(filepath.Join(imageCacheDir, "gcr.io/google_containers/pause-amd64:3.0")), 5)
+	write(filepath.Join(cacheDir, "iso", "minikube.iso"), 5)
+	return cacheDir, imageCacheDir
+}
+
+func cachedVersionNames(t *testing.T, cacheDir, imageCacheDir string) []string {
+	cached, err := listCachedVersions(cacheDir, imageCacheDir)
+	if err != nil {
+		t.Fatalf("Error listing cached versions: %s", err)
+	}
+	var names []string
+	for _, v := range cached {
+		names = append(names, v.Version)
+	}
+	return names
+}
+
+func TestListCachedVersions(t *testing.T) {
+	cacheDir, imageCacheDir := makeCache(t, "v1.10.0", "v1.8.0", "v1.9.1")
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+
+	cached, err := listCachedVersions(cacheDir, imageCacheDir)
+	if err != nil {
+		t.Fatalf("Error listing cached versions: %s", err)
+	}
+	var names []string
+	for _, v := range cached {
+		names = append(names, v.Version)
+		if v.Size != 15 {
+			t.Errorf("Expected %s to use 15 bytes, got %d", v.Version, v.Size)
+		}
+		if v.LastUsed.IsZero() {
+			t.Errorf("Expected %s to have a last used time", v.Version)
+		}
+	}
+	if expected := []string{"v1.8.0", "v1.9.1", "v1.10.0"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected versions %v, got %v", expected, names)
+	}
+}
+
+func TestDeleteCachedVersions(t *testing.T) {
+	cases := []struct {
+		description string
+		versions    []string
+		force       bool
+		expected    []string
+		shouldErr   bool
+	}{
+		{
+			description: "inactive version",
+			versions:    []string{"v1.8.0"},
+			expected:    []string{"v1.9.1"},
+		},
+		{
+			description: "active version",
+			versions:    []string{"v1.9.1"},
+			expected:    []string{"v1.8.0", "v1.9.1"},
+			shouldErr:   true,
+		},
+		{
+			description: "forced active version",
+			versions:    []string{"v1.9.1"},
+			force:       true,
+			expected:    []string{"v1.8.0"},
+		},
+		{
+			description: "not a version",
+			versions:    []string{"images"},
+			expected:    []string{"v1.8.0", "v1.9.1"},
+			shouldErr:   true,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			cacheDir, imageCacheDir := makeCache(t, "v1.8.0", "v1.9.1")
+			defer os.RemoveAll(filepath.Dir(cacheDir))
+
+			err := deleteCachedVersions(cacheDir, imageCacheDir, "v1.9.1", test.versions, test.force)
+			if err != nil && !test.shouldErr {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			if err == nil && test.shouldErr {
+				t.Error("Expected error, got nil")
+			}
+			if names := cachedVersionNames(t, cacheDir, imageCacheDir); !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("Expected cached versions %v, got %v", test.expected, names)
+			}
+			if _, err := os.Stat(filepath.Join(cacheDir, "iso", "minikube.iso")); err != nil {
+				t.Errorf("Expected the ISO to be kept: %s", err)
+			}
+		})
+	}
+}
+
+func TestPruneCachedVersions(t *testing.T) {
+	cacheDir, imageCacheDir := makeCache(t, "v1.8.0", "v1.9.1", "v1.10.0")
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+
+	removed, err := pruneCachedVersions(cacheDir, imageCacheDir, "v1.9.1")
+	if err != nil {
+		t.Fatalf("Error pruning cache: %s", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 versions to be removed, got %v", removed)
+	}
+	if names := cachedVersionNames(t, cacheDir, imageCacheDir); !reflect.DeepEqual(names, []string{"v1.9.1"}) {
+		t.Errorf("Expected only the active version to be kept, got %v", names)
+	}
+	if _, err := os.Stat(sanitizeCacheDir(filepath.Join(imageCacheDir, "gcr.io/google_containers/kube-apiserver-amd64:v1.8.0"))); !os.IsNotExist(err) {
+		t.Errorf("Expected v1.8.0's images to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(sanitizeCacheDir(filepath.Join(imageCacheDir, "gcr.io/google_containers/pause-amd64:3.0"))); err != nil {
+		t.Errorf("Expected shared images to be kept: %s", err)
+	}
+}
+
+func TestActiveKubernetesVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(constants.MinikubeHome, os.Getenv(constants.MinikubeHome))
+	os.Setenv(constants.MinikubeHome, dir)
+
+	if v, err := ActiveKubernetesVersion("minikube"); err != nil || v != "" {
+		t.Errorf("Expected no version for a profile without config, got %q, %v", v, err)
+	}
+
+	path := constants.GetProfileFile("minikube")
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Error making profile dir: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"KubernetesConfig": {"KubernetesVersion": "v1.9.1"}}`), 0644); err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+	if v, err := ActiveKubernetesVersion("minikube"); err != nil || v != "v1.9.1" {
+		t.Errorf("Expected v1.9.1, got %q, %v", v, err)
+	}
+}
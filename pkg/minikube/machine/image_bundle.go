@@ -0,0 +1,200 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// bundlesDir is the directory of the image cache bundles are kept in.
+const bundlesDir = "bundles"
+
+// archiveManifest is the name of the file listing the images in a docker
+// save archive.
+const archiveManifest = "manifest.json"
+
+// archiveManifestItem is an image in a docker save archive's manifest.
+type archiveManifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// BundlePath returns where the bundle of the given images, used by
+// Kubernetes version, is kept in cacheDir. The bundle's name changes with
+// its images, e.g. when they're pulled from another repository.
+func BundlePath(cacheDir, version string, images []string) string {
+	sorted := append([]string{}, images...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return filepath.Join(cacheDir, bundlesDir, fmt.Sprintf("%s-%x.tar", version, sum[:6]))
+}
+
+// BundleCachedImages combines the cached tarballs of the given images, used
+// by Kubernetes version, into a single docker save archive, so that they
+// can be transferred to the node and loaded in one go, and returns its
+// path. Layers shared between images are only bundled once. It does
+// nothing if the bundle already exists, and fails unless every image is
+// cached.
+func BundleCachedImages(images []string, cacheDir, version string) (string, error) {
+	bundle := BundlePath(cacheDir, version, images)
+	if _, err := os.Stat(bundle); err == nil {
+		return bundle, nil
+	}
+	var srcs []string
+	for _, image := range images {
+		src := sanitizeCacheDir(filepath.Join(cacheDir, image))
+		if _, err := os.Stat(src); err != nil {
+			return "", errors.Wrapf(err, "%s isn't cached", image)
+		}
+		srcs = append(srcs, src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bundle), 0777); err != nil {
+		return "", errors.Wrap(err, "making bundle directory")
+	}
+	// Written under another name first, so that a bundle that exists is
+	// complete.
+	tmp, err := ioutil.TempFile(filepath.Dir(bundle), ".bundle")
+	if err != nil {
+		return "", errors.Wrap(err, "creating bundle")
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeBundle(tmp, srcs); err != nil {
+		tmp.Close()
+		return "", errors.Wrapf(err, "bundling images into %s", bundle)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", errors.Wrapf(err, "writing %s", bundle)
+	}
+	if err := os.Rename(tmp.Name(), bundle); err != nil {
+		return "", errors.Wrapf(err, "renaming bundle to %s", bundle)
+	}
+	glog.Infof("Bundled %d cached images into %s", len(images), bundle)
+	return bundle, nil
+}
+
+// writeBundle writes the docker save archives srcs to w as one archive,
+// with the images of each listed in its manifest.
+func writeBundle(w io.Writer, srcs []string) error {
+	tw := tar.NewWriter(w)
+	written := map[string]bool{}
+	var manifest []archiveManifestItem
+	for _, src := range srcs {
+		items, err := copyArchive(tw, src, written)
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", src)
+		}
+		manifest = append(manifest, items...)
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return errors.Wrap(err, "encoding bundle manifest")
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: archiveManifest, Mode: 0644, Size: int64(len(b)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// copyArchive copies the files of the docker save archive at src to tw,
+// except for those already written, which are named by their digests so
+// are the same, and returns the images in its manifest.
+func copyArchive(tw *tar.Writer, src string, written map[string]bool) ([]archiveManifestItem, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var manifest []archiveManifestItem
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		switch {
+		case name == archiveManifest:
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return nil, errors.Wrap(err, "parsing manifest")
+			}
+		case name == "repositories" || written[name]:
+			// The manifest's RepoTags supersede repositories.
+		default:
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return nil, err
+			}
+			written[name] = true
+		}
+	}
+	if len(manifest) == 0 {
+		return nil, fmt.Errorf("no images in %s", archiveManifest)
+	}
+	return manifest, nil
+}
+
+// LoadCachedBundle streams the bundle at path, made by BundleCachedImages
+// from images, to the node and loads it into the container runtime, e.g.
+// docker, in one go. progress, if set, is called as
+// it starts and finishes loading, with how long it took.
+func LoadCachedBundle(cmd bootstrapper.CommandRunner, runtime, path string, images []string, progress ImageLoadProgressFunc) error {
+	if progress == nil {
+		progress = func(ImageLoadProgress) {}
+	}
+	size := int64(-1)
+	if fi, err := os.Stat(path); err == nil {
+		size = fi.Size()
+	}
+	name := filepath.Base(path)
+	progress(ImageLoadProgress{Image: name, Bundled: images, Size: size})
+	start := time.Now()
+	err := loadCachedTarball(cmd, runtime, path, images)
+	d := time.Since(start)
+	if err != nil {
+		err = errors.Wrapf(err, "loading bundle %s", path)
+	} else {
+		glog.Infof("Loaded %d cached images from bundle %s in %s", len(images), path, d)
+	}
+	progress(ImageLoadProgress{Image: name, Bundled: images, Size: size, Finished: true, Err: err, Duration: d})
+	return err
+}
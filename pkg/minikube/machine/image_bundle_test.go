@@ -0,0 +1,167 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// writeArchive writes a docker save archive of image, with the given
+// layers, to where image is cached in cacheDir.
+func writeArchive(t *testing.T, cacheDir, image, config string, layers ...string) {
+	path := sanitizeCacheDir(filepath.Join(cacheDir, image))
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatalf("Error making dir: %s", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Error creating %s: %s", path, err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	write := func(name string, contents []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err)
+		}
+	}
+	for _, l := range layers {
+		write(l, []byte("layer "+l))
+	}
+	write(config, []byte("config "+config))
+	manifest, err := json.Marshal([]archiveManifestItem{{Config: config, RepoTags: []string{image}, Layers: layers}})
+	if err != nil {
+		t.Fatalf("Error encoding manifest: %s", err)
+	}
+	write(archiveManifest, manifest)
+	write("repositories", []byte("{}"))
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error closing %s: %s", path, err)
+	}
+}
+
+func TestBundleCachedImages(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "minikube-image-bundle")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	images := []string{"k8s.gcr.io/kube-proxy-amd64:v1.10.0", "k8s.gcr.io/kube-apiserver-amd64:v1.10.0"}
+	writeArchive(t, cacheDir, images[0], "proxy.json", "base.tar", "proxy.tar")
+	writeArchive(t, cacheDir, images[1], "apiserver.json", "base.tar", "apiserver.tar")
+
+	if _, err := BundleCachedImages(append(images, "k8s.gcr.io/pause-amd64:3.1"), cacheDir, "v1.10.0"); err == nil {
+		t.Error("Expected an error bundling an image that isn't cached, got nil")
+	}
+
+	bundle, err := BundleCachedImages(images, cacheDir, "v1.10.0")
+	if err != nil {
+		t.Fatalf("Error bundling images: %s", err)
+	}
+	if bundle != BundlePath(cacheDir, "v1.10.0", []string{images[1], images[0]}) {
+		t.Errorf("Expected the bundle's path not to depend on the images' order, got %s", bundle)
+	}
+
+	f, err := os.Open(bundle)
+	if err != nil {
+		t.Fatalf("Error opening bundle: %s", err)
+	}
+	defer f.Close()
+	var names []string
+	var manifest []archiveManifestItem
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading bundle: %s", err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == archiveManifest {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				t.Fatalf("Error parsing bundle manifest: %s", err)
+			}
+		}
+	}
+	sort.Strings(names)
+	expected := []string{"apiserver.json", "apiserver.tar", "base.tar", archiveManifest, "proxy.json", "proxy.tar"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the bundle to hold %v, with the shared layer once, got %v", expected, names)
+	}
+	var tags []string
+	for _, item := range manifest {
+		tags = append(tags, item.RepoTags...)
+	}
+	if !reflect.DeepEqual(tags, images) {
+		t.Errorf("Expected the bundle's manifest to list %v, got %v", images, tags)
+	}
+
+	// An existing bundle isn't rebuilt.
+	if err := os.Remove(sanitizeCacheDir(filepath.Join(cacheDir, images[0]))); err != nil {
+		t.Fatalf("Error removing cached image: %s", err)
+	}
+	if _, err := BundleCachedImages(images, cacheDir, "v1.10.0"); err != nil {
+		t.Errorf("Unexpected error getting an existing bundle: %s", err)
+	}
+}
+
+func TestLoadCachedBundle(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "minikube-image-bundle")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+	images := []string{"k8s.gcr.io/pause-amd64:3.1"}
+	writeArchive(t, cacheDir, images[0], "pause.json", "pause.tar")
+	bundle, err := BundleCachedImages(images, cacheDir, "v1.10.0")
+	if err != nil {
+		t.Fatalf("Error bundling images: %s", err)
+	}
+
+	f := bootstrapper.NewFakeCommandRunner()
+	name := filepath.Base(bundle)
+	f.SetCommandToOutput(map[string]string{
+		"docker load -i /tmp/" + name: "",
+		"rm -rf /tmp/" + name:         "",
+	})
+	var reports []ImageLoadProgress
+	if err := LoadCachedBundle(f, "", bundle, images, func(p ImageLoadProgress) { reports = append(reports, p) }); err != nil {
+		t.Fatalf("Error loading bundle: %s", err)
+	}
+	if len(reports) != 2 || !reports[1].Finished || reports[1].Image != name || !reflect.DeepEqual(reports[1].Bundled, images) {
+		t.Errorf("Expected the bundle's load to start and finish, got %+v", reports)
+	}
+
+	if err := LoadCachedBundle(bootstrapper.NewFakeCommandRunner(), "", bundle, images, nil); err == nil {
+		t.Error("Expected an error when the bundle can't be loaded, got nil")
+	}
+}
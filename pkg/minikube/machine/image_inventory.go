@@ -0,0 +1,233 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// NodeImage is an image in the node's container runtime.
+type NodeImage struct {
+	// Name is the image's repository, e.g. k8s.gcr.io/pause, or empty if it
+	// has none.
+	Name string
+	// Tag is empty if the image has no tag, e.g. after it moved to a newer
+	// image.
+	Tag string
+	// Digest is the image's digest in its registry, e.g. sha256:abc, or
+	// empty if it wasn't pulled from one, e.g. when loaded from a tarball.
+	Digest string
+	// Size is the image's size in bytes, as the runtime reports it.
+	Size int64
+}
+
+// Ref returns the image's name, as name:tag, or name@digest if it has no
+// tag, or "" if it has neither.
+func (i NodeImage) Ref() string {
+	switch {
+	case i.Name == "":
+		return ""
+	case i.Tag != "":
+		return i.Name + ":" + i.Tag
+	case i.Digest != "":
+		return i.Name + "@" + i.Digest
+	}
+	return ""
+}
+
+// ListNodeImages returns the images in the node's container runtime, e.g.
+// containerd, as docker or crictl lists them.
+func ListNodeImages(cmd bootstrapper.CommandRunner, runtime string) ([]NodeImage, error) {
+	r, err := getImageRuntime(runtime)
+	if err != nil {
+		return nil, err
+	}
+	res, err := cmd.Output(r.inventoryCmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing node images")
+	}
+	images, err := r.parseInventory(res.Stdout)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing node images")
+	}
+	return images, nil
+}
+
+// parseDockerInventory parses docker's image list, as dockerImageRuntime's
+// inventoryCmd formats it. docker lists the tags and digests images lack as
+// <none>, and reports sizes for humans, e.g. 742kB.
+func parseDockerInventory(out string) ([]NodeImage, error) {
+	none := func(s string) string {
+		if s == "<none>" {
+			return ""
+		}
+		return s
+	}
+	var images []NodeImage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		size, err := units.FromHumanSize(fields[3])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing size of %s", fields[0])
+		}
+		images = append(images, NodeImage{Name: none(fields[0]), Tag: none(fields[1]), Digest: none(fields[2]), Size: size})
+	}
+	return images, nil
+}
+
+// parseCrictlInventory parses crictl's image list, as JSON. An image is
+// listed once for each of its tags, or once by its digest if it has none.
+func parseCrictlInventory(out string) ([]NodeImage, error) {
+	var list struct {
+		Images []struct {
+			RepoTags    []string `json:"repoTags"`
+			RepoDigests []string `json:"repoDigests"`
+			Size        string   `json:"size"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, err
+	}
+	var images []NodeImage
+	for _, image := range list.Images {
+		size, err := strconv.ParseInt(image.Size, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing size %q", image.Size)
+		}
+		var name, digest string
+		if len(image.RepoDigests) > 0 {
+			parts := strings.SplitN(image.RepoDigests[0], "@", 2)
+			if len(parts) == 2 {
+				name, digest = parts[0], parts[1]
+			}
+		}
+		if len(image.RepoTags) == 0 {
+			images = append(images, NodeImage{Name: name, Digest: digest, Size: size})
+			continue
+		}
+		for _, ref := range image.RepoTags {
+			n, tag := splitImageRef(ref)
+			images = append(images, NodeImage{Name: n, Tag: tag, Digest: digest, Size: size})
+		}
+	}
+	return images, nil
+}
+
+// splitImageRef splits an image's name into its repository and its tag,
+// which is empty if the name has none, e.g. one naming a digest.
+func splitImageRef(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ""
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// MissingNodeImages returns the images in required that aren't among
+// present, matching them by name:tag, or by name@digest for those named by
+// digest.
+func MissingNodeImages(required []string, present []NodeImage) []string {
+	refs := map[string]bool{}
+	for _, image := range present {
+		if ref := image.Ref(); ref != "" {
+			refs[ref] = true
+		}
+		if image.Name != "" && image.Digest != "" {
+			refs[image.Name+"@"+image.Digest] = true
+		}
+	}
+	var missing []string
+	for _, image := range required {
+		if !refs[image] {
+			missing = append(missing, image)
+		}
+	}
+	return missing
+}
+
+// RequiredImagesMissingFromNode returns the images kubeadm needs for the
+// cluster configured by k8s that aren't in the node's container runtime,
+// and so must be loaded from the cache or pulled before it starts.
+func RequiredImagesMissingFromNode(cmd bootstrapper.CommandRunner, k8s bootstrapper.KubernetesConfig) ([]string, error) {
+	present, err := ListNodeImages(cmd, k8s.ContainerRuntime)
+	if err != nil {
+		return nil, err
+	}
+	return MissingNodeImages(k8s.GetKubeadmCachedImages(), present), nil
+}
+
+// archiveRepoTag returns the name the image in the docker save archive at
+// path was saved with, from its manifest.
+func archiveRepoTag(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("no %s in %s", archiveManifest, path)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "reading %s", path)
+		}
+		if strings.TrimPrefix(hdr.Name, "./") != archiveManifest {
+			continue
+		}
+		var manifest []archiveManifestItem
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return "", errors.Wrapf(err, "parsing manifest of %s", path)
+		}
+		if len(manifest) != 1 || len(manifest[0].RepoTags) == 0 {
+			return "", fmt.Errorf("expected one named image in %s", path)
+		}
+		return manifest[0].RepoTags[0], nil
+	}
+}
+
+// recordedDigest returns the registry digest recorded for the image cached
+// at path, or "" if none was, e.g. for images cached before digests were
+// recorded.
+func recordedDigest(path string) string {
+	b, err := ioutil.ReadFile(path + digestSuffix)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
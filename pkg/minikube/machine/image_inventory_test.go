@@ -0,0 +1,138 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+func TestListNodeImages(t *testing.T) {
+	cases := []struct {
+		runtime  string
+		output   string
+		expected []NodeImage
+	}{
+		{
+			runtime: "docker",
+			output: "k8s.gcr.io/pause-amd64\t3.1\tsha256:59ee\t742kB\n" +
+				"k8s.gcr.io/etcd-amd64\t3.1.12\t<none>\t193MB\n" +
+				"<none>\t<none>\t<none>\t1.5GB\n",
+			expected: []NodeImage{
+				{Name: "k8s.gcr.io/pause-amd64", Tag: "3.1", Digest: "sha256:59ee", Size: 742000},
+				{Name: "k8s.gcr.io/etcd-amd64", Tag: "3.1.12", Size: 193000000},
+				{Size: 1500000000},
+			},
+		},
+		{
+			runtime: "containerd",
+			output: `{"images": [
+  {"id": "sha256:da86", "repoTags": ["k8s.gcr.io/pause:3.1", "registry.example.com:5000/pause:3.1"], "repoDigests": ["k8s.gcr.io/pause@sha256:59ee"], "size": "317164"},
+  {"id": "sha256:b8df", "repoTags": [], "repoDigests": ["k8s.gcr.io/etcd@sha256:68a5"], "size": "76159831"}
+]}`,
+			expected: []NodeImage{
+				{Name: "k8s.gcr.io/pause", Tag: "3.1", Digest: "sha256:59ee", Size: 317164},
+				{Name: "registry.example.com:5000/pause", Tag: "3.1", Digest: "sha256:59ee", Size: 317164},
+				{Name: "k8s.gcr.io/etcd", Digest: "sha256:68a5", Size: 76159831},
+			},
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.runtime, func(t *testing.T) {
+			r := bootstrapper.NewFakeCommandRunner()
+			r.SetCommandToOutput(map[string]string{imageRuntimes[test.runtime].inventoryCmd: test.output})
+			images, err := ListNodeImages(r, test.runtime)
+			if err != nil {
+				t.Fatalf("Error listing node images: %s", err)
+			}
+			if !reflect.DeepEqual(images, test.expected) {
+				t.Errorf("Expected node images %+v, got %+v", test.expected, images)
+			}
+		})
+	}
+
+	if _, err := ListNodeImages(bootstrapper.NewFakeCommandRunner(), "rkt"); err == nil {
+		t.Error("Expected an error listing rkt's images, got nil")
+	}
+}
+
+func TestRequiredImagesMissingFromNode(t *testing.T) {
+	k8s := bootstrapper.KubernetesConfig{KubernetesVersion: "v1.10.0"}
+	required := k8s.GetKubeadmCachedImages()
+	var present []NodeImage
+	// Every image but the first is present, the second by digest only.
+	for i, image := range required[1:] {
+		name, tag := splitImageRef(image)
+		if i == 0 {
+			present = append(present, NodeImage{Name: name, Digest: "sha256:abc"})
+			continue
+		}
+		present = append(present, NodeImage{Name: name, Tag: tag})
+	}
+	if missing := MissingNodeImages(required, present); !reflect.DeepEqual(missing, required[:2]) {
+		t.Errorf("Expected %v to be missing, got %v", required[:2], missing)
+	}
+
+	present[0].Tag = "other"
+	present = append(present, NodeImage{Name: "k8s.gcr.io/other", Tag: "v1"})
+	r := bootstrapper.NewFakeCommandRunner()
+	var out string
+	for _, image := range present {
+		out += image.Name + "\t" + image.Tag + "\t" + image.Digest + "\t1MB\n"
+	}
+	r.SetCommandToOutput(map[string]string{dockerImageRuntime.inventoryCmd: out})
+	missing, err := RequiredImagesMissingFromNode(r, k8s)
+	if err != nil {
+		t.Fatalf("Error listing missing images: %s", err)
+	}
+	if !reflect.DeepEqual(missing, required[:2]) {
+		t.Errorf("Expected %v to be missing from the node, got %v", required[:2], missing)
+	}
+}
+
+func TestListCachedImagesUnknownName(t *testing.T) {
+	cacheDir, imageCacheDir, profilesDir := makeImageCache(t)
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+	writeArchive(t, imageCacheDir, "example.com/saved:2.0", "abc.json")
+	if err := os.Rename(imageCachePath(imageCacheDir, "example.com/saved:2.0"), filepath.Join(imageCacheDir, "saved.tar")); err != nil {
+		t.Fatalf("Error renaming archive: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(imageCacheDir, "saved.tar"+digestSuffix), []byte("sha256:def\n"), 0644); err != nil {
+		t.Fatalf("Error writing digest: %s", err)
+	}
+
+	images, err := listCachedImages(cacheDir, imageCacheDir, profilesDir)
+	if err != nil {
+		t.Fatalf("Error listing cached images: %s", err)
+	}
+	for _, image := range images {
+		if image.Image != "saved.tar" {
+			continue
+		}
+		if image.Name != "example.com/saved" || image.Tag != "2.0" || image.Digest != "sha256:def" {
+			t.Errorf("Expected saved.tar to be example.com/saved:2.0 with digest sha256:def, got %+v", image)
+		}
+		return
+	}
+	t.Errorf("Expected saved.tar to be listed, got %+v", images)
+}
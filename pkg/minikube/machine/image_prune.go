@@ -0,0 +1,315 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/constants"
+	"k8s.io/minikube/pkg/util"
+	"k8s.io/minikube/pkg/version"
+)
+
+// CachedImage is an image tarball in the image cache.
+type CachedImage struct {
+	// Image is the image's name, e.g. k8s.gcr.io/pause-amd64:3.1, or, if no
+	// Kubernetes version uses it, its path relative to the image cache.
+	Image string
+	// Name and Tag are the image's repository and tag, from its name or,
+	// if no Kubernetes version uses it, its tarball's manifest. They're
+	// empty if neither has them.
+	Name string
+	Tag  string
+	// Digest is the image's digest in its registry when it was cached, if
+	// it was recorded.
+	Digest string
+	Path   string
+	// Size is the size in bytes of the tarball and the files recorded
+	// beside it.
+	Size int64
+	// Versions are the cached or configured Kubernetes versions whose
+	// images include it, oldest first.
+	Versions []string
+}
+
+// ImagePruneResult is what PruneCachedImages removed, or in a dry run
+// would have removed.
+type ImagePruneResult struct {
+	Removed []CachedImage
+	// Reclaimed is the total size in bytes of Removed.
+	Reclaimed int64
+}
+
+// ListCachedImages returns the images in the image cache, sorted by name.
+func ListCachedImages() ([]CachedImage, error) {
+	return listCachedImages(constants.MakeMiniPath("cache"), constants.ImageCacheDir, constants.MakeMiniPath("profiles"))
+}
+
+// DeleteCachedImages removes the given images from the image cache. It
+// refuses to remove images profile needs unless force is set.
+func DeleteCachedImages(profile string, images []string, force bool) error {
+	return deleteCachedImages(constants.ImageCacheDir, constants.MakeMiniPath("profiles"), profile, images, force)
+}
+
+// PruneCachedImages removes the images no configured profile needs from the
+// image cache. With dryRun, it only returns what it would remove.
+func PruneCachedImages(dryRun bool) (ImagePruneResult, error) {
+	return pruneCachedImages(constants.MakeMiniPath("cache"), constants.ImageCacheDir, constants.MakeMiniPath("profiles"), dryRun)
+}
+
+// profileImageConfig is the part of a profile's config deciding which
+// images it needs.
+type profileImageConfig struct {
+	KubernetesConfig struct {
+		KubernetesVersion string
+		ImageRepository   string
+		PauseImage        string
+	}
+}
+
+// loadProfileImageConfigs returns the configs of the profiles in
+// profilesDir, by profile name. Profiles that were never started have none.
+func loadProfileImageConfigs(profilesDir string) (map[string]profileImageConfig, error) {
+	entries, err := ioutil.ReadDir(profilesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", profilesDir)
+	}
+	configs := map[string]profileImageConfig{}
+	for _, e := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(profilesDir, e.Name(), "config.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading profile %s", e.Name())
+		}
+		var cc profileImageConfig
+		if err := json.Unmarshal(data, &cc); err != nil {
+			return nil, errors.Wrapf(err, "parsing profile %s", e.Name())
+		}
+		configs[e.Name()] = cc
+	}
+	return configs, nil
+}
+
+// neededImages returns the images a profile might load, whichever
+// bootstrapper it's started with.
+func (cc profileImageConfig) neededImages() []string {
+	images := append([]string{}, constants.LocalkubeCachedImages...)
+	if v := cc.KubernetesConfig.KubernetesVersion; v != "" {
+		images = append(images, cc.kubeadmImages(v)...)
+	}
+	return images
+}
+
+// kubeadmImages returns the images kubeadm needs for version v, from the
+// profile's repository, and its custom pause image if it has one.
+func (cc profileImageConfig) kubeadmImages(v string) []string {
+	images := constants.GetKubeadmCachedImages(cc.KubernetesConfig.ImageRepository, v)
+	if p := cc.KubernetesConfig.PauseImage; p != "" {
+		images = append(images, p)
+	}
+	return images
+}
+
+// imageCachePath returns where image is cached in imageCacheDir.
+func imageCachePath(imageCacheDir, image string) string {
+	return sanitizeCacheDir(filepath.Join(imageCacheDir, image))
+}
+
+func listCachedImages(cacheDir, imageCacheDir, profilesDir string) ([]CachedImage, error) {
+	versions, err := listCachedVersions(cacheDir, imageCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := loadProfileImageConfigs(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cross-reference the images of every cached version, and of every
+	// profile's version from its repository, with its pause image, by their
+	// paths in the cache.
+	names := map[string]string{}
+	users := map[string]map[string]bool{}
+	use := func(v string, images []string) {
+		for _, image := range images {
+			path := imageCachePath(imageCacheDir, image)
+			names[path] = image
+			if users[path] == nil {
+				users[path] = map[string]bool{}
+			}
+			users[path][v] = true
+		}
+	}
+	for _, v := range versions {
+		use(v.Version, constants.GetKubeadmCachedImages("", v.Version))
+	}
+	for _, cc := range configs {
+		if v := cc.KubernetesConfig.KubernetesVersion; isVersionDir(v) {
+			use(v, cc.kubeadmImages(v))
+		}
+	}
+
+	var images []CachedImage
+	err = filepath.Walk(imageCacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || isCachedImageRecord(path) {
+			return nil
+		}
+		size := info.Size()
+		for _, suffix := range cachedImageRecords {
+			if fi, err := os.Stat(path + suffix); err == nil {
+				size += fi.Size()
+			}
+		}
+		image, ok := names[path]
+		ref := image
+		if !ok {
+			rel, err := filepath.Rel(imageCacheDir, path)
+			if err != nil {
+				return err
+			}
+			image = filepath.ToSlash(rel)
+			ref, _ = archiveRepoTag(path)
+		}
+		c := CachedImage{Image: image, Digest: recordedDigest(path), Path: path, Size: size, Versions: sortedVersions(users[path])}
+		if ref != "" {
+			c.Name, c.Tag = splitImageRef(ref)
+		}
+		images = append(images, c)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", imageCacheDir)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Image < images[j].Image })
+	return images, nil
+}
+
+// sortedVersions returns the Kubernetes versions in set, oldest first.
+func sortedVersions(set map[string]bool) []string {
+	var versions []string
+	for v := range set {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.MustParse(strings.TrimPrefix(versions[i], version.VersionPrefix)).LT(
+			semver.MustParse(strings.TrimPrefix(versions[j], version.VersionPrefix)))
+	})
+	return versions
+}
+
+func deleteCachedImages(imageCacheDir, profilesDir, profile string, images []string, force bool) error {
+	configs, err := loadProfileImageConfigs(profilesDir)
+	if err != nil {
+		return err
+	}
+	needed := map[string]bool{}
+	if cc, ok := configs[profile]; ok {
+		for _, image := range cc.neededImages() {
+			needed[imageCachePath(imageCacheDir, image)] = true
+		}
+	}
+
+	m := util.MultiError{}
+	for _, image := range images {
+		path := imageCachePath(imageCacheDir, image)
+		if needed[path] && !force {
+			m.Collect(fmt.Errorf("%s is used by the active profile, and can only be removed when forced", image))
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			m.Collect(errors.Wrapf(err, "%s isn't cached", image))
+			continue
+		}
+		m.Collect(removeCachedImage(path))
+	}
+	return m.ToError()
+}
+
+func pruneCachedImages(cacheDir, imageCacheDir, profilesDir string, dryRun bool) (ImagePruneResult, error) {
+	var result ImagePruneResult
+	cached, err := listCachedImages(cacheDir, imageCacheDir, profilesDir)
+	if err != nil {
+		return result, err
+	}
+	configs, err := loadProfileImageConfigs(profilesDir)
+	if err != nil {
+		return result, err
+	}
+	needed := map[string]bool{}
+	for _, cc := range configs {
+		for _, image := range cc.neededImages() {
+			needed[imageCachePath(imageCacheDir, image)] = true
+		}
+	}
+
+	m := util.MultiError{}
+	for _, image := range cached {
+		if needed[image.Path] {
+			continue
+		}
+		if !dryRun {
+			if err := removeCachedImage(image.Path); err != nil {
+				m.Collect(err)
+				continue
+			}
+		}
+		result.Removed = append(result.Removed, image)
+		result.Reclaimed += image.Size
+	}
+	return result, m.ToError()
+}
+
+// isCachedImageRecord returns whether path is recorded beside a cached
+// image, rather than an image.
+func isCachedImageRecord(path string) bool {
+	for _, suffix := range cachedImageRecords {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCachedImage removes the image tarball at path and the files
+// recorded beside it.
+func removeCachedImage(path string) error {
+	for _, p := range cachedImagePaths(path) {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/constants"
+)
+
+// makeImageCache creates a cache with the binaries of v1.10.0 and v1.10.1,
+// which share a pause image, each version's 5 byte kube-apiserver image
+// with an 11 byte recorded digest, a 7 byte image no version uses, and a
+// profile using v1.10.1. It returns the cache, image cache and profiles
+// directories.
+func makeImageCache(t *testing.T) (string, string, string) {
+	dir, err := ioutil.TempDir("", "minikube-image-prune")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	imageCacheDir := filepath.Join(cacheDir, "images")
+	profilesDir := filepath.Join(dir, "profiles")
+	write := func(path string, size int) {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Error making dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Error writing file: %s", err)
+		}
+	}
+	for _, v := range []string{"v1.10.0", "v1.10.1"} {
+		write(filepath.Join(cacheDir, v, "kubelet"), 10)
+		apiserver := imageCachePath(imageCacheDir, "k8s.gcr.io/kube-apiserver-amd64:"+v)
+		write(apiserver, 5)
+		if err := ioutil.WriteFile(apiserver+digestSuffix, []byte("sha256:abc\n"), 0644); err != nil {
+			t.Fatalf("Error writing digest: %s", err)
+		}
+	}
+	write(imageCachePath(imageCacheDir, constants.GetPauseImage("", "v1.10.0")), 5)
+	write(imageCachePath(imageCacheDir, "example.com/old:1.0"), 7)
+
+	config := `{"KubernetesConfig": {"KubernetesVersion": "v1.10.1"}}`
+	if err := os.MkdirAll(filepath.Join(profilesDir, "minikube"), 0777); err != nil {
+		t.Fatalf("Error making dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(profilesDir, "minikube", "config.json"), []byte(config), 0644); err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+	return cacheDir, imageCacheDir, profilesDir
+}
+
+func TestListCachedImages(t *testing.T) {
+	cacheDir, imageCacheDir, profilesDir := makeImageCache(t)
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+
+	images, err := listCachedImages(cacheDir, imageCacheDir, profilesDir)
+	if err != nil {
+		t.Fatalf("Error listing cached images: %s", err)
+	}
+	expected := []CachedImage{
+		{Image: "example.com/old_1.0", Size: 7},
+		{Image: "k8s.gcr.io/kube-apiserver-amd64:v1.10.0", Name: "k8s.gcr.io/kube-apiserver-amd64", Tag: "v1.10.0", Digest: "sha256:abc", Size: 16, Versions: []string{"v1.10.0"}},
+		{Image: "k8s.gcr.io/kube-apiserver-amd64:v1.10.1", Name: "k8s.gcr.io/kube-apiserver-amd64", Tag: "v1.10.1", Digest: "sha256:abc", Size: 16, Versions: []string{"v1.10.1"}},
+		{Image: "k8s.gcr.io/pause-amd64:3.1", Name: "k8s.gcr.io/pause-amd64", Tag: "3.1", Size: 5, Versions: []string{"v1.10.0", "v1.10.1"}},
+	}
+	for i := range images {
+		images[i].Path = ""
+	}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("Expected cached images %+v, got %+v", expected, images)
+	}
+}
+
+func TestPruneCachedImages(t *testing.T) {
+	cacheDir, imageCacheDir, profilesDir := makeImageCache(t)
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+	expected := []string{"example.com/old_1.0", "k8s.gcr.io/kube-apiserver-amd64:v1.10.0"}
+
+	cases := []struct {
+		description string
+		dryRun      bool
+		remaining   int
+	}{
+		{description: "dry run", dryRun: true, remaining: 4},
+		{description: "prune", remaining: 2},
+	}
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			result, err := pruneCachedImages(cacheDir, imageCacheDir, profilesDir, test.dryRun)
+			if err != nil {
+				t.Fatalf("Error pruning cached images: %s", err)
+			}
+			var removed []string
+			for _, image := range result.Removed {
+				removed = append(removed, image.Image)
+			}
+			if !reflect.DeepEqual(removed, expected) {
+				t.Errorf("Expected %v to be removed, got %v", expected, removed)
+			}
+			if result.Reclaimed != 23 {
+				t.Errorf("Expected 23 bytes to be reclaimed, got %d", result.Reclaimed)
+			}
+
+			images, err := listCachedImages(cacheDir, imageCacheDir, profilesDir)
+			if err != nil {
+				t.Fatalf("Error listing cached images: %s", err)
+			}
+			if len(images) != test.remaining {
+				t.Errorf("Expected %d images to remain, got %+v", test.remaining, images)
+			}
+		})
+	}
+
+	// The shared pause image is still used by the profile's version.
+	if _, err := os.Stat(imageCachePath(imageCacheDir, "k8s.gcr.io/pause-amd64:3.1")); err != nil {
+		t.Errorf("Expected the pause image to be kept: %s", err)
+	}
+	if _, err := os.Stat(imageCachePath(imageCacheDir, "k8s.gcr.io/kube-apiserver-amd64:v1.10.0") + digestSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected the pruned image's digest to be removed, got %v", err)
+	}
+}
+
+func TestDeleteCachedImages(t *testing.T) {
+	cacheDir, imageCacheDir, profilesDir := makeImageCache(t)
+	defer os.RemoveAll(filepath.Dir(cacheDir))
+	pause := "k8s.gcr.io/pause-amd64:3.1"
+
+	err := deleteCachedImages(imageCacheDir, profilesDir, "minikube", []string{pause, "k8s.gcr.io/kube-apiserver-amd64:v1.10.0", "example.com/missing:1.0"}, false)
+	if err == nil {
+		t.Fatal("Expected an error deleting the active profile's and missing images, got nil")
+	}
+	for _, msg := range []string{pause + " is used by the active profile", "example.com/missing:1.0 isn't cached"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error to contain %q, got: %s", msg, err)
+		}
+	}
+	if _, err := os.Stat(imageCachePath(imageCacheDir, "k8s.gcr.io/kube-apiserver-amd64:v1.10.0")); !os.IsNotExist(err) {
+		t.Errorf("Expected the unused image to be deleted, got %v", err)
+	}
+
+	// Another profile doesn't need the pause image.
+	if err := deleteCachedImages(imageCacheDir, profilesDir, "other", []string{pause}, false); err != nil {
+		t.Errorf("Unexpected error deleting an image the profile doesn't use: %s", err)
+	}
+	if _, err := os.Stat(imageCachePath(imageCacheDir, pause)); !os.IsNotExist(err) {
+		t.Errorf("Expected the pause image to be deleted, got %v", err)
+	}
+
+	if err := deleteCachedImages(imageCacheDir, profilesDir, "minikube", []string{"k8s.gcr.io/kube-apiserver-amd64:v1.10.1"}, true); err != nil {
+		t.Errorf("Unexpected error force deleting the active profile's image: %s", err)
+	}
+}
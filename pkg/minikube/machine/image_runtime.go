@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// imageRuntime is how cached images are loaded into, and listed from, a
+// container runtime on the node.
+type imageRuntime struct {
+	// loadCmd loads the image tarball at the path it's formatted with.
+	loadCmd string
+	// streamLoadCmd loads an image tarball from its standard input.
+	streamLoadCmd string
+	// listCmd lists the runtime's images, one or more whitespace separated
+	// references per line, as repo:tag or repo@digest. Either may end in
+	// <none> when the image has no tag or digest.
+	listCmd string
+	// idCmd prints the ID of the image it's formatted with, or is empty if
+	// the runtime can't report the IDs images have once loaded.
+	idCmd string
+	// inventoryCmd lists the runtime's images with their sizes, which
+	// parseInventory parses.
+	inventoryCmd   string
+	parseInventory func(string) ([]NodeImage, error)
+}
+
+// imageRuntimes are the container runtimes images can be loaded into, keyed
+// by KubernetesConfig.ContainerRuntime. Empty means docker, kubelet's
+// default. containerd's images must be in the k8s.io namespace for its CRI
+// plugin to find them, and cri-o's are in the containers/storage podman
+// shares with it.
+var imageRuntimes = map[string]imageRuntime{
+	"":       dockerImageRuntime,
+	"docker": dockerImageRuntime,
+	"containerd": {
+		loadCmd:        bootstrapper.Sudo("ctr -n=k8s.io images import %s"),
+		streamLoadCmd:  bootstrapper.Sudo("ctr -n=k8s.io images import -"),
+		listCmd:        bootstrapper.Sudo("ctr -n=k8s.io images list -q"),
+		inventoryCmd:   crictlInventoryCmd,
+		parseInventory: parseCrictlInventory,
+	},
+	"cri-o": crioImageRuntime,
+	"crio":  crioImageRuntime,
+}
+
+var (
+	dockerImageRuntime = imageRuntime{
+		loadCmd:        "docker load -i %s",
+		streamLoadCmd:  "docker load",
+		listCmd:        `docker images --digests --format "{{.Repository}}:{{.Tag}} {{.Repository}}@{{.Digest}}"`,
+		idCmd:          `docker image inspect --format "{{.Id}}" %s`,
+		inventoryCmd:   `docker images --digests --format "{{.Repository}}\t{{.Tag}}\t{{.Digest}}\t{{.Size}}"`,
+		parseInventory: parseDockerInventory,
+	}
+	crioImageRuntime = imageRuntime{
+		loadCmd:        bootstrapper.Sudo("podman load -i %s"),
+		streamLoadCmd:  bootstrapper.Sudo("podman load"),
+		listCmd:        bootstrapper.Sudo(`podman images --digests --format "{{.Repository}}:{{.Tag}} {{.Repository}}@{{.Digest}}"`),
+		idCmd:          bootstrapper.Sudo(`podman image inspect --format "{{.Id}}" %s`),
+		inventoryCmd:   crictlInventoryCmd,
+		parseInventory: parseCrictlInventory,
+	}
+)
+
+// crictlInventoryCmd lists the images of a CRI runtime, as JSON.
+var crictlInventoryCmd = bootstrapper.Sudo("crictl images -o json")
+
+// getImageRuntime returns how images are loaded into runtime.
+func getImageRuntime(runtime string) (imageRuntime, error) {
+	r, ok := imageRuntimes[runtime]
+	if !ok {
+		return imageRuntime{}, fmt.Errorf("loading images into container runtime %q is not supported", runtime)
+	}
+	return r, nil
+}
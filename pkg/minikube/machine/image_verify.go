@@ -0,0 +1,182 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// imageIDSuffix is appended to a cached image's path for the file recording
+// the ID of the image in it when it was cached, which is the ID a container
+// runtime gives the image once it's loaded.
+const imageIDSuffix = ".id"
+
+// cachedImageRecords are the suffixes of the files recorded beside each
+// cached image.
+var cachedImageRecords = []string{digestSuffix, imageIDSuffix}
+
+// cachedImagePaths returns the paths of the image cached at path and of the
+// files recorded beside it.
+func cachedImagePaths(path string) []string {
+	paths := []string{path}
+	for _, suffix := range cachedImageRecords {
+		paths = append(paths, path+suffix)
+	}
+	return paths
+}
+
+// archiveImageID returns the ID of the image in the docker save archive at
+// path, which is the digest of its config.
+func archiveImageID(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// The config is small, but may come before or after the manifest, so
+	// every JSON file's digest is kept until the manifest names it.
+	digests := map[string]string{}
+	var manifest []archiveManifestItem
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "reading %s", path)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		switch {
+		case name == archiveManifest:
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return "", errors.Wrapf(err, "parsing manifest of %s", path)
+			}
+		case strings.HasSuffix(name, ".json"):
+			h := sha256.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return "", errors.Wrapf(err, "reading %s in %s", name, path)
+			}
+			digests[name] = fmt.Sprintf("sha256:%x", h.Sum(nil))
+		}
+	}
+	if len(manifest) != 1 {
+		return "", fmt.Errorf("expected one image in %s, found %d", path, len(manifest))
+	}
+	id, ok := digests[manifest[0].Config]
+	if !ok {
+		return "", fmt.Errorf("config %s of %s is missing", manifest[0].Config, path)
+	}
+	return id, nil
+}
+
+// recordImageID records the ID of the image cached at path, so that it can
+// be checked once the image is loaded.
+func recordImageID(path string) error {
+	id, err := archiveImageID(path)
+	if err != nil {
+		return errors.Wrap(err, "getting image ID")
+	}
+	if err := ioutil.WriteFile(path+imageIDSuffix, []byte(id+"\n"), 0644); err != nil {
+		return errors.Wrapf(err, "recording ID of %s", path)
+	}
+	return nil
+}
+
+// recordedImageID returns the ID recorded for the image cached at path, or
+// "" if none was, e.g. for images cached before IDs were recorded.
+func recordedImageID(path string) string {
+	b, err := ioutil.ReadFile(path + imageIDSuffix)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// loadedImageID returns the ID of image in the container runtime. IDs
+// reported without their algorithm are sha256 digests.
+func loadedImageID(cmd bootstrapper.CommandRunner, r imageRuntime, image string) (string, error) {
+	out, err := cmd.CombinedOutput(fmt.Sprintf(r.idCmd, image))
+	if err != nil {
+		return "", errors.Wrapf(err, "getting ID of %s: %s", image, out)
+	}
+	id := strings.TrimSpace(out)
+	if !strings.Contains(id, ":") {
+		id = "sha256:" + id
+	}
+	return id, nil
+}
+
+// verifyLoadedImage checks that image, loaded from its cached tarball at
+// src, has the ID recorded when it was cached. If it doesn't, e.g. as the
+// tarball or its transfer was corrupted, the image is pulled into the cache
+// again if the tarball no longer has the recorded ID, and loaded again. It
+// returns whether the image was repaired. Images without a recorded ID, and
+// runtimes that can't report them, e.g. containerd, aren't verified.
+func verifyLoadedImage(cmd bootstrapper.CommandRunner, runtime, image, src string) (bool, error) {
+	r, err := getImageRuntime(runtime)
+	if err != nil {
+		return false, err
+	}
+	expected := recordedImageID(src)
+	if expected == "" || r.idCmd == "" {
+		return false, nil
+	}
+	id, err := loadedImageID(cmd, r, image)
+	if err != nil {
+		return false, err
+	}
+	if id == expected {
+		return false, nil
+	}
+
+	glog.Warningf("%s loaded with ID %s rather than %s, repairing it", image, id, expected)
+	if actual, err := archiveImageID(src); err != nil || actual != expected {
+		glog.Warningf("Cached %s is corrupted, pulling it again", image)
+		if err := os.Remove(src); err != nil {
+			return false, errors.Wrapf(err, "removing corrupted %s", src)
+		}
+		if err := pullImage(image, src); err != nil {
+			return false, errors.Wrapf(err, "pulling corrupted %s again", image)
+		}
+		expected = recordedImageID(src)
+	}
+	if err := LoadFromCacheBlocking(cmd, runtime, src); err != nil {
+		return false, errors.Wrapf(err, "loading %s again", image)
+	}
+	id, err = loadedImageID(cmd, r, image)
+	if err != nil {
+		return false, err
+	}
+	if id != expected {
+		return false, fmt.Errorf("%s loaded with ID %s rather than %s after repairing it", image, id, expected)
+	}
+	glog.Infof("Repaired %s", image)
+	return true, nil
+}
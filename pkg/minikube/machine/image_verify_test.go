@@ -0,0 +1,173 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/minikube/pkg/minikube/bootstrapper"
+)
+
+// configID returns the ID of an image written by writeArchive with config.
+func configID(config string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("config "+config)))
+}
+
+func TestArchiveImageID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "minikube-image-cache")
+	if err != nil {
+		t.Fatalf("Error making temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	image := "k8s.gcr.io/pause-amd64:3.1"
+	writeArchive(t, dir, image, "abc.json", "layer.tar")
+	path := sanitizeCacheDir(filepath.Join(dir, image))
+
+	if err := recordImageID(path); err != nil {
+		t.Fatalf("Error recording image ID: %s", err)
+	}
+	if id := recordedImageID(path); id != configID("abc.json") {
+		t.Errorf("Expected recorded ID %s, got %s", configID("abc.json"), id)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("truncated"), 0644); err != nil {
+		t.Fatalf("Error corrupting %s: %s", path, err)
+	}
+	if _, err := archiveImageID(path); err == nil {
+		t.Error("Expected an error getting the ID of a corrupted archive, got nil")
+	}
+}
+
+// reloadRunner is a command runner reporting the loaded image's ID as
+// before until the image is loaded again from a copy, and as after since.
+type reloadRunner struct {
+	*bootstrapper.FakeCommandRunner
+	before, after string
+	reloaded      bool
+}
+
+func (r *reloadRunner) Run(cmd string) error {
+	if strings.HasPrefix(cmd, "docker load -i") {
+		r.reloaded = true
+	}
+	return r.FakeCommandRunner.Run(cmd)
+}
+
+func (r *reloadRunner) CombinedOutput(cmd string) (string, error) {
+	if strings.HasPrefix(cmd, "docker image inspect") {
+		if r.reloaded {
+			return r.after + "\n", nil
+		}
+		return r.before + "\n", nil
+	}
+	return r.FakeCommandRunner.CombinedOutput(cmd)
+}
+
+func TestVerifyLoadedImage(t *testing.T) {
+	const image = "k8s.gcr.io/pause-amd64:3.1"
+	good, pulled := configID("good.json"), configID("pulled.json")
+	cases := []struct {
+		description string
+		corrupt     bool
+		unrecorded  bool
+		before      string
+		after       string
+		repaired    bool
+		pulled      bool
+		shouldErr   bool
+	}{
+		{
+			description: "intact",
+			before:      good,
+		},
+		{
+			description: "not recorded",
+			unrecorded:  true,
+			before:      "sha256:other",
+		},
+		{
+			description: "transfer corrupted",
+			before:      "sha256:broken",
+			after:       good,
+			repaired:    true,
+		},
+		{
+			description: "cache corrupted",
+			corrupt:     true,
+			before:      "sha256:broken",
+			after:       pulled,
+			repaired:    true,
+			pulled:      true,
+		},
+		{
+			description: "still corrupted",
+			before:      "sha256:broken",
+			after:       "sha256:broken",
+			shouldErr:   true,
+		},
+	}
+
+	defer func(f func(string, string) error) { pullImage = f }(pullImage)
+	for _, test := range cases {
+		t.Run(test.description, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "minikube-image-cache")
+			if err != nil {
+				t.Fatalf("Error making temp dir: %s", err)
+			}
+			defer os.RemoveAll(dir)
+			writeArchive(t, dir, image, "good.json")
+			src := sanitizeCacheDir(filepath.Join(dir, image))
+			if !test.unrecorded {
+				if err := recordImageID(src); err != nil {
+					t.Fatalf("Error recording image ID: %s", err)
+				}
+			}
+			if test.corrupt {
+				writeArchive(t, dir, image, "bad.json")
+			}
+			didPull := false
+			pullImage = func(image, dst string) error {
+				didPull = true
+				writeArchive(t, dir, image, "pulled.json")
+				return recordImageID(dst)
+			}
+
+			r := &reloadRunner{FakeCommandRunner: bootstrapper.NewFakeCommandRunner(), before: test.before, after: test.after}
+			name := filepath.Base(src)
+			r.SetCommandToOutput(map[string]string{
+				"docker load -i /tmp/" + name: "",
+				"rm -rf /tmp/" + name:         "",
+			})
+			repaired, err := verifyLoadedImage(r, "", image, src)
+			if (err != nil) != test.shouldErr {
+				t.Fatalf("Expected error: %t, got %v", test.shouldErr, err)
+			}
+			if repaired != test.repaired {
+				t.Errorf("Expected repaired: %t, got %t", test.repaired, repaired)
+			}
+			if didPull != test.pulled {
+				t.Errorf("Expected the image to be pulled again: %t, got %t", test.pulled, didPull)
+			}
+		})
+	}
+}
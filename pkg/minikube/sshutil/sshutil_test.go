@@ -52,7 +52,7 @@ func TestNewSSHClient(t *testing.T) {
 	if err := sess.Run(cmd); err != nil {
 		t.Fatalf("Error running command: %s", cmd)
 	}
-	if !s.Connected {
+	if !s.IsConnected() {
 		t.Fatalf("Error!")
 	}
 
@@ -24,7 +24,9 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
@@ -35,14 +37,21 @@ import (
 type SSHServer struct {
 	Config *ssh.ServerConfig
 	// Commands stores the raw commands executed against the server.
-	Commands  map[string]int
-	Connected bool
+	Commands map[string]int
+	// Transfers stores anything that comes in over stdin.
 	Transfers *bytes.Buffer
+	// mu guards Commands and Transfers, which concurrent sessions write to.
+	mu sync.Mutex
+	// Only access this with atomic ops
+	connected int32
 	// Only access this with atomic ops
 	hadASessionRequested int32
 	// commandsToOutput can be used to mock what the SSHServer returns for a given command
 	// Only access this with atomic ops
 	commandToOutput atomic.Value
+	// commandToDelay can be used to mock commands that take a while to run.
+	// Only access this with atomic ops
+	commandToDelay atomic.Value
 }
 
 // NewSSHServer returns a NewSSHServer instance, ready for use.
@@ -65,6 +74,7 @@ func NewSSHServer() (*SSHServer, error) {
 	s.Config.AddHostKey(signer)
 	s.SetSessionRequested(false)
 	s.SetCommandToOutput(map[string]string{})
+	s.SetCommandToDelay(map[string]time.Duration{})
 	return s, nil
 }
 
@@ -95,48 +105,57 @@ func (s *SSHServer) Start() (int, error) {
 				// The incoming Request channel must be serviced.
 				go ssh.DiscardRequests(reqs)
 
-				// Service the incoming Channel channel.
+				// Service the incoming Channel channel. Each session is served
+				// on its own, so that a slow command doesn't hold up the next.
 				for newChannel := range chans {
-					if newChannel.ChannelType() == "session" {
-						s.SetSessionRequested(true)
-					}
-					channel, requests, err := newChannel.Accept()
-					s.Connected = true
-					if err != nil {
-						return
-					}
-
-					for req := range requests {
-						glog.Infoln("Got Req: ", req.Type)
-						// Store anything that comes in over stdin.
-						go func() {
-							io.Copy(s.Transfers, channel)
-							channel.Close()
-						}()
-						switch req.Type {
-						case "exec":
-							req.Reply(true, nil)
-
-							// Note: string(req.Payload) adds additional characters to start of input.
-							var cmd execRequest
-							if err := ssh.Unmarshal(req.Payload, &cmd); err != nil {
-								glog.Errorf("Unmarshall encountered error: %s with req: %v", err, req.Type)
-								return
-							}
-							s.Commands[cmd.Command] = 1
+					go func(newChannel ssh.NewChannel) {
+						if newChannel.ChannelType() == "session" {
+							s.SetSessionRequested(true)
+						}
+						channel, requests, err := newChannel.Accept()
+						atomic.StoreInt32(&s.connected, 1)
+						if err != nil {
+							return
+						}
 
-							// Write specified command output as mocked ssh output
-							if val, err := s.GetCommandToOutput(cmd.Command); err == nil {
-								channel.Write([]byte(val))
+						for req := range requests {
+							glog.Infoln("Got Req: ", req.Type)
+							// Store anything that comes in over stdin.
+							go func() {
+								io.Copy(&lockedWriter{mu: &s.mu, w: s.Transfers}, channel)
+								channel.Close()
+							}()
+							switch req.Type {
+							case "exec":
+								// Note: string(req.Payload) adds additional characters to start of input.
+								var cmd execRequest
+								if err := ssh.Unmarshal(req.Payload, &cmd); err != nil {
+									req.Reply(false, nil)
+									glog.Errorf("Unmarshall encountered error: %s with req: %v", err, req.Type)
+									return
+								}
+								// The client doesn't send its input until the
+								// command starts, which would end the session
+								// early, so a delayed command is started late.
+								time.Sleep(s.getCommandDelay(cmd.Command))
+								req.Reply(true, nil)
+								s.mu.Lock()
+								s.Commands[cmd.Command] = 1
+								s.mu.Unlock()
+
+								// Write specified command output as mocked ssh output
+								if val, err := s.GetCommandToOutput(cmd.Command); err == nil {
+									channel.Write([]byte(val))
+								}
+								channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+
+							case "pty-req":
+								req.Reply(true, nil)
+
+								channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
 							}
-							channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
-
-						case "pty-req":
-							req.Reply(true, nil)
-
-							channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
 						}
-					}
+					}(newChannel)
 				}
 			}()
 		}
@@ -154,6 +173,23 @@ func (s *SSHServer) Start() (int, error) {
 	return port, nil
 }
 
+// lockedWriter serializes writes to w, so that sessions can share it.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// IsConnected returns whether a client has opened a channel to the server.
+func (s *SSHServer) IsConnected() bool {
+	return atomic.LoadInt32(&s.connected) != 0
+}
+
 func (s *SSHServer) SetCommandToOutput(cmdToOutput map[string]string) {
 	s.commandToOutput.Store(cmdToOutput)
 }
@@ -167,6 +203,16 @@ func (s *SSHServer) GetCommandToOutput(cmd string) (string, error) {
 	return val, nil
 }
 
+// SetCommandToDelay sets how long the server takes to run the given
+// commands, e.g. to test timeouts.
+func (s *SSHServer) SetCommandToDelay(cmdToDelay map[string]time.Duration) {
+	s.commandToDelay.Store(cmdToDelay)
+}
+
+func (s *SSHServer) getCommandDelay(cmd string) time.Duration {
+	return s.commandToDelay.Load().(map[string]time.Duration)[cmd]
+}
+
 func (s *SSHServer) SetSessionRequested(b bool) {
 	var i int32
 	if b {
@@ -115,6 +115,59 @@ func SetupKubeConfig(cfg *KubeConfigSetup) error {
 	return nil
 }
 
+// MergeKubeConfig merges the current context of the kubeconfig in data, e.g.
+// a node's admin.conf, into the kubeconfig at filename under name,
+// replacing the cluster, user and context called name and leaving the
+// others as they are. The server already recorded for the cluster is kept,
+// as the one in data might only be reachable from the node.
+func MergeKubeConfig(data []byte, filename, name string) error {
+	src, err := decode(data)
+	if err != nil {
+		return err
+	}
+	dst, err := ReadConfigOrNew(filename)
+	if err != nil {
+		return err
+	}
+	if err := mergeKubeConfig(src, dst, name); err != nil {
+		return err
+	}
+	return WriteConfig(dst, filename)
+}
+
+func mergeKubeConfig(src, dst *api.Config, name string) error {
+	context, ok := src.Contexts[src.CurrentContext]
+	if !ok {
+		return errors.Errorf("kubeconfig has no current context")
+	}
+	cluster, ok := src.Clusters[context.Cluster]
+	if !ok {
+		return errors.Errorf("kubeconfig has no cluster %q", context.Cluster)
+	}
+	user, ok := src.AuthInfos[context.AuthInfo]
+	if !ok {
+		return errors.Errorf("kubeconfig has no user %q", context.AuthInfo)
+	}
+
+	if old, ok := dst.Clusters[name]; ok && old.Server != "" {
+		cluster.Server = old.Server
+	}
+	dst.Clusters[name] = cluster
+	dst.AuthInfos[name] = user
+	merged := api.NewContext()
+	merged.Cluster = name
+	merged.AuthInfo = name
+	merged.Namespace = context.Namespace
+	if old, ok := dst.Contexts[name]; ok && merged.Namespace == "" {
+		merged.Namespace = old.Namespace
+	}
+	dst.Contexts[name] = merged
+	if dst.CurrentContext == "" {
+		dst.CurrentContext = name
+	}
+	return nil
+}
+
 // ReadConfigOrNew retrieves Kubernetes client configuration from a file.
 // If no files exists, an empty configuration is returned.
 func ReadConfigOrNew(filename string) (*api.Config, error) {
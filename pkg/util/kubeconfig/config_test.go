@@ -283,6 +283,108 @@ func TestUpdateKubeconfigIP(t *testing.T) {
 	}
 }
 
+var fakeAdminConf = []byte(`
+apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: Y2E=
+    server: https://10.0.2.15:8443
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: kubernetes-admin
+  name: kubernetes-admin@kubernetes
+current-context: kubernetes-admin@kubernetes
+kind: Config
+preferences: {}
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+`)
+
+func TestMergeKubeConfig(t *testing.T) {
+	var tests = []struct {
+		description string
+		existing    []byte
+		admin       []byte
+		err         bool
+		server      string
+		current     string
+	}{
+		{
+			description: "stale minikube cluster",
+			existing:    fakeKubeCfg3,
+			admin:       fakeAdminConf,
+			server:      "https://192.168.1.1:8443",
+			current:     "la-croix",
+		},
+		{
+			description: "no minikube cluster",
+			existing:    fakeKubeCfg,
+			admin:       fakeAdminConf,
+			server:      "https://10.0.2.15:8443",
+			current:     "la-croix",
+		},
+		{
+			description: "empty kubeconfig",
+			admin:       fakeAdminConf,
+			server:      "https://10.0.2.15:8443",
+			current:     "minikube",
+		},
+		{
+			description: "admin kubeconfig without a current context",
+			existing:    fakeKubeCfg3,
+			admin:       []byte("apiVersion: v1\nkind: Config\n"),
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			configFilename := tempFile(t, test.existing)
+			defer os.Remove(configFilename)
+			err := MergeKubeConfig(test.admin, configFilename, "minikube")
+			if err != nil {
+				if !test.err {
+					t.Fatalf("Got unexpected error: %s", err)
+				}
+				return
+			}
+			if test.err {
+				t.Fatal("Expected error but got none")
+			}
+
+			config, err := ReadConfigOrNew(configFilename)
+			if err != nil {
+				t.Fatalf("Error reading merged config: %s", err)
+			}
+			cluster := config.Clusters["minikube"]
+			if cluster == nil || cluster.Server != test.server || string(cluster.CertificateAuthorityData) != "ca" || cluster.CertificateAuthority != "" {
+				t.Errorf("Expected the minikube cluster at %s with the admin CA, got %+v", test.server, cluster)
+			}
+			user := config.AuthInfos["minikube"]
+			if user == nil || string(user.ClientCertificateData) != "cert" || string(user.ClientKeyData) != "key" {
+				t.Errorf("Expected the minikube user with the admin credentials, got %+v", user)
+			}
+			context := config.Contexts["minikube"]
+			if context == nil || context.Cluster != "minikube" || context.AuthInfo != "minikube" {
+				t.Errorf("Expected the minikube context to use the minikube cluster and user, got %+v", context)
+			}
+			if config.CurrentContext != test.current {
+				t.Errorf("Expected current context %s, got %s", test.current, config.CurrentContext)
+			}
+			if len(test.existing) > 0 && config.Contexts["la-croix"] == nil {
+				t.Errorf("Expected the other contexts to be kept, got %+v", config.Contexts)
+			}
+			if _, ok := config.Clusters["kubernetes"]; ok {
+				t.Errorf("Expected the admin kubeconfig's names not to be merged, got %+v", config.Clusters)
+			}
+		})
+	}
+}
+
 func TestEmptyConfig(t *testing.T) {
 	tmp := tempFile(t, []byte{})
 	defer os.Remove(tmp)
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -111,6 +112,40 @@ func RetryAfter(attempts int, callback func() error, d time.Duration) (err error
 	return m.ToError()
 }
 
+// RetryWithBackoff is RetryAfter, but the wait starts at initial and doubles
+// after each attempt, up to max. Each wait is jittered by up to half its
+// length either way, so that callers retrying against the same server don't
+// do so in lockstep.
+func RetryWithBackoff(attempts int, callback func() error, initial, max time.Duration) error {
+	m := MultiError{}
+	d := initial
+	for i := 0; i < attempts; i++ {
+		err := callback()
+		if err == nil {
+			return nil
+		}
+		m.Collect(err)
+		if _, ok := err.(*RetriableError); !ok {
+			return m.ToError()
+		}
+		if i < attempts-1 {
+			time.Sleep(jitter(d))
+		}
+		if d *= 2; d > max {
+			d = max
+		}
+	}
+	return m.ToError()
+}
+
+// jitter returns a random duration between d/2 and 3d/2.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
 func GetLocalkubeDownloadURL(versionOrURL string, filename string) (string, error) {
 	urlObj, err := url.Parse(versionOrURL)
 	if err != nil {
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/minikube/pkg/minikube/constants"
@@ -86,6 +87,39 @@ func TestRetryNotRetriableError(t *testing.T) {
 	}
 }
 
+func TestRetryWithBackoff(t *testing.T) {
+	f := errorGenerator(4, true)
+	if err := RetryWithBackoff(5, f, time.Millisecond, 2*time.Millisecond); err != nil {
+		t.Fatalf("Error should not have been raised by retry.")
+	}
+
+	f = errorGenerator(5, true)
+	if err := RetryWithBackoff(4, f, time.Millisecond, 2*time.Millisecond); err == nil {
+		t.Fatalf("Error should have been raised by retry.")
+	}
+
+	f = errorGenerator(4, false)
+	start := time.Now()
+	if err := RetryWithBackoff(5, f, time.Hour, time.Hour); err == nil {
+		t.Fatalf("Error should have been raised by retry.")
+	}
+	if time.Since(start) > time.Minute {
+		t.Fatalf("Non retriable errors should not have been retried.")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 100 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		if j := jitter(d); j < d/2 || j >= 3*d/2 {
+			t.Fatalf("Expected jitter of %s to be between %s and %s, got %s", d, d/2, 3*d/2, j)
+		}
+	}
+	if j := jitter(0); j != 0 {
+		t.Errorf("Expected no jitter of 0, got %s", j)
+	}
+}
+
 type getTestArgs struct {
 	input         string
 	expected      string